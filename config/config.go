@@ -0,0 +1,582 @@
+package config
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/utils/pwned"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type Config struct {
+	// listen address, ":8080" unless PORT is set
+	Addr string
+
+	// serve https when both are set
+	TLSCertFile     string
+	TLSKeyFile      string
+	TLSMinVersion   uint16
+	TLSCipherSuites []uint16
+
+	// required
+	DatabaseDSN string
+	// optional read replicas for listings and lookups
+	ReplicaDSNs []string
+
+	// apply pending migrations at startup, turn off when they're run
+	// separately
+	AutoMigrate bool
+
+	// "production" enables extra safety checks
+	Env string
+
+	// what happens to plaintext requests: "redirect", "reject" or "off".
+	// behind a tls terminating proxy it has to be in TrustedProxies
+	HTTPSMode string
+	// ips or cidrs of reverse proxies whose X-Forwarded-* headers are believed
+	TrustedProxies   []string
+	TrustedProxyNets []*net.IPNet
+
+	JWTSecret string
+	JWTTTL    time.Duration
+	// token lifetime for logins with remember set
+	RememberTTL time.Duration
+	BcryptCost  int
+
+	CORSDisabled bool
+	CORSOrigins  []string
+	// overrides CORSOrigins on login, register, logout and availability
+	PublicCORSOrigins []string
+
+	// limits on request header fields, their number and total size
+	MaxHeaderCount int
+	MaxHeaderBytes int
+
+	// largest login request body in bytes
+	LoginMaxBytes int64
+
+	// limits on json write bodies, size in bytes, nesting depth and
+	// members of a single object or array. 0 disables depth and members
+	JSONMaxBytes    int64
+	JSONMaxDepth    int
+	JSONMaxElements int
+
+	// request deadline, exports get their own. 0 disables them
+	RequestTimeout time.Duration
+	ExportTimeout  time.Duration
+
+	// requests allowed per window and client, 0 disables rate limiting
+	RateLimit  int
+	RateWindow time.Duration
+	// requests allowed per window and authenticated user, 0 disables it
+	UserRateLimit int
+	// client ips or sha256 hashes of api keys skipped by the rate limiter,
+	// hex encoded like the api_keys table stores them
+	RateLimitExempt []string
+
+	// users kept in memory for lookups by id, 0 disables the cache
+	UserCacheSize int
+	UserCacheTTL  time.Duration
+
+	// consecutive database failures that open the breaker, 0 disables it
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+
+	// lifecycle events are posted to every url, signed with the secret
+	WebhookURLs   []string
+	WebhookSecret string
+
+	// log every query, queries slower than the threshold are always logged
+	LogQueries         bool
+	SlowQueryThreshold time.Duration
+	// longest a single statement may run, 0 disables it
+	StatementTimeout time.Duration
+
+	// time to cancel a deletion request, and how often due users are purged
+	DeletionGrace time.Duration
+	PurgeInterval time.Duration
+
+	// how often expired revocations and sessions are dropped
+	TokenGCInterval time.Duration
+	// how often signing keys rotated by other instances are picked up
+	KeySyncInterval time.Duration
+
+	// interval of the event stream heartbeats
+	EventsHeartbeat time.Duration
+
+	// most rows of a csv user import
+	MaxImportRows int
+
+	// how long an email change can be confirmed
+	EmailChangeTTL time.Duration
+
+	// smtp server for confirmation mails, empty disables mail
+	SMTPAddr     string
+	SMTPFrom     string
+	SMTPUsername string
+	SMTPPassword string
+	// base of the links in mails, e.g. https://api.example.com
+	PublicURL string
+
+	// size cap of a user's preferences in bytes
+	MaxPreferencesSize int
+
+	// range api of the breached password check, empty disables it
+	PwnedURL string
+
+	// key of signed export urls, empty disables them
+	ExportURLSecret string
+
+	// bind tokens to the client's user agent and subnet
+	BindTokenFingerprint bool
+
+	// least time between two password changes of a user, 0 disables it
+	PasswordChangeCooldown time.Duration
+
+	// live tokens per user, older ones get revoked. 0 is unlimited
+	MaxSessions int
+
+	// previous passwords that can't be reused, 0 disables the check
+	PasswordHistory int
+
+	MaxPageSize      int
+	StrictPagination bool
+	// listing order without ?sort=, "-field" or "field:desc"
+	DefaultSort string
+
+	CheckUserStatus      bool
+	TokenCookie          bool
+	RequireVerifiedEmail bool
+}
+
+// insecure fallback, only good for development
+const defaultJWTSecret = "jwtToken"
+
+// read the config from env vars, falling back to defaults
+func Load() (*Config, error) {
+	var err error
+	cfg := &Config{}
+
+	cfg.Addr = ":" + getEnv("PORT", "8080")
+
+	cfg.TLSCertFile = os.Getenv("TLS_CERT_FILE")
+	cfg.TLSKeyFile = os.Getenv("TLS_KEY_FILE")
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		return nil, fmt.Errorf("config: TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	if err := loadTLS(cfg); err != nil {
+		return nil, err
+	}
+
+	cfg.DatabaseDSN = os.Getenv("DB_DSN")
+	if cfg.DatabaseDSN == "" {
+		return nil, fmt.Errorf("config: DB_DSN is required")
+	}
+	if dsns := os.Getenv("DB_REPLICA_DSNS"); dsns != "" {
+		cfg.ReplicaDSNs = strings.Split(dsns, ",")
+	}
+
+	if cfg.AutoMigrate, err = getBool("AUTO_MIGRATE", true); err != nil {
+		return nil, err
+	}
+
+	cfg.Env = getEnv("APP_ENV", "development")
+
+	// never run production with the secret everyone can read in the repo
+	cfg.JWTSecret = getEnv("JWT_SECRET", defaultJWTSecret)
+	if cfg.Env == "production" && cfg.JWTSecret == defaultJWTSecret {
+		return nil, fmt.Errorf("config: JWT_SECRET must be changed from the default in production")
+	}
+
+	cfg.HTTPSMode = getEnv("HTTPS_MODE", "off")
+	switch cfg.HTTPSMode {
+	case "redirect", "reject", "off":
+	default:
+		return nil, fmt.Errorf("config: HTTPS_MODE must be redirect, reject or off")
+	}
+
+	if proxies := os.Getenv("TRUSTED_PROXIES"); proxies != "" {
+		for _, p := range strings.Split(proxies, ",") {
+			p = strings.TrimSpace(p)
+			n, err := parseNet(p)
+			if err != nil {
+				return nil, fmt.Errorf("config: TRUSTED_PROXIES: %w", err)
+			}
+			cfg.TrustedProxies = append(cfg.TrustedProxies, p)
+			cfg.TrustedProxyNets = append(cfg.TrustedProxyNets, n)
+		}
+	}
+
+	if cfg.JWTTTL, err = getDuration("JWT_TTL", time.Hour*12); err != nil {
+		return nil, err
+	}
+	if cfg.JWTTTL <= 0 {
+		return nil, fmt.Errorf("config: JWT_TTL must be positive")
+	}
+
+	if cfg.RememberTTL, err = getDuration("REMEMBER_TTL", time.Hour*24*30); err != nil {
+		return nil, err
+	}
+	if cfg.RememberTTL < cfg.JWTTTL {
+		return nil, fmt.Errorf("config: REMEMBER_TTL must not be shorter than JWT_TTL")
+	}
+
+	if cfg.BcryptCost, err = getInt("BCRYPT_COST", bcrypt.DefaultCost); err != nil {
+		return nil, err
+	}
+	if cfg.BcryptCost < bcrypt.MinCost || cfg.BcryptCost > bcrypt.MaxCost {
+		return nil, fmt.Errorf("config: BCRYPT_COST must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+	}
+
+	if cfg.CORSDisabled, err = getBool("CORS_DISABLED", false); err != nil {
+		return nil, err
+	}
+	cfg.CORSOrigins = strings.Split(getEnv("CORS_ORIGINS", "*"), ",")
+	if origins := os.Getenv("PUBLIC_CORS_ORIGINS"); origins != "" {
+		cfg.PublicCORSOrigins = strings.Split(origins, ",")
+	}
+
+	if cfg.MaxHeaderCount, err = getInt("MAX_HEADER_COUNT", 100); err != nil {
+		return nil, err
+	}
+	if cfg.MaxHeaderBytes, err = getInt("MAX_HEADER_BYTES", 16<<10); err != nil {
+		return nil, err
+	}
+	if cfg.MaxHeaderCount <= 0 || cfg.MaxHeaderBytes <= 0 {
+		return nil, fmt.Errorf("config: MAX_HEADER_COUNT and MAX_HEADER_BYTES must be positive")
+	}
+
+	loginMax, err := getInt("LOGIN_MAX_BYTES", 4<<10)
+	if err != nil {
+		return nil, err
+	}
+	if loginMax <= 0 {
+		return nil, fmt.Errorf("config: LOGIN_MAX_BYTES must be positive")
+	}
+	cfg.LoginMaxBytes = int64(loginMax)
+
+	if cfg.JSONMaxDepth, err = getInt("JSON_MAX_DEPTH", 32); err != nil {
+		return nil, err
+	}
+	if cfg.JSONMaxElements, err = getInt("JSON_MAX_ELEMENTS", 1000); err != nil {
+		return nil, err
+	}
+	if cfg.JSONMaxDepth < 0 || cfg.JSONMaxElements < 0 {
+		return nil, fmt.Errorf("config: JSON_MAX_DEPTH and JSON_MAX_ELEMENTS must not be negative")
+	}
+	jsonMax, err := getInt("JSON_MAX_BYTES", 1<<20)
+	if err != nil {
+		return nil, err
+	}
+	if jsonMax <= 0 {
+		return nil, fmt.Errorf("config: JSON_MAX_BYTES must be positive")
+	}
+	cfg.JSONMaxBytes = int64(jsonMax)
+
+	if cfg.RequestTimeout, err = getDuration("REQUEST_TIMEOUT", time.Second*30); err != nil {
+		return nil, err
+	}
+	if cfg.ExportTimeout, err = getDuration("EXPORT_TIMEOUT", time.Minute*5); err != nil {
+		return nil, err
+	}
+	if cfg.RequestTimeout < 0 || cfg.ExportTimeout < 0 {
+		return nil, fmt.Errorf("config: REQUEST_TIMEOUT and EXPORT_TIMEOUT must not be negative")
+	}
+
+	if cfg.RateLimit, err = getInt("RATE_LIMIT", 0); err != nil {
+		return nil, err
+	}
+	if cfg.RateLimit < 0 {
+		return nil, fmt.Errorf("config: RATE_LIMIT must not be negative")
+	}
+	if cfg.RateWindow, err = getDuration("RATE_WINDOW", time.Minute); err != nil {
+		return nil, err
+	}
+	if cfg.RateWindow <= 0 {
+		return nil, fmt.Errorf("config: RATE_WINDOW must be positive")
+	}
+	if cfg.UserRateLimit, err = getInt("USER_RATE_LIMIT", 0); err != nil {
+		return nil, err
+	}
+	if cfg.UserRateLimit < 0 {
+		return nil, fmt.Errorf("config: USER_RATE_LIMIT must not be negative")
+	}
+	for _, e := range strings.Split(os.Getenv("RATE_LIMIT_EXEMPT"), ",") {
+		if e = strings.TrimSpace(e); e == "" {
+			continue
+		}
+		// a plain api key would never match, only its hash is compared
+		if net.ParseIP(e) == nil && !isKeyHash(e) {
+			return nil, fmt.Errorf("config: RATE_LIMIT_EXEMPT: %q is neither an ip nor an api key hash", e)
+		}
+		cfg.RateLimitExempt = append(cfg.RateLimitExempt, strings.ToLower(e))
+	}
+
+	if cfg.UserCacheSize, err = getInt("USER_CACHE_SIZE", 0); err != nil {
+		return nil, err
+	}
+	if cfg.UserCacheSize < 0 {
+		return nil, fmt.Errorf("config: USER_CACHE_SIZE must not be negative")
+	}
+	if cfg.UserCacheTTL, err = getDuration("USER_CACHE_TTL", time.Second*30); err != nil {
+		return nil, err
+	}
+	if cfg.UserCacheTTL <= 0 {
+		return nil, fmt.Errorf("config: USER_CACHE_TTL must be positive")
+	}
+
+	if cfg.BreakerThreshold, err = getInt("BREAKER_THRESHOLD", 5); err != nil {
+		return nil, err
+	}
+	if cfg.BreakerThreshold < 0 {
+		return nil, fmt.Errorf("config: BREAKER_THRESHOLD must not be negative")
+	}
+	if cfg.BreakerCooldown, err = getDuration("BREAKER_COOLDOWN", time.Second*30); err != nil {
+		return nil, err
+	}
+	if cfg.BreakerCooldown <= 0 {
+		return nil, fmt.Errorf("config: BREAKER_COOLDOWN must be positive")
+	}
+
+	if urls := os.Getenv("WEBHOOK_URLS"); urls != "" {
+		cfg.WebhookURLs = strings.Split(urls, ",")
+		cfg.WebhookSecret = os.Getenv("WEBHOOK_SECRET")
+		if cfg.WebhookSecret == "" {
+			return nil, fmt.Errorf("config: WEBHOOK_SECRET is required with WEBHOOK_URLS")
+		}
+	}
+
+	if cfg.LogQueries, err = getBool("LOG_QUERIES", false); err != nil {
+		return nil, err
+	}
+	if cfg.StatementTimeout, err = getDuration("STATEMENT_TIMEOUT", time.Second*10); err != nil {
+		return nil, err
+	}
+	if cfg.StatementTimeout < 0 {
+		return nil, fmt.Errorf("config: STATEMENT_TIMEOUT must not be negative")
+	}
+	if cfg.SlowQueryThreshold, err = getDuration("SLOW_QUERY_THRESHOLD", time.Millisecond*200); err != nil {
+		return nil, err
+	}
+	if cfg.SlowQueryThreshold < 0 {
+		return nil, fmt.Errorf("config: SLOW_QUERY_THRESHOLD must not be negative")
+	}
+
+	if cfg.EventsHeartbeat, err = getDuration("EVENTS_HEARTBEAT", time.Second*15); err != nil {
+		return nil, err
+	}
+	if cfg.EventsHeartbeat <= 0 {
+		return nil, fmt.Errorf("config: EVENTS_HEARTBEAT must be positive")
+	}
+
+	if cfg.MaxImportRows, err = getInt("IMPORT_MAX_ROWS", 1000); err != nil {
+		return nil, err
+	}
+	if cfg.MaxImportRows <= 0 {
+		return nil, fmt.Errorf("config: IMPORT_MAX_ROWS must be positive")
+	}
+
+	if cfg.EmailChangeTTL, err = getDuration("EMAIL_CHANGE_TTL", time.Hour*24); err != nil {
+		return nil, err
+	}
+	if cfg.EmailChangeTTL <= 0 {
+		return nil, fmt.Errorf("config: EMAIL_CHANGE_TTL must be positive")
+	}
+
+	if cfg.SMTPAddr = os.Getenv("SMTP_ADDR"); cfg.SMTPAddr != "" {
+		cfg.SMTPFrom = os.Getenv("SMTP_FROM")
+		cfg.SMTPUsername = os.Getenv("SMTP_USERNAME")
+		cfg.SMTPPassword = os.Getenv("SMTP_PASSWORD")
+		cfg.PublicURL = strings.TrimSuffix(os.Getenv("PUBLIC_URL"), "/")
+		if cfg.SMTPFrom == "" || cfg.PublicURL == "" {
+			return nil, fmt.Errorf("config: SMTP_FROM and PUBLIC_URL are required with SMTP_ADDR")
+		}
+	}
+
+	if cfg.MaxPreferencesSize, err = getInt("PREFERENCES_MAX_SIZE", 4<<10); err != nil {
+		return nil, err
+	}
+	if cfg.MaxPreferencesSize <= 0 {
+		return nil, fmt.Errorf("config: PREFERENCES_MAX_SIZE must be positive")
+	}
+
+	if cfg.DeletionGrace, err = getDuration("DELETION_GRACE", time.Hour*24*30); err != nil {
+		return nil, err
+	}
+	if cfg.DeletionGrace < 0 {
+		return nil, fmt.Errorf("config: DELETION_GRACE must not be negative")
+	}
+	if cfg.PurgeInterval, err = getDuration("PURGE_INTERVAL", time.Hour); err != nil {
+		return nil, err
+	}
+	if cfg.PurgeInterval <= 0 {
+		return nil, fmt.Errorf("config: PURGE_INTERVAL must be positive")
+	}
+	if cfg.TokenGCInterval, err = getDuration("TOKEN_GC_INTERVAL", time.Hour); err != nil {
+		return nil, err
+	}
+	if cfg.TokenGCInterval <= 0 {
+		return nil, fmt.Errorf("config: TOKEN_GC_INTERVAL must be positive")
+	}
+	if cfg.KeySyncInterval, err = getDuration("KEY_SYNC_INTERVAL", time.Minute); err != nil {
+		return nil, err
+	}
+	if cfg.KeySyncInterval <= 0 {
+		return nil, fmt.Errorf("config: KEY_SYNC_INTERVAL must be positive")
+	}
+
+	cfg.ExportURLSecret = os.Getenv("EXPORT_URL_SECRET")
+
+	// PWNED_CHECK=true uses the public api, PWNED_URL points elsewhere
+	pwnedCheck, err := getBool("PWNED_CHECK", false)
+	if err != nil {
+		return nil, err
+	}
+	if pwnedCheck {
+		cfg.PwnedURL = getEnv("PWNED_URL", pwned.DefaultURL)
+	}
+
+	if cfg.PasswordChangeCooldown, err = getDuration("PASSWORD_CHANGE_COOLDOWN", 0); err != nil {
+		return nil, err
+	}
+	if cfg.PasswordChangeCooldown < 0 {
+		return nil, fmt.Errorf("config: PASSWORD_CHANGE_COOLDOWN must not be negative")
+	}
+
+	if cfg.BindTokenFingerprint, err = getBool("BIND_TOKEN_FINGERPRINT", false); err != nil {
+		return nil, err
+	}
+
+	if cfg.MaxSessions, err = getInt("MAX_SESSIONS", 0); err != nil {
+		return nil, err
+	}
+	if cfg.MaxSessions < 0 {
+		return nil, fmt.Errorf("config: MAX_SESSIONS must not be negative")
+	}
+
+	if cfg.PasswordHistory, err = getInt("PASSWORD_HISTORY", 5); err != nil {
+		return nil, err
+	}
+	if cfg.PasswordHistory < 0 {
+		return nil, fmt.Errorf("config: PASSWORD_HISTORY must not be negative")
+	}
+
+	cfg.DefaultSort = getEnv("DEFAULT_SORT", "id")
+	if field, dir, ok := strings.Cut(cfg.DefaultSort, ":"); ok {
+		switch dir {
+		case "asc":
+			cfg.DefaultSort = field
+		case "desc":
+			cfg.DefaultSort = "-" + field
+		default:
+			return nil, fmt.Errorf("config: DEFAULT_SORT direction must be asc or desc")
+		}
+	}
+
+	if cfg.MaxPageSize, err = getInt("MAX_PAGE_SIZE", 100); err != nil {
+		return nil, err
+	}
+	if cfg.MaxPageSize <= 0 {
+		return nil, fmt.Errorf("config: MAX_PAGE_SIZE must be positive")
+	}
+	if cfg.StrictPagination, err = getBool("STRICT_PAGINATION", false); err != nil {
+		return nil, err
+	}
+
+	if cfg.CheckUserStatus, err = getBool("CHECK_USER_STATUS", false); err != nil {
+		return nil, err
+	}
+	if cfg.TokenCookie, err = getBool("TOKEN_COOKIE", false); err != nil {
+		return nil, err
+	}
+	if cfg.RequireVerifiedEmail, err = getBool("REQUIRE_VERIFIED_EMAIL", false); err != nil {
+		return nil, err
+	}
+	// new accounts could never get verified
+	if cfg.RequireVerifiedEmail && cfg.SMTPAddr == "" {
+		return nil, fmt.Errorf("config: REQUIRE_VERIFIED_EMAIL needs SMTP_ADDR")
+	}
+
+	return cfg, nil
+}
+
+// a cidr, or a single ip as a network of its own
+// a hex sha256, the form api keys are stored and compared in
+func isKeyHash(s string) bool {
+	_, err := hex.DecodeString(s)
+	return err == nil && len(s) == 64
+}
+
+func parseNet(s string) (*net.IPNet, error) {
+	if strings.Contains(s, "/") {
+		_, n, err := net.ParseCIDR(s)
+		return n, err
+	}
+
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid ip %q", s)
+	}
+	bits := 128
+	if ip.To4() != nil {
+		ip, bits = ip.To4(), 32
+	}
+
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+}
+
+func getEnv(key, def string) string {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+
+	return v
+}
+
+func getInt(key string, def int) (int, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return def, nil
+	}
+
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("config: %s must be an integer", key)
+	}
+
+	return i, nil
+}
+
+func getBool(key string, def bool) (bool, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return def, nil
+	}
+
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("config: %s must be a boolean", key)
+	}
+
+	return b, nil
+}
+
+func getDuration(key string, def time.Duration) (time.Duration, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return def, nil
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("config: %s must be a duration", key)
+	}
+
+	return d, nil
+}
@@ -0,0 +1,260 @@
+package config
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Load with env set on top of a valid DB_DSN
+func loadWith(t *testing.T, env map[string]string) (*Config, error) {
+	t.Helper()
+
+	t.Setenv("DB_DSN", "user:pass@/db")
+	for k, v := range env {
+		t.Setenv(k, v)
+	}
+
+	return Load()
+}
+
+func TestLoadDefaults(t *testing.T) {
+	cfg, err := loadWith(t, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Addr != ":8080" {
+		t.Errorf("Addr = %q, want :8080", cfg.Addr)
+	}
+	if cfg.JWTSecret != defaultJWTSecret || cfg.JWTTTL != time.Hour*12 {
+		t.Errorf("jwt secret %q ttl %v, want the defaults", cfg.JWTSecret, cfg.JWTTTL)
+	}
+	if cfg.BcryptCost != bcrypt.DefaultCost {
+		t.Errorf("BcryptCost = %d, want %d", cfg.BcryptCost, bcrypt.DefaultCost)
+	}
+	if len(cfg.CORSOrigins) != 1 || cfg.CORSOrigins[0] != "*" {
+		t.Errorf("CORSOrigins = %v, want [*]", cfg.CORSOrigins)
+	}
+	if cfg.RememberTTL != time.Hour*24*30 {
+		t.Errorf("RememberTTL = %v, want 30 days", cfg.RememberTTL)
+	}
+	if cfg.RateLimit != 0 || !cfg.AutoMigrate {
+		t.Errorf("RateLimit %d AutoMigrate %v, want 0 and true", cfg.RateLimit, cfg.AutoMigrate)
+	}
+}
+
+func TestLoadReadsEnv(t *testing.T) {
+	cfg, err := loadWith(t, map[string]string{
+		"PORT":                "9000",
+		"JWT_SECRET":          "s3cret",
+		"JWT_TTL":             "1h",
+		"BCRYPT_COST":         "12",
+		"CORS_ORIGINS":        "https://a.example.com,https://b.example.com",
+		"RATE_LIMIT":          "60",
+		"PUBLIC_CORS_ORIGINS": "https://login.example.com",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Addr != ":9000" || cfg.JWTSecret != "s3cret" || cfg.JWTTTL != time.Hour || cfg.BcryptCost != 12 || cfg.RateLimit != 60 {
+		t.Errorf("config = %+v", cfg)
+	}
+	if len(cfg.CORSOrigins) != 2 {
+		t.Errorf("CORSOrigins = %v, want both origins", cfg.CORSOrigins)
+	}
+	if len(cfg.PublicCORSOrigins) != 1 || cfg.PublicCORSOrigins[0] != "https://login.example.com" {
+		t.Errorf("PublicCORSOrigins = %v, want [https://login.example.com]", cfg.PublicCORSOrigins)
+	}
+}
+
+func TestLoadFailsFast(t *testing.T) {
+	for name, env := range map[string]map[string]string{
+		"DB_DSN":               {"DB_DSN": ""},
+		"BCRYPT_COST":          {"BCRYPT_COST": "many"},
+		"JWT_TTL":              {"JWT_TTL": "-1h"},
+		"REMEMBER_TTL":         {"REMEMBER_TTL": "1h"},
+		"AUTO_MIGRATE":         {"AUTO_MIGRATE": "sometimes"},
+		"RATE_LIMIT":           {"RATE_LIMIT": "-5"},
+		"USER_RATE_LIMIT":      {"USER_RATE_LIMIT": "-5"},
+		"MAX_SESSIONS":         {"MAX_SESSIONS": "-1"},
+		"PREFERENCES_MAX_SIZE": {"PREFERENCES_MAX_SIZE": "0"},
+		"TOKEN_GC_INTERVAL":    {"TOKEN_GC_INTERVAL": "0s"},
+		"HTTPS_MODE":           {"HTTPS_MODE": "always"},
+		"TRUSTED_PROXIES":      {"TRUSTED_PROXIES": "10.0.0.0/8,proxy.internal"},
+		// a plain key instead of its hash
+		"RATE_LIMIT_EXEMPT": {"RATE_LIMIT_EXEMPT": "10.0.0.9,uk_monitoring"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := loadWith(t, env); err == nil || !strings.Contains(err.Error(), name) {
+				t.Errorf("err = %v, want one naming %s", err, name)
+			}
+		})
+	}
+}
+
+func TestRequireVerifiedEmailNeedsMailer(t *testing.T) {
+	t.Setenv("DB_DSN", "user:pass@/db")
+	t.Setenv("REQUIRE_VERIFIED_EMAIL", "true")
+
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "SMTP_ADDR") {
+		t.Fatalf("err = %v, want one asking for SMTP_ADDR", err)
+	}
+
+	t.Setenv("SMTP_ADDR", "localhost:25")
+	t.Setenv("SMTP_FROM", "noreply@example.com")
+	t.Setenv("PUBLIC_URL", "https://api.example.com")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.RequireVerifiedEmail {
+		t.Error("RequireVerifiedEmail is off")
+	}
+}
+
+func TestLoadBreakerThresholds(t *testing.T) {
+	cfg, err := loadWith(t, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.BreakerThreshold != 5 || cfg.BreakerCooldown != time.Second*30 {
+		t.Errorf("threshold %d cooldown %v, want 5 and 30s", cfg.BreakerThreshold, cfg.BreakerCooldown)
+	}
+
+	cfg, err = loadWith(t, map[string]string{"BREAKER_THRESHOLD": "2", "BREAKER_COOLDOWN": "1m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.BreakerThreshold != 2 || cfg.BreakerCooldown != time.Minute {
+		t.Errorf("threshold %d cooldown %v, want 2 and 1m", cfg.BreakerThreshold, cfg.BreakerCooldown)
+	}
+
+	for name, value := range map[string]string{"BREAKER_THRESHOLD": "-1", "BREAKER_COOLDOWN": "0s"} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := loadWith(t, map[string]string{name: value}); err == nil || !strings.Contains(err.Error(), name) {
+				t.Errorf("err = %v, want one naming %s", err, name)
+			}
+		})
+	}
+}
+
+func TestProductionRefusesTheDefaultSecret(t *testing.T) {
+	if _, err := loadWith(t, map[string]string{"APP_ENV": "production"}); err == nil || !strings.Contains(err.Error(), "JWT_SECRET") {
+		t.Fatalf("err = %v, want one naming JWT_SECRET", err)
+	}
+	if _, err := loadWith(t, map[string]string{"APP_ENV": "production", "JWT_SECRET": defaultJWTSecret}); err == nil {
+		t.Fatal("the default secret set explicitly was accepted")
+	}
+
+	cfg, err := loadWith(t, map[string]string{"APP_ENV": "production", "JWT_SECRET": "a-real-secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Env != "production" || cfg.JWTSecret != "a-real-secret" {
+		t.Errorf("env %q secret %q", cfg.Env, cfg.JWTSecret)
+	}
+}
+
+func TestDevelopmentAllowsTheDefaultSecret(t *testing.T) {
+	cfg, err := loadWith(t, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Env != "development" || cfg.JWTSecret != defaultJWTSecret {
+		t.Errorf("env %q secret %q, want development with the default", cfg.Env, cfg.JWTSecret)
+	}
+}
+
+func TestLoadHeaderLimits(t *testing.T) {
+	cfg, err := loadWith(t, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.MaxHeaderCount != 100 || cfg.MaxHeaderBytes != 16<<10 {
+		t.Errorf("count %d bytes %d, want 100 and 16KiB", cfg.MaxHeaderCount, cfg.MaxHeaderBytes)
+	}
+
+	if _, err := loadWith(t, map[string]string{"MAX_HEADER_BYTES": "0"}); err == nil {
+		t.Error("a zero header limit was accepted")
+	}
+}
+
+func TestLoadDefaultSort(t *testing.T) {
+	for value, want := range map[string]string{"": "id", "created_at:desc": "-created_at", "email:asc": "email", "-role": "-role"} {
+		t.Run(value, func(t *testing.T) {
+			cfg, err := loadWith(t, map[string]string{"DEFAULT_SORT": value})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.DefaultSort != want {
+				t.Errorf("DefaultSort = %q, want %q", cfg.DefaultSort, want)
+			}
+		})
+	}
+
+	if _, err := loadWith(t, map[string]string{"DEFAULT_SORT": "email:up"}); err == nil || !strings.Contains(err.Error(), "DEFAULT_SORT") {
+		t.Errorf("err = %v, want one naming DEFAULT_SORT", err)
+	}
+}
+
+func TestLoadTrustedProxies(t *testing.T) {
+	cfg, err := loadWith(t, map[string]string{
+		"HTTPS_MODE":      "redirect",
+		"TRUSTED_PROXIES": "10.0.0.0/8, 192.0.2.1,2001:db8::1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.HTTPSMode != "redirect" {
+		t.Errorf("HTTPSMode = %q, want redirect", cfg.HTTPSMode)
+	}
+	if len(cfg.TrustedProxies) != 3 || cfg.TrustedProxies[1] != "192.0.2.1" {
+		t.Errorf("TrustedProxies = %q, want the three trimmed entries", cfg.TrustedProxies)
+	}
+
+	for ip, want := range map[string]bool{
+		"10.200.0.1":  true,
+		"192.0.2.1":   true,
+		"192.0.2.2":   false,
+		"2001:db8::1": true,
+		"2001:db8::2": false,
+	} {
+		trusted := false
+		for _, n := range cfg.TrustedProxyNets {
+			if n.Contains(net.ParseIP(ip)) {
+				trusted = true
+			}
+		}
+		if trusted != want {
+			t.Errorf("%s trusted = %v, want %v", ip, trusted, want)
+		}
+	}
+}
+
+func TestLoadRateLimitExempt(t *testing.T) {
+	keyHash := strings.Repeat("ab", 32)
+	cfg, err := loadWith(t, map[string]string{
+		"RATE_LIMIT_EXEMPT": " 10.0.0.9 ,, " + strings.ToUpper(keyHash) + ",2001:db8::1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"10.0.0.9", keyHash, "2001:db8::1"}
+	if len(cfg.RateLimitExempt) != len(want) {
+		t.Fatalf("RateLimitExempt = %q, want %q", cfg.RateLimitExempt, want)
+	}
+	for i := range want {
+		if cfg.RateLimitExempt[i] != want[i] {
+			t.Errorf("RateLimitExempt = %q, want %q", cfg.RateLimitExempt, want)
+			break
+		}
+	}
+}
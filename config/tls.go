@@ -0,0 +1,53 @@
+package config
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strings"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// parse TLS_MIN_VERSION and TLS_CIPHER_SUITES, only versions from 1.2 and
+// suites go considers secure are accepted
+func loadTLS(cfg *Config) error {
+	v := getEnv("TLS_MIN_VERSION", "1.2")
+	min, ok := tlsVersions[v]
+	if !ok {
+		return fmt.Errorf("config: TLS_MIN_VERSION must be 1.2 or 1.3, got %q", v)
+	}
+	cfg.TLSMinVersion = min
+
+	names := getEnv("TLS_CIPHER_SUITES", "")
+	if names == "" {
+		return nil
+	}
+
+	secure := map[string]uint16{}
+	for _, s := range tls.CipherSuites() {
+		secure[s.Name] = s.ID
+	}
+
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		id, ok := secure[name]
+		if !ok {
+			return fmt.Errorf("config: TLS_CIPHER_SUITES: %q is unknown or insecure", name)
+		}
+
+		cfg.TLSCipherSuites = append(cfg.TLSCipherSuites, id)
+	}
+
+	return nil
+}
+
+// tls settings for the server, cipher suites only apply up to TLS 1.2
+func (cfg *Config) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:   cfg.TLSMinVersion,
+		CipherSuites: cfg.TLSCipherSuites,
+	}
+}
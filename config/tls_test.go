@@ -0,0 +1,49 @@
+package config
+
+import (
+	"crypto/tls"
+	"strings"
+	"testing"
+)
+
+func TestTLSConfigMinVersion(t *testing.T) {
+	cfg, err := loadWith(t, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := cfg.TLSConfig().MinVersion; v != tls.VersionTLS12 {
+		t.Errorf("default MinVersion = %x, want TLS 1.2", v)
+	}
+
+	cfg, err = loadWith(t, map[string]string{
+		"TLS_MIN_VERSION":   "1.3",
+		"TLS_CIPHER_SUITES": "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256, TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tlsCfg := cfg.TLSConfig()
+	if tlsCfg.MinVersion != tls.VersionTLS13 {
+		t.Errorf("MinVersion = %x, want TLS 1.3", tlsCfg.MinVersion)
+	}
+	want := []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256, tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384}
+	if len(tlsCfg.CipherSuites) != 2 || tlsCfg.CipherSuites[0] != want[0] || tlsCfg.CipherSuites[1] != want[1] {
+		t.Errorf("CipherSuites = %x, want %x", tlsCfg.CipherSuites, want)
+	}
+}
+
+func TestWeakTLSIsRejected(t *testing.T) {
+	for name, env := range map[string]map[string]string{
+		"old version":      {"TLS_MIN_VERSION": "1.0"},
+		"insecure suite":   {"TLS_CIPHER_SUITES": "TLS_RSA_WITH_RC4_128_SHA"},
+		"unknown suite":    {"TLS_CIPHER_SUITES": "TLS_MADE_UP"},
+		"cert without key": {"TLS_CERT_FILE": "cert.pem"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := loadWith(t, env); err == nil || !strings.Contains(err.Error(), "TLS_") {
+				t.Errorf("err = %v, want a TLS config error", err)
+			}
+		})
+	}
+}
@@ -1,14 +1,75 @@
 package migration
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
+	"log"
 
 	"github.com/ariopri/Let-It-Be/tree/main/backend/database/seeder"
 	_ "github.com/go-sql-driver/mysql"
 )
 
-func Migrate(db *sql.DB) {
-	_, err := db.Exec(`
+type step struct {
+	name string
+	up   func(db *sql.DB) error
+}
+
+func exec(query string) func(db *sql.DB) error {
+	return func(db *sql.DB) error {
+		_, err := db.Exec(query)
+		return err
+	}
+}
+
+// add column to table unless it's there, definition may end in AFTER so
+// SELECT * keeps the column order scanUser expects
+func addColumn(table, column, definition string) func(db *sql.DB) error {
+	return func(db *sql.DB) error {
+		_, err := ensureColumn(db, table, column, definition)
+		return err
+	}
+}
+
+// like addColumn, reports if the column was added
+func ensureColumn(db *sql.DB, table, column, definition string) (bool, error) {
+	var n int
+	err := db.QueryRow(`SELECT COUNT(*) FROM information_schema.COLUMNS
+		WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND COLUMN_NAME = ?`, table, column).Scan(&n)
+	if err != nil || n > 0 {
+		return false, err
+	}
+
+	_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+
+	return err == nil, err
+}
+
+// run query, an ALTER TABLE ... ADD INDEX or CONSTRAINT, unless table has
+// an index called name
+func addIndex(table, name, query string) func(db *sql.DB) error {
+	return func(db *sql.DB) error {
+		var n int
+		err := db.QueryRow(`SELECT COUNT(*) FROM information_schema.STATISTICS
+			WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND INDEX_NAME = ?`, table, name).Scan(&n)
+		if err != nil || n > 0 {
+			return err
+		}
+
+		_, err = db.Exec(query)
+		return err
+	}
+}
+
+// applied in order, version n means the first n steps ran. only ever
+// append, applied steps must not change.
+//
+// every step must be safe to run on a database that has what it adds:
+// databases created before versions were tracked start at 0, and a
+// CREATE TABLE IF NOT EXISTS leaves their tables as they are. columns and
+// indexes added to old tables get their own guarded step
+var steps = []step{
+	{"create users", exec(`
 			CREATE TABLE IF NOT EXISTS users (
 				id INTEGER PRIMARY KEY AUTO_INCREMENT,
 				firstname VARCHAR(255) NOT NULL,
@@ -16,10 +77,203 @@ func Migrate(db *sql.DB) {
 				email VARCHAR(255) NOT NULL,
 				password VARCHAR(255) NOT NULL,
 				role VARCHAR(255) CHECK (role IN ('admin', 'user')) DEFAULT 'user',
+				active BOOLEAN NOT NULL DEFAULT TRUE,
+				email_verified BOOLEAN NOT NULL DEFAULT FALSE,
+				token_version INTEGER NOT NULL DEFAULT 0,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+				CONSTRAINT users_email_unique UNIQUE (email)
+			);`)},
+	{"create audit_logs", exec(`
+			CREATE TABLE IF NOT EXISTS audit_logs (
+				id INTEGER PRIMARY KEY AUTO_INCREMENT,
+				actor VARCHAR(255) NOT NULL,
+				action VARCHAR(255) NOT NULL,
+				target VARCHAR(255) NOT NULL,
 				created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			);`)},
+	{"create profiles", exec(`
+			CREATE TABLE IF NOT EXISTS profiles (
+				user_id INTEGER PRIMARY KEY,
+				display_name VARCHAR(255) NOT NULL DEFAULT '',
+				bio TEXT NOT NULL,
+				avatar_url VARCHAR(255) NOT NULL DEFAULT '',
+				locale VARCHAR(35) NOT NULL DEFAULT '',
+				updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+				FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+			);`)},
+	{"create password_history", exec(`
+			CREATE TABLE IF NOT EXISTS password_history (
+				id INTEGER PRIMARY KEY AUTO_INCREMENT,
+				user_id INTEGER NOT NULL,
+				password VARCHAR(255) NOT NULL,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+			);`)},
+	{"create deletion_requests", exec(`
+			CREATE TABLE IF NOT EXISTS deletion_requests (
+				user_id INTEGER PRIMARY KEY,
+				purge_at DATETIME NOT NULL,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+			);`)},
+	{"create password_policy", exec(`
+			CREATE TABLE IF NOT EXISTS password_policy (
+				id INTEGER PRIMARY KEY,
+				min_length INTEGER NOT NULL DEFAULT 8,
+				require_upper BOOLEAN NOT NULL DEFAULT FALSE,
+				require_lower BOOLEAN NOT NULL DEFAULT FALSE,
+				require_digit BOOLEAN NOT NULL DEFAULT FALSE,
+				require_symbol BOOLEAN NOT NULL DEFAULT FALSE,
+				updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+			);`)},
+	{"create tags", exec(`
+			CREATE TABLE IF NOT EXISTS tags (
+				id INTEGER PRIMARY KEY AUTO_INCREMENT,
+				name VARCHAR(64) NOT NULL,
+				CONSTRAINT tags_name_unique UNIQUE (name)
+			);`)},
+	{"create user_tags", exec(`
+			CREATE TABLE IF NOT EXISTS user_tags (
+				user_id INTEGER NOT NULL,
+				tag_id INTEGER NOT NULL,
+				PRIMARY KEY (user_id, tag_id),
+				FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+				FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE
+			);`)},
+	{"create role_history", exec(`
+			CREATE TABLE IF NOT EXISTS role_history (
+				id INTEGER PRIMARY KEY AUTO_INCREMENT,
+				user_id INTEGER NOT NULL,
+				old_role VARCHAR(255) NOT NULL,
+				new_role VARCHAR(255) NOT NULL,
+				changed_by VARCHAR(255) NOT NULL,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+			);`)},
+	{"create user_preferences", exec(`
+			CREATE TABLE IF NOT EXISTS user_preferences (
+				user_id INTEGER PRIMARY KEY,
+				data TEXT NOT NULL,
+				updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+				FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+			);`)},
+	{"create email_changes", exec(`
+			CREATE TABLE IF NOT EXISTS email_changes (
+				user_id INTEGER PRIMARY KEY,
+				new_email VARCHAR(255) NOT NULL,
+				token_hash CHAR(64) NOT NULL,
+				expires_at DATETIME NOT NULL,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				CONSTRAINT email_changes_token_unique UNIQUE (token_hash),
+				FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+			);`)},
+	{"seed users", func(db *sql.DB) error {
+		seeder.Seed(db)
+		return nil
+	}},
+	{"index audit_logs", func(db *sql.DB) error {
+		for _, idx := range []struct{ name, columns string }{
+			{"audit_logs_action_created", "action, created_at"},
+			{"audit_logs_actor_created", "actor, created_at"},
+			{"audit_logs_created", "created_at"},
+		} {
+			query := fmt.Sprintf("CREATE INDEX %s ON audit_logs (%s)", idx.name, idx.columns)
+			if err := addIndex("audit_logs", idx.name, query)(db); err != nil {
+				return err
+			}
+		}
+		return nil
+	}},
+	{"create password_changes", exec(`
+			CREATE TABLE IF NOT EXISTS password_changes (
+				user_id INTEGER PRIMARY KEY,
+				changed_at DATETIME NOT NULL,
+				FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+			);`)},
+	{"create api_keys", exec(`
+			CREATE TABLE IF NOT EXISTS api_keys (
+				user_id INTEGER PRIMARY KEY,
+				key_hash CHAR(64) NOT NULL,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				CONSTRAINT api_keys_hash_unique UNIQUE (key_hash),
+				FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+			);`)},
+	// the users columns added since the table was first created
+	{"add users.active", addColumn("users", "active", "BOOLEAN NOT NULL DEFAULT TRUE AFTER role")},
+	{"add users.email_verified", func(db *sql.DB) error {
+		added, err := ensureColumn(db, "users", "email_verified", "BOOLEAN NOT NULL DEFAULT FALSE AFTER active")
+		if err != nil || !added {
+			return err
+		}
+		// accounts from before verification existed would be locked out
+		_, err = db.Exec(`UPDATE users SET email_verified = TRUE`)
+		return err
+	}},
+	{"add users.token_version", addColumn("users", "token_version", "INTEGER NOT NULL DEFAULT 0 AFTER email_verified")},
+	{"add users.updated_at", addColumn("users", "updated_at", "DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP AFTER created_at")},
+	{"unique users.email", func(db *sql.DB) error {
+		// the seeder used to add its user on every start
+		_, err := db.Exec(`DELETE u FROM users u JOIN users first ON first.email = u.email AND first.id < u.id
+			WHERE u.email = ?`, seeder.Email)
+		if err != nil {
+			return err
+		}
+		return addIndex("users", "users_email_unique", `ALTER TABLE users ADD CONSTRAINT users_email_unique UNIQUE (email)`)(db)
+	}},
+	{"verify seeded user", func(db *sql.DB) error {
+		_, err := db.Exec(`UPDATE users SET email_verified = TRUE WHERE email = ?`, seeder.Email)
+		return err
+	}},
+	{"create signing_keys", exec(`
+			CREATE TABLE IF NOT EXISTS signing_keys (
+				id INTEGER PRIMARY KEY AUTO_INCREMENT,
+				secret VARBINARY(64) NOT NULL,
+				created_at DATETIME NOT NULL
+			);`)},
+}
+
+// version the code expects
+func Latest() int {
+	return len(steps)
+}
+
+// version the database is at, 0 before the first migration
+func Version(ctx context.Context, db *sql.DB) (int, error) {
+	var v int
+	err := db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&v)
+
+	return v, err
+}
+
+// apply the pending steps, each is recorded once it ran
+func Migrate(db *sql.DB) {
+	_, err := db.Exec(`
+			CREATE TABLE IF NOT EXISTS schema_migrations (
+				version INTEGER PRIMARY KEY,
+				name VARCHAR(255) NOT NULL,
+				applied_at DATETIME DEFAULT CURRENT_TIMESTAMP
 			);`)
 	if err != nil {
 		panic(err)
 	}
-	seeder.Seed(db)
+
+	current, err := Version(context.Background(), db)
+	if err != nil {
+		panic(err)
+	}
+
+	for i := current; i < len(steps); i++ {
+		if err := steps[i].up(db); err != nil {
+			panic(fmt.Errorf("migration %d (%s): %w", i+1, steps[i].name, err))
+		}
+
+		// ignored when another instance recorded it first
+		_, err := db.Exec(`INSERT IGNORE INTO schema_migrations (version, name) VALUES(?, ?)`, i+1, steps[i].name)
+		if err != nil {
+			panic(err)
+		}
+
+		log.Printf("migration %d (%s) applied", i+1, steps[i].name)
+	}
 }
@@ -0,0 +1,95 @@
+package migration
+
+import (
+	"database/sql/driver"
+	"strings"
+	"testing"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/utils/hash"
+	"github.com/ariopri/Let-It-Be/tree/main/backend/utils/sqltest"
+)
+
+func init() {
+	// bcrypt.MinCost, for the seeder
+	hash.Cost = 4
+}
+
+// a database at version whose information_schema reports every column and
+// index as present or as missing
+func openDB(t *testing.T, version int, present bool) *sqltest.DB {
+	t.Helper()
+
+	count := int64(0)
+	if present {
+		count = 1
+	}
+
+	db, fake := sqltest.Open(t, func(query string, args []driver.Value) sqltest.Result {
+		switch {
+		case strings.Contains(query, "FROM schema_migrations"):
+			return sqltest.Row([]string{"version"}, int64(version))
+		case strings.Contains(query, "information_schema"):
+			return sqltest.Row([]string{"n"}, count)
+		}
+		return sqltest.Result{Affected: 1}
+	})
+	Migrate(db)
+
+	return fake
+}
+
+func TestMigrateUpgradesBaselineUsers(t *testing.T) {
+	fake := openDB(t, 0, false)
+
+	want := []string{
+		"ADD COLUMN active BOOLEAN NOT NULL DEFAULT TRUE AFTER role",
+		"ADD COLUMN email_verified BOOLEAN NOT NULL DEFAULT FALSE AFTER active",
+		"ADD COLUMN token_version INTEGER NOT NULL DEFAULT 0 AFTER email_verified",
+		"ADD COLUMN updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP AFTER created_at",
+	}
+	alters := fake.Ran("ALTER TABLE users ADD COLUMN")
+	if len(alters) != len(want) {
+		t.Fatalf("%d columns added, want %d", len(alters), len(want))
+	}
+	for i, w := range want {
+		if !strings.HasSuffix(alters[i].Query, w) {
+			t.Errorf("alter %d = %q, want it to end in %q", i, alters[i].Query, w)
+		}
+	}
+
+	if len(fake.Ran("ADD CONSTRAINT users_email_unique")) != 1 {
+		t.Error("unique email constraint wasn't added")
+	}
+	if len(fake.Ran("UPDATE users SET email_verified = TRUE")) == 0 {
+		t.Error("existing users weren't marked verified")
+	}
+	if got := len(fake.Ran("INSERT IGNORE INTO schema_migrations")); got != Latest() {
+		t.Errorf("%d steps recorded, want %d", got, Latest())
+	}
+
+	// seeding runs before the columns are added
+	for _, s := range fake.Ran("INSERT INTO users") {
+		if strings.Contains(s.Query, "email_verified") {
+			t.Errorf("seeder uses a column old databases lack: %s", s.Query)
+		}
+	}
+}
+
+func TestMigrateKeepsExistingSchema(t *testing.T) {
+	fake := openDB(t, 0, true)
+
+	if alters := fake.Ran("ALTER TABLE"); len(alters) != 0 {
+		t.Errorf("altered a complete schema: %v", alters)
+	}
+	if idx := fake.Ran("CREATE INDEX"); len(idx) != 0 {
+		t.Errorf("created existing indexes again: %v", idx)
+	}
+}
+
+func TestMigrateUpToDate(t *testing.T) {
+	fake := openDB(t, Latest(), false)
+
+	if got := fake.Ran("INSERT IGNORE INTO schema_migrations"); len(got) != 0 {
+		t.Errorf("ran %d steps on an up to date database", len(got))
+	}
+}
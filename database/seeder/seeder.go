@@ -7,6 +7,9 @@ import (
 	"github.com/ariopri/Let-It-Be/tree/main/backend/utils/hash"
 )
 
+// email of the seeded user
+const Email = "john@doe.com"
+
 func Seed(db *sql.DB) {
 
 	hashedPassword, err := hash.HashPassword("Password@123")
@@ -14,10 +17,14 @@ func Seed(db *sql.DB) {
 	if err != nil {
 		panic(err)
 	}
+	// only the columns users had from the start, this runs before the ones
+	// added since exist on old databases. checked by hand as their emails
+	// weren't unique yet either
 	_, err = db.Exec(`
 		INSERT INTO users (firstname, lastname, email, password, role)
-		VALUES (?, ?, ?, ?, ?)
-	`, "John", "Doe", "john@doe.com", hashedPassword, "user")
+		SELECT ?, ?, ?, ?, ? FROM DUAL
+		WHERE NOT EXISTS (SELECT 1 FROM users WHERE email = ?)
+	`, "John", "Doe", Email, hashedPassword, "user", Email)
 	if err != nil {
 		log.Fatal(err)
 	}
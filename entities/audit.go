@@ -0,0 +1,47 @@
+package entities
+
+import (
+	"context"
+	"time"
+)
+
+// audit actions
+const (
+	AuditImpersonateStart = "impersonate.start"
+	AuditImpersonateStop  = "impersonate.stop"
+	AuditRotateKey        = "key.rotate"
+	AuditForceLogout      = "user.force_logout"
+	AuditLogin            = "user.login"
+	AuditProfileUpdate    = "profile.update"
+	AuditDeletionRequest  = "user.deletion_request"
+	AuditDeletionCancel   = "user.deletion_cancel"
+)
+
+// query options for searching audit logs
+type AuditFilter struct {
+	// one of the audit actions above, keep the list in sync
+	Action string `form:"action" binding:"omitempty,oneof=impersonate.start impersonate.stop key.rotate user.force_logout user.login profile.update user.deletion_request user.deletion_cancel"`
+	Actor  string `form:"actor"`
+	// inclusive created_at bounds, RFC3339
+	From   time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To     time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+	Limit  int       `form:"limit" binding:"min=0"`
+	Offset int       `form:"offset" binding:"min=0"`
+}
+
+type AuditLog struct {
+	ID        int64     `json:"id" form:"id"`
+	Actor     string    `json:"actor" form:"actor"`
+	Action    string    `json:"action" form:"action"`
+	Target    string    `json:"target" form:"target"`
+	CreatedAt Timestamp `json:"created_at" form:"created_at"`
+}
+
+type AuditRepository interface {
+	Create(ctx context.Context, l *AuditLog) error
+	// entries the user made or was the target of, newest first. a zero
+	// limit returns all of them
+	ByUser(ctx context.Context, email string, limit, offset int) ([]AuditLog, error)
+	// entries matching the filter, newest first
+	Search(ctx context.Context, f *AuditFilter) ([]AuditLog, error)
+}
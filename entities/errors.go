@@ -1,8 +1,92 @@
 package entities
 
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
 const (
-	BadRequest     = "bad request"
-	InternalServer = "internal server error"
-	Unauthorized   = "unauthorized"
-	ItemNotFound   = "item not found"
+	BadRequest            = "bad request"
+	BodyRequired          = "request body required"
+	InternalServer        = "internal server error"
+	Unauthorized          = "unauthorized"
+	Forbidden             = "forbidden"
+	ItemNotFound          = "item not found"
+	UserDisabled          = "user account is disabled"
+	EmailNotVerified      = "email is not verified"
+	LastAdmin             = "at least one admin must remain"
+	PreconditionFailed    = "user was modified since the given time"
+	LimitTooLarge         = "limit exceeds the maximum page size"
+	TooManyRequests       = "too many requests"
+	HeadersTooLarge       = "request headers too large"
+	JSONTooComplex        = "request body is nested too deeply or too large"
+	PreferencesTooLarge   = "preferences exceed the maximum size"
+	WrongPassword         = "current password is incorrect"
+	BodyTooLarge          = "request body too large"
+	InvalidRole           = "unknown role"
+	HTTPSRequired         = "https is required"
+	UserReferenced        = "user is still referenced, disable the account instead"
+	PasswordChangeTooSoon = "password was changed too recently"
+	PasswordWeak          = "password is acceptable but weak"
+	InvalidSort           = "invalid sort column"
+	ValidationFailed      = "validation failed"
+	ServiceUnavailable    = "service temporarily unavailable"
+	RequestTimeout        = "request timed out"
+	PasswordReused        = "password was used recently"
+	PasswordBreached      = "password appears in a known data breach"
+	TokenRevoked          = "token has been revoked"
+	InvalidSignedURL      = "link is invalid or expired"
+	InvalidAuthScheme     = "authorization header must be Bearer <token>"
+	DeleteVetoed          = "user can't be deleted"
+	NoDeletionRequest     = "no deletion request pending"
+	UnsupportedMediaType  = "content type must be application/json"
+	MailUnavailable       = "email delivery is not configured"
+	BatchNotApplied       = "not applied, the batch failed"
+
+	// format strings
+	AlreadyExists = "%s already exists"
+	FieldError    = "error on field %s, condition: %s"
 )
+
+var (
+	ErrNotFound       = errors.New(ItemNotFound)
+	ErrUserDisabled   = errors.New(UserDisabled)
+	ErrInvalidSort    = errors.New(InvalidSort)
+	ErrLastAdmin      = errors.New(LastAdmin)
+	ErrPasswordReused = errors.New(PasswordReused)
+	ErrWrongPassword  = errors.New(WrongPassword)
+	ErrReferenced     = errors.New(UserReferenced)
+)
+
+// password changed again before the cooldown ran out
+type PasswordCooldownError struct {
+	RetryAfter time.Duration
+}
+
+func (e *PasswordCooldownError) Error() string {
+	return PasswordChangeTooSoon
+}
+
+// failure of the item at Index of a batch that is applied as a whole
+type BatchItemError struct {
+	Index int
+	Err   error
+}
+
+func (e *BatchItemError) Error() string {
+	return fmt.Sprintf("item %d: %v", e.Index, e.Err)
+}
+
+func (e *BatchItemError) Unwrap() error {
+	return e.Err
+}
+
+// unique constraint violation on a single field
+type DuplicateError struct {
+	Field string
+}
+
+func (e *DuplicateError) Error() string {
+	return fmt.Sprintf(AlreadyExists, e.Field)
+}
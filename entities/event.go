@@ -0,0 +1,24 @@
+package entities
+
+// user lifecycle events
+const (
+	EventUserCreated = "user.created"
+	EventUserUpdated = "user.updated"
+	EventUserDeleted = "user.deleted"
+	// the confirmation link itself is only mailed to the new address
+	EventEmailChangeRequested = "user.email_change_requested"
+	// a deletion is pending until its purge_at, or was called off
+	EventDeletionRequested = "user.deletion_requested"
+	EventDeletionCancelled = "user.deletion_cancelled"
+)
+
+type Event struct {
+	Type       string      `json:"type"`
+	Data       interface{} `json:"data"`
+	OccurredAt Timestamp   `json:"occurred_at"`
+}
+
+// delivers events to external receivers, Notify must not block
+type Notifier interface {
+	Notify(e Event)
+}
@@ -0,0 +1,6 @@
+package entities
+
+// delivers mail to a single address, for links only its owner may follow
+type Mailer interface {
+	Send(to, subject, body string) error
+}
@@ -0,0 +1,32 @@
+package entities
+
+import "encoding/json"
+
+// tells an omitted json field apart from an explicit null
+type OptionalString struct {
+	Set   bool
+	Null  bool
+	Value string
+}
+
+// only called when the key is present
+func (o *OptionalString) UnmarshalJSON(b []byte) error {
+	o.Set = true
+	if string(b) == "null" {
+		o.Null = true
+		return nil
+	}
+
+	return json.Unmarshal(b, &o.Value)
+}
+
+// apply to dst: omitted leaves it, null clears it, a value replaces it
+func (o OptionalString) Apply(dst *string) {
+	switch {
+	case !o.Set:
+	case o.Null:
+		*dst = ""
+	default:
+		*dst = o.Value
+	}
+}
@@ -0,0 +1,31 @@
+package entities
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestProfilePatchOmitNullValue(t *testing.T) {
+	profile := Profile{DisplayName: "Ada", Bio: "hi", AvatarURL: "https://example.com/a.png", Locale: "en"}
+
+	var patch ProfilePatch
+	if err := json.Unmarshal([]byte(`{"avatar_url":null,"bio":"hello","locale":""}`), &patch); err != nil {
+		t.Fatal(err)
+	}
+	patch.Apply(&profile)
+
+	want := Profile{DisplayName: "Ada", Bio: "hello", AvatarURL: "", Locale: ""}
+	if profile != want {
+		t.Errorf("profile = %+v, want %+v", profile, want)
+	}
+	if !patch.AvatarURL.Null || patch.DisplayName.Set {
+		t.Errorf("patch = %+v, want avatar_url null and display_name omitted", patch)
+	}
+}
+
+func TestOptionalStringRejectsOtherTypes(t *testing.T) {
+	var o OptionalString
+	if err := json.Unmarshal([]byte(`12`), &o); err == nil {
+		t.Error("a number was accepted as a string")
+	}
+}
@@ -0,0 +1,70 @@
+package entities
+
+import "unicode"
+
+// rules new passwords must follow, changeable at runtime by admins
+type PasswordPolicy struct {
+	MinLength     int       `json:"min_length" binding:"min=8,max=72"`
+	RequireUpper  bool      `json:"require_upper"`
+	RequireLower  bool      `json:"require_lower"`
+	RequireDigit  bool      `json:"require_digit"`
+	RequireSymbol bool      `json:"require_symbol"`
+	UpdatedAt     Timestamp `json:"updated_at"`
+}
+
+// used until an admin stores a policy
+var DefaultPasswordPolicy = PasswordPolicy{MinLength: 8}
+
+type passwordClasses struct {
+	length                      int
+	upper, lower, digit, symbol bool
+}
+
+func classify(password string) passwordClasses {
+	var c passwordClasses
+	for _, r := range password {
+		c.length++
+		switch {
+		case unicode.IsUpper(r):
+			c.upper = true
+		case unicode.IsLower(r):
+			c.lower = true
+		case unicode.IsDigit(r):
+			c.digit = true
+		default:
+			c.symbol = true
+		}
+	}
+
+	return c
+}
+
+// report whether password satisfies the policy
+func (p PasswordPolicy) Allows(password string) bool {
+	c := classify(password)
+	n, upper, lower, digit, symbol := c.length, c.upper, c.lower, c.digit, c.symbol
+
+	return n >= p.MinLength &&
+		(!p.RequireUpper || upper) &&
+		(!p.RequireLower || lower) &&
+		(!p.RequireDigit || digit) &&
+		(!p.RequireSymbol || symbol)
+}
+
+// passwords shorter than this or mixing fewer than three character classes
+// are accepted but reported as weak
+const strongPasswordLength = 12
+
+// report whether an allowed password is still easy to guess
+func WeakPassword(password string) bool {
+	c := classify(password)
+
+	classes := 0
+	for _, ok := range []bool{c.upper, c.lower, c.digit, c.symbol} {
+		if ok {
+			classes++
+		}
+	}
+
+	return c.length < strongPasswordLength || classes < 3
+}
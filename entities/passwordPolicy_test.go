@@ -0,0 +1,48 @@
+package entities
+
+import "testing"
+
+func TestPasswordPolicyAllows(t *testing.T) {
+	strict := PasswordPolicy{MinLength: 10, RequireUpper: true, RequireLower: true, RequireDigit: true, RequireSymbol: true}
+
+	for _, tc := range []struct {
+		policy   PasswordPolicy
+		password string
+		want     bool
+	}{
+		{DefaultPasswordPolicy, "abcdefgh", true},
+		{DefaultPasswordPolicy, "abcdefg", false},
+		{strict, "Abcdefgh1!", true},
+		{strict, "abcdefgh1!", false},
+		{strict, "ABCDEFGH1!", false},
+		{strict, "Abcdefghi!", false},
+		{strict, "Abcdefgh12", false},
+		{strict, "Abcdef1!", false},
+		// counted in characters, not bytes
+		{PasswordPolicy{MinLength: 8}, "ééééééé", false},
+	} {
+		if got := tc.policy.Allows(tc.password); got != tc.want {
+			t.Errorf("%+v allows %q = %v, want %v", tc.policy, tc.password, got, tc.want)
+		}
+	}
+}
+
+func TestWeakPassword(t *testing.T) {
+	for _, tc := range []struct {
+		password string
+		want     bool
+	}{
+		{"Str0ng-pass!", false},
+		{"str0ng-passw", false},
+		{"abcdefgh", true},
+		// long but only two classes
+		{"abcdefghijkl1", true},
+		// three classes but short
+		{"Abc-1234567", true},
+		{"Éé-1éééééééé", false},
+	} {
+		if got := WeakPassword(tc.password); got != tc.want {
+			t.Errorf("WeakPassword(%q) = %v, want %v", tc.password, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package entities
+
+// permissions
+const (
+	PermUsersRead   = "users:read"
+	PermUsersList   = "users:list"
+	PermUsersWrite  = "users:write"
+	PermUsersDelete = "users:delete"
+	PermUsersManage = "users:manage"
+)
+
+// role to permissions map, replace entries to change what a role can do
+var RolePermissions = map[string][]string{
+	"admin": {
+		PermUsersRead,
+		PermUsersList,
+		PermUsersWrite,
+		PermUsersDelete,
+		PermUsersManage,
+	},
+	"user": {
+		PermUsersRead,
+		PermUsersWrite,
+	},
+}
+
+// effective permissions of a role, empty for unknown roles
+func Permissions(role string) []string {
+	perms, ok := RolePermissions[role]
+	if !ok {
+		return []string{}
+	}
+
+	return perms
+}
+
+func HasPermission(role, perm string) bool {
+	for _, p := range Permissions(role) {
+		if p == perm {
+			return true
+		}
+	}
+
+	return false
+}
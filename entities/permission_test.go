@@ -0,0 +1,37 @@
+package entities
+
+import "testing"
+
+func TestHasPermission(t *testing.T) {
+	for _, tc := range []struct {
+		role, perm string
+		want       bool
+	}{
+		{"admin", PermUsersManage, true},
+		{"admin", PermUsersDelete, true},
+		{"user", PermUsersRead, true},
+		{"user", PermUsersDelete, false},
+		{"user", PermUsersManage, false},
+		{"guest", PermUsersRead, false},
+	} {
+		if got := HasPermission(tc.role, tc.perm); got != tc.want {
+			t.Errorf("HasPermission(%q, %q) = %v, want %v", tc.role, tc.perm, got, tc.want)
+		}
+	}
+}
+
+func TestPermissionsOfUnknownRole(t *testing.T) {
+	if perms := Permissions("guest"); perms == nil || len(perms) != 0 {
+		t.Errorf("Permissions = %#v, want an empty list", perms)
+	}
+}
+
+func TestRolePermissionsAreConfigurable(t *testing.T) {
+	old := RolePermissions["user"]
+	t.Cleanup(func() { RolePermissions["user"] = old })
+
+	RolePermissions["user"] = append(append([]string{}, old...), PermUsersList)
+	if !HasPermission("user", PermUsersList) {
+		t.Error("added permission not granted")
+	}
+}
@@ -0,0 +1,26 @@
+package entities
+
+// optional user details, stored 1:1 with the user
+type Profile struct {
+	UserID      int64     `json:"user_id" form:"user_id"`
+	DisplayName string    `json:"display_name" form:"display_name" binding:"max=255"`
+	Bio         string    `json:"bio" form:"bio" binding:"max=1000"`
+	AvatarURL   string    `json:"avatar_url" form:"avatar_url" binding:"omitempty,url,max=255"`
+	Locale      string    `json:"locale" form:"locale" binding:"omitempty,bcp47_language_tag"`
+	UpdatedAt   Timestamp `json:"updated_at" form:"updated_at"`
+}
+
+// partial profile update, null clears a field
+type ProfilePatch struct {
+	DisplayName OptionalString `json:"display_name"`
+	Bio         OptionalString `json:"bio"`
+	AvatarURL   OptionalString `json:"avatar_url"`
+	Locale      OptionalString `json:"locale"`
+}
+
+func (p ProfilePatch) Apply(profile *Profile) {
+	p.DisplayName.Apply(&profile.DisplayName)
+	p.Bio.Apply(&profile.Bio)
+	p.AvatarURL.Apply(&profile.AvatarURL)
+	p.Locale.Apply(&profile.Locale)
+}
@@ -0,0 +1,71 @@
+package entities
+
+// body of responses that issue a token
+type LoginResponse struct {
+	Message        string    `json:"message"`
+	Token          string    `json:"token"`
+	TokenType      string    `json:"token_type"`
+	ExpiresAt      Timestamp `json:"expires_at"`
+	ImpersonatedBy string    `json:"impersonated_by,omitempty"`
+	// left out for Prefer: return=minimal, ID is set instead
+	ID   int64         `json:"id,omitempty"`
+	Data *UserResponse `json:"data,omitempty"`
+	// of the token's role, only sent on login
+	Permissions []string  `json:"permissions,omitempty"`
+	Warnings    []Warning `json:"warnings,omitempty"`
+}
+
+// body of user listings, Users holds maps instead of UserResponse when a
+// sparse fieldset was requested
+type UserListResponse struct {
+	Message string      `json:"message"`
+	Users   interface{} `json:"users"`
+	Meta    *ListMeta   `json:"meta,omitempty"`
+	Missing []int64     `json:"missing,omitempty"`
+}
+
+// paging details of a listing, Total is only set when counted
+type ListMeta struct {
+	Limit  int    `json:"limit"`
+	Offset int    `json:"offset"`
+	Count  int    `json:"count"`
+	Total  *int64 `json:"total,omitempty"`
+}
+
+// body of responses that carry nothing but a message
+type MessageResponse struct {
+	Message string `json:"message"`
+}
+
+// everything stored about a user, for data portability. password hashes
+// are never part of it
+type UserExport struct {
+	User       UserResponse `json:"user"`
+	Profile    Profile      `json:"profile"`
+	Audit      []AuditLog   `json:"audit"`
+	ExportedAt Timestamp    `json:"exported_at"`
+}
+
+// outcome of a single item of a batch request, Index is its position in
+// the request body
+type BatchResult struct {
+	Index   int    `json:"index"`
+	ID      int64  `json:"id,omitempty"`
+	Status  int    `json:"status"`
+	Error   string `json:"error,omitempty"`
+	Skipped bool   `json:"skipped,omitempty"`
+}
+
+type BatchSummary struct {
+	Total     int `json:"total"`
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+	Skipped   int `json:"skipped,omitempty"`
+}
+
+// body of batch responses, items succeed or fail on their own
+type BatchResponse struct {
+	Message string        `json:"message"`
+	Results []BatchResult `json:"results"`
+	Summary BatchSummary  `json:"summary"`
+}
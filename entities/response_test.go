@@ -0,0 +1,52 @@
+package entities
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func marshal(t *testing.T, v interface{}) string {
+	t.Helper()
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(b)
+}
+
+func TestLoginResponseJSON(t *testing.T) {
+	expires := Timestamp{time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)}
+
+	got := marshal(t, LoginResponse{Message: "login success", Token: "t", TokenType: "Bearer", ExpiresAt: expires, Data: &UserResponse{ID: 1}})
+	want := `{"message":"login success","token":"t","token_type":"Bearer","expires_at":"2024-03-01T12:00:00Z",` +
+		`"data":{"id":1,"first_name":"","last_name":"","email":"","role":"","active":false,"email_verified":false,"created_at":null,"updated_at":null}}`
+	if got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+
+	// minimal responses carry the id instead of the user
+	got = marshal(t, LoginResponse{Message: "user registered", Token: "t", TokenType: "Bearer", ExpiresAt: expires, ID: 7})
+	want = `{"message":"user registered","token":"t","token_type":"Bearer","expires_at":"2024-03-01T12:00:00Z","id":7}`
+	if got != want {
+		t.Errorf("minimal: got  %s\nwant %s", got, want)
+	}
+}
+
+func TestUserListResponseJSON(t *testing.T) {
+	total := int64(12)
+
+	got := marshal(t, UserListResponse{Message: "users fetched", Users: []UserResponse{}, Meta: &ListMeta{Limit: 10, Offset: 10, Count: 2, Total: &total}})
+	want := `{"message":"users fetched","users":[],"meta":{"limit":10,"offset":10,"count":2,"total":12}}`
+	if got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+
+	got = marshal(t, UserListResponse{Message: "users fetched", Users: []UserResponse{}, Missing: []int64{4}})
+	want = `{"message":"users fetched","users":[],"missing":[4]}`
+	if got != want {
+		t.Errorf("by ids: got  %s\nwant %s", got, want)
+	}
+}
@@ -0,0 +1,55 @@
+package entities
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"time"
+)
+
+// Timestamp is always serialized as RFC3339 in UTC, whatever the location
+// it was read in
+type Timestamp struct {
+	time.Time
+}
+
+func (t Timestamp) MarshalJSON() ([]byte, error) {
+	if t.IsZero() {
+		return []byte("null"), nil
+	}
+
+	return []byte(`"` + t.UTC().Format(time.RFC3339) + `"`), nil
+}
+
+func (t *Timestamp) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		t.Time = time.Time{}
+		return nil
+	}
+
+	parsed, err := time.Parse(`"`+time.RFC3339+`"`, string(b))
+	if err != nil {
+		return err
+	}
+	t.Time = parsed
+
+	return nil
+}
+
+// sql.Scanner
+func (t *Timestamp) Scan(v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+		t.Time = time.Time{}
+	case time.Time:
+		t.Time = v
+	default:
+		return fmt.Errorf("can't scan %T into Timestamp", v)
+	}
+
+	return nil
+}
+
+// driver.Valuer
+func (t Timestamp) Value() (driver.Value, error) {
+	return t.Time, nil
+}
@@ -0,0 +1,42 @@
+package entities
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestTimestampIsRFC3339UTC(t *testing.T) {
+	jakarta := time.FixedZone("WIB", 7*60*60)
+	user := UserResponse{CreatedAt: Timestamp{time.Date(2024, 3, 1, 9, 30, 15, 123456789, jakarta)}}
+
+	b, err := json.Marshal(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(b, &fields); err != nil {
+		t.Fatal(err)
+	}
+	if got := fields["created_at"]; got != "2024-03-01T02:30:15Z" {
+		t.Errorf("created_at = %v, want 2024-03-01T02:30:15Z", got)
+	}
+	if got := fields["updated_at"]; got != nil {
+		t.Errorf("zero updated_at = %v, want null", got)
+	}
+}
+
+func TestTimestampRoundTrip(t *testing.T) {
+	var ts Timestamp
+	if err := json.Unmarshal([]byte(`"2024-03-01T09:30:15+07:00"`), &ts); err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2024, 3, 1, 2, 30, 15, 0, time.UTC); !ts.Equal(want) {
+		t.Errorf("parsed %v, want %v", ts.Time, want)
+	}
+
+	if err := json.Unmarshal([]byte(`"2024-03-01 09:30:15"`), &ts); err == nil {
+		t.Error("a non RFC3339 time was accepted")
+	}
+}
@@ -2,39 +2,234 @@ package entities
 
 import (
 	"context"
+	"encoding/json"
+	"strings"
 	"time"
 )
 
 type User struct {
-	ID        int64     `json:"id" form:"id"`
-	FirstName string    `json:"firstname" form:"firstname" binding:"required"`
-	LastName  string    `json:"lastname" form:"lastname" binding:"required"`
-	Email     string    `json:"email" form:"email" binding:"required,email"`
-	Password  string    `json:"password" form:"password" binding:"required, min=8"`
-	Role      string    `json:"role" form:"role"`
-	CreatedAt time.Time `json:"created_at" form:"created_at"`
+	ID            int64     `json:"id" form:"id"`
+	FirstName     string    `json:"first_name" form:"first_name" binding:"required"`
+	LastName      string    `json:"last_name" form:"last_name" binding:"required"`
+	Email         string    `json:"email" form:"email" binding:"required,email"`
+	Password      string    `json:"password" form:"password" binding:"required,min=8,password" trim:"-"`
+	Role          string    `json:"role" form:"role"`
+	Active        bool      `json:"active" form:"active"`
+	EmailVerified bool      `json:"email_verified" form:"email_verified"`
+	TokenVersion  int       `json:"-" form:"-"`
+	CreatedAt     Timestamp `json:"created_at" form:"created_at"`
+	UpdatedAt     Timestamp `json:"updated_at" form:"updated_at"`
+	// only set when loaded explicitly
+	Profile *Profile `json:"profile,omitempty" form:"-"`
+}
+
+// the fields a user update may set, role, status, verification and the
+// email have their own endpoints. an empty password keeps the current one
+type UserUpdate struct {
+	FirstName string `json:"first_name" form:"first_name" binding:"required"`
+	LastName  string `json:"last_name" form:"last_name" binding:"required"`
+	Password  string `json:"password" form:"password" binding:"omitempty,min=8,password" trim:"-"`
+}
+
+func (u UserUpdate) User() *User {
+	return &User{
+		FirstName: u.FirstName,
+		LastName:  u.LastName,
+		Password:  u.Password,
+	}
+}
+
+// user fields after applying a merge patch, an empty password keeps the
+// current one
+type UserPatch struct {
+	FirstName string `json:"first_name" binding:"required"`
+	LastName  string `json:"last_name" binding:"required"`
+	Password  string `json:"password" binding:"omitempty,min=8,password" trim:"-"`
+}
+
+func (u UserPatch) User() *User {
+	return &User{
+		FirstName: u.FirstName,
+		LastName:  u.LastName,
+		Password:  u.Password,
+	}
 }
 
 type UserResponse struct {
+	ID            int64     `json:"id" form:"id"`
+	FirstName     string    `json:"first_name" form:"first_name"`
+	LastName      string    `json:"last_name" form:"last_name"`
+	Email         string    `json:"email" form:"email"`
+	Role          string    `json:"role" form:"role"`
+	Active        bool      `json:"active" form:"active"`
+	EmailVerified bool      `json:"email_verified" form:"email_verified"`
+	TokenVersion  int       `json:"-" form:"-"`
+	CreatedAt     Timestamp `json:"created_at" form:"created_at"`
+	UpdatedAt     Timestamp `json:"updated_at" form:"updated_at"`
+	// only set when loaded explicitly
+	Profile *Profile `json:"profile,omitempty" form:"-"`
+}
+
+// what strangers get to see of another user
+type PublicUserResponse struct {
 	ID        int64     `json:"id" form:"id"`
-	FirstName string    `json:"firstname" form:"firstname"`
-	LastName  string    `json:"lastname" form:"lastname"`
-	Email     string    `json:"email" form:"email"`
-	Role      string    `json:"role" form:"role"`
-	CreatedAt time.Time `json:"created_at" form:"created_at"`
+	FirstName string    `json:"first_name" form:"first_name"`
+	LastName  string    `json:"last_name" form:"last_name"`
+	CreatedAt Timestamp `json:"created_at" form:"created_at"`
+}
+
+func (u UserResponse) Public() PublicUserResponse {
+	return PublicUserResponse{
+		ID:        u.ID,
+		FirstName: u.FirstName,
+		LastName:  u.LastName,
+		CreatedAt: u.CreatedAt,
+	}
+}
+
+// enable / disable an account
+type UserStatus struct {
+	Active *bool `json:"active" form:"active" binding:"required"`
+}
+
+// a role change and the email of the admin who made it
+type RoleChange struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"user_id"`
+	OldRole   string    `json:"old_role"`
+	NewRole   string    `json:"new_role"`
+	ChangedBy string    `json:"changed_by"`
+	CreatedAt Timestamp `json:"created_at"`
+}
+
+// ids of a batch request
+type IDList struct {
+	IDs []int64 `json:"ids" binding:"required,min=1"`
+}
+
+// single entry of a batch role assignment
+type RoleAssignment struct {
+	ID   int64  `json:"id" form:"id" binding:"required"`
+	Role string `json:"role" form:"role" binding:"required,oneof=admin user"`
 }
 
 type Login struct {
 	Email    string `json:"email" form:"email" binding:"required,email"`
-	Password string `json:"password" form:"password" binding:"required"`
+	Password string `json:"password" form:"password" binding:"required" trim:"-"`
+	// ask for a token that lives token.RememberTTL
+	Remember bool `json:"remember" form:"remember"`
+}
+
+// body of an email change, the current password confirms it
+type EmailChange struct {
+	Email    string `json:"email" binding:"required,email,max=255"`
+	Password string `json:"password" binding:"required" trim:"-"`
+}
+
+// asks for a new verification link
+type EmailVerification struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// query options for listing users
+type UserFilter struct {
+	// column to sort by, prefix with "-" for descending
+	Sort   string `form:"sort"`
+	Limit  int    `form:"limit" binding:"min=0"`
+	Offset int    `form:"offset" binding:"min=0"`
+	// inclusive created_at bounds, RFC3339
+	CreatedAfter  time.Time `form:"created_after" time_format:"2006-01-02T15:04:05Z07:00"`
+	CreatedBefore time.Time `form:"created_before" time_format:"2006-01-02T15:04:05Z07:00"`
+	// case insensitive prefix of the email, first, last or full name
+	Search string `form:"search"`
+	// only users with this tag
+	Tag string `form:"tag"`
+	// only users with one of these comma separated roles
+	Role string `form:"role"`
+	// how to compute the total, defaults to none
+	Count string `form:"count" binding:"omitempty,oneof=exact estimate none"`
+}
+
+// roles of the Role filter, nil when it's unset
+func (f *UserFilter) Roles() []string {
+	if f.Role == "" {
+		return nil
+	}
+
+	var roles []string
+	for _, r := range strings.Split(f.Role, ",") {
+		if r = strings.TrimSpace(r); r != "" {
+			roles = append(roles, r)
+		}
+	}
+
+	return roles
+}
+
+// whether any option narrows the users down, paging and sorting don't
+func (f *UserFilter) Filtered() bool {
+	return !f.CreatedAfter.IsZero() || !f.CreatedBefore.IsZero() ||
+		f.Search != "" || f.Tag != "" || len(f.Roles()) > 0
+}
+
+// total count modes for listings
+const (
+	CountExact    = "exact"
+	CountEstimate = "estimate"
+	CountNone     = "none"
+)
+
+// tags to add or remove
+type TagList struct {
+	Tags []string `json:"tags" binding:"required,min=1,dive,required,max=64"`
+}
+
+// limit and offset of a paginated listing
+type Page struct {
+	Limit  int `form:"limit" binding:"min=0"`
+	Offset int `form:"offset" binding:"min=0"`
+}
+
+// availability check query
+type Availability struct {
+	Email string `form:"email" binding:"required,email"`
 }
 
 type UserRepository interface {
-	Fetch(ctx context.Context) ([]UserResponse, error)
+	Fetch(ctx context.Context, f *UserFilter) ([]UserResponse, error)
+	Count(ctx context.Context, f *UserFilter, mode string) (int64, error)
 	FetchById(ctx context.Context, id int64) (UserResponse, error)
+	FetchByEmail(ctx context.Context, email string) (UserResponse, error)
+	FetchByIDs(ctx context.Context, ids []int64) ([]UserResponse, []int64, error)
+	EmailExists(ctx context.Context, email string) (bool, error)
 	Create(ctx context.Context, u *User) (UserResponse, error)
 	Update(ctx context.Context, id int64, u *User) (UserResponse, error)
+	Upsert(ctx context.Context, u *User) (UserResponse, bool, error)
 	Delete(ctx context.Context, id int64) error
+	UpdateStatus(ctx context.Context, id int64, active bool) (UserResponse, error)
+	UpdateRoles(ctx context.Context, roles []RoleAssignment, changedBy string) ([]UserResponse, error)
+	RoleHistory(ctx context.Context, id int64) ([]RoleChange, error)
+	BumpTokenVersion(ctx context.Context, id int64) error
+	Export(ctx context.Context, fn func(UserResponse) error) error
+	FetchProfile(ctx context.Context, id int64) (Profile, error)
+	UpdateProfile(ctx context.Context, id int64, p *Profile) (Profile, error)
+	FetchPreferences(ctx context.Context, id int64) (json.RawMessage, error)
+	UpdatePreferences(ctx context.Context, id int64, prefs json.RawMessage) error
+	RecordPasswordHistory(ctx context.Context, id int64, passwordHash string) error
+	PasswordReused(ctx context.Context, id int64, password string) (bool, error)
+	FetchPasswordPolicy(ctx context.Context) (PasswordPolicy, error)
+	UpdatePasswordPolicy(ctx context.Context, p *PasswordPolicy) (PasswordPolicy, error)
+	AddTags(ctx context.Context, id int64, tags []string) ([]string, error)
+	RemoveTags(ctx context.Context, id int64, tags []string) ([]string, error)
+	UserTags(ctx context.Context, id int64) ([]string, error)
+	RequestEmailChange(ctx context.Context, id int64, password, newEmail, tokenHash string, expiresAt time.Time) error
+	RequestEmailVerification(ctx context.Context, id int64, tokenHash string, expiresAt time.Time) error
+	ConfirmEmailChange(ctx context.Context, tokenHash string, now time.Time) (UserResponse, error)
+	RotateAPIKey(ctx context.Context, id int64, keyHash string) error
+	FetchByAPIKey(ctx context.Context, keyHash string) (UserResponse, error)
+	RequestDeletion(ctx context.Context, id int64, purgeAt time.Time) error
+	CancelDeletion(ctx context.Context, id int64) (bool, error)
+	DueDeletions(ctx context.Context, now time.Time) ([]int64, error)
 	Login(ctx context.Context, l *Login) (UserResponse, error)
 	Register(ctx context.Context, u *User) (UserResponse, error)
 }
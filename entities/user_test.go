@@ -0,0 +1,51 @@
+package entities
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestUserResponseKeys(t *testing.T) {
+	b, err := json.Marshal(UserResponse{ID: 1, FirstName: "Ada", LastName: "Lovelace", Email: "ada@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(b, &fields); err != nil {
+		t.Fatal(err)
+	}
+
+	var keys []string
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	want := []string{"active", "created_at", "email", "email_verified", "first_name", "id", "last_name", "role", "updated_at"}
+	if len(keys) != len(want) {
+		t.Fatalf("keys = %v, want %v", keys, want)
+	}
+	for i := range want {
+		if keys[i] != want[i] {
+			t.Fatalf("keys = %v, want %v", keys, want)
+		}
+	}
+}
+
+func TestUserFilterRoles(t *testing.T) {
+	for role, want := range map[string][]string{
+		"":              nil,
+		"admin":         {"admin"},
+		"admin,user":    {"admin", "user"},
+		" admin , user": {"admin", "user"},
+		"admin,,":       {"admin"},
+	} {
+		f := UserFilter{Role: role}
+		if got := f.Roles(); !reflect.DeepEqual(got, want) {
+			t.Errorf("Roles() of %q = %q, want %q", role, got, want)
+		}
+	}
+}
@@ -0,0 +1,8 @@
+package entities
+
+// non blocking remark on a request that succeeded, unlike validation errors
+type Warning struct {
+	Field     string `json:"field"`
+	Condition string `json:"condition"`
+	Message   string `json:"message"`
+}
@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+	"github.com/ariopri/Let-It-Be/tree/main/backend/utils/token"
+	"github.com/gin-gonic/gin"
+)
+
+// audit an everyday action of the requester. unlike admin actions these
+// don't fail the request when the audit log can't be written
+func (u *userHandler) recordActivity(c *gin.Context, action, target string) {
+	claims := c.MustGet("user").(*token.Claims)
+	u.recordActivityOf(c, claims.Email, action, target)
+}
+
+func (u *userHandler) recordActivityOf(c *gin.Context, actor, action, target string) {
+	err := u.auditRepo.Create(c.Request.Context(), &entities.AuditLog{
+		Actor:  actor,
+		Action: action,
+		Target: target,
+	})
+	if err != nil {
+		log.Printf("audit: %s by %s: %v", action, actor, err)
+	}
+}
+
+// recent audit entries of a user, newest first
+func (u *userHandler) activity(c *gin.Context) {
+	ctx := c.Request.Context()
+	id := c.Param("id")
+	idConv, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": localize(c, entities.BadRequest),
+		})
+		return
+	}
+
+	page := entities.Page{}
+	if err := c.ShouldBindQuery(&page); err != nil {
+		bindError(c, err)
+		return
+	}
+	if page.Limit == 0 || page.Limit > MaxPageSize {
+		page.Limit = MaxPageSize
+	}
+
+	user, err := u.userRepo.FetchById(ctx, idConv)
+	if errors.Is(err, entities.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": localize(c, entities.ItemNotFound),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": localize(c, entities.InternalServer),
+		})
+		return
+	}
+
+	logs, err := u.auditRepo.ByUser(ctx, user.Email, page.Limit, page.Offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": localize(c, entities.InternalServer),
+		})
+		return
+	}
+
+	meta := entities.ListMeta{
+		Limit:  page.Limit,
+		Offset: page.Offset,
+		Count:  len(logs),
+	}
+	setLinkHeader(c, meta)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "activity fetched",
+		"activity": logs,
+		"meta":     meta,
+	})
+}
@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+)
+
+func TestActivityNewestFirst(t *testing.T) {
+	r := newTestRouter(t, newStubRepo(testAdmin, testUser))
+
+	if w := login(t, r, testUser, ""); w.Code != http.StatusOK {
+		t.Fatalf("login: status = %d: %s", w.Code, w.Body)
+	}
+	body := `{"display_name":"Uma U."}`
+	if w := doRequest(t, r, http.MethodPut, "/api/users/2/profile", body, testUser); w.Code != http.StatusOK {
+		t.Fatalf("profile: status = %d: %s", w.Code, w.Body)
+	}
+	// someone else's activity isn't listed
+	login(t, r, testAdmin, "")
+
+	for _, as := range []entities.UserResponse{testUser, testAdmin} {
+		w := doRequest(t, r, http.MethodGet, "/api/users/2/activity", "", as)
+		if w.Code != http.StatusOK {
+			t.Fatalf("as %s: status = %d, want %d: %s", as.Role, w.Code, http.StatusOK, w.Body)
+		}
+
+		var res struct {
+			Activity []entities.AuditLog `json:"activity"`
+			Meta     entities.ListMeta   `json:"meta"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+			t.Fatal(err)
+		}
+
+		var actions []string
+		for _, l := range res.Activity {
+			actions = append(actions, l.Action)
+		}
+		want := []string{entities.AuditProfileUpdate, entities.AuditLogin}
+		if len(actions) != len(want) || actions[0] != want[0] || actions[1] != want[1] {
+			t.Errorf("as %s: activity = %v, want %v", as.Role, actions, want)
+		}
+		if res.Meta.Count != 2 || res.Meta.Limit != MaxPageSize {
+			t.Errorf("as %s: meta = %+v, want a count of 2 and the default limit", as.Role, res.Meta)
+		}
+	}
+}
+
+func TestActivityIsSelfOrAdmin(t *testing.T) {
+	r := newTestRouter(t, newStubRepo(testAdmin, testUser))
+
+	for _, tc := range []struct {
+		path string
+		as   entities.UserResponse
+		want int
+	}{
+		{"/api/users/1/activity", testUser, http.StatusForbidden},
+		{"/api/users/9/activity", testAdmin, http.StatusNotFound},
+		{"/api/users/2/activity?limit=-1", testUser, http.StatusUnprocessableEntity},
+	} {
+		if w := doRequest(t, r, http.MethodGet, tc.path, "", tc.as); w.Code != tc.want {
+			t.Errorf("%s as %s: status = %d, want %d", tc.path, tc.as.Role, w.Code, tc.want)
+		}
+	}
+}
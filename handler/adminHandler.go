@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+	"github.com/ariopri/Let-It-Be/tree/main/backend/utils/token"
+	"github.com/gin-gonic/gin"
+)
+
+// rotate the jwt signing key, tokens signed with the previous key stay
+// valid for token.RotationGrace
+func (u *userHandler) rotateKey(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	// role check, impersonation tokens never qualify
+	claims := c.MustGet("user").(*token.Claims)
+	if !isAdmin(c) || claims.ImpersonatedBy != "" {
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": localize(c, entities.Forbidden),
+		})
+		return
+	}
+
+	kid, err := token.RotateKey()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": localize(c, entities.InternalServer),
+		})
+		return
+	}
+
+	err = u.auditRepo.Create(ctx, &entities.AuditLog{
+		Actor:  claims.Email,
+		Action: entities.AuditRotateKey,
+		Target: kid,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": localize(c, entities.InternalServer),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "signing key rotated",
+		"kid":     kid,
+	})
+}
+
+// applied and latest known schema version, nil when main doesn't track it
+var SchemaVersion func(ctx context.Context) (current, latest int, err error)
+
+// schema version of the database next to the one the code expects
+func (u *userHandler) schemaVersion(c *gin.Context) {
+	// role check
+	if !isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": localize(c, entities.Forbidden),
+		})
+		return
+	}
+
+	if SchemaVersion == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": localize(c, entities.ItemNotFound),
+		})
+		return
+	}
+
+	current, latest, err := SchemaVersion(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": localize(c, entities.InternalServer),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "schema version fetched",
+		"version": current,
+		"latest":  latest,
+		"pending": latest - current,
+	})
+}
@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+	"github.com/ariopri/Let-It-Be/tree/main/backend/utils/token"
+	"github.com/gin-gonic/gin"
+)
+
+// issue the current user a new api key, the previous one stops working.
+// the key is only ever returned here, just its hash is stored
+func (u *userHandler) rotateAPIKey(c *gin.Context) {
+	// impersonation tokens never qualify
+	claims := c.MustGet("user").(*token.Claims)
+	if claims.ImpersonatedBy != "" {
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": localize(c, entities.Forbidden),
+		})
+		return
+	}
+
+	user := c.MustGet("current_user").(entities.UserResponse)
+
+	key, keyHash, err := token.NewAPIKey()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": localize(c, entities.InternalServer),
+		})
+		return
+	}
+
+	if err := u.userRepo.RotateAPIKey(c.Request.Context(), user.ID, keyHash); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": localize(c, entities.InternalServer),
+		})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "api key created, it won't be shown again",
+		"api_key": key,
+		"header":  token.APIKeyHeader,
+	})
+}
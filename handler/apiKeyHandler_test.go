@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+	"github.com/ariopri/Let-It-Be/tree/main/backend/utils/token"
+)
+
+func (r *stubUserRepo) RotateAPIKey(ctx context.Context, id int64, keyHash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.users[id]; !ok {
+		return entities.ErrNotFound
+	}
+	if r.apiKeys == nil {
+		r.apiKeys = map[int64]string{}
+	}
+	r.apiKeys[id] = keyHash
+
+	return nil
+}
+
+func (r *stubUserRepo) FetchByAPIKey(ctx context.Context, keyHash string) (entities.UserResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, h := range r.apiKeys {
+		if h == keyHash {
+			return r.users[id], nil
+		}
+	}
+
+	return entities.UserResponse{}, entities.ErrNotFound
+}
+
+// rotate the key of as and return the new one
+func rotateAPIKey(t *testing.T, r http.Handler, as entities.UserResponse) string {
+	t.Helper()
+
+	w := doRequest(t, r, http.MethodPost, "/api/me/api-key", "", as)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusCreated, w.Body)
+	}
+	if cc := w.Header().Get("Cache-Control"); cc != "no-store" {
+		t.Errorf("Cache-Control = %q, want no-store", cc)
+	}
+
+	res := decodeBody(t, w)
+	if res["header"] != token.APIKeyHeader {
+		t.Errorf("header = %v, want %s", res["header"], token.APIKeyHeader)
+	}
+	key, _ := res["api_key"].(string)
+	if key == "" {
+		t.Fatalf("no api_key in %v", res)
+	}
+
+	return key
+}
+
+// status of a request authenticated by key alone
+func withAPIKey(r http.Handler, key string) int {
+	req := httptest.NewRequest(http.MethodGet, "/api/me/permissions", nil)
+	req.Header.Set(token.APIKeyHeader, key)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	return w.Code
+}
+
+func TestRotatedAPIKeyReplacesTheOldOne(t *testing.T) {
+	repo := newStubRepo(testAdmin, testUser)
+	r := newTestRouter(t, repo)
+
+	old := rotateAPIKey(t, r, testUser)
+	// only the hash is stored
+	if repo.apiKeys[testUser.ID] != token.HashAPIKey(old) {
+		t.Errorf("stored %q, want the hash of the key", repo.apiKeys[testUser.ID])
+	}
+	if code := withAPIKey(r, old); code != http.StatusOK {
+		t.Fatalf("request with the key: status = %d, want %d", code, http.StatusOK)
+	}
+
+	key := rotateAPIKey(t, r, testUser)
+	if key == old {
+		t.Fatal("rotating returned the same key")
+	}
+	if code := withAPIKey(r, old); code != http.StatusUnauthorized {
+		t.Errorf("request with the old key: status = %d, want %d", code, http.StatusUnauthorized)
+	}
+	if code := withAPIKey(r, key); code != http.StatusOK {
+		t.Errorf("request with the new key: status = %d, want %d", code, http.StatusOK)
+	}
+}
+
+func TestAPIKeyAuthenticatesItsOwner(t *testing.T) {
+	r := newTestRouter(t, newStubRepo(testAdmin, testUser))
+
+	key := rotateAPIKey(t, r, testUser)
+	req := httptest.NewRequest(http.MethodGet, "/api/me/permissions", nil)
+	req.Header.Set(token.APIKeyHeader, key)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+
+	var res struct {
+		Role string `json:"role"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Role != testUser.Role {
+		t.Errorf("role = %q, want %q", res.Role, testUser.Role)
+	}
+
+	if code := withAPIKey(r, "uk_unknown"); code != http.StatusUnauthorized {
+		t.Errorf("unknown key: status = %d, want %d", code, http.StatusUnauthorized)
+	}
+}
+
+func TestImpersonationCantRotateAPIKeys(t *testing.T) {
+	repo := newStubRepo(testAdmin, testUser)
+	r := newTestRouter(t, repo)
+
+	w := doRequest(t, r, http.MethodPost, "/api/users/2/impersonate", "", testAdmin)
+	if w.Code != http.StatusOK {
+		t.Fatalf("impersonate status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	var res entities.LoginResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+
+	if w := doRequestToken(t, r, http.MethodPost, "/api/me/api-key", "", res.Token); w.Code != http.StatusForbidden {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+	if len(repo.apiKeys) != 0 {
+		t.Errorf("keys stored: %v", repo.apiKeys)
+	}
+}
@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+	"github.com/gin-gonic/gin"
+)
+
+// search audit logs by action, actor and time range
+func (u *userHandler) searchAudit(c *gin.Context) {
+	// role check
+	if !isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": localize(c, entities.Forbidden),
+		})
+		return
+	}
+
+	filter := entities.AuditFilter{}
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		bindError(c, err)
+		return
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && filter.From.After(filter.To) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": localize(c, entities.BadRequest),
+		})
+		return
+	}
+	if filter.Limit == 0 || filter.Limit > MaxPageSize {
+		filter.Limit = MaxPageSize
+	}
+
+	logs, err := u.auditRepo.Search(c.Request.Context(), &filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": localize(c, entities.InternalServer),
+		})
+		return
+	}
+
+	meta := entities.ListMeta{
+		Limit:  filter.Limit,
+		Offset: filter.Offset,
+		Count:  len(logs),
+	}
+	setLinkHeader(c, meta)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "audit logs fetched",
+		"audit":   logs,
+		"meta":    meta,
+	})
+}
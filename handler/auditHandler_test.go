@@ -0,0 +1,141 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+)
+
+// logs matching f, newest first
+func (a *stubAuditRepo) Search(ctx context.Context, f *entities.AuditFilter) ([]entities.AuditLog, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	copied := *f
+	a.searched = &copied
+
+	logs := []entities.AuditLog{}
+	for i := len(a.logs) - 1; i >= 0; i-- {
+		l := a.logs[i]
+		switch {
+		case f.Action != "" && l.Action != f.Action,
+			f.Actor != "" && l.Actor != f.Actor,
+			!f.From.IsZero() && l.CreatedAt.Before(f.From),
+			!f.To.IsZero() && l.CreatedAt.After(f.To):
+			continue
+		}
+		logs = append(logs, l)
+	}
+
+	return logs, nil
+}
+
+var auditStart = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// a router whose audit log holds a login, a profile update and a forced
+// logout an hour apart each
+func auditedRouter(t *testing.T) (http.Handler, *stubAuditRepo) {
+	t.Helper()
+
+	r, audit := newAuditedRouter(t, newStubRepo(testAdmin, testUser))
+	for i, l := range []entities.AuditLog{
+		{Actor: testUser.Email, Action: entities.AuditLogin, Target: testUser.Email},
+		{Actor: testUser.Email, Action: entities.AuditProfileUpdate, Target: testUser.Email},
+		{Actor: testAdmin.Email, Action: entities.AuditForceLogout, Target: testUser.Email},
+	} {
+		l.ID = int64(i + 1)
+		l.CreatedAt = entities.Timestamp{Time: auditStart.Add(time.Duration(i) * time.Hour)}
+		audit.logs = append(audit.logs, l)
+	}
+
+	return r, audit
+}
+
+func TestSearchAudit(t *testing.T) {
+	r, _ := auditedRouter(t)
+
+	for _, tc := range []struct {
+		query url.Values
+		want  []int64
+	}{
+		{url.Values{}, []int64{3, 2, 1}},
+		{url.Values{"action": {entities.AuditLogin}}, []int64{1}},
+		{url.Values{"actor": {testUser.Email}}, []int64{2, 1}},
+		// the bounds are inclusive
+		{url.Values{"from": {"2024-03-01T13:00:00Z"}, "to": {"2024-03-01T14:00:00Z"}}, []int64{3, 2}},
+		{url.Values{"action": {entities.AuditLogin}, "from": {"2024-03-01T13:00:00Z"}}, []int64{}},
+	} {
+		w := doRequest(t, r, http.MethodGet, "/api/audit?"+tc.query.Encode(), "", testAdmin)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d, want %d: %s", tc.query.Encode(), w.Code, http.StatusOK, w.Body)
+		}
+
+		var res struct {
+			Audit []entities.AuditLog `json:"audit"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+			t.Fatal(err)
+		}
+
+		var ids []int64
+		for _, l := range res.Audit {
+			ids = append(ids, l.ID)
+		}
+		if len(ids) != len(tc.want) {
+			t.Errorf("%s: ids = %v, want %v", tc.query.Encode(), ids, tc.want)
+			continue
+		}
+		for i := range ids {
+			if ids[i] != tc.want[i] {
+				t.Errorf("%s: ids = %v, want %v", tc.query.Encode(), ids, tc.want)
+				break
+			}
+		}
+	}
+}
+
+func TestSearchAuditCapsTheLimit(t *testing.T) {
+	r, audit := auditedRouter(t)
+
+	for _, tc := range []struct {
+		limit string
+		want  int
+	}{
+		{"", MaxPageSize},
+		{"2", 2},
+		{"100000", MaxPageSize},
+	} {
+		w := doRequest(t, r, http.MethodGet, "/api/audit?offset=1&limit="+tc.limit, "", testAdmin)
+		if w.Code != http.StatusOK {
+			t.Fatalf("limit %q: status = %d, want %d: %s", tc.limit, w.Code, http.StatusOK, w.Body)
+		}
+		if audit.searched.Limit != tc.want || audit.searched.Offset != 1 {
+			t.Errorf("limit %q: searched limit %d offset %d, want %d and 1", tc.limit, audit.searched.Limit, audit.searched.Offset, tc.want)
+		}
+	}
+}
+
+func TestSearchAuditRejects(t *testing.T) {
+	r, _ := auditedRouter(t)
+
+	for _, tc := range []struct {
+		query string
+		as    entities.UserResponse
+		want  int
+	}{
+		{"", testUser, http.StatusForbidden},
+		{"action=user.delete", testAdmin, http.StatusUnprocessableEntity},
+		{"from=yesterday", testAdmin, http.StatusBadRequest},
+		{"from=2024-03-02T00:00:00Z&to=2024-03-01T00:00:00Z", testAdmin, http.StatusBadRequest},
+		{"limit=-1", testAdmin, http.StatusUnprocessableEntity},
+	} {
+		if w := doRequest(t, r, http.MethodGet, "/api/audit?"+tc.query, "", tc.as); w.Code != tc.want {
+			t.Errorf("%q as %s: status = %d, want %d: %s", tc.query, tc.as.Role, w.Code, tc.want, w.Body)
+		}
+	}
+}
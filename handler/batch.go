@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// collects the outcome of every item of a batch request
+type batch struct {
+	results []entities.BatchResult
+}
+
+func newBatch(size int) *batch {
+	return &batch{results: make([]entities.BatchResult, 0, size)}
+}
+
+func (b *batch) ok(index int, id int64, status int) {
+	b.results = append(b.results, entities.BatchResult{Index: index, ID: id, Status: status})
+}
+
+func (b *batch) fail(index int, id int64, status int, msg string) {
+	b.results = append(b.results, entities.BatchResult{Index: index, ID: id, Status: status, Error: msg})
+}
+
+// left out on purpose, counts neither as success nor failure
+func (b *batch) skip(index int, status int, msg string) {
+	b.results = append(b.results, entities.BatchResult{Index: index, Status: status, Error: msg, Skipped: true})
+}
+
+// record err with the status a single item request would have answered
+func (b *batch) failErr(c *gin.Context, index int, id int64, err error) {
+	var dupErr *entities.DuplicateError
+	var valErrs validator.ValidationErrors
+
+	switch {
+	case errors.Is(err, entities.ErrNotFound):
+		b.fail(index, id, http.StatusNotFound, localize(c, entities.ItemNotFound))
+	case errors.Is(err, entities.ErrReferenced):
+		b.fail(index, id, http.StatusConflict, localize(c, entities.UserReferenced))
+	case errors.Is(err, entities.ErrLastAdmin):
+		b.fail(index, id, http.StatusConflict, localize(c, entities.LastAdmin))
+	case errors.As(err, &dupErr):
+		b.fail(index, id, http.StatusConflict, fmt.Sprintf(localize(c, entities.AlreadyExists), dupErr.Field))
+	case errors.As(err, &valErrs):
+		b.fail(index, id, http.StatusUnprocessableEntity, localize(c, entities.ValidationFailed))
+	default:
+		b.fail(index, id, http.StatusInternalServerError, localize(c, entities.InternalServer))
+	}
+}
+
+// 200 when every item succeeded, 207 otherwise
+func (b *batch) respond(c *gin.Context, message string) {
+	summary := entities.BatchSummary{Total: len(b.results)}
+	for _, r := range b.results {
+		switch {
+		case r.Skipped:
+			summary.Skipped++
+		case r.Error == "":
+			summary.Succeeded++
+		default:
+			summary.Failed++
+		}
+	}
+
+	status := http.StatusOK
+	if summary.Failed > 0 {
+		status = http.StatusMultiStatus
+	}
+
+	c.JSON(status, entities.BatchResponse{
+		Message: message,
+		Results: b.results,
+		Summary: summary,
+	})
+}
+
+// respond with 400 and report false if a batch of n items is empty or
+// larger than MaxPageSize
+func batchSize(c *gin.Context, n int) bool {
+	if n == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": localize(c, entities.BadRequest),
+		})
+		return false
+	}
+
+	if n > MaxPageSize {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message":   localize(c, entities.LimitTooLarge),
+			"max_limit": MaxPageSize,
+		})
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+	"github.com/ariopri/Let-It-Be/tree/main/backend/utils/token"
+	"github.com/gin-gonic/gin"
+)
+
+// time a user has to cancel a deletion request
+var DeletionGrace = time.Hour * 24 * 30
+
+// schedule deletion of the current user
+func (u *userHandler) requestDeletion(c *gin.Context) {
+	ctx := c.Request.Context()
+	user := c.MustGet("current_user").(entities.UserResponse)
+
+	if BeforeDelete != nil {
+		if err := BeforeDelete(ctx, user.ID); err != nil {
+			c.JSON(http.StatusConflict, gin.H{
+				"message": localize(c, entities.DeleteVetoed),
+				"reason":  err.Error(),
+			})
+			return
+		}
+	}
+
+	purgeAt := time.Now().Add(DeletionGrace)
+	if err := u.userRepo.RequestDeletion(ctx, user.ID, purgeAt); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": localize(c, entities.InternalServer),
+		})
+		return
+	}
+
+	// admins see pending purges before the user is gone
+	if !u.auditDeletion(c, entities.AuditDeletionRequest, user) {
+		return
+	}
+	notify(entities.EventDeletionRequested, gin.H{
+		"user_id":  user.ID,
+		"purge_at": entities.Timestamp{Time: purgeAt},
+	})
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":  "deletion scheduled",
+		"purge_at": entities.Timestamp{Time: purgeAt},
+	})
+}
+
+// cancel a pending deletion of the current user
+func (u *userHandler) cancelDeletion(c *gin.Context) {
+	user := c.MustGet("current_user").(entities.UserResponse)
+
+	ok, err := u.userRepo.CancelDeletion(c.Request.Context(), user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": localize(c, entities.InternalServer),
+		})
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": localize(c, entities.NoDeletionRequest),
+		})
+		return
+	}
+
+	if !u.auditDeletion(c, entities.AuditDeletionCancel, user) {
+		return
+	}
+	notify(entities.EventDeletionCancelled, gin.H{"user_id": user.ID})
+
+	c.JSON(http.StatusOK, entities.MessageResponse{
+		Message: "deletion cancelled",
+	})
+}
+
+// audit a deletion request or its cancellation like other admin visible
+// actions, answers 500 and reports false when it can't be written
+func (u *userHandler) auditDeletion(c *gin.Context, action string, user entities.UserResponse) bool {
+	claims := c.MustGet("user").(*token.Claims)
+
+	err := u.auditRepo.Create(c.Request.Context(), &entities.AuditLog{
+		Actor:  claims.Email,
+		Action: action,
+		Target: user.Email,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": localize(c, entities.InternalServer),
+		})
+		return false
+	}
+
+	return true
+}
+
+// delete users whose grace period ran out every interval, blocks until ctx
+// is done
+func PurgeDeletedUsers(ctx context.Context, userRepo entities.UserRepository, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			purgeDeleted(ctx, userRepo, now)
+		}
+	}
+}
+
+// delete the users due at now the way a delete request would, BeforeDelete
+// included. a vetoed or failing user is logged and left for the next run
+func purgeDeleted(ctx context.Context, userRepo entities.UserRepository, now time.Time) []int64 {
+	ids, err := userRepo.DueDeletions(ctx, now)
+	if err != nil {
+		log.Printf("purge: %v", err)
+		return nil
+	}
+
+	var purged []int64
+	for _, id := range ids {
+		if BeforeDelete != nil {
+			if err := BeforeDelete(ctx, id); err != nil {
+				log.Printf("purge: user %d vetoed: %v", id, err)
+				continue
+			}
+		}
+
+		if err := userRepo.Delete(ctx, id); err != nil {
+			log.Printf("purge: user %d: %v", id, err)
+			continue
+		}
+
+		notify(entities.EventUserDeleted, gin.H{"id": id})
+		purged = append(purged, id)
+	}
+
+	return purged
+}
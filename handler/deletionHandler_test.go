@@ -0,0 +1,166 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+)
+
+func (r *stubUserRepo) RequestDeletion(ctx context.Context, id int64, purgeAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.deletions == nil {
+		r.deletions = map[int64]time.Time{}
+	}
+	r.deletions[id] = purgeAt
+
+	return nil
+}
+
+func (r *stubUserRepo) CancelDeletion(ctx context.Context, id int64) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, ok := r.deletions[id]
+	delete(r.deletions, id)
+
+	return ok, nil
+}
+
+func (r *stubUserRepo) DueDeletions(ctx context.Context, now time.Time) ([]int64, error) {
+	return r.due, nil
+}
+
+func (r *stubUserRepo) Delete(ctx context.Context, id int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.deleteErrs[id]; err != nil {
+		return err
+	}
+	if _, ok := r.users[id]; !ok {
+		return entities.ErrNotFound
+	}
+
+	delete(r.users, id)
+
+	return nil
+}
+
+func TestPurgeContinuesPastFailures(t *testing.T) {
+	third := entities.UserResponse{ID: 3, Email: "third@example.com"}
+	repo := newStubRepo(testAdmin, testUser, third)
+	repo.due = []int64{1, 2, 3}
+	repo.deleteErrs = map[int64]error{1: entities.ErrReferenced}
+
+	purged := purgeDeleted(context.Background(), repo, time.Now())
+
+	if len(purged) != 2 || purged[0] != 2 || purged[1] != 3 {
+		t.Errorf("purged %v, want [2 3]", purged)
+	}
+	if _, ok := repo.users[1]; !ok {
+		t.Error("the failing user is gone")
+	}
+}
+
+func TestPurgeRunsBeforeDelete(t *testing.T) {
+	repo := newStubRepo(testAdmin, testUser)
+	repo.due = []int64{1, 2}
+
+	var asked []int64
+	BeforeDelete = func(ctx context.Context, id int64) error {
+		asked = append(asked, id)
+		if id == 1 {
+			return errors.New("owns resources")
+		}
+		return nil
+	}
+	t.Cleanup(func() { BeforeDelete = nil })
+
+	purged := purgeDeleted(context.Background(), repo, time.Now())
+
+	if len(asked) != 2 {
+		t.Errorf("BeforeDelete ran for %v, want both users", asked)
+	}
+	if len(purged) != 1 || purged[0] != 2 {
+		t.Errorf("purged %v, want [2]", purged)
+	}
+	if _, ok := repo.users[1]; !ok {
+		t.Error("the vetoed user was deleted")
+	}
+}
+
+func TestDeleteReferencedUser(t *testing.T) {
+	repo := newStubRepo(testAdmin, testUser)
+	repo.deleteErrs = map[int64]error{testUser.ID: entities.ErrReferenced}
+
+	w := doRequest(t, newTestRouter(t, repo), http.MethodDelete, "/api/users/2", "", testAdmin)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusConflict, w.Body)
+	}
+	if msg := decodeBody(t, w)["message"]; msg != entities.UserReferenced {
+		t.Errorf("message = %v, want %q", msg, entities.UserReferenced)
+	}
+	if _, ok := repo.users[testUser.ID]; !ok {
+		t.Error("the user is gone")
+	}
+}
+
+func TestDeletionRequestsAreAuditedAndNotified(t *testing.T) {
+	repo := newStubRepo(testAdmin, testUser)
+	r, audit := newAuditedRouter(t, repo)
+
+	events, cancel := eventHub.Subscribe()
+	defer cancel()
+
+	for _, tc := range []struct {
+		path   string
+		code   int
+		action string
+		event  string
+	}{
+		{"/api/me/delete-request", http.StatusAccepted, entities.AuditDeletionRequest, entities.EventDeletionRequested},
+		{"/api/me/delete-cancel", http.StatusOK, entities.AuditDeletionCancel, entities.EventDeletionCancelled},
+	} {
+		if w := doRequest(t, r, http.MethodPost, tc.path, "", testUser); w.Code != tc.code {
+			t.Fatalf("%s: status = %d, want %d: %s", tc.path, w.Code, tc.code, w.Body)
+		}
+
+		want := entities.AuditLog{Actor: testUser.Email, Action: tc.action, Target: testUser.Email}
+		if n := len(audit.logs); n == 0 || audit.logs[n-1] != want {
+			t.Errorf("%s: audit = %+v, want it to end with %+v", tc.path, audit.logs, want)
+		}
+
+		select {
+		case e := <-events:
+			if e.Type != tc.event {
+				t.Errorf("%s: event %q, want %q", tc.path, e.Type, tc.event)
+			}
+		default:
+			t.Errorf("%s: no event", tc.path)
+		}
+	}
+
+	// nothing pending, nothing to audit
+	n := len(audit.logs)
+	if w := doRequest(t, r, http.MethodPost, "/api/me/delete-cancel", "", testUser); w.Code != http.StatusNotFound {
+		t.Errorf("second cancel: status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+	if len(audit.logs) != n {
+		t.Errorf("a failed cancel was audited: %+v", audit.logs[n:])
+	}
+}
+
+func TestDeletionRequestFailsWithoutAudit(t *testing.T) {
+	r, audit := newAuditedRouter(t, newStubRepo(testAdmin, testUser))
+	audit.err = errors.New("audit table gone")
+
+	if w := doRequest(t, r, http.MethodPost, "/api/me/delete-request", "", testUser); w.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusInternalServerError)
+	}
+}
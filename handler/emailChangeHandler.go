@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+	"github.com/gin-gonic/gin"
+)
+
+// how long an email change or verification can be confirmed
+var EmailChangeTTL = time.Hour * 24
+
+// opened from the link sent to the new address
+const emailConfirmPath = "/email/confirm"
+
+// ask to change the current user's email. only the new address gets the
+// confirmation link, the old one stays in use until it's followed
+func (u *userHandler) changeEmail(c *gin.Context) {
+	ctx := c.Request.Context()
+	user := c.MustGet("current_user").(entities.UserResponse)
+
+	// nobody could ever confirm the change
+	if Mailer == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"message": localize(c, entities.MailUnavailable),
+		})
+		return
+	}
+
+	var body entities.EmailChange
+	if err := c.ShouldBindJSON(&body); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	confirmToken, err := newConfirmToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": localize(c, entities.InternalServer),
+		})
+		return
+	}
+	expires := time.Now().Add(EmailChangeTTL)
+
+	err = u.userRepo.RequestEmailChange(ctx, user.ID, body.Password, body.Email, hashToken(confirmToken), expires)
+	if errors.Is(err, entities.ErrWrongPassword) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": localize(c, entities.WrongPassword),
+		})
+		return
+	}
+	if conflict(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": localize(c, entities.InternalServer),
+		})
+		return
+	}
+
+	err = Mailer.Send(body.Email, "Confirm your new email address",
+		"Open this link to use this address for your account:\n\n"+confirmLink(confirmToken)+
+			"\n\nIt expires at "+expires.UTC().Format(time.RFC1123)+". Ignore this mail if you didn't ask for it.\n")
+	if err != nil {
+		log.Printf("mailing the email change of user %d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": localize(c, entities.InternalServer),
+		})
+		return
+	}
+
+	// without the token, receivers of events must not be able to confirm
+	notify(entities.EventEmailChangeRequested, gin.H{
+		"user_id":    user.ID,
+		"new_email":  body.Email,
+		"expires_at": entities.Timestamp{Time: expires},
+	})
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":    "verification sent to the new email",
+		"expires_at": entities.Timestamp{Time: expires},
+	})
+}
+
+// apply the email change the token was issued for
+func (u *userHandler) confirmEmail(c *gin.Context) {
+	confirmToken := c.Query("token")
+	if confirmToken == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": localize(c, entities.BadRequest),
+		})
+		return
+	}
+
+	user, err := u.userRepo.ConfirmEmailChange(c.Request.Context(), hashToken(confirmToken), time.Now())
+	if errors.Is(err, entities.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": localize(c, entities.ItemNotFound),
+		})
+		return
+	}
+	if conflict(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": localize(c, entities.InternalServer),
+		})
+		return
+	}
+
+	notify(entities.EventUserUpdated, user)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "email changed",
+		"data":    user,
+	})
+}
+
+// secret of a confirmation link
+func newConfirmToken() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(secret), nil
+}
+
+// link that confirms the email of confirmToken
+func confirmLink(confirmToken string) string {
+	return PublicURL + emailConfirmPath + "?" + url.Values{"token": {confirmToken}}.Encode()
+}
+
+// only hashes are stored so a database leak can't confirm changes
+func hashToken(t string) string {
+	sum := sha256.Sum256([]byte(t))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,221 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+)
+
+const testPassword = "secret-pass"
+
+type pendingEmail struct {
+	id    int64
+	email string
+}
+
+func (r *stubUserRepo) RequestEmailChange(ctx context.Context, id int64, password, newEmail, tokenHash string, expiresAt time.Time) error {
+	if password != testPassword {
+		return entities.ErrWrongPassword
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.pending == nil {
+		r.pending = map[string]pendingEmail{}
+	}
+	r.pending[tokenHash] = pendingEmail{id: id, email: newEmail}
+
+	return nil
+}
+
+func (r *stubUserRepo) ConfirmEmailChange(ctx context.Context, tokenHash string, now time.Time) (entities.UserResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p, ok := r.pending[tokenHash]
+	if !ok {
+		return entities.UserResponse{}, entities.ErrNotFound
+	}
+	delete(r.pending, tokenHash)
+
+	user := r.users[p.id]
+	if user.Email != p.email {
+		user.TokenVersion++
+	}
+	user.Email = p.email
+	user.EmailVerified = true
+	r.users[p.id] = user
+
+	return user, nil
+}
+
+type sentMail struct {
+	to, subject, body string
+}
+
+// records mails instead of sending them
+type stubMailer struct {
+	mu    sync.Mutex
+	mails []sentMail
+}
+
+func (m *stubMailer) Send(to, subject, body string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.mails = append(m.mails, sentMail{to, subject, body})
+
+	return nil
+}
+
+// install a stubMailer for the test
+func useStubMailer(t *testing.T) *stubMailer {
+	t.Helper()
+
+	m := &stubMailer{}
+	oldMailer, oldURL := Mailer, PublicURL
+	Mailer, PublicURL = m, "https://api.example.com"
+	t.Cleanup(func() { Mailer, PublicURL = oldMailer, oldURL })
+
+	return m
+}
+
+var linkPattern = regexp.MustCompile(`https://api\.example\.com(/\S+)`)
+
+func TestEmailChangePendingUntilConfirmed(t *testing.T) {
+	mailer := useStubMailer(t)
+	repo := newStubRepo(testUser)
+	r := newTestRouter(t, repo)
+
+	events, cancel := eventHub.Subscribe()
+	defer cancel()
+
+	body := `{"email":"new@example.com","password":"` + testPassword + `"}`
+	w := doRequest(t, r, http.MethodPut, "/api/me/email", body, testUser)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusAccepted, w.Body)
+	}
+
+	// pending, the old address is still in use
+	if u, _ := repo.FetchById(context.Background(), testUser.ID); u.Email != testUser.Email {
+		t.Fatalf("email changed to %q before it was confirmed", u.Email)
+	}
+
+	if len(mailer.mails) != 1 || mailer.mails[0].to != "new@example.com" {
+		t.Fatalf("mails = %+v, want one to the new address", mailer.mails)
+	}
+	m := linkPattern.FindStringSubmatch(mailer.mails[0].body)
+	if m == nil {
+		t.Fatalf("no confirmation link in %q", mailer.mails[0].body)
+	}
+	link, _ := url.Parse(m[1])
+	confirmToken := link.Query().Get("token")
+
+	select {
+	case e := <-events:
+		data, _ := json.Marshal(e)
+		if strings.Contains(string(data), confirmToken) {
+			t.Errorf("event %s carries the confirmation token", data)
+		}
+	default:
+		t.Error("no event for the email change request")
+	}
+
+	w = doRequest(t, r, http.MethodGet, link.RequestURI(), "", entities.UserResponse{})
+	if w.Code != http.StatusOK {
+		t.Fatalf("confirm status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	if u, _ := repo.FetchById(context.Background(), testUser.ID); u.Email != "new@example.com" || !u.EmailVerified {
+		t.Errorf("after confirming email = %q, verified %v", u.Email, u.EmailVerified)
+	}
+
+	// tokens work once
+	w = doRequest(t, r, http.MethodGet, link.RequestURI(), "", entities.UserResponse{})
+	if w.Code != http.StatusNotFound {
+		t.Errorf("second confirm status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+// the old address is free after a change, its tokens must not work for
+// the user nor for whoever registers it next
+func TestEmailChangeRevokesTokens(t *testing.T) {
+	mailer := useStubMailer(t)
+	repo := newStubRepo(testAdmin, testUser)
+	r := newTestRouter(t, repo)
+
+	oldToken := decodeBody(t, login(t, r, testUser, ""))["token"].(string)
+
+	body := `{"email":"new@example.com","password":"` + testPassword + `"}`
+	if w := doRequestToken(t, r, http.MethodPut, "/api/me/email", body, oldToken); w.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusAccepted, w.Body)
+	}
+	if w := doRequest(t, r, http.MethodGet, mailedLink(t, mailer, "new@example.com"), "", entities.UserResponse{}); w.Code != http.StatusOK {
+		t.Fatalf("confirm status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+
+	if w := doRequestToken(t, r, http.MethodGet, "/api/me/permissions", "", oldToken); w.Code != http.StatusUnauthorized {
+		t.Errorf("token from before the change: status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+
+	body = `{"first_name":"Nia","last_name":"New","email":"` + testUser.Email + `","password":"Str0ng-pass!"}`
+	if w := doRequest(t, r, http.MethodPost, "/register", body, entities.UserResponse{}); w.Code != http.StatusOK {
+		t.Fatalf("register status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	if w := doRequestToken(t, r, http.MethodGet, "/api/me/permissions", "", oldToken); w.Code != http.StatusUnauthorized {
+		t.Errorf("token from before the change after the email was registered again: status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+
+	// logging in again with the new address works
+	changed, _ := repo.FetchById(context.Background(), testUser.ID)
+	if w := doRequest(t, r, http.MethodGet, "/api/me/permissions", "", changed); w.Code != http.StatusOK {
+		t.Errorf("new token: status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+}
+
+func TestEmailChangeWrongPassword(t *testing.T) {
+	mailer := useStubMailer(t)
+	r := newTestRouter(t, newStubRepo(testUser))
+
+	w := doRequest(t, r, http.MethodPut, "/api/me/email", `{"email":"new@example.com","password":"wrong-pass"}`, testUser)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusForbidden, w.Body)
+	}
+	if len(mailer.mails) != 0 {
+		t.Error("mail sent for a wrong password")
+	}
+}
+
+func TestEmailChangeWithoutMailer(t *testing.T) {
+	r := newTestRouter(t, newStubRepo(testUser))
+
+	body := `{"email":"new@example.com","password":"` + testPassword + `"}`
+	w := doRequest(t, r, http.MethodPut, "/api/me/email", body, testUser)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusServiceUnavailable, w.Body)
+	}
+}
+
+func TestUpdateCannotChangeEmail(t *testing.T) {
+	for _, method := range []string{http.MethodPut, http.MethodPatch} {
+		repo := newStubRepo(testUser)
+		r := newTestRouter(t, repo)
+
+		body := `{"first_name":"Uma","last_name":"User","email":"other@example.com"}`
+		w := doRequest(t, r, method, "/api/users/2", body, testUser)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s status = %d, want %d: %s", method, w.Code, http.StatusOK, w.Body)
+		}
+		if repo.updated.Email != "" {
+			t.Errorf("%s passed email %q to the repository", method, repo.updated.Email)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+	"github.com/gin-gonic/gin"
+)
+
+// verification links that can be asked for per client and minute
+var VerificationRateLimit = 5
+
+// asks for a new verification link, e.g. after the first one expired
+const emailVerifyPath = "/email/verify"
+
+// mail user a link that marks their email verified, opened at
+// emailConfirmPath like an email change
+func (u *userHandler) sendVerification(ctx context.Context, user entities.UserResponse) error {
+	confirmToken, err := newConfirmToken()
+	if err != nil {
+		return err
+	}
+	expires := time.Now().Add(EmailChangeTTL)
+
+	if err := u.userRepo.RequestEmailVerification(ctx, user.ID, hashToken(confirmToken), expires); err != nil {
+		return err
+	}
+
+	return Mailer.Send(user.Email, "Verify your email address",
+		"Open this link to verify the email address of your account:\n\n"+confirmLink(confirmToken)+
+			"\n\nIt expires at "+expires.UTC().Format(time.RFC1123)+". Ignore this mail if you didn't sign up.\n")
+}
+
+// send another verification link. the answer is the same whether or not
+// the email belongs to an unverified user, so it can't probe for accounts
+func (u *userHandler) resendVerification(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	if Mailer == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"message": localize(c, entities.MailUnavailable),
+		})
+		return
+	}
+
+	var body entities.EmailVerification
+	if err := c.ShouldBindJSON(&body); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	user, err := u.userRepo.FetchByEmail(ctx, body.Email)
+	if err != nil && !errors.Is(err, entities.ErrNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": localize(c, entities.InternalServer),
+		})
+		return
+	}
+
+	if err == nil && !user.EmailVerified {
+		if err := u.sendVerification(ctx, user); err != nil {
+			log.Printf("mailing the verification of user %d: %v", user.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": localize(c, entities.InternalServer),
+			})
+			return
+		}
+	}
+
+	c.JSON(http.StatusAccepted, entities.MessageResponse{
+		Message: "a verification link is sent if the email needs one",
+	})
+}
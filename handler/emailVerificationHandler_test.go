@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+)
+
+func (r *stubUserRepo) RequestEmailVerification(ctx context.Context, id int64, tokenHash string, expiresAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.pending == nil {
+		r.pending = map[string]pendingEmail{}
+	}
+	r.pending[tokenHash] = pendingEmail{id: id, email: r.users[id].Email}
+
+	return nil
+}
+
+// the path of the single link mailed to to
+func mailedLink(t *testing.T, mailer *stubMailer, to string) string {
+	t.Helper()
+
+	if len(mailer.mails) != 1 || mailer.mails[0].to != to {
+		t.Fatalf("mails = %+v, want one to %s", mailer.mails, to)
+	}
+	m := linkPattern.FindStringSubmatch(mailer.mails[0].body)
+	if m == nil {
+		t.Fatalf("no link in %q", mailer.mails[0].body)
+	}
+
+	return m[1]
+}
+
+func TestRegisteredUserVerifiesThroughMailedLink(t *testing.T) {
+	mailer := useStubMailer(t)
+	RequireVerifiedEmail = true
+	t.Cleanup(func() { RequireVerifiedEmail = false })
+
+	repo := newStubRepo(testAdmin, testUser)
+	r := newTestRouter(t, repo)
+
+	body := `{"first_name":"Nia","last_name":"New","email":"new@example.com","password":"Str0ng-pass!"}`
+	w := doRequest(t, r, http.MethodPost, "/register", body, entities.UserResponse{})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusCreated, w.Body)
+	}
+
+	link := mailedLink(t, mailer, "new@example.com")
+	if u, _ := url.Parse(link); u.Path != emailConfirmPath {
+		t.Fatalf("link %q doesn't point at %s", link, emailConfirmPath)
+	}
+
+	w = doRequest(t, r, http.MethodGet, link, "", entities.UserResponse{})
+	if w.Code != http.StatusOK {
+		t.Fatalf("confirm status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+
+	u, _ := repo.FetchByEmail(context.Background(), "new@example.com")
+	if !u.EmailVerified {
+		t.Error("the email isn't verified after following the link")
+	}
+}
+
+func TestResendVerification(t *testing.T) {
+	unverified := entities.UserResponse{ID: 3, Email: "late@example.com", Role: "user", Active: true}
+
+	for _, tc := range []struct {
+		email  string
+		mailed bool
+	}{
+		{unverified.Email, true},
+		{testUser.Email, false},
+		{"nobody@example.com", false},
+	} {
+		mailer := useStubMailer(t)
+		repo := newStubRepo(testUser, unverified)
+
+		w := doRequest(t, newTestRouter(t, repo), http.MethodPost, emailVerifyPath, `{"email":"`+tc.email+`"}`, entities.UserResponse{})
+		if w.Code != http.StatusAccepted {
+			t.Errorf("%s: status = %d, want %d: %s", tc.email, w.Code, http.StatusAccepted, w.Body)
+		}
+
+		if tc.mailed {
+			mailedLink(t, mailer, tc.email)
+		} else if len(mailer.mails) != 0 {
+			t.Errorf("%s: mailed %+v", tc.email, mailer.mails)
+		}
+	}
+}
+
+func TestResendVerificationWithoutMailer(t *testing.T) {
+	w := doRequest(t, newTestRouter(t, newStubRepo()), http.MethodPost, emailVerifyPath, `{"email":"late@example.com"}`, entities.UserResponse{})
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+}
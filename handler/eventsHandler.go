@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+	"github.com/ariopri/Let-It-Be/tree/main/backend/utils/eventhub"
+	"github.com/gin-gonic/gin"
+)
+
+// gets every event published to Notifier, feeds /api/users/events
+var eventHub = eventhub.New(64)
+
+// interval of the comment lines that keep proxies from closing the stream
+var EventsHeartbeat = time.Second * 15
+
+// stream user lifecycle events as server-sent events until the client
+// goes away
+func (u *userHandler) events(c *gin.Context) {
+	// role check
+	if !isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": localize(c, entities.Forbidden),
+		})
+		return
+	}
+
+	events, cancel := eventHub.Subscribe()
+	defer cancel()
+
+	heartbeat := time.NewTicker(EventsHeartbeat)
+	defer heartbeat.Stop()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	// nginx buffers responses unless told not to
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case e := <-events:
+			c.SSEvent(e.Type, e)
+		case <-heartbeat.C:
+			if _, err := io.WriteString(w, ": heartbeat\n\n"); err != nil {
+				return false
+			}
+		}
+
+		return true
+	})
+}
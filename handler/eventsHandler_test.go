@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+)
+
+// connect to the event stream of srv as user, closed when the test ends
+func subscribeEvents(t *testing.T, srv *httptest.Server, user entities.UserResponse) *bufio.Reader {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	req := newRequest(t, http.MethodGet, srv.URL+"/api/users/events", "", user).WithContext(ctx)
+	req.RequestURI = ""
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { res.Body.Close() })
+
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want %d", res.StatusCode, http.StatusOK)
+	}
+	if ct := res.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q, want text/event-stream", ct)
+	}
+
+	return bufio.NewReader(res.Body)
+}
+
+// the next line of the stream that starts with prefix
+func readUntil(t *testing.T, stream *bufio.Reader, prefix string) string {
+	t.Helper()
+
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		for {
+			line, err := stream.ReadString('\n')
+			if err != nil {
+				return
+			}
+			if strings.HasPrefix(line, prefix) {
+				lines <- strings.TrimSpace(line)
+				return
+			}
+		}
+	}()
+
+	select {
+	case line, ok := <-lines:
+		if !ok {
+			t.Fatalf("the stream ended before a %q line", prefix)
+		}
+		return line
+	case <-time.After(2 * time.Second):
+		t.Fatalf("no %q line within 2s", prefix)
+	}
+
+	return ""
+}
+
+func TestCreatedUserIsStreamed(t *testing.T) {
+	r := newTestRouter(t, newStubRepo(testAdmin, testUser))
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+
+	stream := subscribeEvents(t, srv, testAdmin)
+
+	body := `{"first_name":"New","last_name":"User","email":"new@example.com","password":"` + testPassword + `"}`
+	if w := doRequest(t, r, http.MethodPost, "/api/users", body, testAdmin); w.Code != http.StatusOK {
+		t.Fatalf("create: status = %d: %s", w.Code, w.Body)
+	}
+
+	if line := readUntil(t, stream, "event:"); line != "event:"+entities.EventUserCreated {
+		t.Errorf("event line = %q, want %s", line, entities.EventUserCreated)
+	}
+
+	var e struct {
+		Type string                `json:"type"`
+		Data entities.UserResponse `json:"data"`
+	}
+	data := strings.TrimPrefix(readUntil(t, stream, "data:"), "data:")
+	if err := json.Unmarshal([]byte(data), &e); err != nil {
+		t.Fatalf("decoding %q: %v", data, err)
+	}
+	if e.Type != entities.EventUserCreated || e.Data.Email != "new@example.com" {
+		t.Errorf("event = %+v, want the created user", e)
+	}
+}
+
+func TestEventsHeartbeat(t *testing.T) {
+	EventsHeartbeat = 10 * time.Millisecond
+	t.Cleanup(func() { EventsHeartbeat = time.Second * 15 })
+
+	srv := httptest.NewServer(newTestRouter(t, newStubRepo(testAdmin)))
+	t.Cleanup(srv.Close)
+
+	if line := readUntil(t, subscribeEvents(t, srv, testAdmin), ":"); line != ": heartbeat" {
+		t.Errorf("line = %q, want a heartbeat comment", line)
+	}
+}
+
+func TestEventsNeedAdmin(t *testing.T) {
+	w := doRequest(t, newTestRouter(t, newStubRepo(testUser)), http.MethodGet, "/api/users/events", "", testUser)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+}
@@ -0,0 +1,141 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+)
+
+// the users by id
+func (r *stubUserRepo) Export(ctx context.Context, fn func(entities.UserResponse) error) error {
+	r.mu.Lock()
+	var users []entities.UserResponse
+	for _, u := range r.users {
+		users = append(users, u)
+	}
+	r.mu.Unlock()
+
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	for _, u := range users {
+		if err := fn(u); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func TestExportJSONLines(t *testing.T) {
+	w := doRequest(t, newTestRouter(t, newStubRepo(testAdmin, testUser)), http.MethodGet, "/api/users/export?format=jsonl", "", testAdmin)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	var users []entities.UserResponse
+	sc := bufio.NewScanner(w.Body)
+	for sc.Scan() {
+		var u entities.UserResponse
+		dec := json.NewDecoder(strings.NewReader(sc.Text()))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&u); err != nil {
+			t.Fatalf("line %q: %v", sc.Text(), err)
+		}
+		users = append(users, u)
+	}
+
+	if len(users) != 2 || users[0].Email != testAdmin.Email || users[1].Email != testUser.Email {
+		t.Errorf("exported %+v, want the admin and the user", users)
+	}
+}
+
+func TestExportCSV(t *testing.T) {
+	w := doRequest(t, newTestRouter(t, newStubRepo(testAdmin, testUser)), http.MethodGet, "/api/users/export", "", testAdmin)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+
+	records, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 || records[0][0] != "id" || records[2][3] != testUser.Email {
+		t.Errorf("records = %v, want a header and both users", records)
+	}
+}
+
+func TestExportRejects(t *testing.T) {
+	r := newTestRouter(t, newStubRepo(testAdmin, testUser))
+
+	if w := doRequest(t, r, http.MethodGet, "/api/users/export?format=xml", "", testAdmin); w.Code != http.StatusBadRequest {
+		t.Errorf("unknown format: status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	if w := doRequest(t, r, http.MethodGet, "/api/users/export?format=jsonl", "", testUser); w.Code != http.StatusForbidden {
+		t.Errorf("non admin: status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+}
+
+// logs with email as actor or target, newest first
+func (a *stubAuditRepo) ByUser(ctx context.Context, email string, limit, offset int) ([]entities.AuditLog, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	logs := []entities.AuditLog{}
+	for i := len(a.logs) - 1; i >= 0; i-- {
+		if l := a.logs[i]; l.Actor == email || l.Target == email {
+			logs = append(logs, l)
+		}
+	}
+
+	return logs, nil
+}
+
+func TestExportMeBundle(t *testing.T) {
+	repo := newStubRepo(testAdmin, testUser)
+	repo.profiles = map[int64]entities.Profile{testUser.ID: {UserID: testUser.ID, DisplayName: "Uma"}}
+	r, _ := newAuditedRouter(t, repo)
+
+	if w := login(t, r, testUser, ""); w.Code != http.StatusOK {
+		t.Fatalf("login: status = %d: %s", w.Code, w.Body)
+	}
+	login(t, r, testAdmin, "")
+
+	w := doRequest(t, r, http.MethodGet, "/api/me/export", "", testUser)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	if cd := w.Header().Get("Content-Disposition"); !strings.Contains(cd, "attachment") {
+		t.Errorf("Content-Disposition = %q, want an attachment", cd)
+	}
+	for _, secret := range []string{"password", "token_version"} {
+		if strings.Contains(w.Body.String(), secret) {
+			t.Errorf("bundle contains %s: %s", secret, w.Body)
+		}
+	}
+
+	var bundle struct {
+		User       map[string]interface{}   `json:"user"`
+		Profile    map[string]interface{}   `json:"profile"`
+		Audit      []map[string]interface{} `json:"audit"`
+		ExportedAt string                   `json:"exported_at"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &bundle); err != nil {
+		t.Fatal(err)
+	}
+	if bundle.User["email"] != testUser.Email || bundle.Profile["display_name"] != "Uma" || bundle.ExportedAt == "" {
+		t.Errorf("bundle = %+v", bundle)
+	}
+	// only the user's own entries
+	if len(bundle.Audit) != 1 || bundle.Audit[0]["actor"] != testUser.Email || bundle.Audit[0]["action"] != entities.AuditLogin {
+		t.Errorf("audit = %v, want the user's login only", bundle.Audit)
+	}
+}
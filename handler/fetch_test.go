@@ -0,0 +1,203 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+)
+
+// the users by id, with the filter's tag and paged by its limit and offset
+func (r *stubUserRepo) Fetch(ctx context.Context, f *entities.UserFilter) ([]entities.UserResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	copied := *f
+	r.fetched = &copied
+
+	users := []entities.UserResponse{}
+	for _, u := range r.users {
+		if f.Tag != "" && !r.tags[u.ID][f.Tag] {
+			continue
+		}
+		users = append(users, u)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+
+	if f.Offset >= len(users) {
+		return []entities.UserResponse{}, nil
+	}
+	users = users[f.Offset:]
+	if f.Limit > 0 && f.Limit < len(users) {
+		users = users[:f.Limit]
+	}
+
+	return users, nil
+}
+
+func (r *stubUserRepo) Count(ctx context.Context, f *entities.UserFilter, mode string) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return int64(len(r.users)), nil
+}
+
+func (r *stubUserRepo) FetchByIDs(ctx context.Context, ids []int64) ([]entities.UserResponse, []int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	users := []entities.UserResponse{}
+	missing := []int64{}
+	for _, id := range ids {
+		if u, ok := r.users[id]; ok {
+			users = append(users, u)
+		} else {
+			missing = append(missing, id)
+		}
+	}
+
+	return users, missing, nil
+}
+
+func TestFetchWithoutUsersListsNone(t *testing.T) {
+	r := newTestRouter(t, newStubRepo(testAdmin))
+
+	// nobody has the tag
+	for _, path := range []string{"/api/users?tag=none", "/api/users?tag=none&fields=id,email"} {
+		w := doRequest(t, r, http.MethodGet, path, "", testAdmin)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d, want %d: %s", path, w.Code, http.StatusOK, w.Body)
+		}
+
+		if users, ok := decodeBody(t, w)["users"].([]interface{}); !ok || len(users) != 0 {
+			t.Errorf("%s: body = %s, want users as an empty array", path, w.Body)
+		}
+		if meta, ok := decodeBody(t, w)["meta"].(map[string]interface{}); !ok || meta["count"] != float64(0) {
+			t.Errorf("%s: meta = %v, want a count of 0", path, meta)
+		}
+	}
+}
+
+func TestFetchCapsThePageSize(t *testing.T) {
+	for limit, want := range map[string]int{"": MaxPageSize, "1000000": MaxPageSize, "10": 10} {
+		repo := newStubRepo(testAdmin)
+
+		w := doRequest(t, newTestRouter(t, repo), http.MethodGet, "/api/users?limit="+limit, "", testAdmin)
+		if w.Code != http.StatusOK {
+			t.Fatalf("limit %q: status = %d, want %d: %s", limit, w.Code, http.StatusOK, w.Body)
+		}
+		if repo.fetched.Limit != want {
+			t.Errorf("limit %q: fetched %d, want %d", limit, repo.fetched.Limit, want)
+		}
+	}
+}
+
+func TestFetchStrictPagination(t *testing.T) {
+	StrictPagination = true
+	t.Cleanup(func() { StrictPagination = false })
+
+	repo := newStubRepo(testAdmin)
+	r := newTestRouter(t, repo)
+
+	w := doRequest(t, r, http.MethodGet, "/api/users?limit=1000", "", testAdmin)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	if max := decodeBody(t, w)["max_limit"]; max != float64(MaxPageSize) {
+		t.Errorf("max_limit = %v, want %d", max, MaxPageSize)
+	}
+	if repo.fetched != nil {
+		t.Error("an oversized page was fetched")
+	}
+
+	if w := doRequest(t, r, http.MethodGet, "/api/users?limit=100", "", testAdmin); w.Code != http.StatusOK {
+		t.Errorf("the maximum itself: status = %d, want %d", w.Code, http.StatusOK)
+	}
+}
+
+func TestFetchByIDs(t *testing.T) {
+	r := newTestRouter(t, newStubRepo(testAdmin, testUser))
+
+	w := doRequest(t, r, http.MethodGet, "/api/users?ids=2,99,1", "", testAdmin)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+
+	body := decodeBody(t, w)
+	users := body["users"].([]interface{})
+	if len(users) != 2 ||
+		users[0].(map[string]interface{})["id"] != float64(testUser.ID) ||
+		users[1].(map[string]interface{})["id"] != float64(testAdmin.ID) {
+		t.Errorf("users = %v, want 2 and 1 in request order", users)
+	}
+	if missing := body["missing"].([]interface{}); len(missing) != 1 || missing[0] != float64(99) {
+		t.Errorf("missing = %v, want [99]", missing)
+	}
+
+	if w := doRequest(t, r, http.MethodGet, "/api/users?ids=1,x", "", testAdmin); w.Code != http.StatusBadRequest {
+		t.Errorf("malformed id: status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	if w := doRequest(t, r, http.MethodGet, "/api/users?ids=1,2", "", testUser); w.Code != http.StatusUnauthorized {
+		t.Errorf("as a user: status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestFetchCreatedBounds(t *testing.T) {
+	repo := newStubRepo(testAdmin)
+	r := newTestRouter(t, repo)
+
+	w := doRequest(t, r, http.MethodGet, "/api/users?created_after=2024-01-01T00:00:00Z&created_before=2024-02-01T12:00:00%2B07:00", "", testAdmin)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	after := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	before := time.Date(2024, 2, 1, 5, 0, 0, 0, time.UTC)
+	if !repo.fetched.CreatedAfter.Equal(after) || !repo.fetched.CreatedBefore.Equal(before) {
+		t.Errorf("fetched between %v and %v, want %v and %v", repo.fetched.CreatedAfter, repo.fetched.CreatedBefore, after, before)
+	}
+
+	for _, query := range []string{"created_after=yesterday", "created_before=2024-01-01", "created_after=2024-13-01T00:00:00Z"} {
+		if w := doRequest(t, r, http.MethodGet, "/api/users?"+query, "", testAdmin); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want %d", query, w.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestFetchSearch(t *testing.T) {
+	repo := newStubRepo(testAdmin)
+	r := newTestRouter(t, repo)
+
+	w := doRequest(t, r, http.MethodGet, "/api/users?search=50%25+Ann", "", testAdmin)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	if repo.fetched.Search != "50% Ann" {
+		t.Errorf("fetched with search %q, want %q", repo.fetched.Search, "50% Ann")
+	}
+}
+
+func TestFetchByRoles(t *testing.T) {
+	repo := newStubRepo(testAdmin)
+	r := newTestRouter(t, repo)
+
+	for _, role := range []string{"admin", "admin,user"} {
+		w := doRequest(t, r, http.MethodGet, "/api/users?role="+role, "", testAdmin)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d, want %d: %s", role, w.Code, http.StatusOK, w.Body)
+		}
+		if repo.fetched.Role != role {
+			t.Errorf("fetched with role %q, want %q", repo.fetched.Role, role)
+		}
+	}
+
+	w := doRequest(t, r, http.MethodGet, "/api/users?role=admin,root", "", testAdmin)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("unknown role: status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	if res := decodeBody(t, w); res["role"] != "root" {
+		t.Errorf("body = %v, want the unknown role named", res)
+	}
+}
@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fields clients may select with ?fields=
+var userFields = map[string]bool{
+	"id":             true,
+	"first_name":     true,
+	"last_name":      true,
+	"email":          true,
+	"role":           true,
+	"active":         true,
+	"email_verified": true,
+	"created_at":     true,
+	"updated_at":     true,
+}
+
+// parse ?fields=id,email, nil means every field
+func parseFields(c *gin.Context) ([]string, error) {
+	q := c.Query("fields")
+	if q == "" {
+		return nil, nil
+	}
+
+	fields := []string{}
+	for _, f := range strings.Split(q, ",") {
+		f = strings.TrimSpace(f)
+		if !userFields[f] {
+			return nil, errors.New("unknown field " + f)
+		}
+
+		fields = append(fields, f)
+	}
+
+	return fields, nil
+}
+
+// keep only the selected json keys of v, a struct or a slice of structs
+func pickFields(v interface{}, fields []string) (interface{}, error) {
+	if fields == nil {
+		return v, nil
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if string(b) == "null" {
+		return v, nil
+	}
+
+	pick := func(m map[string]interface{}) map[string]interface{} {
+		out := map[string]interface{}{}
+		for _, f := range fields {
+			if val, ok := m[f]; ok {
+				out[f] = val
+			}
+		}
+
+		return out
+	}
+
+	if strings.HasPrefix(string(b), "[") {
+		var list []map[string]interface{}
+		if err := json.Unmarshal(b, &list); err != nil {
+			return nil, err
+		}
+
+		picked := make([]map[string]interface{}, 0, len(list))
+		for _, m := range list {
+			picked = append(picked, pick(m))
+		}
+
+		return picked, nil
+	}
+
+	var m map[string]interface{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+
+	return pick(m), nil
+}
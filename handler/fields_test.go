@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"net/http"
+	"testing"
+)
+
+// the keys of a decoded user
+func keys(v interface{}) map[string]bool {
+	out := map[string]bool{}
+	for k := range v.(map[string]interface{}) {
+		out[k] = true
+	}
+
+	return out
+}
+
+func TestSparseFieldsets(t *testing.T) {
+	r := newTestRouter(t, newStubRepo(testAdmin, testUser))
+
+	w := doRequest(t, r, http.MethodGet, "/api/users?fields=id,email", "", testAdmin)
+	if w.Code != http.StatusOK {
+		t.Fatalf("list: status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	users := decodeBody(t, w)["users"].([]interface{})
+	if len(users) != 2 {
+		t.Fatalf("users = %v, want both", users)
+	}
+	for _, u := range users {
+		if k := keys(u); len(k) != 2 || !k["id"] || !k["email"] {
+			t.Errorf("list user = %v, want only id and email", u)
+		}
+	}
+
+	w = doRequest(t, r, http.MethodGet, "/api/users/2?fields=first_name,%20role", "", testAdmin)
+	if w.Code != http.StatusOK {
+		t.Fatalf("single: status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	if k := keys(decodeBody(t, w)["user"]); len(k) != 2 || !k["first_name"] || !k["role"] {
+		t.Errorf("single user keys = %v, want first_name and role", k)
+	}
+
+	for _, path := range []string{"/api/users?fields=id,password", "/api/users/2?fields=token_version", "/api/users?fields=id,"} {
+		if w := doRequest(t, r, http.MethodGet, path, "", testAdmin); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want %d", path, w.Code, http.StatusBadRequest)
+		}
+	}
+}
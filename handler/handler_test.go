@@ -0,0 +1,305 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+	"github.com/ariopri/Let-It-Be/tree/main/backend/utils/token"
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// in memory UserRepository for handler tests. methods a test needs and
+// that aren't implemented here panic through the nil embedded interface
+type stubUserRepo struct {
+	entities.UserRepository
+
+	mu    sync.Mutex
+	users map[int64]entities.UserResponse
+
+	// arguments of the last Update
+	updated *entities.User
+	// returned by Update instead of storing the user
+	updateErr error
+
+	// email changes by token hash
+	pending map[string]pendingEmail
+
+	// batches passed to UpdateRoles, and its error
+	roleBatches [][]entities.RoleAssignment
+	rolesErr    error
+
+	// role changes UpdateRoles made
+	history []entities.RoleChange
+
+	// stored password policy, nil for the default
+	policy *entities.PasswordPolicy
+
+	// tags by user id
+	tags map[int64]map[string]bool
+
+	// profiles by user id
+	profiles map[int64]entities.Profile
+
+	// stored preferences by user id
+	prefs map[int64]json.RawMessage
+	// api key hash per user id
+	apiKeys map[int64]string
+
+	// filter of the last Fetch
+	fetched *entities.UserFilter
+
+	// returned by Register instead of storing the user
+	registerErr error
+
+	// returned by UpdateStatus instead of storing the status
+	statusErr error
+
+	// ids DueDeletions returns, and Delete's error by id
+	due        []int64
+	deleteErrs map[int64]error
+	// purge dates of requested deletions by user id
+	deletions map[int64]time.Time
+}
+
+func newStubRepo(users ...entities.UserResponse) *stubUserRepo {
+	repo := &stubUserRepo{users: map[int64]entities.UserResponse{}}
+	for _, u := range users {
+		repo.users[u.ID] = u
+	}
+
+	return repo
+}
+
+func (r *stubUserRepo) FetchById(ctx context.Context, id int64) (entities.UserResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	u, ok := r.users[id]
+	if !ok {
+		return entities.UserResponse{}, entities.ErrNotFound
+	}
+
+	return u, nil
+}
+
+func (r *stubUserRepo) FetchByEmail(ctx context.Context, email string) (entities.UserResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, u := range r.users {
+		if u.Email == email {
+			return u, nil
+		}
+	}
+
+	return entities.UserResponse{}, entities.ErrNotFound
+}
+
+func (r *stubUserRepo) Update(ctx context.Context, id int64, u *entities.User) (entities.UserResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.updated = u
+	if r.updateErr != nil {
+		return entities.UserResponse{}, r.updateErr
+	}
+
+	res := r.users[id]
+	res.FirstName = u.FirstName
+	res.LastName = u.LastName
+	r.users[id] = res
+
+	return res, nil
+}
+
+// accepts testPassword for every user
+func (r *stubUserRepo) Login(ctx context.Context, l *entities.Login) (entities.UserResponse, error) {
+	u, err := r.FetchByEmail(ctx, l.Email)
+	if err != nil {
+		return entities.UserResponse{}, err
+	}
+	if l.Password != testPassword {
+		return entities.UserResponse{}, errors.New("wrong password")
+	}
+	if !u.Active {
+		return entities.UserResponse{}, entities.ErrUserDisabled
+	}
+
+	return u, nil
+}
+
+func (r *stubUserRepo) UpdateStatus(ctx context.Context, id int64, active bool) (entities.UserResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.statusErr != nil {
+		return entities.UserResponse{}, r.statusErr
+	}
+	u, ok := r.users[id]
+	if !ok {
+		return entities.UserResponse{}, entities.ErrNotFound
+	}
+	u.Active = active
+	r.users[id] = u
+
+	return u, nil
+}
+
+func (r *stubUserRepo) Register(ctx context.Context, u *entities.User) (entities.UserResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.registerErr != nil {
+		return entities.UserResponse{}, r.registerErr
+	}
+	// the unique email constraint
+	for _, existing := range r.users {
+		if existing.Email == u.Email {
+			return entities.UserResponse{}, &entities.DuplicateError{Field: "email"}
+		}
+	}
+
+	res := entities.UserResponse{
+		ID:        int64(len(r.users) + 1),
+		FirstName: u.FirstName,
+		LastName:  u.LastName,
+		Email:     u.Email,
+		Role:      "user",
+		Active:    true,
+	}
+	r.users[res.ID] = res
+
+	return res, nil
+}
+
+// records the audit logs handlers write
+type stubAuditRepo struct {
+	entities.AuditRepository
+
+	mu   sync.Mutex
+	logs []entities.AuditLog
+	// returned by Create instead of recording the log
+	err error
+
+	// filter of the last Search
+	searched *entities.AuditFilter
+}
+
+func (a *stubAuditRepo) Create(ctx context.Context, l *entities.AuditLog) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.err != nil {
+		return a.err
+	}
+	a.logs = append(a.logs, *l)
+
+	return nil
+}
+
+var (
+	testAdmin = entities.UserResponse{ID: 1, FirstName: "Ada", LastName: "Admin", Email: "admin@example.com", Role: "admin", Active: true, EmailVerified: true}
+	testUser  = entities.UserResponse{ID: 2, FirstName: "Uma", LastName: "User", Email: "user@example.com", Role: "user", Active: true, EmailVerified: true}
+)
+
+func newTestRouter(t *testing.T, repo entities.UserRepository) *gin.Engine {
+	t.Helper()
+
+	r, _ := newAuditedRouter(t, repo)
+
+	return r
+}
+
+// a router and the audit repository it writes to
+func newAuditedRouter(t *testing.T, repo entities.UserRepository) (*gin.Engine, *stubAuditRepo) {
+	t.Helper()
+
+	audit := &stubAuditRepo{}
+	r := gin.New()
+	if err := NewUserHandler(r, repo, audit); err != nil {
+		t.Fatal(err)
+	}
+
+	return r, audit
+}
+
+// send a request as user, a zero user sends none
+func doRequest(t *testing.T, h http.Handler, method, path, body string, user entities.UserResponse) *httptest.ResponseRecorder {
+	t.Helper()
+
+	return serve(h, newRequest(t, method, path, body, user))
+}
+
+// send a request with tokenStr as bearer token, an empty one sends none
+func doRequestToken(t *testing.T, h http.Handler, method, path, body, tokenStr string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if tokenStr != "" {
+		req.Header.Set("Authorization", "Bearer "+tokenStr)
+	}
+
+	return serve(h, req)
+}
+
+// a request as user for tests that need to add headers, see doRequest
+func newRequest(t *testing.T, method, path, body string, user entities.UserResponse) *http.Request {
+	t.Helper()
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if user.Email != "" {
+		tokenStr, _, err := token.CreateToken(user.ID, user.Email, user.Role, user.TokenVersion, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+tokenStr)
+	}
+
+	return req
+}
+
+func serve(h http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	return w
+}
+
+// log in as user with testPassword
+func login(t *testing.T, h http.Handler, user entities.UserResponse, extra string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	body := `{"email":"` + user.Email + `","password":"` + testPassword + `"` + extra + `}`
+
+	return doRequest(t, h, http.MethodPost, "/login", body, entities.UserResponse{})
+}
+
+// decode a json response body into a map
+func decodeBody(t *testing.T, w *httptest.ResponseRecorder) map[string]interface{} {
+	t.Helper()
+
+	var body map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decoding %q: %v", w.Body.String(), err)
+	}
+
+	return body
+}
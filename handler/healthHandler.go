@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type healthHandler struct {
+	version   string
+	commit    string
+	startedAt time.Time
+	deps      map[string]string
+}
+
+// routes, version and commit are injected at build time through ldflags
+func NewHealthHandler(r *gin.Engine, version, commit string) {
+	handler := &healthHandler{
+		version:   version,
+		commit:    commit,
+		startedAt: time.Now(),
+		deps:      map[string]string{},
+	}
+
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, d := range info.Deps {
+			handler.deps[d.Path] = d.Version
+		}
+	}
+
+	r.GET("/health", handler.health)
+}
+
+// health
+func (h *healthHandler) health(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"message":      "ok",
+		"version":      h.version,
+		"commit":       h.commit,
+		"go_version":   runtime.Version(),
+		"started_at":   h.startedAt.UTC().Format(time.RFC3339),
+		"uptime":       time.Since(h.startedAt).Round(time.Second).String(),
+		"dependencies": h.deps,
+	})
+}
@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"net/http"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+	"github.com/gin-gonic/gin"
+)
+
+func TestHealthReportsBuildInfo(t *testing.T) {
+	r := gin.New()
+	NewHealthHandler(r, "v1.2.3", "abc123")
+
+	w := doRequest(t, r, http.MethodGet, "/health", "", entities.UserResponse{})
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	body := decodeBody(t, w)
+	for field, want := range map[string]string{
+		"version":    "v1.2.3",
+		"commit":     "abc123",
+		"go_version": runtime.Version(),
+	} {
+		if body[field] != want {
+			t.Errorf("%s = %v, want %q", field, body[field], want)
+		}
+	}
+
+	started, _ := body["started_at"].(string)
+	if _, err := time.Parse(time.RFC3339, started); err != nil {
+		t.Errorf("started_at = %q: %v", started, err)
+	}
+	uptime, _ := body["uptime"].(string)
+	if _, err := time.ParseDuration(uptime); err != nil {
+		t.Errorf("uptime = %q: %v", uptime, err)
+	}
+	if _, ok := body["dependencies"].(map[string]interface{}); !ok {
+		t.Errorf("dependencies = %v, want an object", body["dependencies"])
+	}
+}
@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+	"github.com/ariopri/Let-It-Be/tree/main/backend/utils/token"
+)
+
+func TestImpersonation(t *testing.T) {
+	r, audit := newAuditedRouter(t, newStubRepo(testAdmin, testUser))
+
+	w := doRequest(t, r, http.MethodPost, "/api/users/2/impersonate", "", testAdmin)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+
+	var res entities.LoginResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if res.ImpersonatedBy != testAdmin.Email {
+		t.Errorf("impersonated_by = %q, want %q", res.ImpersonatedBy, testAdmin.Email)
+	}
+
+	claims, err := token.ValidateToken(res.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Email != testUser.Email || claims.ImpersonatedBy != testAdmin.Email {
+		t.Errorf("claims for %q by %q, want %q by %q", claims.Email, claims.ImpersonatedBy, testUser.Email, testAdmin.Email)
+	}
+	if ttl := time.Until(res.ExpiresAt.Time); ttl > token.ImpersonationTTL {
+		t.Errorf("impersonation token lives %v, more than %v", ttl, token.ImpersonationTTL)
+	}
+
+	// an impersonation can't start another one
+	if w := doRequestToken(t, r, http.MethodPost, "/api/users/1/impersonate", "", res.Token); w.Code != http.StatusForbidden {
+		t.Errorf("nested impersonation status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+
+	w = doRequestToken(t, r, http.MethodDelete, "/api/users/2/impersonate", "", res.Token)
+	if w.Code != http.StatusOK {
+		t.Fatalf("stop status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	var stopped entities.LoginResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &stopped); err != nil {
+		t.Fatal(err)
+	}
+	if claims, err := token.ValidateToken(stopped.Token); err != nil || claims.Email != testAdmin.Email || claims.ImpersonatedBy != "" {
+		t.Errorf("stopping returned claims %+v (%v), want the admin's own", claims, err)
+	}
+
+	want := []entities.AuditLog{
+		{Actor: testAdmin.Email, Action: entities.AuditImpersonateStart, Target: testUser.Email},
+		{Actor: testAdmin.Email, Action: entities.AuditImpersonateStop, Target: testUser.Email},
+	}
+	if len(audit.logs) != len(want) {
+		t.Fatalf("audit logs = %+v, want %+v", audit.logs, want)
+	}
+	for i := range want {
+		if audit.logs[i] != want[i] {
+			t.Errorf("audit log %d = %+v, want %+v", i, audit.logs[i], want[i])
+		}
+	}
+}
+
+func TestImpersonationNeedsAdmin(t *testing.T) {
+	r := newTestRouter(t, newStubRepo(testAdmin, testUser))
+
+	if w := doRequest(t, r, http.MethodPost, "/api/users/1/impersonate", "", testUser); w.Code != http.StatusForbidden {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+}
@@ -0,0 +1,164 @@
+package handler
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// most data rows a single import may hold
+var MaxImportRows = 1000
+
+// columns an import needs, in any order. others are ignored
+var importColumns = []string{"first_name", "last_name", "email", "password"}
+
+// create users from a csv upload, either a multipart "file" field or a
+// text/csv body. ?on_conflict=skip passes over existing emails, fail (the
+// default) reports them as failed rows. rows succeed or fail on their own
+func (u *userHandler) importUsers(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	// role check
+	if !isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": localize(c, entities.Forbidden),
+		})
+		return
+	}
+
+	onConflict := c.DefaultQuery("on_conflict", "fail")
+	if onConflict != "skip" && onConflict != "fail" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": localize(c, entities.BadRequest),
+		})
+		return
+	}
+
+	body, err := importBody(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": localize(c, entities.BadRequest),
+		})
+		return
+	}
+	defer body.Close()
+
+	r := csv.NewReader(body)
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": localize(c, entities.BadRequest),
+		})
+		return
+	}
+
+	col := map[string]int{}
+	for i, name := range header {
+		col[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range importColumns {
+		if _, ok := col[name]; !ok {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": localize(c, entities.BadRequest),
+				"missing": name,
+			})
+			return
+		}
+	}
+
+	var rows [][]string
+	for {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": localize(c, entities.BadRequest),
+				"error":   err.Error(),
+			})
+			return
+		}
+		if len(rows) == MaxImportRows {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message":   localize(c, entities.LimitTooLarge),
+				"max_limit": MaxImportRows,
+			})
+			return
+		}
+
+		rows = append(rows, row)
+	}
+	if len(rows) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": localize(c, entities.BadRequest),
+		})
+		return
+	}
+
+	b := newBatch(len(rows))
+	for i, row := range rows {
+		field := func(name string) string {
+			if j := col[name]; j < len(row) {
+				return row[j]
+			}
+			return ""
+		}
+
+		user := entities.User{
+			FirstName: field("first_name"),
+			LastName:  field("last_name"),
+			Email:     field("email"),
+			Password:  field("password"),
+		}
+		if err := binding.Validator.ValidateStruct(&user); err != nil {
+			b.failErr(c, i, 0, err)
+			continue
+		}
+
+		if isBreached(ctx, user.Password) {
+			b.fail(i, 0, http.StatusUnprocessableEntity, localize(c, entities.PasswordBreached))
+			continue
+		}
+
+		userData, err := u.userRepo.Create(ctx, &user)
+		var dupErr *entities.DuplicateError
+		if errors.As(err, &dupErr) && onConflict == "skip" {
+			b.skip(i, http.StatusConflict, fmt.Sprintf(localize(c, entities.AlreadyExists), dupErr.Field))
+			continue
+		}
+		if err != nil {
+			b.failErr(c, i, 0, err)
+			continue
+		}
+
+		notify(entities.EventUserCreated, userData)
+		b.ok(i, userData.ID, http.StatusCreated)
+	}
+
+	b.respond(c, "users imported")
+}
+
+// the uploaded file or the raw body
+func importBody(c *gin.Context) (io.ReadCloser, error) {
+	switch c.ContentType() {
+	case binding.MIMEMultipartPOSTForm:
+		fh, err := c.FormFile("file")
+		if err != nil {
+			return nil, err
+		}
+		return fh.Open()
+	case "text/csv":
+		return c.Request.Body, nil
+	}
+
+	return nil, errors.New("import must be multipart/form-data or text/csv")
+}
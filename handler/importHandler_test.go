@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+)
+
+// a valid row, a row with a bad email, an existing user and a repeat of
+// the first row, with the columns out of order and an extra one
+const importCSV = `email,first_name,last_name,password,note
+new@example.com,Nia,New,Str0ng-pass!,hi
+not-an-email,Bad,Row,Str0ng-pass!,
+user@example.com,Uma,User,Str0ng-pass!,
+new@example.com,Nia,Again,Str0ng-pass!,
+`
+
+// post body to the import route as an admin
+func importUsers(t *testing.T, repo *stubUserRepo, query, contentType string, body []byte) (int, entities.BatchResponse) {
+	t.Helper()
+
+	req := newRequest(t, http.MethodPost, "/api/users/import"+query, "", testAdmin)
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+	req.Header.Set("Content-Type", contentType)
+	w := serve(newTestRouter(t, repo), req)
+
+	var res entities.BatchResponse
+	if w.Code == http.StatusOK || w.Code == http.StatusMultiStatus {
+		if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+			t.Fatalf("decoding %q: %v", w.Body, err)
+		}
+	}
+
+	return w.Code, res
+}
+
+func TestImportFailsOnConflict(t *testing.T) {
+	repo := newStubRepo(testAdmin, testUser)
+
+	code, res := importUsers(t, repo, "", "text/csv", []byte(importCSV))
+	if code != http.StatusMultiStatus {
+		t.Fatalf("status = %d, want %d", code, http.StatusMultiStatus)
+	}
+
+	want := []int{http.StatusCreated, http.StatusUnprocessableEntity, http.StatusConflict, http.StatusConflict}
+	if got := statuses(res); !equalInts(got, want) {
+		t.Errorf("statuses = %v, want %v", got, want)
+	}
+	if s := res.Summary; s.Total != 4 || s.Succeeded != 1 || s.Failed != 3 || s.Skipped != 0 {
+		t.Errorf("summary = %+v", s)
+	}
+	if len(repo.users) != 3 {
+		t.Errorf("%d users stored, want the one valid row added", len(repo.users))
+	}
+}
+
+func TestImportSkipsConflicts(t *testing.T) {
+	code, res := importUsers(t, newStubRepo(testAdmin, testUser), "?on_conflict=skip", "text/csv", []byte(importCSV))
+	if code != http.StatusMultiStatus {
+		t.Fatalf("status = %d, want %d", code, http.StatusMultiStatus)
+	}
+
+	if s := res.Summary; s.Succeeded != 1 || s.Failed != 1 || s.Skipped != 2 {
+		t.Errorf("summary = %+v, want the duplicates skipped and the bad row failed", s)
+	}
+	for _, i := range []int{2, 3} {
+		if r := res.Results[i]; !r.Skipped || r.Status != http.StatusConflict {
+			t.Errorf("row %d = %+v, want it skipped as a conflict", i, r)
+		}
+	}
+}
+
+func TestImportMultipart(t *testing.T) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, err := mw.CreateFormFile("file", "users.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write([]byte("first_name,last_name,email,password\nNia,New,new@example.com,Str0ng-pass!\n"))
+	mw.Close()
+
+	code, res := importUsers(t, newStubRepo(testAdmin), "", mw.FormDataContentType(), body.Bytes())
+	if code != http.StatusOK || res.Summary.Succeeded != 1 {
+		t.Errorf("status = %d summary %+v, want the row imported", code, res.Summary)
+	}
+}
+
+func TestImportRejects(t *testing.T) {
+	MaxImportRows = 2
+	t.Cleanup(func() { MaxImportRows = 1000 })
+
+	for _, tc := range []struct {
+		name, query, contentType, body string
+	}{
+		{"missing column", "", "text/csv", "first_name,last_name,email\nNia,New,new@example.com\n"},
+		{"no rows", "", "text/csv", "first_name,last_name,email,password\n"},
+		{"too many rows", "", "text/csv", "first_name,last_name,email,password\na,b,a@example.com,p\nc,d,c@example.com,p\ne,f,e@example.com,p\n"},
+		{"json", "", "application/json", `[{"email":"new@example.com"}]`},
+		{"unknown on_conflict", "?on_conflict=overwrite", "text/csv", importCSV},
+	} {
+		if code, _ := importUsers(t, newStubRepo(testAdmin), tc.query, tc.contentType, []byte(tc.body)); code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want %d", tc.name, code, http.StatusBadRequest)
+		}
+	}
+
+	req := newRequest(t, http.MethodPost, "/api/users/import", "", testUser)
+	req.Body = io.NopCloser(strings.NewReader(importCSV))
+	req.Header.Set("Content-Type", "text/csv")
+	if w := serve(newTestRouter(t, newStubRepo(testUser)), req); w.Code != http.StatusForbidden {
+		t.Errorf("non admin: status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+}
@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestMergePatch(t *testing.T) {
+	// from the examples of RFC 7386
+	for _, tc := range []struct{ target, patch, want string }{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	} {
+		var target, patch interface{}
+		json.Unmarshal([]byte(tc.target), &target)
+		json.Unmarshal([]byte(tc.patch), &patch)
+
+		got, _ := json.Marshal(mergePatch(target, patch))
+		if string(got) != tc.want {
+			t.Errorf("%s patched with %s = %s, want %s", tc.target, tc.patch, got, tc.want)
+		}
+	}
+}
+
+func TestPatchUserWithMergePatch(t *testing.T) {
+	repo := newStubRepo(testUser)
+	r := newTestRouter(t, repo)
+
+	patch := func(body string) int {
+		req := newRequest(t, http.MethodPatch, "/api/users/2", body, testUser)
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+
+		return serve(r, req).Code
+	}
+
+	// change one member, the others come from the stored user
+	if code := patch(`{"first_name":"Changed"}`); code != http.StatusOK {
+		t.Fatalf("change: status = %d, want %d", code, http.StatusOK)
+	}
+	if repo.updated.FirstName != "Changed" || repo.updated.LastName != testUser.LastName || repo.updated.Password != "" {
+		t.Errorf("change: updated %+v", repo.updated)
+	}
+
+	// add a member the document didn't have
+	if code := patch(`{"password":"` + testPassword + `"}`); code != http.StatusOK {
+		t.Fatalf("add: status = %d, want %d", code, http.StatusOK)
+	}
+	if repo.updated.Password != testPassword {
+		t.Errorf("add: password %q, want it set", repo.updated.Password)
+	}
+
+	// deleting a required member fails validation
+	if code := patch(`{"last_name":null}`); code != http.StatusUnprocessableEntity {
+		t.Errorf("delete: status = %d, want %d", code, http.StatusUnprocessableEntity)
+	}
+}
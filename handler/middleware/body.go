@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+	"github.com/gin-gonic/gin"
+)
+
+// reject bodies larger than n bytes with 413. bodies without a length are
+// cut off at n and fail to bind
+func (m *middleware) LimitBody(n int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.ContentLength > n {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+				"message": localize(c, entities.BodyTooLarge),
+			})
+			c.Abort()
+			return
+		}
+
+		if c.Request.Body != nil {
+			c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, n)
+		}
+
+		c.Next()
+	}
+}
+
+// read the body for inspection, at most max bytes. answers 413 for larger
+// bodies, also when an earlier LimitBody cut it off, and 400 for unreadable
+// ones, then aborts and returns false. the body is put back to be read again
+func readBody(c *gin.Context, max int64) ([]byte, bool) {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, max+1))
+
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) || int64(len(body)) > max {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"message": localize(c, entities.BodyTooLarge),
+		})
+		c.Abort()
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": localize(c, entities.BadRequest),
+		})
+		c.Abort()
+		return nil, false
+	}
+
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	return body, true
+}
@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestLimitBody(t *testing.T) {
+	r := echoRouter(InitMiddleware().LimitBody(8))
+
+	for _, tc := range []struct {
+		name          string
+		body          string
+		contentLength int64
+		want          int
+		echo          string
+	}{
+		{"small", "12345678", 8, http.StatusOK, "12345678"},
+		{"declared too large", "123456789", 9, http.StatusRequestEntityTooLarge, ""},
+		// without a length the body is cut off at the limit
+		{"chunked", strings.Repeat("x", 64), -1, http.StatusOK, "xxxxxxxx"},
+	} {
+		w := postJSON(r, tc.body, tc.contentLength)
+		if w.Code != tc.want {
+			t.Errorf("%s: status = %d, want %d", tc.name, w.Code, tc.want)
+		}
+		if tc.echo != "" && w.Body.String() != tc.echo {
+			t.Errorf("%s: handler read %q, want %q", tc.name, w.Body, tc.echo)
+		}
+	}
+}
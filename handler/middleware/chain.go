@@ -0,0 +1,17 @@
+package middleware
+
+import "github.com/gin-gonic/gin"
+
+// the handlers in the order they run, for one Use or Group call so the
+// order is visible in one place. nil entries are dropped, which lets
+// optional middleware be written inline
+func Chain(handlers ...gin.HandlerFunc) gin.HandlersChain {
+	chain := make(gin.HandlersChain, 0, len(handlers))
+	for _, h := range handlers {
+		if h != nil {
+			chain = append(chain, h)
+		}
+	}
+
+	return chain
+}
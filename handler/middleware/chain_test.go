@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestChainRunsInOrder(t *testing.T) {
+	var ran []string
+	step := func(name string) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			ran = append(ran, name)
+			c.Next()
+			ran = append(ran, "/"+name)
+		}
+	}
+
+	r := gin.New()
+	r.Use(Chain(step("recovery"), nil, step("logger"))...)
+	api := r.Group("/api", Chain(step("request id"), nil, step("auth"))...)
+	api.GET("/", func(c *gin.Context) {
+		ran = append(ran, "handler")
+		c.Status(http.StatusOK)
+	})
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/", nil))
+
+	want := []string{"recovery", "logger", "request id", "auth", "handler", "/auth", "/request id", "/logger", "/recovery"}
+	if !reflect.DeepEqual(ran, want) {
+		t.Errorf("ran %q, want %q", ran, want)
+	}
+}
+
+func TestChainDropsNil(t *testing.T) {
+	if got := Chain(nil, nil); len(got) != 0 {
+		t.Errorf("Chain(nil, nil) has %d handlers, want 0", len(got))
+	}
+}
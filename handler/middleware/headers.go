@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+	"github.com/gin-gonic/gin"
+)
+
+// reject requests with more than maxCount header fields or more than
+// maxBytes of them with 431. the server's MaxHeaderBytes cuts off anything
+// far larger before it gets here
+func (m *middleware) LimitHeaders(maxCount, maxBytes int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		count, size := 0, 0
+		for k, vs := range c.Request.Header {
+			for _, v := range vs {
+				count++
+				size += len(k) + len(v)
+			}
+		}
+
+		if count > maxCount || size > maxBytes {
+			c.JSON(http.StatusRequestHeaderFieldsTooLarge, gin.H{
+				"message": localize(c, entities.HeadersTooLarge),
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+	"github.com/gin-gonic/gin"
+)
+
+func TestLimitHeaders(t *testing.T) {
+	r := gin.New()
+	r.Use(InitMiddleware().LimitHeaders(10, 1024))
+	r.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	for name, set := range map[string]func(h http.Header){
+		"within limits": func(h http.Header) { h.Set("X-Small", "value") },
+		"too many": func(h http.Header) {
+			for i := 0; i < 11; i++ {
+				h.Add(fmt.Sprintf("X-Header-%d", i), "v")
+			}
+		},
+		"repeated field": func(h http.Header) {
+			for i := 0; i < 11; i++ {
+				h.Add("X-Same", "v")
+			}
+		},
+		"too large": func(h http.Header) { h.Set("X-Large", strings.Repeat("a", 1025)) },
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		set(req.Header)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		want := http.StatusRequestHeaderFieldsTooLarge
+		if name == "within limits" {
+			want = http.StatusOK
+		}
+		if w.Code != want {
+			t.Errorf("%s: status = %d, want %d", name, w.Code, want)
+		}
+		if want != http.StatusOK && !strings.Contains(w.Body.String(), entities.HeadersTooLarge) {
+			t.Errorf("%s: body = %s, want %q", name, w.Body, entities.HeadersTooLarge)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+	"github.com/gin-gonic/gin"
+)
+
+// send plaintext requests to https with 308, or reject them with 403 when
+// redirect is off. X-Forwarded-Proto is only believed from the trusted
+// proxies, a TLS terminating load balancer has to be one of them
+func (m *middleware) RequireHTTPS(redirect bool, trustedProxies []*net.IPNet) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if isHTTPS(c, trustedProxies) {
+			c.Next()
+			return
+		}
+
+		if redirect {
+			c.Redirect(http.StatusPermanentRedirect, "https://"+c.Request.Host+c.Request.URL.RequestURI())
+			c.Abort()
+			return
+		}
+
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": localize(c, entities.HTTPSRequired),
+		})
+		c.Abort()
+	}
+}
+
+func isHTTPS(c *gin.Context, trustedProxies []*net.IPNet) bool {
+	if c.Request.TLS != nil {
+		return true
+	}
+
+	proto := c.GetHeader("X-Forwarded-Proto")
+	if proto == "" {
+		return false
+	}
+
+	host, _, err := net.SplitHostPort(c.Request.RemoteAddr)
+	if err != nil {
+		host = c.Request.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+
+	for _, n := range trustedProxies {
+		if n.Contains(ip) {
+			// proxies chaining the header append to it, the first is the client's
+			first := strings.TrimSpace(strings.Split(proto, ",")[0])
+			return strings.EqualFold(first, "https")
+		}
+	}
+
+	return false
+}
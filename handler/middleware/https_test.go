@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// a router requiring https on GET /path that trusts 10.0.0.0/8
+func httpsRouter(redirect bool) *gin.Engine {
+	_, proxies, _ := net.ParseCIDR("10.0.0.0/8")
+
+	r := gin.New()
+	r.GET("/path", InitMiddleware().RequireHTTPS(redirect, []*net.IPNet{proxies}), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	return r
+}
+
+func TestRequireHTTPSRedirects(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "http://api.example.com/path?page=2", nil)
+	w := httptest.NewRecorder()
+	httpsRouter(true).ServeHTTP(w, req)
+
+	if w.Code != http.StatusPermanentRedirect {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusPermanentRedirect)
+	}
+	if loc := w.Header().Get("Location"); loc != "https://api.example.com/path?page=2" {
+		t.Errorf("Location = %q, want the https url", loc)
+	}
+}
+
+func TestRequireHTTPSRejects(t *testing.T) {
+	w := httptest.NewRecorder()
+	httpsRouter(false).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/path", nil))
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+}
+
+func TestRequireHTTPSForwardedProto(t *testing.T) {
+	r := httpsRouter(false)
+
+	for _, tc := range []struct {
+		name   string
+		remote string
+		proto  string
+		tls    bool
+		want   int
+	}{
+		{"direct tls", "203.0.113.7:1234", "", true, http.StatusOK},
+		{"trusted proxy", "10.1.2.3:1234", "https", false, http.StatusOK},
+		{"trusted proxy, chained", "10.1.2.3:1234", "HTTPS, http", false, http.StatusOK},
+		{"trusted proxy, plain", "10.1.2.3:1234", "http", false, http.StatusForbidden},
+		{"untrusted client", "203.0.113.7:1234", "https", false, http.StatusForbidden},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/path", nil)
+		req.RemoteAddr = tc.remote
+		if tc.proto != "" {
+			req.Header.Set("X-Forwarded-Proto", tc.proto)
+		}
+		if tc.tls {
+			req.TLS = &tls.ConnectionState{}
+		}
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tc.want {
+			t.Errorf("%s: status = %d, want %d", tc.name, w.Code, tc.want)
+		}
+	}
+}
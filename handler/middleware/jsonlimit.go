@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+	"github.com/gin-gonic/gin"
+)
+
+var errJSONTooComplex = errors.New("json too complex")
+
+// reject json write bodies larger than maxBytes with 413, nested deeper
+// than maxDepth or with an object or array of more than maxElements
+// members with 400. 0 disables the depth and member limits. a LimitBody
+// before it with a lower limit keeps it from reading more
+func (m *middleware) LimitJSON(maxBytes int64, maxDepth, maxElements int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch:
+		default:
+			c.Next()
+			return
+		}
+
+		if c.Request.Body == nil || !strings.HasSuffix(c.ContentType(), "json") {
+			c.Next()
+			return
+		}
+
+		body, ok := readBody(c, maxBytes)
+		if !ok {
+			return
+		}
+
+		// malformed bodies are left to the handler's binding
+		if err := checkJSON(body, maxDepth, maxElements); errors.Is(err, errJSONTooComplex) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": localize(c, entities.JSONTooComplex),
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+type jsonFrame struct {
+	object    bool
+	expectKey bool
+	members   int
+}
+
+// walk the tokens without building the value
+func checkJSON(body []byte, maxDepth, maxElements int) error {
+	dec := json.NewDecoder(bytes.NewReader(body))
+
+	var open []*jsonFrame
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if tok == json.Delim('}') || tok == json.Delim(']') {
+			open = open[:len(open)-1]
+			continue
+		}
+
+		if len(open) > 0 {
+			f := open[len(open)-1]
+			if f.object {
+				// members are counted by their key, the value follows it
+				f.expectKey = !f.expectKey
+				if !f.expectKey {
+					f.members++
+				}
+			} else {
+				f.members++
+			}
+			if maxElements > 0 && f.members > maxElements {
+				return errJSONTooComplex
+			}
+		}
+
+		if tok == json.Delim('{') || tok == json.Delim('[') {
+			open = append(open, &jsonFrame{object: tok == json.Delim('{'), expectKey: true})
+			if maxDepth > 0 && len(open) > maxDepth {
+				return errJSONTooComplex
+			}
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// a router answering POST /echo with the body it got after handlers ran
+func echoRouter(handlers ...gin.HandlerFunc) *gin.Engine {
+	r := gin.New()
+	r.POST("/echo", append(handlers, func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		c.String(http.StatusOK, "%s", body)
+	})...)
+
+	return r
+}
+
+func postJSON(h http.Handler, body string, contentLength int64) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/echo", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.ContentLength = contentLength
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	return w
+}
+
+func TestLimitJSON(t *testing.T) {
+	m := InitMiddleware()
+	r := echoRouter(m.LimitJSON(64, 3, 4))
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"within limits", `{"a":[1,2],"b":{"c":1}}`, http.StatusOK},
+		{"too deep", `{"a":[[{"b":1}]]}`, http.StatusBadRequest},
+		{"too many members", `[1,2,3,4,5]`, http.StatusBadRequest},
+		{"too large", `{"a":"` + strings.Repeat("x", 64) + `"}`, http.StatusRequestEntityTooLarge},
+		{"malformed is left to binding", `{"a":`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := postJSON(r, tt.body, int64(len(tt.body)))
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+			if tt.want == http.StatusOK && w.Body.String() != tt.body {
+				t.Errorf("handler read %q, want the original body", w.Body)
+			}
+		})
+	}
+}
+
+func TestLimitJSONAfterLimitBody(t *testing.T) {
+	m := InitMiddleware()
+	r := echoRouter(m.LimitBody(16), m.LimitJSON(1<<20, 0, 0))
+
+	// no length, only the reader can stop it
+	body := `{"password":"` + strings.Repeat("x", 100) + `"}`
+	if w := postJSON(r, body, -1); w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusRequestEntityTooLarge)
+	}
+}
@@ -0,0 +1,18 @@
+package middleware
+
+import (
+	"github.com/ariopri/Let-It-Be/tree/main/backend/utils/i18n"
+	"github.com/gin-gonic/gin"
+)
+
+// announce the locale messages are translated to, the same one localize
+// picks from Accept-Language, so clients know which language errors are in
+func (m *middleware) ContentLanguage() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Content-Language", i18n.Locale(c.GetHeader("Accept-Language")))
+		// responses differ by Accept-Language, caches must keep them apart
+		c.Writer.Header().Add("Vary", "Accept-Language")
+
+		c.Next()
+	}
+}
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/utils/jsonschema"
+)
+
+func TestContentLanguageMatchesTheErrors(t *testing.T) {
+	m := InitMiddleware()
+	r := echoRouter(m.ContentLanguage(), m.ValidateSchema(testSchema, 1<<10))
+
+	for _, tc := range []struct {
+		acceptLanguage string
+		locale         string
+		message        string
+		required       string
+	}{
+		{"", "en", "validation failed", jsonschema.MsgRequired},
+		{"id-ID,en;q=0.8", "id", "validasi gagal", "wajib diisi"},
+		{"de, fr", "en", "validation failed", jsonschema.MsgRequired},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/echo", strings.NewReader(`{}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept-Language", tc.acceptLanguage)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("%q: status = %d, want %d: %s", tc.acceptLanguage, w.Code, http.StatusUnprocessableEntity, w.Body)
+		}
+		if got := w.Header().Get("Content-Language"); got != tc.locale {
+			t.Errorf("%q: Content-Language = %q, want %q", tc.acceptLanguage, got, tc.locale)
+		}
+		if got := w.Header().Get("Vary"); !strings.Contains(got, "Accept-Language") {
+			t.Errorf("%q: Vary = %q, want Accept-Language", tc.acceptLanguage, got)
+		}
+
+		var body struct {
+			Message string             `json:"message"`
+			Errors  []jsonschema.Error `json:"errors"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		if body.Message != tc.message {
+			t.Errorf("%q: message = %q, want %q", tc.acceptLanguage, body.Message, tc.message)
+		}
+		if len(body.Errors) != 1 || body.Errors[0].Message != tc.required {
+			t.Errorf("%q: errors = %+v, want /email %q", tc.acceptLanguage, body.Errors, tc.required)
+		}
+	}
+}
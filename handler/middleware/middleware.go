@@ -1,22 +1,45 @@
 package middleware
 
 import (
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+	"github.com/ariopri/Let-It-Be/tree/main/backend/utils/breaker"
+	"github.com/ariopri/Let-It-Be/tree/main/backend/utils/i18n"
 	"github.com/ariopri/Let-It-Be/tree/main/backend/utils/token"
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 )
 
 type middleware struct{}
 
-func (m *middleware) CORS() gin.HandlerFunc {
+// origins may contain "*" to allow any origin. a CORS on a route or group
+// replaces the global one, register an OPTIONS route answered by Preflight
+// next to it so preflights reach it too
+func (m *middleware) CORS(origins []string) gin.HandlerFunc {
+	allowed := map[string]bool{}
+	for _, o := range origins {
+		allowed[strings.TrimSpace(o)] = true
+	}
+
 	return func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
+		c.Writer.Header().Del("Access-Control-Allow-Origin")
+		if allowed["*"] {
+			c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
+		} else if origin := c.Request.Header.Get("Origin"); allowed[origin] {
+			c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
+			c.Writer.Header().Set("Vary", "Origin")
+		}
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
 		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, PATCH, DELETE")
 
-		if c.Request.Method == "OPTIONS" {
+		// preflights of routes with their own OPTIONS route go on to it
+		if c.Request.Method == "OPTIONS" && c.FullPath() == "" {
 			c.AbortWithStatus(http.StatusNoContent)
 			return
 		}
@@ -26,12 +49,69 @@ func (m *middleware) CORS() gin.HandlerFunc {
 
 }
 
+// answers preflights for routes with their own CORS
+func Preflight(c *gin.Context) {
+	c.Status(http.StatusNoContent)
+}
+
+// authenticate requests carrying an api key instead of a token, they get
+// claims of the key's owner. requests without one are left to
+// JWTMiddleware, which must follow
+func (m *middleware) APIKey(userRepo entities.UserRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(token.APIKeyHeader)
+		if key == "" || c.GetHeader("Authorization") != "" {
+			c.Next()
+			return
+		}
+
+		user, err := userRepo.FetchByAPIKey(c.Request.Context(), token.HashAPIKey(key))
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"message": localize(c, entities.Unauthorized),
+			})
+			c.Abort()
+			return
+		}
+
+		c.Set("user", &token.Claims{
+			UserID:       user.ID,
+			Email:        user.Email,
+			Role:         user.Role,
+			TokenVersion: user.TokenVersion,
+		})
+		c.Set("api_key", true)
+
+		c.Next()
+	}
+}
+
 func (m *middleware) JWTMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		tokenStr := c.Request.Header.Get("Authorization")
+		// authenticated by APIKey already
+		if _, ok := c.Get("user"); ok {
+			c.Next()
+			return
+		}
+
+		var tokenStr string
+		if header := c.Request.Header.Get("Authorization"); header != "" {
+			var err error
+			if tokenStr, err = token.FromHeader(header); err != nil {
+				c.Header("WWW-Authenticate", token.TokenType)
+				c.JSON(http.StatusUnauthorized, gin.H{
+					"message": localize(c, entities.InvalidAuthScheme),
+				})
+				c.Abort()
+				return
+			}
+		} else {
+			// browser clients may send the token as a cookie instead
+			tokenStr, _ = c.Cookie(token.CookieName)
+		}
 		if tokenStr == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{
-				"message": "Unauthorized",
+				"message": localize(c, entities.Unauthorized),
 			})
 			c.Abort()
 			return
@@ -40,18 +120,179 @@ func (m *middleware) JWTMiddleware() gin.HandlerFunc {
 		claims, err := token.ValidateToken(tokenStr)
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{
-				"message": "Unauthorized",
+				"message": localize(c, entities.Unauthorized),
+			})
+			c.Abort()
+			return
+		}
+
+		// bound tokens only work for the client they were issued to
+		if token.BindFingerprint && claims.Fingerprint != "" &&
+			claims.Fingerprint != token.Fingerprint(c.Request.UserAgent(), c.ClientIP()) {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"message": localize(c, entities.Unauthorized),
 			})
 			c.Abort()
 			return
 		}
 
+		// mark responses served with an impersonation token
+		if claims.ImpersonatedBy != "" {
+			c.Header("X-Impersonated-By", claims.ImpersonatedBy)
+		}
+
 		c.Set("user", claims)
 
 		c.Next()
 	}
 }
 
+// load the token's user and reject revoked tokens, with checkActive also
+// tokens of disabled users. must run after JWTMiddleware
+func (m *middleware) CurrentUser(userRepo entities.UserRepository, checkActive bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims := c.MustGet("user").(*token.Claims)
+
+		user, err := userRepo.FetchByEmail(c.Request.Context(), claims.Email)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"message": localize(c, entities.Unauthorized),
+			})
+			c.Abort()
+			return
+		}
+
+		// bumped on force logout and email changes. a token of another
+		// user, who had the email before, is revoked as well
+		if claims.UserID != user.ID || claims.TokenVersion != user.TokenVersion {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"message": localize(c, entities.TokenRevoked),
+			})
+			c.Abort()
+			return
+		}
+
+		if checkActive && !user.Active {
+			c.JSON(http.StatusForbidden, gin.H{
+				"message": localize(c, entities.UserDisabled),
+			})
+			c.Abort()
+			return
+		}
+
+		c.Set("current_user", user)
+
+		c.Next()
+	}
+}
+
+// require a permission of the token's role, must run after JWTMiddleware
+func (m *middleware) RequirePermission(perm string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims := c.MustGet("user").(*token.Claims)
+
+		if !entities.HasPermission(claims.Role, perm) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"message": localize(c, entities.Forbidden),
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// content type of json merge patch bodies
+const MIMEMergePatch = "application/merge-patch+json"
+
+// allow the request when the id in paramName is the current user's own or
+// the token's role is one of roles. must run after CurrentUser
+func (m *middleware) RequireSelfOrRole(paramName string, roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := c.MustGet("current_user").(entities.UserResponse)
+		if c.Param(paramName) == strconv.FormatInt(user.ID, 10) {
+			c.Next()
+			return
+		}
+
+		claims := c.MustGet("user").(*token.Claims)
+		for _, role := range roles {
+			if claims.Role == role {
+				c.Next()
+				return
+			}
+		}
+
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": localize(c, entities.Forbidden),
+		})
+		c.Abort()
+	}
+}
+
+// reject write requests whose body isn't json
+func (m *middleware) RequireJSON() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch:
+		default:
+			c.Next()
+			return
+		}
+
+		// bodyless requests are left to the handler
+		if c.Request.ContentLength == 0 {
+			c.Next()
+			return
+		}
+
+		if ct := c.ContentType(); ct != binding.MIMEJSON && ct != MIMEMergePatch {
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{
+				"message": localize(c, entities.UnsupportedMediaType),
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// fail fast with 503 while the breaker is open
+func (m *middleware) CircuitBreaker(b *breaker.Breaker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !b.Allow() {
+			SetRetryAfter(c, b.RetryAfter())
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"message": localize(c, entities.ServiceUnavailable),
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+
+		// a probe that never reached the database must not block others
+		b.Release()
+	}
+}
+
+// set Retry-After in whole seconds, at least one
+func SetRetryAfter(c *gin.Context, d time.Duration) {
+	secs := int64(math.Ceil(d.Seconds()))
+	if secs < 1 {
+		secs = 1
+	}
+
+	c.Header("Retry-After", strconv.FormatInt(secs, 10))
+}
+
+// translate msg to the language the client asked for
+func localize(c *gin.Context, msg string) string {
+	return i18n.Translate(c.GetHeader("Accept-Language"), msg)
+}
+
 func InitMiddleware() *middleware {
 	return &middleware{}
 }
@@ -0,0 +1,174 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+	"github.com/ariopri/Let-It-Be/tree/main/backend/utils/breaker"
+	"github.com/ariopri/Let-It-Be/tree/main/backend/utils/token"
+	"github.com/gin-gonic/gin"
+)
+
+// sets the claims JWTMiddleware would for a token of role
+func asRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set("user", &token.Claims{Email: role + "@example.com", Role: role})
+	}
+}
+
+// status of a GET / through handlers
+func get(handlers ...gin.HandlerFunc) int {
+	r := gin.New()
+	r.GET("/", append(handlers, func(c *gin.Context) { c.Status(http.StatusOK) })...)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	return w.Code
+}
+
+func TestRequirePermission(t *testing.T) {
+	m := InitMiddleware()
+
+	if code := get(asRole("admin"), m.RequirePermission(entities.PermUsersDelete)); code != http.StatusOK {
+		t.Errorf("admin: status = %d, want %d", code, http.StatusOK)
+	}
+	if code := get(asRole("user"), m.RequirePermission(entities.PermUsersDelete)); code != http.StatusForbidden {
+		t.Errorf("user: status = %d, want %d", code, http.StatusForbidden)
+	}
+	if code := get(asRole("user"), m.RequirePermission(entities.PermUsersRead)); code != http.StatusOK {
+		t.Errorf("user reading: status = %d, want %d", code, http.StatusOK)
+	}
+}
+
+func TestRequireJSON(t *testing.T) {
+	r := gin.New()
+	r.Use(InitMiddleware().RequireJSON())
+	r.Any("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	for _, tc := range []struct {
+		method, contentType, body string
+		want                      int
+	}{
+		{http.MethodPost, "text/plain", "hello", http.StatusUnsupportedMediaType},
+		{http.MethodPut, "application/x-www-form-urlencoded", "a=b", http.StatusUnsupportedMediaType},
+		{http.MethodPatch, "", "{}", http.StatusUnsupportedMediaType},
+		{http.MethodPost, "application/json", "{}", http.StatusOK},
+		{http.MethodPost, "application/json; charset=utf-8", "{}", http.StatusOK},
+		{http.MethodPatch, MIMEMergePatch, "{}", http.StatusOK},
+		{http.MethodPost, "text/plain", "", http.StatusOK},
+		{http.MethodDelete, "text/plain", "hello", http.StatusOK},
+	} {
+		req := httptest.NewRequest(tc.method, "/", strings.NewReader(tc.body))
+		if tc.contentType != "" {
+			req.Header.Set("Content-Type", tc.contentType)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != tc.want {
+			t.Errorf("%s %q: status = %d, want %d", tc.method, tc.contentType, w.Code, tc.want)
+		}
+	}
+}
+
+func TestCircuitBreakerFailsFast(t *testing.T) {
+	m := InitMiddleware()
+	b := breaker.New(1, time.Minute)
+
+	if code := get(m.CircuitBreaker(b)); code != http.StatusOK {
+		t.Fatalf("closed: status = %d, want %d", code, http.StatusOK)
+	}
+
+	b.Failure()
+
+	r := gin.New()
+	r.GET("/", m.CircuitBreaker(b), func(c *gin.Context) { t.Error("handler ran while open") })
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("open: status = %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+	if secs, err := strconv.Atoi(w.Header().Get("Retry-After")); err != nil || secs < 1 || secs > 60 {
+		t.Errorf("Retry-After = %q, want the cooldown left", w.Header().Get("Retry-After"))
+	}
+}
+
+func TestRequireSelfOrRole(t *testing.T) {
+	m := InitMiddleware()
+
+	for _, tc := range []struct {
+		name   string
+		userID int64
+		role   string
+		path   string
+		want   int
+	}{
+		{"self", 2, "user", "/users/2", http.StatusOK},
+		{"other user", 2, "user", "/users/3", http.StatusForbidden},
+		{"admin on another", 1, "admin", "/users/3", http.StatusOK},
+		{"moderator on another", 4, "moderator", "/users/3", http.StatusOK},
+		{"role not listed", 5, "viewer", "/users/3", http.StatusForbidden},
+	} {
+		r := gin.New()
+		r.GET("/users/:id",
+			asRole(tc.role),
+			func(c *gin.Context) { c.Set("current_user", entities.UserResponse{ID: tc.userID, Role: tc.role}) },
+			m.RequireSelfOrRole("id", "admin", "moderator"),
+			func(c *gin.Context) { c.Status(http.StatusOK) },
+		)
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tc.path, nil))
+		if w.Code != tc.want {
+			t.Errorf("%s: status = %d, want %d", tc.name, w.Code, tc.want)
+		}
+	}
+}
+
+func TestJWTMiddlewareNeedsBearerScheme(t *testing.T) {
+	tokenStr, _, err := token.CreateToken(2, "user@example.com", "user", 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := gin.New()
+	r.GET("/", InitMiddleware().JWTMiddleware(), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	for header, want := range map[string]string{
+		"Bearer " + tokenStr:        "",
+		"bearer " + tokenStr:        "",
+		"Basic dXNlcjpwYXNz":        entities.InvalidAuthScheme,
+		tokenStr:                    entities.InvalidAuthScheme,
+		"Bearer":                    entities.InvalidAuthScheme,
+		"Bearer " + tokenStr + " x": entities.InvalidAuthScheme,
+		"Bearer not-a-token":        entities.Unauthorized,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", header)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if want == "" {
+			if w.Code != http.StatusOK {
+				t.Errorf("%q: status = %d, want %d: %s", header, w.Code, http.StatusOK, w.Body)
+			}
+			continue
+		}
+		var body struct{ Message string }
+		json.Unmarshal(w.Body.Bytes(), &body)
+		if w.Code != http.StatusUnauthorized || body.Message != want {
+			t.Errorf("%q: status = %d message %q, want %d and %q", header, w.Code, body.Message, http.StatusUnauthorized, want)
+		}
+		if want == entities.InvalidAuthScheme && w.Header().Get("WWW-Authenticate") != "Bearer" {
+			t.Errorf("%q: WWW-Authenticate = %q, want Bearer", header, w.Header().Get("WWW-Authenticate"))
+		}
+	}
+}
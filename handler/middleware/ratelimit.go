@@ -0,0 +1,124 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+	"github.com/ariopri/Let-It-Be/tree/main/backend/utils/token"
+	"github.com/gin-gonic/gin"
+)
+
+// fixed window counter per client
+type rateLimiter struct {
+	mu        sync.Mutex
+	limit     int
+	window    time.Duration
+	clients   map[string]*rateWindow
+	lastSweep time.Time
+}
+
+type rateWindow struct {
+	count int
+	reset time.Time
+}
+
+func newRateLimiter(limit int, window time.Duration) *rateLimiter {
+	return &rateLimiter{
+		limit:     limit,
+		window:    window,
+		clients:   map[string]*rateWindow{},
+		lastSweep: time.Now(),
+	}
+}
+
+// count a request for key, returns the remaining requests, when the
+// window resets and whether the request is allowed
+func (l *rateLimiter) take(key string, now time.Time) (int, time.Time, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// drop expired windows so idle clients don't pile up
+	if now.Sub(l.lastSweep) > l.window {
+		for k, w := range l.clients {
+			if !now.Before(w.reset) {
+				delete(l.clients, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	w, ok := l.clients[key]
+	if !ok || !now.Before(w.reset) {
+		w = &rateWindow{reset: now.Add(l.window)}
+		l.clients[key] = w
+	}
+
+	if w.count >= l.limit {
+		return 0, w.reset, false
+	}
+
+	w.count++
+
+	return l.limit - w.count, w.reset, true
+}
+
+// client ips or hashes of X-API-Key values, see token.HashAPIKey, that are
+// never throttled, e.g. monitoring. read when a limiter is created
+var RateLimitExempt []string
+
+// limit requests per client ip, reports the state in X-RateLimit-* headers
+func (m *middleware) RateLimit(limit int, window time.Duration) gin.HandlerFunc {
+	return rateLimit(limit, window, func(c *gin.Context) string {
+		return c.ClientIP()
+	})
+}
+
+// limit requests per authenticated user however many ips they come from.
+// must run after CurrentUser
+func (m *middleware) RateLimitByUser(limit int, window time.Duration) gin.HandlerFunc {
+	return rateLimit(limit, window, func(c *gin.Context) string {
+		user := c.MustGet("current_user").(entities.UserResponse)
+		return strconv.FormatInt(user.ID, 10)
+	})
+}
+
+// limit requests per key
+func rateLimit(limit int, window time.Duration, key func(c *gin.Context) string) gin.HandlerFunc {
+	l := newRateLimiter(limit, window)
+
+	exempt := map[string]bool{}
+	for _, e := range RateLimitExempt {
+		exempt[e] = true
+	}
+
+	return func(c *gin.Context) {
+		if exempt[c.ClientIP()] {
+			c.Next()
+			return
+		}
+		if key := c.GetHeader(token.APIKeyHeader); key != "" && exempt[token.HashAPIKey(key)] {
+			c.Next()
+			return
+		}
+
+		remaining, reset, ok := l.take(key(c), time.Now())
+
+		c.Header("X-RateLimit-Limit", strconv.Itoa(limit))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(remaining))
+		c.Header("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+
+		if !ok {
+			SetRetryAfter(c, time.Until(reset))
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"message": localize(c, entities.TooManyRequests),
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
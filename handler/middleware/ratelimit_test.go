@@ -0,0 +1,193 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+	"github.com/ariopri/Let-It-Be/tree/main/backend/utils/token"
+	"github.com/gin-gonic/gin"
+)
+
+// a router limited to limit requests per minute on GET /
+func limitedRouter(limit int) *gin.Engine {
+	r := gin.New()
+	r.GET("/", InitMiddleware().RateLimit(limit, time.Minute), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	return r
+}
+
+// GET / from ip
+func getFrom(h http.Handler, ip string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = ip + ":1234"
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	return w
+}
+
+func TestRateLimitHeaders(t *testing.T) {
+	r := limitedRouter(3)
+
+	for want := 2; want >= 0; want-- {
+		w := getFrom(r, "10.0.0.1")
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+		}
+		if got := w.Header().Get("X-RateLimit-Limit"); got != "3" {
+			t.Errorf("X-RateLimit-Limit = %q, want 3", got)
+		}
+		if got := w.Header().Get("X-RateLimit-Remaining"); got != strconv.Itoa(want) {
+			t.Errorf("X-RateLimit-Remaining = %q, want %d", got, want)
+		}
+		reset, err := strconv.ParseInt(w.Header().Get("X-RateLimit-Reset"), 10, 64)
+		if err != nil || reset < time.Now().Unix() {
+			t.Errorf("X-RateLimit-Reset = %q, want a future unix time", w.Header().Get("X-RateLimit-Reset"))
+		}
+	}
+
+	w := getFrom(r, "10.0.0.1")
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("over the limit: status = %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+	if got := w.Header().Get("X-RateLimit-Remaining"); got != "0" {
+		t.Errorf("over the limit: X-RateLimit-Remaining = %q, want 0", got)
+	}
+
+	if got := getFrom(r, "10.0.0.2").Header().Get("X-RateLimit-Remaining"); got != "2" {
+		t.Errorf("another client: X-RateLimit-Remaining = %q, want 2", got)
+	}
+}
+
+func TestRateLimitWindowResets(t *testing.T) {
+	l := newRateLimiter(1, time.Minute)
+	now := time.Now()
+
+	if _, _, ok := l.take("a", now); !ok {
+		t.Fatal("first request refused")
+	}
+	if _, _, ok := l.take("a", now.Add(time.Second)); ok {
+		t.Fatal("second request in the window allowed")
+	}
+	if remaining, _, ok := l.take("a", now.Add(time.Minute)); !ok || remaining != 0 {
+		t.Errorf("next window: remaining %d allowed %v, want 0 and true", remaining, ok)
+	}
+}
+
+func TestRateLimitExemptClients(t *testing.T) {
+	RateLimitExempt = []string{"10.0.0.9", token.HashAPIKey("monitoring-key")}
+	t.Cleanup(func() { RateLimitExempt = nil })
+	r := limitedRouter(1)
+
+	for i := 0; i < 3; i++ {
+		if w := getFrom(r, "10.0.0.9"); w.Code != http.StatusOK || w.Header().Get("X-RateLimit-Limit") != "" {
+			t.Fatalf("exempt ip, request %d: status %d, headers %v", i+1, w.Code, w.Header())
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "10.0.0.5:1234"
+		req.Header.Set("X-API-Key", "monitoring-key")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("exempt key, request %d: status = %d, want %d", i+1, w.Code, http.StatusOK)
+		}
+	}
+
+	// the configured hash itself isn't a key
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.0.0.7:1234"
+	req.Header.Set("X-API-Key", token.HashAPIKey("monitoring-key"))
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if i == 1 && w.Code != http.StatusTooManyRequests {
+			t.Errorf("the hash sent as key: status = %d, want %d", w.Code, http.StatusTooManyRequests)
+		}
+	}
+
+	// the exempt key's requests weren't counted against its ip
+	if w := getFrom(r, "10.0.0.5"); w.Code != http.StatusOK {
+		t.Errorf("ip of the exempt key: status = %d, want %d", w.Code, http.StatusOK)
+	}
+	if w := getFrom(r, "10.0.0.5"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("other client over the limit: status = %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+}
+
+func TestRateLimitRetryAfter(t *testing.T) {
+	r := limitedRouter(1)
+
+	if w := getFrom(r, "10.0.0.1"); w.Header().Get("Retry-After") != "" {
+		t.Errorf("allowed request has Retry-After %q", w.Header().Get("Retry-After"))
+	}
+
+	w := getFrom(r, "10.0.0.1")
+	secs, err := strconv.Atoi(w.Header().Get("Retry-After"))
+	if err != nil || secs < 59 || secs > 60 {
+		t.Errorf("Retry-After = %q, want the 60s window left", w.Header().Get("Retry-After"))
+	}
+}
+
+func TestSetRetryAfterRoundsUp(t *testing.T) {
+	for d, want := range map[time.Duration]string{
+		0:                       "1",
+		-time.Second:            "1",
+		300 * time.Millisecond:  "1",
+		time.Second:             "1",
+		1500 * time.Millisecond: "2",
+		time.Minute:             "60",
+	} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		SetRetryAfter(c, d)
+
+		if got := w.Header().Get("Retry-After"); got != want {
+			t.Errorf("%v: Retry-After = %q, want %q", d, got, want)
+		}
+	}
+}
+
+func TestRateLimitByUser(t *testing.T) {
+	r := gin.New()
+	r.GET("/",
+		// stands in for CurrentUser, the user id comes from a header
+		func(c *gin.Context) {
+			id, _ := strconv.ParseInt(c.GetHeader("X-User"), 10, 64)
+			c.Set("current_user", entities.UserResponse{ID: id})
+		},
+		InitMiddleware().RateLimitByUser(2, time.Minute),
+		func(c *gin.Context) { c.Status(http.StatusOK) },
+	)
+
+	as := func(user, ip string) int {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = ip + ":1234"
+		req.Header.Set("X-User", user)
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		return w.Code
+	}
+
+	// one account from many ips shares its limit
+	for _, ip := range []string{"10.0.0.1", "10.0.0.2"} {
+		if code := as("1", ip); code != http.StatusOK {
+			t.Fatalf("user 1 from %s: status = %d, want %d", ip, code, http.StatusOK)
+		}
+	}
+	if code := as("1", "10.0.0.3"); code != http.StatusTooManyRequests {
+		t.Errorf("user 1 over the limit: status = %d, want %d", code, http.StatusTooManyRequests)
+	}
+
+	// and doesn't use up anyone else's from the same ip
+	if code := as("2", "10.0.0.1"); code != http.StatusOK {
+		t.Errorf("user 2: status = %d, want %d", code, http.StatusOK)
+	}
+}
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+	"github.com/ariopri/Let-It-Be/tree/main/backend/utils/jsonschema"
+	"github.com/gin-gonic/gin"
+)
+
+// validate the json body against schema before the handler binds it,
+// violations are answered with 422 and their json pointers, bodies over
+// maxBytes with 413. malformed json is left to the handler's binding
+func (m *middleware) ValidateSchema(schema *jsonschema.Schema, maxBytes int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Body == nil || c.Request.ContentLength == 0 {
+			c.Next()
+			return
+		}
+
+		body, ok := readBody(c, maxBytes)
+		if !ok {
+			return
+		}
+
+		errs, err := schema.ValidateJSON(body)
+		if err == nil && len(errs) > 0 {
+			for i := range errs {
+				errs[i] = errs[i].Translate(func(msg string) string {
+					return localize(c, msg)
+				})
+			}
+
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"message": localize(c, entities.ValidationFailed),
+				"errors":  errs,
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
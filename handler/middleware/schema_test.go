@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/utils/jsonschema"
+)
+
+var testSchema = jsonschema.MustCompile(`{
+	"type": "object",
+	"required": ["email"],
+	"properties": {
+		"email": {"type": "string"},
+		"age": {"type": "number", "minimum": 0}
+	}
+}`)
+
+func TestValidateSchemaViolations(t *testing.T) {
+	r := echoRouter(InitMiddleware().ValidateSchema(testSchema, 1<<10))
+
+	w := postJSON(r, `{"age":-1}`, 10)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusUnprocessableEntity, w.Body)
+	}
+
+	var body struct {
+		Errors []jsonschema.Error `json:"errors"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	pointers := map[string]bool{}
+	for _, e := range body.Errors {
+		pointers[e.Pointer] = true
+	}
+	if len(pointers) != 2 || !pointers["/email"] || !pointers["/age"] {
+		t.Errorf("violations at %v, want /email and /age", pointers)
+	}
+}
+
+func TestValidateSchemaPassesValidBodies(t *testing.T) {
+	r := echoRouter(InitMiddleware().ValidateSchema(testSchema, 1<<10))
+
+	body := `{"email":"a@b.c","age":3}`
+	w := postJSON(r, body, int64(len(body)))
+	if w.Code != http.StatusOK || w.Body.String() != body {
+		t.Fatalf("status = %d, body %q; want the handler to get the body unchanged", w.Code, w.Body)
+	}
+}
+
+func TestValidateSchemaBodyTooLarge(t *testing.T) {
+	r := echoRouter(InitMiddleware().ValidateSchema(testSchema, 32))
+
+	body := `{"email":"` + strings.Repeat("x", 64) + `"}`
+	if w := postJSON(r, body, -1); w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusRequestEntityTooLarge)
+	}
+}
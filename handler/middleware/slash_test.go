@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestTrailingSlashIsServedWithoutRedirect(t *testing.T) {
+	r := gin.New()
+	r.RedirectTrailingSlash = false
+	r.RedirectFixedPath = false
+	r.POST("/api/users", func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		c.String(http.StatusOK, string(body))
+	})
+	r.GET("/", func(c *gin.Context) { c.String(http.StatusOK, "root") })
+	h := TrimTrailingSlash(r)
+
+	for _, path := range []string{"/api/users", "/api/users/", "/api/users//"} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"a":1}`)))
+
+		if w.Code != http.StatusOK || w.Body.String() != `{"a":1}` {
+			t.Errorf("%s: status %d body %q, want the body echoed", path, w.Code, w.Body)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("/: status = %d, want %d", w.Code, http.StatusOK)
+	}
+}
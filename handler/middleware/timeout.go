@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+	"github.com/gin-gonic/gin"
+)
+
+// context keys of the request context before any timeout was applied, and
+// of the one the Timeout in effect set
+const (
+	timeoutBaseKey   = "timeout_base"
+	timeoutActiveKey = "timeout_active"
+)
+
+// give the request context a deadline of d, 0 disables it. a Timeout on a
+// route or group replaces the global one instead of nesting in it, so it
+// can be longer as well as shorter
+func (m *middleware) Timeout(d time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		base := c.Request.Context()
+		if v, ok := c.Get(timeoutBaseKey); ok {
+			base = v.(context.Context)
+		} else {
+			c.Set(timeoutBaseKey, base)
+		}
+
+		if d <= 0 {
+			c.Set(timeoutActiveKey, base)
+			c.Request = c.Request.WithContext(base)
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(base, d)
+		defer cancel()
+
+		c.Set(timeoutActiveKey, ctx)
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+
+		// a route's Timeout replaced this one, its deadline doesn't apply
+		if active, _ := c.Get(timeoutActiveKey); active != ctx {
+			return
+		}
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) && !c.Writer.Written() {
+			c.JSON(http.StatusGatewayTimeout, gin.H{
+				"message": localize(c, entities.RequestTimeout),
+			})
+		}
+	}
+}
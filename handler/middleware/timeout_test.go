@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// waits for its deadline or d, whichever comes first
+func slowHandler(d time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		select {
+		case <-c.Request.Context().Done():
+		case <-time.After(d):
+			c.Status(http.StatusOK)
+		}
+	}
+}
+
+func TestTimeoutOverridesPerRoute(t *testing.T) {
+	m := InitMiddleware()
+	r := gin.New()
+	r.Use(m.Timeout(20 * time.Millisecond))
+	r.GET("/default", slowHandler(100*time.Millisecond))
+	r.GET("/export", m.Timeout(time.Second), slowHandler(100*time.Millisecond))
+	r.GET("/unlimited", m.Timeout(0), slowHandler(100*time.Millisecond))
+	r.GET("/deadline", m.Timeout(time.Hour), func(c *gin.Context) {
+		deadline, _ := c.Request.Context().Deadline()
+		c.String(http.StatusOK, time.Until(deadline).String())
+	})
+
+	for path, want := range map[string]int{
+		"/default":   http.StatusGatewayTimeout,
+		"/export":    http.StatusOK,
+		"/unlimited": http.StatusOK,
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+
+		if w.Code != want {
+			t.Errorf("%s: status = %d, want %d", path, w.Code, want)
+		}
+	}
+
+	// the override replaces the global deadline rather than nesting in it
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/deadline", nil))
+	if left, err := time.ParseDuration(w.Body.String()); err != nil || left < time.Minute {
+		t.Errorf("deadline in %s, want about an hour", w.Body)
+	}
+}
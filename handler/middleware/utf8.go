@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"net/http"
+	"unicode/utf8"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+	"github.com/gin-gonic/gin"
+)
+
+// reject requests whose path or query holds invalid utf-8 with 400
+func (m *middleware) ValidUTF8() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !validRequestUTF8(c) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": localize(c, entities.BadRequest),
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func validRequestUTF8(c *gin.Context) bool {
+	if !utf8.ValidString(c.Request.URL.Path) {
+		return false
+	}
+
+	for _, p := range c.Params {
+		if !utf8.ValidString(p.Value) {
+			return false
+		}
+	}
+
+	for k, vs := range c.Request.URL.Query() {
+		if !utf8.ValidString(k) {
+			return false
+		}
+		for _, v := range vs {
+			if !utf8.ValidString(v) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestValidUTF8(t *testing.T) {
+	r := gin.New()
+	r.Use(InitMiddleware().ValidUTF8())
+	r.GET("/users/:name", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	for url, want := range map[string]int{
+		"/users/ada?search=Zo%C3%AB": http.StatusOK,
+		"/users/ada?search=%FF%FE":   http.StatusBadRequest,
+		"/users/ada?%C0%AF=x":        http.StatusBadRequest,
+		"/users/%C3%28":              http.StatusBadRequest,
+		"/users/J%C3%B6rg":           http.StatusOK,
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+
+		if w.Code != want {
+			t.Errorf("%s: status = %d, want %d", url, w.Code, want)
+		}
+	}
+}
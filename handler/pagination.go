@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+	"github.com/gin-gonic/gin"
+)
+
+// advertise the neighbouring pages in an RFC 5988 Link header. without a
+// total, next is only offered while pages come back full and last is left out
+func setLinkHeader(c *gin.Context, meta entities.ListMeta) {
+	if meta.Limit <= 0 {
+		return
+	}
+
+	links := []string{pageLink(c, 0, meta.Limit, "first")}
+
+	if meta.Offset > 0 {
+		prev := meta.Offset - meta.Limit
+		if prev < 0 {
+			prev = 0
+		}
+		links = append(links, pageLink(c, prev, meta.Limit, "prev"))
+	}
+
+	next := meta.Offset + meta.Limit
+	if meta.Total != nil {
+		if int64(next) < *meta.Total {
+			links = append(links, pageLink(c, next, meta.Limit, "next"))
+		}
+
+		last := 0
+		if *meta.Total > 0 {
+			last = int((*meta.Total - 1) / int64(meta.Limit) * int64(meta.Limit))
+		}
+		links = append(links, pageLink(c, last, meta.Limit, "last"))
+	} else if meta.Count == meta.Limit {
+		links = append(links, pageLink(c, next, meta.Limit, "next"))
+	}
+
+	c.Header("Link", strings.Join(links, ", "))
+}
+
+// the current request with another page
+func pageLink(c *gin.Context, offset, limit int, rel string) string {
+	q := c.Request.URL.Query()
+	q.Set("limit", strconv.Itoa(limit))
+	q.Set("offset", strconv.Itoa(offset))
+
+	return fmt.Sprintf(`<%s?%s>; rel="%s"`, c.Request.URL.Path, q.Encode(), rel)
+}
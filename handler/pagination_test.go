@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+)
+
+var linkPart = regexp.MustCompile(`^<([^>]*)>; rel="([a-z]+)"$`)
+
+// the query of each rel in a Link header
+func parseLinks(t *testing.T, header string) map[string]url.Values {
+	t.Helper()
+
+	links := map[string]url.Values{}
+	if header == "" {
+		return links
+	}
+	for _, part := range strings.Split(header, ", ") {
+		m := linkPart.FindStringSubmatch(part)
+		if m == nil {
+			t.Fatalf("malformed link %q in %q", part, header)
+		}
+
+		u, err := url.Parse(m[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u.Path != "/api/users" {
+			t.Errorf("%s link to %q, want /api/users", m[2], u.Path)
+		}
+		links[m[2]] = u.Query()
+	}
+
+	return links
+}
+
+// seven users, the admin included
+func sevenUsers() *stubUserRepo {
+	users := []entities.UserResponse{testAdmin, testUser}
+	for id := int64(3); id <= 7; id++ {
+		users = append(users, entities.UserResponse{ID: id, Email: "u" + strconv.FormatInt(id, 10) + "@example.com", Role: "user", Active: true})
+	}
+
+	return newStubRepo(users...)
+}
+
+func TestLinkHeaderOnAMiddlePage(t *testing.T) {
+	r := newTestRouter(t, sevenUsers())
+
+	w := doRequest(t, r, http.MethodGet, "/api/users?limit=2&offset=2&count=exact&sort=id", "", testAdmin)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+
+	links := parseLinks(t, w.Header().Get("Link"))
+	for rel, offset := range map[string]string{"first": "0", "prev": "0", "next": "4", "last": "6"} {
+		q, ok := links[rel]
+		if !ok {
+			t.Errorf("no %s link in %q", rel, w.Header().Get("Link"))
+			continue
+		}
+		if q.Get("offset") != offset || q.Get("limit") != "2" {
+			t.Errorf("%s link offset %s limit %s, want %s and 2", rel, q.Get("offset"), q.Get("limit"), offset)
+		}
+		// the rest of the request is kept
+		if q.Get("count") != "exact" || q.Get("sort") != "id" {
+			t.Errorf("%s link = %v, want count and sort kept", rel, q)
+		}
+	}
+	if len(links) != 4 {
+		t.Errorf("links = %v, want first, prev, next and last", links)
+	}
+}
+
+func TestLinkHeaderWithoutTotal(t *testing.T) {
+	r := newTestRouter(t, sevenUsers())
+
+	for _, tc := range []struct {
+		query string
+		rels  []string
+	}{
+		{"limit=3&offset=0", []string{"first", "next"}},
+		// a short page is the last one
+		{"limit=3&offset=6", []string{"first", "prev"}},
+	} {
+		w := doRequest(t, r, http.MethodGet, "/api/users?"+tc.query, "", testAdmin)
+		links := parseLinks(t, w.Header().Get("Link"))
+
+		if len(links) != len(tc.rels) {
+			t.Errorf("%s: links = %v, want %v", tc.query, links, tc.rels)
+		}
+		for _, rel := range tc.rels {
+			if _, ok := links[rel]; !ok {
+				t.Errorf("%s: no %s link in %q", tc.query, rel, w.Header().Get("Link"))
+			}
+		}
+	}
+}
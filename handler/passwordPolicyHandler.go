@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+	"github.com/ariopri/Let-It-Be/tree/main/backend/utils/token"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// how long a loaded policy is trusted before it's read again, so changes
+// made through another instance take effect too
+var PasswordPolicyTTL = time.Minute
+
+// policy consulted by the "password" validation tag
+var passwordPolicy = &policyCache{policy: entities.DefaultPasswordPolicy}
+
+type policyCache struct {
+	mu       sync.Mutex
+	userRepo entities.UserRepository
+	policy   entities.PasswordPolicy
+	loadedAt time.Time
+}
+
+// the current policy, the last known one if it can't be reloaded
+func (p *policyCache) get() entities.PasswordPolicy {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.userRepo != nil && time.Since(p.loadedAt) > PasswordPolicyTTL {
+		if policy, err := p.userRepo.FetchPasswordPolicy(context.Background()); err == nil {
+			p.policy = policy
+			p.loadedAt = time.Now()
+		}
+	}
+
+	return p.policy
+}
+
+// load the policy from userRepo on next use
+func (p *policyCache) source(userRepo entities.UserRepository) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.userRepo = userRepo
+	p.loadedAt = time.Time{}
+}
+
+func (p *policyCache) set(policy entities.PasswordPolicy) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.policy = policy
+	p.loadedAt = time.Now()
+}
+
+func validatePassword(fl validator.FieldLevel) bool {
+	return passwordPolicy.get().Allows(fl.Field().String())
+}
+
+// fetch the password policy
+func (u *userHandler) fetchPasswordPolicy(c *gin.Context) {
+	if !isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": localize(c, entities.Forbidden),
+		})
+		return
+	}
+
+	policy, err := u.userRepo.FetchPasswordPolicy(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": localize(c, entities.InternalServer),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "password policy fetched",
+		"policy":  policy,
+	})
+}
+
+// replace the password policy, applies to passwords set from now on
+func (u *userHandler) updatePasswordPolicy(c *gin.Context) {
+	// role check, impersonation tokens never qualify
+	claims := c.MustGet("user").(*token.Claims)
+	if !isAdmin(c) || claims.ImpersonatedBy != "" {
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": localize(c, entities.Forbidden),
+		})
+		return
+	}
+
+	if emptyBody(c) {
+		return
+	}
+
+	policy := entities.PasswordPolicy{}
+	if err := c.ShouldBindJSON(&policy); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	policyData, err := u.userRepo.UpdatePasswordPolicy(c.Request.Context(), &policy)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": localize(c, entities.InternalServer),
+		})
+		return
+	}
+
+	passwordPolicy.set(policyData)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "password policy updated",
+		"policy":  policyData,
+	})
+}
@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+)
+
+func (r *stubUserRepo) FetchPasswordPolicy(ctx context.Context) (entities.PasswordPolicy, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.policy == nil {
+		return entities.DefaultPasswordPolicy, nil
+	}
+
+	return *r.policy, nil
+}
+
+func (r *stubUserRepo) UpdatePasswordPolicy(ctx context.Context, p *entities.PasswordPolicy) (entities.PasswordPolicy, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored := *p
+	r.policy = &stored
+
+	return stored, nil
+}
+
+func TestPasswordPolicyAppliesToLaterPasswords(t *testing.T) {
+	t.Cleanup(func() { passwordPolicy.set(entities.DefaultPasswordPolicy) })
+
+	repo := newStubRepo(testAdmin, testUser)
+	r := newTestRouter(t, repo)
+	setPassword := func(password string) int {
+		body := `{"first_name":"Uma","last_name":"User","password":"` + password + `"}`
+		return doRequest(t, r, http.MethodPut, "/api/users/2", body, testUser).Code
+	}
+
+	if code := setPassword("lettersonly"); code != http.StatusOK {
+		t.Fatalf("default policy: status = %d, want %d", code, http.StatusOK)
+	}
+
+	w := doRequest(t, r, http.MethodPut, "/api/admin/password-policy", `{"min_length":12,"require_digit":true}`, testAdmin)
+	if w.Code != http.StatusOK {
+		t.Fatalf("update policy: status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+
+	for password, want := range map[string]int{
+		"lettersonlyhere": http.StatusUnprocessableEntity,
+		"short1":          http.StatusUnprocessableEntity,
+		"twelve-chars-1":  http.StatusOK,
+	} {
+		if code := setPassword(password); code != want {
+			t.Errorf("%q: status = %d, want %d", password, code, want)
+		}
+	}
+
+	w = doRequest(t, r, http.MethodGet, "/api/admin/password-policy", "", testAdmin)
+	policy := decodeBody(t, w)["policy"].(map[string]interface{})
+	if policy["min_length"] != float64(12) || policy["require_digit"] != true {
+		t.Errorf("policy = %v", policy)
+	}
+}
+
+func TestPasswordPolicyIsAdminOnly(t *testing.T) {
+	r := newTestRouter(t, newStubRepo(testAdmin, testUser))
+
+	if w := doRequest(t, r, http.MethodGet, "/api/admin/password-policy", "", testUser); w.Code != http.StatusForbidden {
+		t.Errorf("get: status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+	if w := doRequest(t, r, http.MethodPut, "/api/admin/password-policy", `{"min_length":4}`, testUser); w.Code != http.StatusForbidden {
+		t.Errorf("put: status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+	// below the floor bcrypt and the defaults allow
+	if w := doRequest(t, r, http.MethodPut, "/api/admin/password-policy", `{"min_length":4}`, testAdmin); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("too short a minimum: status = %d, want %d", w.Code, http.StatusUnprocessableEntity)
+	}
+}
@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+	"github.com/gin-gonic/gin"
+)
+
+// largest preferences object a user may store, in bytes of compact json
+var MaxPreferencesSize = 4 << 10
+
+// current user's preferences
+func (u *userHandler) preferences(c *gin.Context) {
+	user := c.MustGet("current_user").(entities.UserResponse)
+
+	prefs, err := u.userRepo.FetchPreferences(c.Request.Context(), user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": localize(c, entities.InternalServer),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "preferences fetched",
+		"preferences": prefs,
+	})
+}
+
+// replace the current user's preferences with the json object in the body
+func (u *userHandler) updatePreferences(c *gin.Context) {
+	user := c.MustGet("current_user").(entities.UserResponse)
+
+	body, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": localize(c, entities.BadRequest),
+		})
+		return
+	}
+
+	prefs, err := compactObject(body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": localize(c, entities.BadRequest),
+		})
+		return
+	}
+
+	if len(prefs) > MaxPreferencesSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"message":  localize(c, entities.PreferencesTooLarge),
+			"max_size": MaxPreferencesSize,
+		})
+		return
+	}
+
+	if err := u.userRepo.UpdatePreferences(c.Request.Context(), user.ID, prefs); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": localize(c, entities.InternalServer),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "preferences updated",
+		"preferences": prefs,
+	})
+}
+
+// compact body, which must hold a single json object
+func compactObject(body []byte) (json.RawMessage, error) {
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(body, &obj); err != nil {
+		return nil, err
+	}
+	if obj == nil {
+		return nil, errors.New("preferences must be an object")
+	}
+
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, body); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func (r *stubUserRepo) FetchPreferences(ctx context.Context, id int64) (json.RawMessage, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if prefs, ok := r.prefs[id]; ok {
+		return prefs, nil
+	}
+
+	return json.RawMessage(`{}`), nil
+}
+
+func (r *stubUserRepo) UpdatePreferences(ctx context.Context, id int64, prefs json.RawMessage) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.prefs == nil {
+		r.prefs = map[int64]json.RawMessage{}
+	}
+	r.prefs[id] = prefs
+
+	return nil
+}
+
+// the preferences in a response body, re-encoded compactly
+func prefsOf(t *testing.T, body []byte) string {
+	t.Helper()
+
+	var res struct {
+		Preferences json.RawMessage `json:"preferences"`
+	}
+	if err := json.Unmarshal(body, &res); err != nil {
+		t.Fatalf("decoding %q: %v", body, err)
+	}
+
+	return string(res.Preferences)
+}
+
+func TestPreferencesRoundTrip(t *testing.T) {
+	r := newTestRouter(t, newStubRepo(testAdmin, testUser))
+
+	w := doRequest(t, r, http.MethodGet, "/api/me/preferences", "", testUser)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	if got := prefsOf(t, w.Body.Bytes()); got != `{}` {
+		t.Errorf("preferences = %s before any were set, want {}", got)
+	}
+
+	body := `{ "theme": "dark",
+		"notifications": {"email": false} }`
+	w = doRequest(t, r, http.MethodPut, "/api/me/preferences", body, testUser)
+	if w.Code != http.StatusOK {
+		t.Fatalf("update: status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+
+	want := `{"theme":"dark","notifications":{"email":false}}`
+	if got := prefsOf(t, doRequest(t, r, http.MethodGet, "/api/me/preferences", "", testUser).Body.Bytes()); got != want {
+		t.Errorf("preferences = %s, want %s", got, want)
+	}
+	// they're per user
+	if got := prefsOf(t, doRequest(t, r, http.MethodGet, "/api/me/preferences", "", testAdmin).Body.Bytes()); got != `{}` {
+		t.Errorf("admin's preferences = %s, want {}", got)
+	}
+}
+
+func TestPreferencesRejected(t *testing.T) {
+	MaxPreferencesSize = 32
+	t.Cleanup(func() { MaxPreferencesSize = 4 << 10 })
+
+	repo := newStubRepo(testUser)
+	r := newTestRouter(t, repo)
+
+	for _, tc := range []struct {
+		body string
+		want int
+	}{
+		{`["dark"]`, http.StatusBadRequest},
+		{`null`, http.StatusBadRequest},
+		{`{"theme":`, http.StatusBadRequest},
+		{`{"theme":"` + strings.Repeat("x", 32) + `"}`, http.StatusRequestEntityTooLarge},
+	} {
+		if w := doRequest(t, r, http.MethodPut, "/api/me/preferences", tc.body, testUser); w.Code != tc.want {
+			t.Errorf("%s: status = %d, want %d: %s", tc.body, w.Code, tc.want, w.Body)
+		}
+	}
+	if len(repo.prefs) != 0 {
+		t.Errorf("rejected preferences were stored: %v", repo.prefs)
+	}
+
+	// the cap applies to the compacted object, whitespace doesn't count
+	body := `{ "theme" :   "dark"` + strings.Repeat(" ", 64) + `}`
+	if w := doRequest(t, r, http.MethodPut, "/api/me/preferences", body, testUser); w.Code != http.StatusOK {
+		t.Errorf("padded body: status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+}
@@ -0,0 +1,159 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+	"github.com/ariopri/Let-It-Be/tree/main/backend/utils/token"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// fetch profile
+func (u *userHandler) fetchProfile(c *gin.Context) {
+	ctx := c.Request.Context()
+	id := c.Param("id")
+	idConv, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": localize(c, entities.BadRequest),
+		})
+		return
+	}
+
+	profile, err := u.userRepo.FetchProfile(ctx, idConv)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": localize(c, entities.ItemNotFound),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "profile fetched",
+		"profile": profile,
+	})
+}
+
+// update profile, owner or admin only
+func (u *userHandler) updateProfile(c *gin.Context) {
+	ctx := c.Request.Context()
+	id := c.Param("id")
+	idConv, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": localize(c, entities.BadRequest),
+		})
+		return
+	}
+
+	owner, ok := u.ownerOrAdmin(c, idConv)
+	if !ok {
+		return
+	}
+
+	if emptyBody(c) {
+		return
+	}
+
+	profile := entities.Profile{}
+	if err := c.ShouldBind(&profile); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	profileData, err := u.userRepo.UpdateProfile(ctx, idConv, &profile)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": localize(c, entities.InternalServer),
+		})
+		return
+	}
+
+	u.recordActivity(c, entities.AuditProfileUpdate, owner.Email)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "profile updated",
+		"profile": profileData,
+	})
+}
+
+// partially update profile, explicit nulls clear fields
+func (u *userHandler) patchProfile(c *gin.Context) {
+	ctx := c.Request.Context()
+	id := c.Param("id")
+	idConv, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": localize(c, entities.BadRequest),
+		})
+		return
+	}
+
+	owner, ok := u.ownerOrAdmin(c, idConv)
+	if !ok {
+		return
+	}
+
+	if emptyBody(c) {
+		return
+	}
+
+	patch := entities.ProfilePatch{}
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	profile, err := u.userRepo.FetchProfile(ctx, idConv)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": localize(c, entities.InternalServer),
+		})
+		return
+	}
+
+	// validate the result, the patch itself has no rules
+	patch.Apply(&profile)
+	if err := binding.Validator.ValidateStruct(profile); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	profileData, err := u.userRepo.UpdateProfile(ctx, idConv, &profile)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": localize(c, entities.InternalServer),
+		})
+		return
+	}
+
+	u.recordActivity(c, entities.AuditProfileUpdate, owner.Email)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "profile updated",
+		"profile": profileData,
+	})
+}
+
+// respond with 404 or 403 unless the requester is the user or an admin
+func (u *userHandler) ownerOrAdmin(c *gin.Context, id int64) (entities.UserResponse, bool) {
+	user, err := u.userRepo.FetchById(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": localize(c, entities.ItemNotFound),
+		})
+		return user, false
+	}
+
+	claims := c.MustGet("user").(*token.Claims)
+	if claims.Email != user.Email && !isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": localize(c, entities.Forbidden),
+		})
+		return user, false
+	}
+
+	return user, true
+}
@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+)
+
+// users without a stored profile get an empty one
+func (r *stubUserRepo) FetchProfile(ctx context.Context, id int64) (entities.Profile, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.users[id]; !ok {
+		return entities.Profile{}, entities.ErrNotFound
+	}
+	if p, ok := r.profiles[id]; ok {
+		return p, nil
+	}
+
+	return entities.Profile{UserID: id}, nil
+}
+
+func (r *stubUserRepo) UpdateProfile(ctx context.Context, id int64, p *entities.Profile) (entities.Profile, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.users[id]; !ok {
+		return entities.Profile{}, entities.ErrNotFound
+	}
+	if r.profiles == nil {
+		r.profiles = map[int64]entities.Profile{}
+	}
+
+	stored := *p
+	stored.UserID = id
+	r.profiles[id] = stored
+
+	return stored, nil
+}
+
+func TestProfileIsSeparateFromTheUser(t *testing.T) {
+	repo := newStubRepo(testAdmin, testUser)
+	r := newTestRouter(t, repo)
+
+	w := doRequest(t, r, http.MethodGet, "/api/users/2/profile", "", testUser)
+	if w.Code != http.StatusOK {
+		t.Fatalf("empty profile: status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	if p := decodeBody(t, w)["profile"].(map[string]interface{}); p["user_id"] != float64(2) || p["display_name"] != "" {
+		t.Errorf("empty profile = %v", p)
+	}
+
+	body := `{"display_name":"Uma U.","bio":"hello","avatar_url":"https://example.com/uma.png","locale":"id-ID"}`
+	w = doRequest(t, r, http.MethodPut, "/api/users/2/profile", body, testUser)
+	if w.Code != http.StatusOK {
+		t.Fatalf("update: status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+
+	w = doRequest(t, r, http.MethodGet, "/api/users/2/profile", "", testUser)
+	p := decodeBody(t, w)["profile"].(map[string]interface{})
+	if p["display_name"] != "Uma U." || p["bio"] != "hello" || p["locale"] != "id-ID" {
+		t.Errorf("profile after update = %v", p)
+	}
+
+	// the core user is untouched
+	if u := repo.users[testUser.ID]; u != testUser {
+		t.Errorf("user = %+v, want it unchanged", u)
+	}
+}
+
+func TestUpdateProfileIsOwnerOrAdmin(t *testing.T) {
+	stranger := entities.UserResponse{ID: 3, Email: "stranger@example.com", Role: "user", Active: true}
+	r := newTestRouter(t, newStubRepo(testAdmin, testUser, stranger))
+	body := `{"display_name":"Someone"}`
+
+	if w := doRequest(t, r, http.MethodPut, "/api/users/2/profile", body, stranger); w.Code != http.StatusForbidden {
+		t.Errorf("stranger: status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+	if w := doRequest(t, r, http.MethodPut, "/api/users/2/profile", body, testAdmin); w.Code != http.StatusOK {
+		t.Errorf("admin: status = %d, want %d", w.Code, http.StatusOK)
+	}
+	if w := doRequest(t, r, http.MethodPut, "/api/users/2/profile", `{"avatar_url":"not a url"}`, testUser); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("invalid avatar: status = %d, want %d", w.Code, http.StatusUnprocessableEntity)
+	}
+}
+
+func TestPatchProfile(t *testing.T) {
+	repo := newStubRepo(testUser)
+	repo.profiles = map[int64]entities.Profile{
+		testUser.ID: {UserID: testUser.ID, DisplayName: "Uma", Bio: "hi", AvatarURL: "https://example.com/uma.png", Locale: "en"},
+	}
+	r := newTestRouter(t, repo)
+
+	req := newRequest(t, http.MethodPatch, "/api/users/2/profile", `{"avatar_url":null,"bio":"hello"}`, testUser)
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	w := serve(r, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+
+	want := entities.Profile{UserID: testUser.ID, DisplayName: "Uma", Bio: "hello", Locale: "en"}
+	if got := repo.profiles[testUser.ID]; got != want {
+		t.Errorf("profile = %+v, want %+v", got, want)
+	}
+
+	// the patched result is validated
+	w = doRequest(t, r, http.MethodPatch, "/api/users/2/profile", `{"avatar_url":"not a url"}`, testUser)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("invalid value: status = %d, want %d", w.Code, http.StatusUnprocessableEntity)
+	}
+}
@@ -0,0 +1,212 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+)
+
+func (r *stubUserRepo) UpdateRoles(ctx context.Context, roles []entities.RoleAssignment, changedBy string) ([]entities.UserResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.roleBatches = append(r.roleBatches, roles)
+	if r.rolesErr != nil {
+		return nil, r.rolesErr
+	}
+
+	var users []entities.UserResponse
+	for _, a := range roles {
+		u := r.users[a.ID]
+		if u.Role != a.Role {
+			r.history = append(r.history, entities.RoleChange{
+				ID:        int64(len(r.history) + 1),
+				UserID:    a.ID,
+				OldRole:   u.Role,
+				NewRole:   a.Role,
+				ChangedBy: changedBy,
+			})
+		}
+		u.Role = a.Role
+		r.users[a.ID] = u
+		users = append(users, u)
+	}
+
+	return users, nil
+}
+
+func (r *stubUserRepo) RoleHistory(ctx context.Context, id int64) ([]entities.RoleChange, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.users[id]; !ok {
+		return nil, entities.ErrNotFound
+	}
+
+	changes := []entities.RoleChange{}
+	for _, rc := range r.history {
+		if rc.UserID == id {
+			changes = append(changes, rc)
+		}
+	}
+
+	return changes, nil
+}
+
+func updateRoles(t *testing.T, repo *stubUserRepo, body string) (int, entities.BatchResponse) {
+	t.Helper()
+
+	w := doRequest(t, newTestRouter(t, repo), http.MethodPut, "/api/users/roles", body, testAdmin)
+
+	var res entities.BatchResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatalf("decoding %q: %v", w.Body, err)
+	}
+
+	return w.Code, res
+}
+
+func statuses(res entities.BatchResponse) []int {
+	var s []int
+	for _, r := range res.Results {
+		s = append(s, r.Status)
+	}
+
+	return s
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+const roleBatch = `[{"id":1,"role":"user"},{"id":2,"role":"admin"}]`
+
+func TestUpdateRolesInOneTransaction(t *testing.T) {
+	repo := newStubRepo(testAdmin, testUser)
+
+	code, res := updateRoles(t, repo, roleBatch)
+	if code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", code, http.StatusOK)
+	}
+	if len(repo.roleBatches) != 1 || len(repo.roleBatches[0]) != 2 {
+		t.Errorf("UpdateRoles got %v, want the whole batch in one call", repo.roleBatches)
+	}
+	if res.Summary.Succeeded != 2 {
+		t.Errorf("summary = %+v, want 2 succeeded", res.Summary)
+	}
+}
+
+func TestUpdateRolesFailingItem(t *testing.T) {
+	repo := newStubRepo(testAdmin, testUser)
+	repo.rolesErr = &entities.BatchItemError{Index: 1, Err: entities.ErrNotFound}
+
+	code, res := updateRoles(t, repo, roleBatch)
+	if code != http.StatusMultiStatus {
+		t.Fatalf("status = %d, want %d", code, http.StatusMultiStatus)
+	}
+	if want := []int{http.StatusFailedDependency, http.StatusNotFound}; !equalInts(statuses(res), want) {
+		t.Errorf("item statuses = %v, want %v", statuses(res), want)
+	}
+}
+
+func TestUpdateRolesLastAdmin(t *testing.T) {
+	repo := newStubRepo(testAdmin, testUser)
+	repo.rolesErr = entities.ErrLastAdmin
+
+	code, res := updateRoles(t, repo, `[{"id":1,"role":"user"}]`)
+	if code != http.StatusMultiStatus {
+		t.Fatalf("status = %d, want %d", code, http.StatusMultiStatus)
+	}
+	if want := []int{http.StatusConflict}; !equalInts(statuses(res), want) {
+		t.Errorf("item statuses = %v, want %v", statuses(res), want)
+	}
+}
+
+func TestUpdateRolesInvalidItem(t *testing.T) {
+	repo := newStubRepo(testAdmin, testUser)
+
+	w := doRequest(t, newTestRouter(t, repo), http.MethodPut, "/api/users/roles", `[{"id":1,"role":"user"},{"id":2,"role":"owner"}]`, testAdmin)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	if len(repo.roleBatches) != 0 {
+		t.Error("a batch with an invalid item reached the repository")
+	}
+}
+
+func TestUpdateRolesNeedsAdmin(t *testing.T) {
+	repo := newStubRepo(testAdmin, testUser)
+
+	w := doRequest(t, newTestRouter(t, repo), http.MethodPut, "/api/users/roles", roleBatch, testUser)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+	if len(repo.roleBatches) != 0 {
+		t.Error("a non admin's batch reached the repository")
+	}
+}
+
+func TestRoleChangeAppendsHistory(t *testing.T) {
+	repo := newStubRepo(testAdmin, testUser)
+	r := newTestRouter(t, repo)
+
+	w := doRequest(t, r, http.MethodGet, "/api/users/2/role-history", "", testAdmin)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	if history := decodeBody(t, w)["history"].([]interface{}); len(history) != 0 {
+		t.Fatalf("history = %v before any change, want none", history)
+	}
+
+	body := `[{"id":1,"role":"admin"},{"id":2,"role":"admin"}]`
+	if w := doRequest(t, r, http.MethodPut, "/api/users/roles", body, testAdmin); w.Code != http.StatusOK {
+		t.Fatalf("updating roles: status = %d: %s", w.Code, w.Body)
+	}
+
+	w = doRequest(t, r, http.MethodGet, "/api/users/2/role-history", "", testAdmin)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+
+	var res struct {
+		History []entities.RoleChange `json:"history"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+
+	want := entities.RoleChange{ID: 1, UserID: 2, OldRole: "user", NewRole: "admin", ChangedBy: testAdmin.Email}
+	if len(res.History) != 1 || res.History[0] != want {
+		t.Errorf("history = %+v, want [%+v]", res.History, want)
+	}
+}
+
+func TestRoleHistory(t *testing.T) {
+	r := newTestRouter(t, newStubRepo(testAdmin, testUser))
+
+	for _, tc := range []struct {
+		path string
+		as   entities.UserResponse
+		want int
+	}{
+		{"/api/users/2/role-history", testUser, http.StatusForbidden},
+		{"/api/users/x/role-history", testAdmin, http.StatusBadRequest},
+		{"/api/users/9/role-history", testAdmin, http.StatusNotFound},
+	} {
+		if w := doRequest(t, r, http.MethodGet, tc.path, "", tc.as); w.Code != tc.want {
+			t.Errorf("%s as %s: status = %d, want %d", tc.path, tc.as.Role, w.Code, tc.want)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package handler
+
+import "github.com/ariopri/Let-It-Be/tree/main/backend/utils/jsonschema"
+
+// checked before binding, the struct tags still apply afterwards. strings
+// are validated as sent, before binding trims them, so patterns allow the
+// surrounding space
+var registerSchema = jsonschema.MustCompile(`{
+	"type": "object",
+	"required": ["first_name", "last_name", "email", "password"],
+	"properties": {
+		"first_name": {"type": "string", "minLength": 1, "maxLength": 255},
+		"last_name": {"type": "string", "minLength": 1, "maxLength": 255},
+		"email": {"type": "string", "maxLength": 255, "pattern": "^\\s*[^@\\s]+@[^@\\s]+\\s*$"},
+		"password": {"type": "string", "minLength": 8}
+	}
+}`)
+
+var emailChangeSchema = jsonschema.MustCompile(`{
+	"type": "object",
+	"required": ["email", "password"],
+	"properties": {
+		"email": {"type": "string", "maxLength": 255, "pattern": "^\\s*[^@\\s]+@[^@\\s]+\\s*$"},
+		"password": {"type": "string", "minLength": 1}
+	}
+}`)
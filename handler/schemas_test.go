@@ -0,0 +1,33 @@
+package handler
+
+import "testing"
+
+func TestRegisterSchemaAllowsPaddedEmail(t *testing.T) {
+	// binding trims it afterwards
+	body := `{"first_name":"Uma","last_name":"User","email":"  user@example.com ","password":"secret-pass"}`
+
+	errs, err := registerSchema.ValidateJSON([]byte(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(errs) != 0 {
+		t.Errorf("padded email rejected: %+v", errs)
+	}
+}
+
+func TestRegisterSchemaViolations(t *testing.T) {
+	errs, err := registerSchema.ValidateJSON([]byte(`{"first_name":"","email":"not an email","password":"short"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string]bool{}
+	for _, e := range errs {
+		got[e.Pointer] = true
+	}
+	for _, ptr := range []string{"/first_name", "/last_name", "/email", "/password"} {
+		if !got[ptr] {
+			t.Errorf("no violation at %s in %+v", ptr, errs)
+		}
+	}
+}
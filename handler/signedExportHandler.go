@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+	"github.com/ariopri/Let-It-Be/tree/main/backend/utils/signedurl"
+	"github.com/gin-gonic/gin"
+)
+
+// key of signed export urls, nil disables them
+var ExportURLSecret []byte
+
+// how long a signed export url works
+var ExportURLTTL = time.Minute * 5
+
+const signedExportPath = "/exports/users"
+
+// issue a short lived url that downloads the export without a token,
+// e.g. for a plain browser link
+func (u *userHandler) exportURL(c *gin.Context) {
+	// role check
+	if !isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": localize(c, entities.Forbidden),
+		})
+		return
+	}
+
+	if ExportURLSecret == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": localize(c, entities.ItemNotFound),
+		})
+		return
+	}
+
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "jsonl" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": localize(c, entities.BadRequest),
+		})
+		return
+	}
+
+	expires := time.Now().Add(ExportURLTTL)
+	link := signedurl.Sign(ExportURLSecret, signedExportPath, url.Values{"format": {format}}, expires)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "export url created",
+		"url":        link,
+		"expires_at": entities.Timestamp{Time: expires},
+	})
+}
+
+// download the export through a signed url
+func (u *userHandler) signedExport(c *gin.Context) {
+	if ExportURLSecret == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": localize(c, entities.ItemNotFound),
+		})
+		return
+	}
+
+	if err := signedurl.Verify(ExportURLSecret, signedExportPath, c.Request.URL.Query(), time.Now()); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": localize(c, entities.InvalidSignedURL),
+		})
+		return
+	}
+
+	u.streamExport(c)
+}
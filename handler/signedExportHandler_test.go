@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"encoding/csv"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+	"github.com/ariopri/Let-It-Be/tree/main/backend/utils/signedurl"
+)
+
+func TestSignedExportURL(t *testing.T) {
+	ExportURLSecret = []byte("test-secret")
+	t.Cleanup(func() { ExportURLSecret = nil })
+
+	r := newTestRouter(t, newStubRepo(testAdmin, testUser))
+
+	w := doRequest(t, r, http.MethodPost, "/api/users/export/url", "", testAdmin)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	link := decodeBody(t, w)["url"].(string)
+
+	// no Authorization header needed
+	w = doRequest(t, r, http.MethodGet, link, "", entities.UserResponse{})
+	if w.Code != http.StatusOK {
+		t.Fatalf("download: status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	records, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 {
+		t.Errorf("records = %v, want a header and both users", records)
+	}
+
+	expired := signedurl.Sign(ExportURLSecret, signedExportPath, url.Values{"format": {"csv"}}, time.Now().Add(-time.Second))
+	if w := doRequest(t, r, http.MethodGet, expired, "", entities.UserResponse{}); w.Code != http.StatusForbidden {
+		t.Errorf("expired: status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+	if w := doRequest(t, r, http.MethodGet, link+"x", "", entities.UserResponse{}); w.Code != http.StatusForbidden {
+		t.Errorf("tampered: status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+	if w := doRequest(t, r, http.MethodPost, "/api/users/export/url", "", testUser); w.Code != http.StatusForbidden {
+		t.Errorf("non admin: status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+}
+
+func TestSignedExportDisabled(t *testing.T) {
+	r := newTestRouter(t, newStubRepo(testAdmin))
+
+	if w := doRequest(t, r, http.MethodPost, "/api/users/export/url", "", testAdmin); w.Code != http.StatusNotFound {
+		t.Errorf("url: status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+	if w := doRequest(t, r, http.MethodGet, signedExportPath+"?expires=1&signature=x", "", entities.UserResponse{}); w.Code != http.StatusNotFound {
+		t.Errorf("download: status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+	"github.com/gin-gonic/gin"
+)
+
+// add tags to a user
+func (u *userHandler) addTags(c *gin.Context) {
+	u.changeTags(c, u.userRepo.AddTags, "tags added")
+}
+
+// remove tags from a user
+func (u *userHandler) removeTags(c *gin.Context) {
+	u.changeTags(c, u.userRepo.RemoveTags, "tags removed")
+}
+
+func (u *userHandler) changeTags(c *gin.Context, change func(ctx context.Context, id int64, tags []string) ([]string, error), message string) {
+	// role check
+	if !isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": localize(c, entities.Forbidden),
+		})
+		return
+	}
+
+	id := c.Param("id")
+	idConv, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": localize(c, entities.BadRequest),
+		})
+		return
+	}
+
+	if emptyBody(c) {
+		return
+	}
+
+	body := entities.TagList{}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	tags, err := change(c.Request.Context(), idConv, body.Tags)
+	if errors.Is(err, entities.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": localize(c, entities.ItemNotFound),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": localize(c, entities.InternalServer),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": message,
+		"tags":    tags,
+	})
+}
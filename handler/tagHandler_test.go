@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"testing"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+)
+
+func (r *stubUserRepo) AddTags(ctx context.Context, id int64, tags []string) ([]string, error) {
+	r.mu.Lock()
+	if _, ok := r.users[id]; !ok {
+		r.mu.Unlock()
+		return nil, entities.ErrNotFound
+	}
+	if r.tags == nil {
+		r.tags = map[int64]map[string]bool{}
+	}
+	if r.tags[id] == nil {
+		r.tags[id] = map[string]bool{}
+	}
+	for _, tag := range tags {
+		r.tags[id][tag] = true
+	}
+	r.mu.Unlock()
+
+	return r.UserTags(ctx, id)
+}
+
+func (r *stubUserRepo) RemoveTags(ctx context.Context, id int64, tags []string) ([]string, error) {
+	r.mu.Lock()
+	if _, ok := r.users[id]; !ok {
+		r.mu.Unlock()
+		return nil, entities.ErrNotFound
+	}
+	for _, tag := range tags {
+		delete(r.tags[id], tag)
+	}
+	r.mu.Unlock()
+
+	return r.UserTags(ctx, id)
+}
+
+func (r *stubUserRepo) UserTags(ctx context.Context, id int64) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tags := []string{}
+	for tag := range r.tags[id] {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	return tags, nil
+}
+
+// the tags of a response
+func tagsOf(body map[string]interface{}) []interface{} {
+	tags, _ := body["tags"].([]interface{})
+
+	return tags
+}
+
+// the ids of a user listing
+func listedIDs(body map[string]interface{}) []float64 {
+	var ids []float64
+	for _, u := range body["users"].([]interface{}) {
+		ids = append(ids, u.(map[string]interface{})["id"].(float64))
+	}
+
+	return ids
+}
+
+func TestTags(t *testing.T) {
+	third := entities.UserResponse{ID: 3, Email: "third@example.com", Role: "user", Active: true}
+	r := newTestRouter(t, newStubRepo(testAdmin, testUser, third))
+
+	w := doRequest(t, r, http.MethodPost, "/api/users/2/tags", `{"tags":["vip","beta"]}`, testAdmin)
+	if w.Code != http.StatusOK {
+		t.Fatalf("add: status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	if tags := tagsOf(decodeBody(t, w)); len(tags) != 2 || tags[0] != "beta" || tags[1] != "vip" {
+		t.Errorf("after add: tags = %v, want beta and vip", tags)
+	}
+	doRequest(t, r, http.MethodPost, "/api/users/3/tags", `{"tags":["beta"]}`, testAdmin)
+
+	w = doRequest(t, r, http.MethodGet, "/api/users?tag=beta", "", testAdmin)
+	if ids := listedIDs(decodeBody(t, w)); len(ids) != 2 || ids[0] != 2 || ids[1] != 3 {
+		t.Errorf("?tag=beta lists %v, want 2 and 3", ids)
+	}
+
+	w = doRequest(t, r, http.MethodDelete, "/api/users/2/tags", `{"tags":["beta","unknown"]}`, testAdmin)
+	if w.Code != http.StatusOK {
+		t.Fatalf("remove: status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	if tags := tagsOf(decodeBody(t, w)); len(tags) != 1 || tags[0] != "vip" {
+		t.Errorf("after remove: tags = %v, want vip", tags)
+	}
+
+	w = doRequest(t, r, http.MethodGet, "/api/users?tag=beta", "", testAdmin)
+	if ids := listedIDs(decodeBody(t, w)); len(ids) != 1 || ids[0] != 3 {
+		t.Errorf("?tag=beta after removal lists %v, want 3", ids)
+	}
+}
+
+func TestTagsAreAdminOnly(t *testing.T) {
+	r := newTestRouter(t, newStubRepo(testAdmin, testUser))
+
+	if w := doRequest(t, r, http.MethodPost, "/api/users/2/tags", `{"tags":["vip"]}`, testUser); w.Code != http.StatusForbidden {
+		t.Errorf("as a user: status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+	if w := doRequest(t, r, http.MethodPost, "/api/users/99/tags", `{"tags":["vip"]}`, testAdmin); w.Code != http.StatusNotFound {
+		t.Errorf("missing user: status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+	if w := doRequest(t, r, http.MethodPost, "/api/users/2/tags", `{"tags":[]}`, testAdmin); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("no tags: status = %d, want %d", w.Code, http.StatusUnprocessableEntity)
+	}
+}
@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin/binding"
+)
+
+// trims string fields of bound structs before they are validated, fields
+// tagged `trim:"-"` are left as sent
+type trimValidator struct {
+	binding.StructValidator
+}
+
+func init() {
+	binding.Validator = &trimValidator{binding.Validator}
+}
+
+func (v *trimValidator) ValidateStruct(obj interface{}) error {
+	trimStrings(reflect.ValueOf(obj))
+
+	return v.StructValidator.ValidateStruct(obj)
+}
+
+func trimStrings(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			trimStrings(v.Elem())
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			trimStrings(v.Index(i))
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < v.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" || f.Tag.Get("trim") == "-" {
+				continue
+			}
+
+			trimStrings(v.Field(i))
+		}
+	case reflect.String:
+		if v.CanSet() {
+			v.SetString(strings.TrimSpace(v.String()))
+		}
+	}
+}
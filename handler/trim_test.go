@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+)
+
+func TestBoundStringsAreTrimmed(t *testing.T) {
+	repo := newStubRepo(testUser)
+	r := newTestRouter(t, repo)
+
+	body := `{"first_name":"  Uma\t","last_name":" Trimmed ","password":"  spaced secret  "}`
+	if w := doRequest(t, r, http.MethodPut, "/api/users/2", body, testUser); w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+
+	if repo.updated.FirstName != "Uma" || repo.updated.LastName != "Trimmed" {
+		t.Errorf("stored names %q %q, want them trimmed", repo.updated.FirstName, repo.updated.LastName)
+	}
+	// passwords opt out, their spaces are part of them
+	if repo.updated.Password != "  spaced secret  " {
+		t.Errorf("stored password %q, want it as sent", repo.updated.Password)
+	}
+}
+
+func TestTrimStringsNested(t *testing.T) {
+	type inner struct {
+		Name string
+	}
+	v := struct {
+		Name    string
+		Kept    string `trim:"-"`
+		Inner   *inner
+		List    []inner
+		private string
+	}{" a ", " b ", &inner{" c "}, []inner{{" d "}}, " e "}
+
+	trimStrings(reflect.ValueOf(&v))
+
+	if v.Name != "a" || v.Kept != " b " || v.Inner.Name != "c" || v.List[0].Name != "d" || v.private != " e " {
+		t.Errorf("trimmed to %+v", v)
+	}
+}
+
+func TestRegisterTrimsTheEmail(t *testing.T) {
+	repo := newStubRepo()
+	r := newTestRouter(t, repo)
+
+	body := `{"first_name":" New ","last_name":"User","email":"  new@example.com ","password":"` + testPassword + `"}`
+	if w := doRequest(t, r, http.MethodPost, "/register", body, entities.UserResponse{}); w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+
+	if u := repo.users[1]; u.Email != "new@example.com" || u.FirstName != "New" {
+		t.Errorf("stored %q %q, want them trimmed", u.FirstName, u.Email)
+	}
+}
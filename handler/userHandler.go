@@ -1,247 +1,1688 @@
 package handler
 
 import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
+	"reflect"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
 	"github.com/ariopri/Let-It-Be/tree/main/backend/handler/middleware"
+	"github.com/ariopri/Let-It-Be/tree/main/backend/utils/i18n"
 	"github.com/ariopri/Let-It-Be/tree/main/backend/utils/token"
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	"github.com/go-playground/validator/v10"
 )
 
+// reject tokens of disabled users on every request
+var CheckUserStatus = false
+
+// always set the token cookie on login, otherwise only with ?cookie=true
+var TokenCookie = false
+
+// largest login body accepted, a login is only an email and a password
+var LoginMaxBytes int64 = 4 << 10
+
+// limits on json write bodies, see middleware.LimitJSON
+var (
+	JSONMaxBytes    int64 = 1 << 20
+	JSONMaxDepth          = 32
+	JSONMaxElements       = 1000
+)
+
+// no tokens are issued for users with an unverified email, needs Mailer
+// to send the verification links
+var RequireVerifiedEmail = false
+
+// largest page the user listing returns, also used when no limit is given
+var MaxPageSize = 100
+
+// reject limits above MaxPageSize with 400 instead of capping them
+var StrictPagination = false
+
+// availability checks allowed per client and minute, keeps enumeration slow
+var AvailabilityRateLimit = 10
+
+// replaces the global request timeout on export routes, 0 disables it
+var ExportTimeout = time.Minute * 5
+
+// origins allowed on the public routes instead of the global CORS origins,
+// nil keeps the global ones
+var PublicCORSOrigins []string
+
+// requests allowed per authenticated user and window on /api, 0 disables it
+var (
+	UserRateLimit  = 0
+	UserRateWindow = time.Minute
+)
+
+// rejects passwords found in data breaches, nil disables the check
+var PwnedChecker interface {
+	Pwned(ctx context.Context, password string) (bool, error)
+}
+
+// receives user lifecycle events, nil disables them
+var Notifier entities.Notifier
+
+// sends confirmation links, nil disables the flows that need one
+var Mailer entities.Mailer
+
+// scheme and host the links in mails point to, set along with Mailer
+var PublicURL string
+
+// runs before a user is deleted, returning an error vetoes the deletion
+// with 409, e.g. when the user still owns resources
+var BeforeDelete func(ctx context.Context, id int64) error
+
 type userHandler struct {
-	userRepo entities.UserRepository
+	userRepo  entities.UserRepository
+	auditRepo entities.AuditRepository
+}
+
+// report json field names in validation errors
+func init() {
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(f reflect.StructField) string {
+			name := strings.SplitN(f.Tag.Get("json"), ",", 2)[0]
+			if name == "" || name == "-" {
+				return f.Name
+			}
+
+			return name
+		})
+		v.RegisterValidation("password", validatePassword)
+	}
 }
 
 // routes
-func NewUserHandler(r *gin.Engine, userRepo entities.UserRepository) {
+func NewUserHandler(r *gin.Engine, userRepo entities.UserRepository, auditRepo entities.AuditRepository) error {
+	if r == nil {
+		return errors.New("user handler: gin engine is nil")
+	}
+	if userRepo == nil {
+		return errors.New("user handler: user repository is nil")
+	}
+	if auditRepo == nil {
+		return errors.New("user handler: audit repository is nil")
+	}
+
 	handler := &userHandler{
-		userRepo: userRepo,
+		userRepo:  userRepo,
+		auditRepo: auditRepo,
 	}
 
+	passwordPolicy.source(userRepo)
+
 	// middleware
 	m := middleware.InitMiddleware()
-	auth := r.Group("/api").Use(m.JWTMiddleware())
+	var perUser gin.HandlerFunc
+	if UserRateLimit > 0 {
+		perUser = m.RateLimitByUser(UserRateLimit, UserRateWindow)
+	}
+	// per route, so tighter body limits of a route apply before it reads
+	limitJSON := m.LimitJSON(JSONMaxBytes, JSONMaxDepth, JSONMaxElements)
+	auth := r.Group("/api", middleware.Chain(
+		m.APIKey(userRepo),
+		m.JWTMiddleware(),
+		m.CurrentUser(userRepo, CheckUserStatus),
+		m.RequireJSON(),
+		limitJSON,
+		perUser,
+	)...)
 	{
 		auth.GET("/users", handler.fetch)
+		auth.GET("/users/export", m.Timeout(ExportTimeout), handler.export)
+		auth.POST("/users/export/url", handler.exportURL)
 		auth.GET("/users/:id", handler.fetchById)
 		auth.POST("/users", handler.create)
-		auth.PUT("/users/:id", handler.update)
-		auth.DELETE("/users/:id", handler.delete)
+		// a stream, the request timeout doesn't apply
+		auth.GET("/users/events", m.Timeout(0), handler.events)
+		auth.POST("/users/batch", handler.batchCreate)
+		auth.DELETE("/users/batch", handler.batchDelete)
+		auth.PUT("/users", handler.upsert)
+		auth.PUT("/users/:id", m.RequireSelfOrRole("id", "admin"), handler.update)
+		auth.PATCH("/users/:id", m.RequireSelfOrRole("id", "admin"), handler.patch)
+		auth.DELETE("/users/:id", m.RequireSelfOrRole("id", "admin"), handler.delete)
+		auth.PUT("/users/roles", handler.updateRoles)
+		auth.PUT("/users/:id/status", handler.updateStatus)
+		auth.GET("/users/:id/role-history", handler.roleHistory)
+		auth.GET("/users/:id/activity", m.RequireSelfOrRole("id", "admin"), handler.activity)
+		auth.POST("/users/:id/tags", handler.addTags)
+		auth.DELETE("/users/:id/tags", handler.removeTags)
+		auth.POST("/users/:id/logout", handler.forceLogout)
+		auth.GET("/users/:id/profile", handler.fetchProfile)
+		auth.PUT("/users/:id/profile", handler.updateProfile)
+		auth.PATCH("/users/:id/profile", handler.patchProfile)
+		auth.POST("/users/:id/impersonate", handler.impersonate)
+		auth.DELETE("/users/:id/impersonate", handler.stopImpersonate)
+		auth.GET("/me/permissions", handler.permissions)
+		auth.GET("/me/export", m.Timeout(ExportTimeout), handler.exportMe)
+		auth.POST("/me/api-key", handler.rotateAPIKey)
+		auth.PUT("/me/email", m.ValidateSchema(emailChangeSchema, JSONMaxBytes), handler.changeEmail)
+		auth.GET("/me/preferences", handler.preferences)
+		auth.PUT("/me/preferences", handler.updatePreferences)
+		auth.POST("/me/delete-request", handler.requestDeletion)
+		auth.POST("/me/delete-cancel", handler.cancelDeletion)
+		auth.POST("/admin/rotate-key", handler.rotateKey)
+		auth.GET("/admin/schema-version", handler.schemaVersion)
+		auth.GET("/audit", handler.searchAudit)
+		auth.GET("/admin/password-policy", handler.fetchPasswordPolicy)
+		auth.PUT("/admin/password-policy", handler.updatePasswordPolicy)
 	}
 
+	// uploads, the same as auth without the json requirement
+	upload := r.Group("/api", middleware.Chain(
+		m.APIKey(userRepo),
+		m.JWTMiddleware(),
+		m.CurrentUser(userRepo, CheckUserStatus),
+		perUser,
+	)...)
+	upload.POST("/users/import", handler.importUsers)
+
 	// should be public routes
-	r.POST("/login", handler.login)
-	r.POST("/register", handler.register)
+	public := r.Group("")
+	if PublicCORSOrigins != nil {
+		public.Use(m.CORS(PublicCORSOrigins))
+		for _, path := range []string{"/login", "/register", "/logout", "/availability", emailVerifyPath} {
+			public.OPTIONS(path, middleware.Preflight)
+		}
+	}
+	public.POST("/login", m.LimitBody(LoginMaxBytes), m.RequireJSON(), limitJSON, handler.login)
+	public.POST("/register", m.RequireJSON(), limitJSON, m.ValidateSchema(registerSchema, JSONMaxBytes), handler.register)
+	public.POST("/logout", handler.logout)
+	public.GET("/availability", m.RateLimit(AvailabilityRateLimit, time.Minute), handler.availability)
+	public.GET(emailConfirmPath, handler.confirmEmail)
+	public.POST(emailVerifyPath, m.RateLimit(VerificationRateLimit, time.Minute), m.RequireJSON(), limitJSON, handler.resendVerification)
+	// authorized by the signature in the url, see exportURL
+	r.GET(signedExportPath, m.Timeout(ExportTimeout), handler.signedExport)
+
+	return nil
 }
 
-func errMessage(v validator.FieldError) string {
-	m := fmt.Sprintf("error on field %s, condition: %s", v.Field(), v.ActualTag())
+func errMessage(c *gin.Context, v validator.FieldError) string {
+	m := fmt.Sprintf(localize(c, entities.FieldError), v.Field(), v.ActualTag())
 
 	return m
 }
 
-// login
-func (u *userHandler) login(c *gin.Context) {
+// respond with 422 and the failing fields for validation errors, 400 for
+// anything else the binding rejects
+func bindError(c *gin.Context, err error) {
+	errs, ok := err.(validator.ValidationErrors)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": localize(c, entities.BadRequest),
+		})
+		return
+	}
+
+	fields := make([]gin.H, 0, len(errs))
+	for _, v := range errs {
+		fields = append(fields, gin.H{
+			"field":     v.Field(),
+			"condition": v.ActualTag(),
+			"message":   errMessage(c, v),
+		})
+	}
+
+	c.JSON(http.StatusUnprocessableEntity, gin.H{
+		"message": localize(c, entities.ValidationFailed),
+		"errors":  fields,
+	})
+}
+
+// publish a lifecycle event to the event stream and the notifier if one
+// is configured
+func notify(event string, data interface{}) {
+	e := entities.Event{
+		Type:       event,
+		Data:       data,
+		OccurredAt: entities.Timestamp{Time: time.Now()},
+	}
+
+	eventHub.Notify(e)
+	if Notifier != nil {
+		Notifier.Notify(e)
+	}
+}
+
+// fingerprint to bind new tokens to, empty unless token.BindFingerprint
+func clientFingerprint(c *gin.Context) string {
+	if !token.BindFingerprint {
+		return ""
+	}
+
+	return token.Fingerprint(c.Request.UserAgent(), c.ClientIP())
+}
+
+// report whether the client sent Prefer: return=minimal (RFC 7240) and
+// acknowledge it. representation is the default
+func preferMinimal(c *gin.Context) bool {
+	for _, v := range c.Request.Header.Values("Prefer") {
+		for _, pref := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(pref), "return=minimal") {
+				c.Header("Preference-Applied", "return=minimal")
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// translate msg to the language the client asked for
+func localize(c *gin.Context, msg string) string {
+	return i18n.Translate(c.GetHeader("Accept-Language"), msg)
+}
+
+// role check from the jwt claims
+func isAdmin(c *gin.Context) bool {
+	claims, ok := c.Get("user")
+	if !ok {
+		return false
+	}
+
+	return claims.(*token.Claims).Role == "admin"
+}
+
+// set the token as an httpOnly cookie for browser clients
+func setTokenCookie(c *gin.Context, tokenStr string, expTime time.Time) {
+	if !TokenCookie && c.Query("cookie") != "true" {
+		return
+	}
+
+	c.SetSameSite(http.SameSiteStrictMode)
+	c.SetCookie(token.CookieName, tokenStr, int(time.Until(expTime).Seconds()), "/", "", true, true)
+}
+
+// respond with 400 if the request has no body
+func emptyBody(c *gin.Context) bool {
+	if c.Request.Body != nil && c.Request.Body != http.NoBody && c.Request.ContentLength != 0 {
+		return false
+	}
+
+	c.JSON(http.StatusBadRequest, gin.H{
+		"message": localize(c, entities.BodyRequired),
+	})
+
+	return true
+}
+
+// respond with 422 if password shows up in a known breach. the check fails
+// open, an unreachable api must not block signups
+func breachedPassword(c *gin.Context, password string) bool {
+	if !isBreached(c.Request.Context(), password) {
+		return false
+	}
+
+	c.JSON(http.StatusUnprocessableEntity, gin.H{
+		"message": localize(c, entities.ValidationFailed),
+		"errors": []gin.H{{
+			"field":     "password",
+			"condition": "breached",
+			"message":   localize(c, entities.PasswordBreached),
+		}},
+	})
+
+	return true
+}
+
+func isBreached(ctx context.Context, password string) bool {
+	if PwnedChecker == nil || password == "" {
+		return false
+	}
+
+	pwned, err := PwnedChecker.Pwned(ctx, password)
+	if err != nil {
+		log.Printf("pwned check: %v", err)
+		return false
+	}
+
+	return pwned
+}
+
+// respond with 409 if err is a unique constraint violation
+func conflict(c *gin.Context, err error) bool {
+	var dupErr *entities.DuplicateError
+	if !errors.As(err, &dupErr) {
+		return false
+	}
+
+	c.JSON(http.StatusConflict, gin.H{
+		"message": fmt.Sprintf(localize(c, entities.AlreadyExists), dupErr.Field),
+		"field":   dupErr.Field,
+	})
+
+	return true
+}
+
+// respond with 422 if err rejects a recently used password
+func passwordReused(c *gin.Context, err error) bool {
+	if !errors.Is(err, entities.ErrPasswordReused) {
+		return false
+	}
+
+	c.JSON(http.StatusUnprocessableEntity, gin.H{
+		"message": localize(c, entities.ValidationFailed),
+		"errors": []gin.H{{
+			"field":     "password",
+			"condition": "history",
+			"message":   localize(c, entities.PasswordReused),
+		}},
+	})
+
+	return true
+}
+
+// respond with 429 and Retry-After if err says the password was changed
+// too recently
+func passwordCooldown(c *gin.Context, err error) bool {
+	var cooldown *entities.PasswordCooldownError
+	if !errors.As(err, &cooldown) {
+		return false
+	}
+
+	middleware.SetRetryAfter(c, cooldown.RetryAfter)
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"message": localize(c, entities.PasswordChangeTooSoon),
+	})
+
+	return true
+}
+
+// login
+func (u *userHandler) login(c *gin.Context) {
+	ctx := c.Request.Context()
+	var login entities.Login
+
+	if emptyBody(c) {
+		return
+	}
+
+	if err := c.ShouldBind(&login); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	userLogin, err := u.userRepo.Login(ctx, &login)
+	if errors.Is(err, entities.ErrUserDisabled) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": localize(c, entities.UserDisabled),
+		})
+
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": localize(c, entities.InternalServer),
+		})
+
+		return
+	}
+
+	if RequireVerifiedEmail && !userLogin.EmailVerified {
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": localize(c, entities.EmailNotVerified),
+		})
+
+		return
+	}
+
+	// sent along so clients don't need a request for it right after
+	profile, err := u.userRepo.FetchProfile(ctx, userLogin.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": localize(c, entities.InternalServer),
+		})
+
+		return
+	}
+	userLogin.Profile = &profile
+
+	// JWT
+	ttl := token.TokenTTL
+	if login.Remember {
+		ttl = token.RememberTTL
+	}
+	tokenStr, expTime, _ := token.CreateTokenTTL(userLogin.ID, userLogin.Email, userLogin.Role, userLogin.TokenVersion, clientFingerprint(c), ttl)
+	setTokenCookie(c, tokenStr, expTime)
+
+	u.recordActivityOf(c, userLogin.Email, entities.AuditLogin, userLogin.Email)
+
+	c.JSON(http.StatusOK, entities.LoginResponse{
+		Message:     "user logged in",
+		Token:       tokenStr,
+		TokenType:   token.TokenType,
+		ExpiresAt:   entities.Timestamp{Time: expTime},
+		Data:        &userLogin,
+		Permissions: entities.Permissions(userLogin.Role),
+	})
+}
+
+// logout, clears the token cookie
+func (u *userHandler) logout(c *gin.Context) {
+	// revoke the presented token so it stops working before it expires
+	tokenStr, _ := token.FromHeader(c.GetHeader("Authorization"))
+	if tokenStr == "" {
+		tokenStr, _ = c.Cookie(token.CookieName)
+	}
+	if claims, err := token.ValidateToken(tokenStr); err == nil {
+		if err := token.Revoke(claims); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": localize(c, entities.InternalServer),
+			})
+			return
+		}
+	}
+
+	c.SetSameSite(http.SameSiteStrictMode)
+	c.SetCookie(token.CookieName, "", -1, "/", "", true, true)
+
+	c.JSON(http.StatusOK, entities.MessageResponse{
+		Message: "user logged out",
+	})
+}
+
+// check if an email is still free, answers nothing but a bool
+func (u *userHandler) availability(c *gin.Context) {
+	ctx := c.Request.Context()
+	query := entities.Availability{}
+
+	if err := c.ShouldBindQuery(&query); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	exists, err := u.userRepo.EmailExists(ctx, query.Email)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": localize(c, entities.InternalServer),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"available": !exists,
+	})
+}
+
+// register
+func (u *userHandler) register(c *gin.Context) {
+	ctx := c.Request.Context()
+	user := entities.User{}
+
+	if emptyBody(c) {
+		return
+	}
+
+	if err := c.ShouldBind(&user); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	if breachedPassword(c, user.Password) {
+		return
+	}
+
+	// before registering, which replaces the password with its hash
+	warnings := registerWarnings(c, &user)
+
+	userData, err := u.userRepo.Register(ctx, &user)
+	if conflict(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": localize(c, entities.InternalServer),
+		})
+		return
+	}
+
+	notify(entities.EventUserCreated, userData)
+
+	// the account is usable either way, a lost mail can be sent again
+	if Mailer != nil && !userData.EmailVerified {
+		if err := u.sendVerification(ctx, userData); err != nil {
+			log.Printf("mailing the verification of user %d: %v", userData.ID, err)
+		}
+	}
+
+	minimal := preferMinimal(c)
+
+	// no auto login until the email is verified
+	if RequireVerifiedEmail && !userData.EmailVerified {
+		res := gin.H{
+			"message": "user registered, please verify your email before logging in",
+			"data":    userData,
+		}
+		if minimal {
+			res = gin.H{"message": res["message"], "id": userData.ID}
+		}
+		if warnings != nil {
+			res["warnings"] = warnings
+		}
+
+		c.JSON(http.StatusCreated, res)
+		return
+	}
+
+	// JWT
+	tokenStr, expTime, _ := token.CreateToken(userData.ID, userData.Email, userData.Role, userData.TokenVersion, clientFingerprint(c))
+	setTokenCookie(c, tokenStr, expTime)
+
+	res := entities.LoginResponse{
+		Message:   "user registered",
+		Token:     tokenStr,
+		TokenType: token.TokenType,
+		ExpiresAt: entities.Timestamp{Time: expTime},
+		Data:      &userData,
+		Warnings:  warnings,
+	}
+	if minimal {
+		res.ID, res.Data = userData.ID, nil
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+// remarks on an accepted registration, nil when there are none
+func registerWarnings(c *gin.Context, user *entities.User) []entities.Warning {
+	var warnings []entities.Warning
+	if entities.WeakPassword(user.Password) {
+		warnings = append(warnings, entities.Warning{
+			Field:     "password",
+			Condition: "weak",
+			Message:   localize(c, entities.PasswordWeak),
+		})
+	}
+
+	return warnings
+}
+
+// fetch users
+func (u *userHandler) fetch(c *gin.Context) {
+	ctx := c.Request.Context()
+	filter := entities.UserFilter{}
+
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": localize(c, entities.BadRequest),
+		})
+		return
+	}
+
+	fields, err := parseFields(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": localize(c, entities.BadRequest),
+		})
+		return
+	}
+
+	for _, role := range filter.Roles() {
+		if _, ok := entities.RolePermissions[role]; !ok {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": localize(c, entities.InvalidRole),
+				"role":    role,
+			})
+			return
+		}
+	}
+
+	// batch fetch by ids, ?ids=1,2,3
+	if ids := c.Query("ids"); ids != "" {
+		u.fetchByIDs(c, ids, fields)
+		return
+	}
+
+	if filter.Limit > MaxPageSize && StrictPagination {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message":   localize(c, entities.LimitTooLarge),
+			"max_limit": MaxPageSize,
+		})
+		return
+	}
+	if filter.Limit == 0 || filter.Limit > MaxPageSize {
+		filter.Limit = MaxPageSize
+	}
+
+	users, err := u.userRepo.Fetch(ctx, &filter)
+	if errors.Is(err, entities.ErrInvalidSort) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": localize(c, entities.InvalidSort),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": localize(c, entities.InternalServer),
+		})
+
+		return
+	}
+
+	// role check
+	if !isAdmin(c) {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"message": localize(c, entities.Unauthorized),
+		})
+
+		return
+	}
+
+	out, err := pickFields(users, fields)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": localize(c, entities.InternalServer),
+		})
+		return
+	}
+
+	var total *int64
+	if filter.Count != "" && filter.Count != entities.CountNone {
+		n, err := u.userRepo.Count(ctx, &filter, filter.Count)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": localize(c, entities.InternalServer),
+			})
+			return
+		}
+		total = &n
+	}
+
+	meta := entities.ListMeta{
+		Limit:  filter.Limit,
+		Offset: filter.Offset,
+		Count:  len(users),
+		Total:  total,
+	}
+	setLinkHeader(c, meta)
+
+	c.JSON(http.StatusOK, entities.UserListResponse{
+		Message: "users fetched",
+		Users:   out,
+		Meta:    &meta,
+	})
+}
+
+// fetch users by a comma separated id list
+func (u *userHandler) fetchByIDs(c *gin.Context, idList string, fields []string) {
+	ctx := c.Request.Context()
+
+	// role check
+	if !isAdmin(c) {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"message": localize(c, entities.Unauthorized),
+		})
+		return
+	}
+
+	parts := strings.Split(idList, ",")
+	if len(parts) > MaxPageSize {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message":   localize(c, entities.LimitTooLarge),
+			"max_limit": MaxPageSize,
+		})
+		return
+	}
+
+	ids := make([]int64, 0, len(parts))
+	for _, p := range parts {
+		id, err := strconv.ParseInt(strings.TrimSpace(p), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": localize(c, entities.BadRequest),
+			})
+			return
+		}
+
+		ids = append(ids, id)
+	}
+
+	users, missing, err := u.userRepo.FetchByIDs(ctx, ids)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": localize(c, entities.InternalServer),
+		})
+		return
+	}
+
+	out, err := pickFields(users, fields)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": localize(c, entities.InternalServer),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, entities.UserListResponse{
+		Message: "users fetched",
+		Users:   out,
+		Missing: missing,
+	})
+}
+
+// fetch user by id
+func (u *userHandler) fetchById(c *gin.Context) {
+	ctx := c.Request.Context()
+	id := c.Param("id")
+	idConv, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": localize(c, entities.BadRequest),
+		})
+		return
+	}
+
+	fields, err := parseFields(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": localize(c, entities.BadRequest),
+		})
+		return
+	}
+
+	user, err := u.userRepo.FetchById(ctx, idConv)
+	if errors.Is(err, entities.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": localize(c, entities.ItemNotFound),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": localize(c, entities.InternalServer),
+		})
+		return
+	}
+
+	// only the owner and admins see the full user
+	var view interface{} = user
+	claims := c.MustGet("user").(*token.Claims)
+	if claims.Email != user.Email && !isAdmin(c) {
+		view = user.Public()
+	} else {
+		c.Header("Last-Modified", user.UpdatedAt.UTC().Format(http.TimeFormat))
+	}
+
+	out, err := pickFields(view, fields)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": localize(c, entities.InternalServer),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "user fetched",
+		"user":    out,
+	})
+}
+
+// create user
+func (u *userHandler) create(c *gin.Context) {
+	ctx := c.Request.Context()
+	user := entities.User{}
+
+	if emptyBody(c) {
+		return
+	}
+
+	if err := c.ShouldBind(&user); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	if breachedPassword(c, user.Password) {
+		return
+	}
+
+	userData, err := u.userRepo.Create(ctx, &user)
+	if conflict(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": localize(c, entities.InternalServer),
+		})
+		return
+	}
+
+	notify(entities.EventUserCreated, userData)
+
+	c.Header("Location", fmt.Sprintf("/api/users/%d", userData.ID))
+	if preferMinimal(c) {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "user created",
+			"id":      userData.ID,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "user created",
+		"data":    userData,
+	})
+}
+
+// create or update a user by email
+func (u *userHandler) upsert(c *gin.Context) {
+	ctx := c.Request.Context()
+	user := entities.User{}
+
+	// role check
+	if !isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": localize(c, entities.Forbidden),
+		})
+		return
+	}
+
+	if emptyBody(c) {
+		return
+	}
+
+	if err := c.ShouldBind(&user); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	if breachedPassword(c, user.Password) {
+		return
+	}
+
+	userData, created, err := u.userRepo.Upsert(ctx, &user)
+	if conflict(c, err) {
+		return
+	}
+	if passwordReused(c, err) {
+		return
+	}
+	if passwordCooldown(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": localize(c, entities.InternalServer),
+		})
+		return
+	}
+
+	if created {
+		notify(entities.EventUserCreated, userData)
+
+		c.JSON(http.StatusCreated, gin.H{
+			"message": "user created",
+			"data":    userData,
+		})
+		return
+	}
+
+	notify(entities.EventUserUpdated, userData)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "user updated",
+		"user":    userData,
+	})
+}
+
+// update user
+func (u *userHandler) update(c *gin.Context) {
+	id := c.Param("id")
+	idConv, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": localize(c, entities.BadRequest),
+		})
+		return
+	}
+
+	// only the fields of UserUpdate can be set here
+	user := entities.UserUpdate{}
+
+	if emptyBody(c) {
+		return
+	}
+
+	if err := c.ShouldBind(&user); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	if u.modifiedSince(c, idConv) {
+		return
+	}
+
+	u.save(c, idConv, user.User())
+}
+
+// partially update user with a json merge patch, nulls delete fields
+func (u *userHandler) patch(c *gin.Context) {
+	ctx := c.Request.Context()
+	id := c.Param("id")
+	idConv, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": localize(c, entities.BadRequest),
+		})
+		return
+	}
+
+	if emptyBody(c) {
+		return
+	}
+
+	var patch interface{}
+	if err := json.NewDecoder(c.Request.Body).Decode(&patch); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": localize(c, entities.BadRequest),
+		})
+		return
+	}
+
+	current, err := u.userRepo.FetchById(ctx, idConv)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": localize(c, entities.ItemNotFound),
+		})
+		return
+	}
+
+	// the password hash never leaves the repository, an absent password
+	// keeps the stored one. the email only changes through /api/me/email
+	doc := map[string]interface{}{
+		"first_name": current.FirstName,
+		"last_name":  current.LastName,
+	}
+
+	merged, err := json.Marshal(mergePatch(doc, patch))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": localize(c, entities.InternalServer),
+		})
+		return
+	}
+
+	user := entities.UserPatch{}
+	if err := json.Unmarshal(merged, &user); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": localize(c, entities.BadRequest),
+		})
+		return
+	}
+	if err := binding.Validator.ValidateStruct(&user); err != nil {
+		bindError(c, err)
+		return
+	}
+
+	if u.modifiedSince(c, idConv) {
+		return
+	}
+
+	u.save(c, idConv, user.User())
+}
+
+// conditional update, respond with 412 if the user changed since the
+// If-Unmodified-Since time
+func (u *userHandler) modifiedSince(c *gin.Context, id int64) bool {
+	since := c.GetHeader("If-Unmodified-Since")
+	if since == "" {
+		return false
+	}
+
+	t, err := http.ParseTime(since)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": localize(c, entities.BadRequest),
+		})
+		return true
+	}
+
+	current, err := u.userRepo.FetchById(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": localize(c, entities.InternalServer),
+		})
+		return true
+	}
+
+	// http dates have second precision
+	if current.UpdatedAt.Truncate(time.Second).After(t) {
+		c.JSON(http.StatusPreconditionFailed, gin.H{
+			"message": localize(c, entities.PreconditionFailed),
+		})
+		return true
+	}
+
+	return false
+}
+
+// persist an update and respond with the stored user
+func (u *userHandler) save(c *gin.Context, id int64, user *entities.User) {
+	if breachedPassword(c, user.Password) {
+		return
+	}
+
+	userData, err := u.userRepo.Update(c.Request.Context(), id, user)
+	if conflict(c, err) {
+		return
+	}
+	if passwordReused(c, err) {
+		return
+	}
+	if passwordCooldown(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": localize(c, entities.InternalServer),
+		})
+		return
+	}
+
+	notify(entities.EventUserUpdated, userData)
+
+	c.Header("Last-Modified", userData.UpdatedAt.UTC().Format(http.TimeFormat))
+	c.JSON(http.StatusOK, gin.H{
+		"message": "user updated",
+		"user":    userData,
+	})
+}
+
+// delete user
+func (u *userHandler) delete(c *gin.Context) {
 	ctx := c.Request.Context()
-	var login entities.Login
+	id := c.Param("id")
 
-	if err := c.ShouldBind(&login); err != nil {
-		for _, v := range err.(validator.ValidationErrors) {
-			eM := errMessage(v)
+	idConv, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": localize(c, entities.BadRequest),
+		})
+		return
+	}
 
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"message": eM,
+	if BeforeDelete != nil {
+		if err := BeforeDelete(ctx, idConv); err != nil {
+			c.JSON(http.StatusConflict, gin.H{
+				"message": localize(c, entities.DeleteVetoed),
+				"reason":  err.Error(),
 			})
-
 			return
 		}
 	}
 
-	userLogin, err := u.userRepo.Login(ctx, &login)
+	err = u.userRepo.Delete(ctx, idConv)
+	if errors.Is(err, entities.ErrReferenced) {
+		c.JSON(http.StatusConflict, gin.H{
+			"message": localize(c, entities.UserReferenced),
+		})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"message": entities.InternalServer,
+			"message": localize(c, entities.ItemNotFound),
 		})
-
 		return
 	}
 
-	// JWT
-	token, _ := token.CreateToken(userLogin.Email, userLogin.Role)
+	notify(entities.EventUserDeleted, gin.H{"id": idConv})
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "user logged in",
-		"token":   token,
-		"data":    userLogin,
+		"message": "user deleted",
 	})
 }
 
-// register
-func (u *userHandler) register(c *gin.Context) {
+// enable or disable user
+func (u *userHandler) updateStatus(c *gin.Context) {
 	ctx := c.Request.Context()
-	user := entities.User{}
 
-	if err := c.ShouldBind(&user); err != nil {
+	// role check
+	if !isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": localize(c, entities.Forbidden),
+		})
+		return
+	}
+
+	id := c.Param("id")
+	idConv, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"message": entities.BadRequest,
+			"message": localize(c, entities.BadRequest),
 		})
 		return
 	}
 
-	userData, err := u.userRepo.Register(ctx, &user)
+	status := entities.UserStatus{}
+	if err := c.ShouldBind(&status); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": localize(c, entities.BadRequest),
+		})
+		return
+	}
+
+	userData, err := u.userRepo.UpdateStatus(ctx, idConv, *status.Active)
+	if errors.Is(err, entities.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": localize(c, entities.ItemNotFound),
+		})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"message": entities.InternalServer,
+			"message": localize(c, entities.InternalServer),
 		})
 		return
 	}
 
-	// JWT
-	token, _ := token.CreateToken(userData.Email, userData.Role)
+	notify(entities.EventUserUpdated, userData)
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "user registered",
-		"data":    userData,
-		"token":   token,
+		"message": "user status updated",
+		"user":    userData,
 	})
 }
 
-// fetch users
-func (u *userHandler) fetch(c *gin.Context) {
+// issue a short lived token for another user
+func (u *userHandler) impersonate(c *gin.Context) {
 	ctx := c.Request.Context()
-	users, err := u.userRepo.Fetch(ctx)
+
+	// role check
+	if !isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": localize(c, entities.Forbidden),
+		})
+		return
+	}
+
+	claims := c.MustGet("user").(*token.Claims)
+	if claims.ImpersonatedBy != "" {
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": localize(c, entities.Forbidden),
+		})
+		return
+	}
+
+	id := c.Param("id")
+	idConv, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": localize(c, entities.BadRequest),
+		})
+		return
+	}
+
+	target, err := u.userRepo.FetchById(ctx, idConv)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": localize(c, entities.ItemNotFound),
+		})
+		return
+	}
+
+	tokenStr, expTime, err := token.CreateImpersonationToken(target.ID, target.Email, target.Role, target.TokenVersion, claims.Email, clientFingerprint(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"message": entities.InternalServer,
+			"message": localize(c, entities.InternalServer),
 		})
+		return
+	}
 
+	err = u.auditRepo.Create(ctx, &entities.AuditLog{
+		Actor:  claims.Email,
+		Action: entities.AuditImpersonateStart,
+		Target: target.Email,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": localize(c, entities.InternalServer),
+		})
 		return
 	}
 
-	// role check
-	auth := c.Request.Header.Get("Authorization")
+	c.JSON(http.StatusOK, entities.LoginResponse{
+		Message:        "impersonating user",
+		Token:          tokenStr,
+		TokenType:      token.TokenType,
+		ExpiresAt:      entities.Timestamp{Time: expTime},
+		ImpersonatedBy: claims.Email,
+		Data:           &target,
+	})
+}
+
+// stop impersonating, returns a regular token for the admin
+func (u *userHandler) stopImpersonate(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	claims := c.MustGet("user").(*token.Claims)
+	if claims.ImpersonatedBy == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": localize(c, entities.BadRequest),
+		})
+		return
+	}
 
-	token, _ := token.ValidateToken(auth)
+	id := c.Param("id")
+	idConv, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": localize(c, entities.BadRequest),
+		})
+		return
+	}
+
+	target, err := u.userRepo.FetchById(ctx, idConv)
+	if err != nil || target.Email != claims.Email {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": localize(c, entities.BadRequest),
+		})
+		return
+	}
 
-	if token.Role != "admin" {
+	admin, err := u.userRepo.FetchByEmail(ctx, claims.ImpersonatedBy)
+	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{
-			"message": entities.Unauthorized,
+			"message": localize(c, entities.Unauthorized),
 		})
+		return
+	}
 
+	tokenStr, expTime, err := token.CreateToken(admin.ID, admin.Email, admin.Role, admin.TokenVersion, clientFingerprint(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": localize(c, entities.InternalServer),
+		})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "users fetched",
-		"users":   users,
+	err = u.auditRepo.Create(ctx, &entities.AuditLog{
+		Actor:  admin.Email,
+		Action: entities.AuditImpersonateStop,
+		Target: target.Email,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": localize(c, entities.InternalServer),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, entities.LoginResponse{
+		Message:   "impersonation stopped",
+		Token:     tokenStr,
+		TokenType: token.TokenType,
+		ExpiresAt: entities.Timestamp{Time: expTime},
+		Data:      &admin,
 	})
 }
 
-// fetch user by id
-func (u *userHandler) fetchById(c *gin.Context) {
+// revoke every token of a user
+func (u *userHandler) forceLogout(c *gin.Context) {
 	ctx := c.Request.Context()
+
+	// role check
+	if !isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": localize(c, entities.Forbidden),
+		})
+		return
+	}
+
 	id := c.Param("id")
-	idConv, err := strconv.Atoi(id)
+	idConv, err := strconv.ParseInt(id, 10, 64)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"message": entities.BadRequest,
+			"message": localize(c, entities.BadRequest),
+		})
+		return
+	}
+
+	user, err := u.userRepo.FetchById(ctx, idConv)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": localize(c, entities.ItemNotFound),
+		})
+		return
+	}
+
+	if err := u.userRepo.BumpTokenVersion(ctx, idConv); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": localize(c, entities.InternalServer),
 		})
 		return
 	}
 
-	user, err := u.userRepo.FetchById(ctx, int64(idConv))
+	claims := c.MustGet("user").(*token.Claims)
+	err = u.auditRepo.Create(ctx, &entities.AuditLog{
+		Actor:  claims.Email,
+		Action: entities.AuditForceLogout,
+		Target: user.Email,
+	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"message": entities.InternalServer,
+			"message": localize(c, entities.InternalServer),
 		})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "user fetched",
-		"user":    user,
+		"message": "user logged out",
 	})
 }
 
-// create user
-func (u *userHandler) create(c *gin.Context) {
+// batch role assignment
+func (u *userHandler) updateRoles(c *gin.Context) {
 	ctx := c.Request.Context()
-	user := entities.User{}
 
-	if err := c.ShouldBind(&user); err != nil {
+	// role check
+	if !isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": localize(c, entities.Forbidden),
+		})
+		return
+	}
+
+	var roles []entities.RoleAssignment
+	if err := c.ShouldBindJSON(&roles); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"message": entities.BadRequest,
+			"message": localize(c, entities.BadRequest),
 		})
 		return
 	}
+	if !batchSize(c, len(roles)) {
+		return
+	}
 
-	userData, err := u.userRepo.Create(ctx, &user)
+	// all or nothing in one transaction, so the last admin guard sees the
+	// whole batch. items report the failing one, the others weren't applied
+	claims := c.MustGet("user").(*token.Claims)
+	users, err := u.userRepo.UpdateRoles(ctx, roles, claims.Email)
+
+	var itemErr *entities.BatchItemError
+	b := newBatch(len(roles))
+	for i, r := range roles {
+		switch {
+		case err == nil:
+			b.ok(i, r.ID, http.StatusOK)
+		case errors.As(err, &itemErr) && itemErr.Index != i:
+			b.fail(i, r.ID, http.StatusFailedDependency, localize(c, entities.BatchNotApplied))
+		default:
+			// the failing item, or all of them for batch wide errors
+			b.failErr(c, i, r.ID, err)
+		}
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"message": entities.InternalServer,
+		b.respond(c, "user roles not updated")
+		return
+	}
+
+	for _, user := range users {
+		notify(entities.EventUserUpdated, user)
+	}
+	b.respond(c, "user roles updated")
+}
+
+// create several users, each succeeds or fails on its own
+func (u *userHandler) batchCreate(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	// role check
+	if !isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": localize(c, entities.Forbidden),
+		})
+		return
+	}
+
+	var users []entities.User
+	if err := c.ShouldBindJSON(&users); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": localize(c, entities.BadRequest),
+		})
+		return
+	}
+	if !batchSize(c, len(users)) {
+		return
+	}
+
+	b := newBatch(len(users))
+	for i := range users {
+		user := &users[i]
+		if err := binding.Validator.ValidateStruct(user); err != nil {
+			b.failErr(c, i, 0, err)
+			continue
+		}
+
+		if isBreached(ctx, user.Password) {
+			b.fail(i, 0, http.StatusUnprocessableEntity, localize(c, entities.PasswordBreached))
+			continue
+		}
+
+		userData, err := u.userRepo.Create(ctx, user)
+		if err != nil {
+			b.failErr(c, i, 0, err)
+			continue
+		}
+
+		notify(entities.EventUserCreated, userData)
+		b.ok(i, userData.ID, http.StatusCreated)
+	}
+
+	b.respond(c, "users created")
+}
+
+// delete several users by id, each succeeds or fails on its own
+func (u *userHandler) batchDelete(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	// role check
+	if !isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": localize(c, entities.Forbidden),
 		})
 		return
 	}
 
+	var list entities.IDList
+	if err := c.ShouldBindJSON(&list); err != nil {
+		bindError(c, err)
+		return
+	}
+	if !batchSize(c, len(list.IDs)) {
+		return
+	}
+
+	b := newBatch(len(list.IDs))
+	for i, id := range list.IDs {
+		if BeforeDelete != nil {
+			if err := BeforeDelete(ctx, id); err != nil {
+				b.fail(i, id, http.StatusConflict, localize(c, entities.DeleteVetoed))
+				continue
+			}
+		}
+
+		if err := u.userRepo.Delete(ctx, id); err != nil {
+			b.failErr(c, i, id, err)
+			continue
+		}
+
+		notify(entities.EventUserDeleted, gin.H{"id": id})
+		b.ok(i, id, http.StatusOK)
+	}
+
+	b.respond(c, "users deleted")
+}
+
+// permissions of the authenticated user
+func (u *userHandler) permissions(c *gin.Context) {
+	claims := c.MustGet("user").(*token.Claims)
+
 	c.JSON(http.StatusOK, gin.H{
-		"message": "user created",
-		"data":    userData,
+		"message":     "permissions fetched",
+		"role":        claims.Role,
+		"permissions": entities.Permissions(claims.Role),
 	})
 }
 
-// update user
-func (u *userHandler) update(c *gin.Context) {
+// export all users as csv or json lines, streamed row by row
+func (u *userHandler) export(c *gin.Context) {
+	// role check
+	if !isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": localize(c, entities.Forbidden),
+		})
+		return
+	}
+
+	u.streamExport(c)
+}
+
+// stream the users in the format asked for by ?format=
+func (u *userHandler) streamExport(c *gin.Context) {
 	ctx := c.Request.Context()
-	id := c.Param("id")
-	idConv, _ := strconv.Atoi(id)
-	user := entities.User{}
 
-	if err := c.ShouldBind(&user); err != nil {
+	var write func(entities.UserResponse) error
+	var flush func()
+
+	switch c.DefaultQuery("format", "csv") {
+	case "csv":
+		w := csv.NewWriter(c.Writer)
+		c.Header("Content-Type", "text/csv")
+		c.Header("Content-Disposition", `attachment; filename="users.csv"`)
+
+		if err := w.Write([]string{"id", "first_name", "last_name", "email", "role", "active", "created_at"}); err != nil {
+			return
+		}
+
+		write = func(user entities.UserResponse) error {
+			return w.Write([]string{
+				strconv.FormatInt(user.ID, 10),
+				user.FirstName,
+				user.LastName,
+				user.Email,
+				user.Role,
+				strconv.FormatBool(user.Active),
+				user.CreatedAt.UTC().Format(time.RFC3339),
+			})
+		}
+		flush = w.Flush
+	case "jsonl":
+		enc := json.NewEncoder(c.Writer)
+		c.Header("Content-Type", "application/x-ndjson")
+		c.Header("Content-Disposition", `attachment; filename="users.jsonl"`)
+
+		// Encode terminates every object with a newline
+		write = func(user entities.UserResponse) error {
+			return enc.Encode(user)
+		}
+		flush = func() {}
+	default:
 		c.JSON(http.StatusBadRequest, gin.H{
-			"message": entities.BadRequest,
+			"message": localize(c, entities.BadRequest),
+		})
+		return
+	}
+
+	c.Status(http.StatusOK)
+
+	n := 0
+	err := u.userRepo.Export(ctx, func(user entities.UserResponse) error {
+		if err := write(user); err != nil {
+			return err
+		}
+
+		// keep memory flat on big tables
+		if n++; n%100 == 0 {
+			flush()
+			c.Writer.Flush()
+		}
+
+		return nil
+	})
+	if err != nil {
+		// headers are gone already, just cut the stream
+		c.Abort()
+		return
+	}
+
+	flush()
+	c.Writer.Flush()
+}
+
+// export everything stored about the current user as one json document
+func (u *userHandler) exportMe(c *gin.Context) {
+	ctx := c.Request.Context()
+	user := c.MustGet("current_user").(entities.UserResponse)
+
+	profile, err := u.userRepo.FetchProfile(ctx, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": localize(c, entities.InternalServer),
 		})
 		return
 	}
 
-	userData, err := u.userRepo.Update(ctx, int64(idConv), &user)
+	audit, err := u.auditRepo.ByUser(ctx, user.Email, 0, 0)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"message": entities.InternalServer,
+			"message": localize(c, entities.InternalServer),
 		})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "user updated",
-		"user":    userData,
+	c.Header("Content-Disposition", `attachment; filename="me.json"`)
+	c.JSON(http.StatusOK, entities.UserExport{
+		User:       user,
+		Profile:    profile,
+		Audit:      audit,
+		ExportedAt: entities.Timestamp{Time: time.Now()},
 	})
 }
 
-// delete user
-func (u *userHandler) delete(c *gin.Context) {
-	ctx := c.Request.Context()
-	id := c.Param("id")
+// role changes of a user, oldest first
+func (u *userHandler) roleHistory(c *gin.Context) {
+	// role check
+	if !isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": localize(c, entities.Forbidden),
+		})
+		return
+	}
 
-	idConv, err := strconv.Atoi(id)
+	id := c.Param("id")
+	idConv, err := strconv.ParseInt(id, 10, 64)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"message": entities.BadRequest,
+			"message": localize(c, entities.BadRequest),
 		})
 		return
 	}
 
-	if err := u.userRepo.Delete(ctx, int64(idConv)); err != nil {
+	history, err := u.userRepo.RoleHistory(c.Request.Context(), idConv)
+	if errors.Is(err, entities.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": localize(c, entities.ItemNotFound),
+		})
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"message": entities.ItemNotFound,
+			"message": localize(c, entities.InternalServer),
 		})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "user deleted",
+		"message": "role history fetched",
+		"history": history,
 	})
 }
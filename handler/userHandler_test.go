@@ -0,0 +1,1077 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+	"github.com/ariopri/Let-It-Be/tree/main/backend/handler/middleware"
+	"github.com/ariopri/Let-It-Be/tree/main/backend/utils/token"
+	"github.com/gin-gonic/gin"
+)
+
+func TestDisabledUserCantLogIn(t *testing.T) {
+	repo := newStubRepo(testAdmin, testUser)
+	r := newTestRouter(t, repo)
+
+	w := doRequest(t, r, http.MethodPut, "/api/users/2/status", `{"active":false}`, testAdmin)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	if repo.users[testUser.ID].Active {
+		t.Fatal("the user is still active")
+	}
+
+	w = login(t, r, testUser, "")
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("login status = %d, want %d: %s", w.Code, http.StatusForbidden, w.Body)
+	}
+	if res := decodeBody(t, w); res["message"] != entities.UserDisabled {
+		t.Errorf("message = %v, want %q", res["message"], entities.UserDisabled)
+	}
+
+	// enabled again
+	if w := doRequest(t, r, http.MethodPut, "/api/users/2/status", `{"active":true}`, testAdmin); w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	if w := login(t, r, testUser, ""); w.Code != http.StatusOK {
+		t.Errorf("login after enabling: status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+}
+
+func TestTokensOfDisabledUsers(t *testing.T) {
+	for _, check := range []bool{false, true} {
+		CheckUserStatus = check
+		t.Cleanup(func() { CheckUserStatus = false })
+
+		repo := newStubRepo(testAdmin, testUser)
+		r := newTestRouter(t, repo)
+
+		// issued while the user was active
+		req := newRequest(t, http.MethodGet, "/api/me/permissions", "", testUser)
+
+		if w := doRequest(t, r, http.MethodPut, "/api/users/2/status", `{"active":false}`, testAdmin); w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+		}
+
+		want := http.StatusOK
+		if check {
+			want = http.StatusForbidden
+		}
+		w := serve(r, req)
+		if w.Code != want {
+			t.Fatalf("checking %v: status = %d, want %d: %s", check, w.Code, want, w.Body)
+		}
+		if res := decodeBody(t, w); check && res["message"] != entities.UserDisabled {
+			t.Errorf("message = %v, want %q", res["message"], entities.UserDisabled)
+		}
+	}
+}
+
+func TestUpdateStatusRejects(t *testing.T) {
+	for _, tc := range []struct {
+		path string
+		body string
+		as   entities.UserResponse
+		want int
+	}{
+		{"/api/users/1/status", `{"active":false}`, testUser, http.StatusForbidden},
+		{"/api/users/x/status", `{"active":false}`, testAdmin, http.StatusBadRequest},
+		{"/api/users/2/status", `{}`, testAdmin, http.StatusBadRequest},
+	} {
+		repo := newStubRepo(testAdmin, testUser)
+
+		if w := doRequest(t, newTestRouter(t, repo), http.MethodPut, tc.path, tc.body, tc.as); w.Code != tc.want {
+			t.Errorf("%s %s as %s: status = %d, want %d: %s", tc.path, tc.body, tc.as.Role, w.Code, tc.want, w.Body)
+		}
+		if !repo.users[testAdmin.ID].Active {
+			t.Errorf("%s %s as %s: the admin was disabled", tc.path, tc.body, tc.as.Role)
+		}
+	}
+}
+
+func TestUpdateIgnoresProtectedFields(t *testing.T) {
+	repo := newStubRepo(testUser)
+	r := newTestRouter(t, repo)
+
+	body := `{"first_name":"Uma","last_name":"Changed","email":"user@example.com",
+		"role":"admin","active":false,"email_verified":false}`
+	w := doRequest(t, r, http.MethodPut, "/api/users/2", body, testUser)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+
+	u := repo.updated
+	if u.Role != "" || u.Active || u.EmailVerified {
+		t.Errorf("protected fields reached the repository: role %q, active %v, email_verified %v", u.Role, u.Active, u.EmailVerified)
+	}
+}
+
+func TestUpdateWithoutPasswordKeepsIt(t *testing.T) {
+	repo := newStubRepo(testUser)
+	r := newTestRouter(t, repo)
+
+	body := `{"first_name":"Uma","last_name":"Changed","email":"user@example.com"}`
+	w := doRequest(t, r, http.MethodPut, "/api/users/2", body, testUser)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+
+	if repo.updated.Password != "" {
+		t.Errorf("password = %q, want empty so the stored one is kept", repo.updated.Password)
+	}
+}
+
+func TestUpdateDuringPasswordCooldown(t *testing.T) {
+	repo := newStubRepo(testUser)
+	repo.updateErr = &entities.PasswordCooldownError{RetryAfter: 1500 * time.Millisecond}
+	r := newTestRouter(t, repo)
+
+	body := `{"first_name":"Uma","last_name":"User","email":"user@example.com","password":"brand-new-pass"}`
+	w := doRequest(t, r, http.MethodPut, "/api/users/2", body, testUser)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusTooManyRequests, w.Body)
+	}
+	if got := w.Header().Get("Retry-After"); got != "2" {
+		t.Errorf("Retry-After = %q, want %q", got, "2")
+	}
+}
+
+func TestNewUserHandlerRejectsNil(t *testing.T) {
+	if err := NewUserHandler(nil, newStubRepo(), &stubAuditRepo{}); err == nil {
+		t.Error("nil engine accepted")
+	}
+	if err := NewUserHandler(gin.New(), nil, &stubAuditRepo{}); err == nil {
+		t.Error("nil user repository accepted")
+	}
+	if err := NewUserHandler(gin.New(), newStubRepo(), nil); err == nil {
+		t.Error("nil audit repository accepted")
+	}
+}
+
+func TestLoginReturnsTokenExpiry(t *testing.T) {
+	w := login(t, newTestRouter(t, newStubRepo(testUser)), testUser, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+
+	var res entities.LoginResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+
+	if res.TokenType != "Bearer" {
+		t.Errorf("token_type = %q, want Bearer", res.TokenType)
+	}
+	if left := time.Until(res.ExpiresAt.Time); left <= token.TokenTTL-time.Minute || left > token.TokenTTL {
+		t.Errorf("expires_at %v is not a TokenTTL away", res.ExpiresAt)
+	}
+
+	claims, err := token.ValidateToken(res.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.ExpiresAt != res.ExpiresAt.Unix() {
+		t.Errorf("expires_at %v doesn't match the token's exp %d", res.ExpiresAt, claims.ExpiresAt)
+	}
+}
+
+func TestConcurrentRegistrationsOneWins(t *testing.T) {
+	r := newTestRouter(t, newStubRepo())
+
+	const n = 10
+	codes := make(chan int, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			body := `{"first_name":"Nia","last_name":"New","email":"race@example.com","password":"Str0ng-pass!"}`
+			req := httptest.NewRequest(http.MethodPost, "/register", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			codes <- serve(r, req).Code
+		}()
+	}
+	wg.Wait()
+	close(codes)
+
+	count := map[int]int{}
+	for code := range codes {
+		count[code]++
+	}
+	if count[http.StatusOK] != 1 || count[http.StatusConflict] != n-1 {
+		t.Errorf("statuses = %v, want one %d and the rest %d", count, http.StatusOK, http.StatusConflict)
+	}
+}
+
+func TestLoginIncludesProfileAndPermissions(t *testing.T) {
+	repo := newStubRepo(testUser)
+	repo.profiles = map[int64]entities.Profile{testUser.ID: {UserID: testUser.ID, DisplayName: "Uma U.", Locale: "id-ID"}}
+
+	w := login(t, newTestRouter(t, repo), testUser, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+
+	var res entities.LoginResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Data == nil || res.Data.Profile == nil {
+		t.Fatalf("data = %+v, want the user with a profile", res.Data)
+	}
+	if p := res.Data.Profile; p.DisplayName != "Uma U." || p.Locale != "id-ID" {
+		t.Errorf("profile = %+v", p)
+	}
+	want := entities.Permissions(testUser.Role)
+	if strings.Join(res.Permissions, ",") != strings.Join(want, ",") || len(want) == 0 {
+		t.Errorf("permissions = %v, want %v", res.Permissions, want)
+	}
+	if strings.Contains(w.Body.String(), "password") {
+		t.Errorf("body = %s, want no password in it", w.Body)
+	}
+}
+
+func TestLoginRememberExtendsTokenLifetime(t *testing.T) {
+	for _, tc := range []struct {
+		extra string
+		ttl   time.Duration
+	}{
+		{"", token.TokenTTL},
+		{`,"remember":false`, token.TokenTTL},
+		{`,"remember":true`, token.RememberTTL},
+	} {
+		w := login(t, newTestRouter(t, newStubRepo(testUser)), testUser, tc.extra)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%q: status = %d, want %d: %s", tc.extra, w.Code, http.StatusOK, w.Body)
+		}
+
+		var res entities.LoginResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+			t.Fatal(err)
+		}
+
+		if left := time.Until(res.ExpiresAt.Time); left <= tc.ttl-time.Minute || left > tc.ttl {
+			t.Errorf("%q: expires_at %v is not %v away", tc.extra, res.ExpiresAt, tc.ttl)
+		}
+
+		claims, err := token.ValidateToken(res.Token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if claims.ExpiresAt != res.ExpiresAt.Unix() {
+			t.Errorf("%q: expires_at %v doesn't match the token's exp %d", tc.extra, res.ExpiresAt, claims.ExpiresAt)
+		}
+	}
+}
+
+func TestLoginBodyIsCapped(t *testing.T) {
+	w := login(t, newTestRouter(t, newStubRepo(testUser)), testUser, `,"padding":"`+strings.Repeat("x", int(LoginMaxBytes))+`"`)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusRequestEntityTooLarge)
+	}
+}
+
+func TestRegisterReturnsTokenExpiry(t *testing.T) {
+	body := `{"first_name":"Nia","last_name":"New","email":"new@example.com","password":"Str0ng-pass!"}`
+	w := doRequest(t, newTestRouter(t, newStubRepo()), http.MethodPost, "/register", body, entities.UserResponse{})
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+
+	res := decodeBody(t, w)
+	if res["token_type"] != "Bearer" {
+		t.Errorf("token_type = %v, want Bearer", res["token_type"])
+	}
+	if _, err := time.Parse(time.RFC3339, res["expires_at"].(string)); err != nil {
+		t.Errorf("expires_at: %v", err)
+	}
+}
+
+func TestRegisterWarnsAboutWeakPasswords(t *testing.T) {
+	for _, tc := range []struct {
+		password string
+		verify   bool
+		want     int
+		weak     bool
+	}{
+		{"Str0ng-pass!", false, http.StatusOK, false},
+		{"abcdefgh", false, http.StatusOK, true},
+		{"Str0ng-pass!", true, http.StatusCreated, false},
+		{"abcdefgh", true, http.StatusCreated, true},
+	} {
+		if tc.verify {
+			useStubMailer(t)
+		}
+		RequireVerifiedEmail = tc.verify
+		t.Cleanup(func() { RequireVerifiedEmail = false })
+
+		body := `{"first_name":"Nia","last_name":"New","email":"new@example.com","password":"` + tc.password + `"}`
+		w := doRequest(t, newTestRouter(t, newStubRepo(testAdmin)), http.MethodPost, "/register", body, entities.UserResponse{})
+		// a weak password still registers
+		if w.Code != tc.want {
+			t.Fatalf("%q: status = %d, want %d: %s", tc.password, w.Code, tc.want, w.Body)
+		}
+
+		res := decodeBody(t, w)
+		warnings, _ := res["warnings"].([]interface{})
+		if !tc.weak {
+			if _, ok := res["warnings"]; ok {
+				t.Errorf("%q: warnings = %v, want none", tc.password, res["warnings"])
+			}
+			continue
+		}
+
+		if len(warnings) != 1 {
+			t.Fatalf("%q: warnings = %v, want one", tc.password, res["warnings"])
+		}
+		warning := warnings[0].(map[string]interface{})
+		if warning["field"] != "password" || warning["condition"] != "weak" || warning["message"] != entities.PasswordWeak {
+			t.Errorf("%q: warning = %v", tc.password, warning)
+		}
+	}
+}
+
+func TestLoginTokenCookie(t *testing.T) {
+	r := newTestRouter(t, newStubRepo(testUser))
+	body := `{"email":"` + testUser.Email + `","password":"` + testPassword + `"}`
+
+	if w := doRequest(t, r, http.MethodPost, "/login", body, entities.UserResponse{}); len(w.Result().Cookies()) != 0 {
+		t.Errorf("cookies set without asking: %v", w.Result().Cookies())
+	}
+
+	w := doRequest(t, r, http.MethodPost, "/login?cookie=true", body, entities.UserResponse{})
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != token.CookieName {
+		t.Fatalf("cookies = %v, want the token cookie", cookies)
+	}
+	c := cookies[0]
+	if !c.HttpOnly || !c.Secure || c.SameSite != http.SameSiteStrictMode || c.Path != "/" {
+		t.Errorf("cookie attributes httpOnly=%v secure=%v sameSite=%v path=%q", c.HttpOnly, c.Secure, c.SameSite, c.Path)
+	}
+	if c.Value != decodeBody(t, w)["token"] {
+		t.Error("cookie doesn't hold the returned token")
+	}
+	if ttl := time.Duration(c.MaxAge) * time.Second; ttl <= token.TokenTTL-time.Minute || ttl > token.TokenTTL {
+		t.Errorf("cookie max age %v, want about %v", ttl, token.TokenTTL)
+	}
+
+	// the cookie alone authenticates
+	req := httptest.NewRequest(http.MethodGet, "/api/me/permissions", nil)
+	req.AddCookie(c)
+	authed := httptest.NewRecorder()
+	r.ServeHTTP(authed, req)
+	if authed.Code != http.StatusOK {
+		t.Errorf("request with the cookie: status = %d, want %d", authed.Code, http.StatusOK)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/logout", nil)
+	req.AddCookie(c)
+	out := httptest.NewRecorder()
+	r.ServeHTTP(out, req)
+
+	cleared := out.Result().Cookies()
+	if len(cleared) != 1 || cleared[0].Name != token.CookieName || cleared[0].MaxAge >= 0 {
+		t.Errorf("logout cookies = %v, want the token cookie cleared", cleared)
+	}
+}
+
+func TestRegisterConflictNamesTheField(t *testing.T) {
+	repo := newStubRepo()
+	repo.registerErr = &entities.DuplicateError{Field: "email"}
+
+	body := `{"first_name":"Nia","last_name":"New","email":"user@example.com","password":"Str0ng-pass!"}`
+	w := doRequest(t, newTestRouter(t, repo), http.MethodPost, "/register", body, entities.UserResponse{})
+	if w.Code != http.StatusConflict {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusConflict, w.Body)
+	}
+	if res := decodeBody(t, w); res["field"] != "email" {
+		t.Errorf("field = %v, want email", res["field"])
+	}
+}
+
+func TestUpdateIfUnmodifiedSince(t *testing.T) {
+	updatedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	user := testUser
+	user.UpdatedAt = entities.Timestamp{Time: updatedAt}
+
+	body := `{"first_name":"Uma","last_name":"Changed"}`
+	for _, tc := range []struct {
+		since string
+		code  int
+	}{
+		{updatedAt.Format(http.TimeFormat), http.StatusOK},
+		{updatedAt.Add(time.Hour).Format(http.TimeFormat), http.StatusOK},
+		{updatedAt.Add(-time.Second).Format(http.TimeFormat), http.StatusPreconditionFailed},
+		{"yesterday", http.StatusBadRequest},
+	} {
+		repo := newStubRepo(user)
+
+		req := newRequest(t, http.MethodPut, "/api/users/2", body, user)
+		req.Header.Set("If-Unmodified-Since", tc.since)
+		w := serve(newTestRouter(t, repo), req)
+
+		if w.Code != tc.code {
+			t.Errorf("since %q: status = %d, want %d: %s", tc.since, w.Code, tc.code, w.Body)
+		}
+		if updated := repo.updated != nil; updated != (tc.code == http.StatusOK) {
+			t.Errorf("since %q: updated = %v", tc.since, updated)
+		}
+	}
+}
+
+func TestFetchByIdLastModified(t *testing.T) {
+	user := testUser
+	user.UpdatedAt = entities.Timestamp{Time: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)}
+
+	w := doRequest(t, newTestRouter(t, newStubRepo(user)), http.MethodGet, "/api/users/2", "", user)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	if got, want := w.Header().Get("Last-Modified"), "Wed, 01 May 2024 12:00:00 GMT"; got != want {
+		t.Errorf("Last-Modified = %q, want %q", got, want)
+	}
+}
+
+func TestPermissionsOfCurrentUser(t *testing.T) {
+	w := doRequest(t, newTestRouter(t, newStubRepo(testUser)), http.MethodGet, "/api/me/permissions", "", testUser)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+
+	var res struct {
+		Role        string   `json:"role"`
+		Permissions []string `json:"permissions"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+
+	want := entities.Permissions("user")
+	if res.Role != "user" || len(res.Permissions) != len(want) {
+		t.Fatalf("got %+v, want role user with %v", res, want)
+	}
+	for i := range want {
+		if res.Permissions[i] != want[i] {
+			t.Errorf("permissions = %v, want %v", res.Permissions, want)
+		}
+	}
+}
+
+func TestFetchByIdScopedToViewer(t *testing.T) {
+	stranger := entities.UserResponse{ID: 3, Email: "stranger@example.com", Role: "user", Active: true}
+	r := newTestRouter(t, newStubRepo(testAdmin, testUser, stranger))
+
+	for _, tc := range []struct {
+		viewer entities.UserResponse
+		full   bool
+	}{
+		{testUser, true},
+		{testAdmin, true},
+		{stranger, false},
+	} {
+		w := doRequest(t, r, http.MethodGet, "/api/users/2", "", tc.viewer)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d, want %d: %s", tc.viewer.Email, w.Code, http.StatusOK, w.Body)
+		}
+
+		user := decodeBody(t, w)["user"].(map[string]interface{})
+		if user["first_name"] != testUser.FirstName {
+			t.Errorf("%s: user = %v, want the names", tc.viewer.Email, user)
+		}
+		for _, field := range []string{"email", "role", "active", "email_verified", "updated_at"} {
+			if _, ok := user[field]; ok != tc.full {
+				t.Errorf("%s: %s shown = %v, want %v", tc.viewer.Email, field, ok, tc.full)
+			}
+		}
+	}
+}
+
+func TestEmptyBodyIsBadRequest(t *testing.T) {
+	r := newTestRouter(t, newStubRepo(testUser))
+
+	for _, path := range []string{"/login", "/register"} {
+		req := newRequest(t, http.MethodPost, path, "", entities.UserResponse{})
+		req.Header.Set("Content-Type", "application/json")
+		w := serve(r, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("%s: status = %d, want %d: %s", path, w.Code, http.StatusBadRequest, w.Body)
+		}
+		if msg := decodeBody(t, w)["message"]; msg != entities.BodyRequired {
+			t.Errorf("%s: message = %v, want %q", path, msg, entities.BodyRequired)
+		}
+	}
+}
+
+func TestMessagesFollowAcceptLanguage(t *testing.T) {
+	r := newTestRouter(t, newStubRepo(testUser))
+
+	for lang, want := range map[string]string{
+		"":               entities.BodyRequired,
+		"en-US":          entities.BodyRequired,
+		"id-ID,en;q=0.5": "body permintaan wajib diisi",
+		"fr":             entities.BodyRequired,
+	} {
+		req := newRequest(t, http.MethodPost, "/login", "", entities.UserResponse{})
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept-Language", lang)
+		w := serve(r, req)
+
+		if msg := decodeBody(t, w)["message"]; msg != want {
+			t.Errorf("Accept-Language %q: message = %v, want %q", lang, msg, want)
+		}
+	}
+}
+
+func TestWriteRoutesRequireJSON(t *testing.T) {
+	r := newTestRouter(t, newStubRepo(testUser))
+
+	req := newRequest(t, http.MethodPost, "/login", `email=user@example.com`, entities.UserResponse{})
+	req.Header.Set("Content-Type", "text/plain")
+	w := serve(r, req)
+
+	if w.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusUnsupportedMediaType)
+	}
+	if msg := decodeBody(t, w)["message"]; msg != entities.UnsupportedMediaType {
+		t.Errorf("message = %v, want %q", msg, entities.UnsupportedMediaType)
+	}
+}
+
+func TestBeforeDeleteCanVeto(t *testing.T) {
+	owner := entities.UserResponse{ID: 3, Email: "owner@example.com", Role: "user", Active: true}
+	repo := newStubRepo(testAdmin, testUser, owner)
+	r := newTestRouter(t, repo)
+
+	var asked []int64
+	BeforeDelete = func(ctx context.Context, id int64) error {
+		asked = append(asked, id)
+		if id == owner.ID {
+			return errors.New("user owns 2 projects")
+		}
+		return nil
+	}
+	t.Cleanup(func() { BeforeDelete = nil })
+
+	w := doRequest(t, r, http.MethodDelete, "/api/users/3", "", testAdmin)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("vetoed: status = %d, want %d: %s", w.Code, http.StatusConflict, w.Body)
+	}
+	body := decodeBody(t, w)
+	if body["message"] != entities.DeleteVetoed || body["reason"] != "user owns 2 projects" {
+		t.Errorf("vetoed: body = %v", body)
+	}
+	if _, ok := repo.users[owner.ID]; !ok {
+		t.Error("a vetoed user was deleted")
+	}
+
+	if w := doRequest(t, r, http.MethodDelete, "/api/users/2", "", testAdmin); w.Code != http.StatusOK {
+		t.Fatalf("allowed: status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	if _, ok := repo.users[testUser.ID]; ok {
+		t.Error("an allowed user wasn't deleted")
+	}
+
+	if len(asked) != 2 || asked[0] != owner.ID || asked[1] != testUser.ID {
+		t.Errorf("BeforeDelete ran for %v, want 3 then 2", asked)
+	}
+}
+
+func TestHandlersTakeInt64IDs(t *testing.T) {
+	big := entities.UserResponse{ID: math.MaxInt64, FirstName: "Big", Email: "big@example.com", Role: "user", Active: true}
+	r := newTestRouter(t, newStubRepo(testAdmin, big))
+
+	w := doRequest(t, r, http.MethodGet, "/api/users/9223372036854775807", "", testAdmin)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	if id := decodeBody(t, w)["user"].(map[string]interface{})["id"]; id != float64(math.MaxInt64) {
+		t.Errorf("id = %v, want %d", id, int64(math.MaxInt64))
+	}
+
+	// one past the range is malformed rather than wrapped around
+	if w := doRequest(t, r, http.MethodGet, "/api/users/9223372036854775808", "", testAdmin); w.Code != http.StatusBadRequest {
+		t.Errorf("overflow: status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestRegisterAndLoginReportFieldErrors(t *testing.T) {
+	r := newTestRouter(t, newStubRepo(testUser))
+
+	for path, body := range map[string]string{
+		"/register": `{"first_name":"New","last_name":"User","email":"not-an-email"}`,
+		"/login":    `{"email":"not-an-email"}`,
+	} {
+		w := doRequest(t, r, http.MethodPost, path, body, entities.UserResponse{})
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("%s: status = %d, want %d: %s", path, w.Code, http.StatusUnprocessableEntity, w.Body)
+		}
+
+		res := decodeBody(t, w)
+		if res["message"] != entities.ValidationFailed {
+			t.Errorf("%s: message = %v, want %q", path, res["message"], entities.ValidationFailed)
+		}
+
+		// register's schema reports json pointers rather than field names
+		fields := map[string]bool{}
+		for _, e := range res["errors"].([]interface{}) {
+			e := e.(map[string]interface{})
+			if field, ok := e["field"].(string); ok {
+				fields[field] = true
+			}
+			if pointer, ok := e["pointer"].(string); ok {
+				fields[strings.TrimPrefix(pointer, "/")] = true
+			}
+			if msg, _ := e["message"].(string); msg == "" {
+				t.Errorf("%s: %v has no message", path, e)
+			}
+		}
+		if !fields["email"] || !fields["password"] {
+			t.Errorf("%s: errors = %v, want email and password", path, res["errors"])
+		}
+	}
+}
+
+func (r *stubUserRepo) EmailExists(ctx context.Context, email string) (bool, error) {
+	_, err := r.FetchByEmail(ctx, email)
+	if errors.Is(err, entities.ErrNotFound) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+func TestAvailability(t *testing.T) {
+	r := newTestRouter(t, newStubRepo(testUser))
+
+	for email, want := range map[string]bool{
+		testUser.Email:     false,
+		"free@example.com": true,
+	} {
+		w := doRequest(t, r, http.MethodGet, "/availability?email="+email, "", entities.UserResponse{})
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d, want %d", email, w.Code, http.StatusOK)
+		}
+
+		body := decodeBody(t, w)
+		if body["available"] != want || len(body) != 1 {
+			t.Errorf("%s: body = %v, want only available %v", email, body, want)
+		}
+	}
+
+	if w := doRequest(t, r, http.MethodGet, "/availability?email=nope", "", entities.UserResponse{}); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("malformed email: status = %d, want %d", w.Code, http.StatusUnprocessableEntity)
+	}
+}
+
+func TestAvailabilityIsRateLimited(t *testing.T) {
+	AvailabilityRateLimit = 2
+	t.Cleanup(func() { AvailabilityRateLimit = 10 })
+	r := newTestRouter(t, newStubRepo(testUser))
+
+	for i := 0; i < 2; i++ {
+		if w := doRequest(t, r, http.MethodGet, "/availability?email=a@example.com", "", entities.UserResponse{}); w.Code != http.StatusOK {
+			t.Fatalf("request %d: status = %d, want %d", i+1, w.Code, http.StatusOK)
+		}
+	}
+	if w := doRequest(t, r, http.MethodGet, "/availability?email=b@example.com", "", entities.UserResponse{}); w.Code != http.StatusTooManyRequests {
+		t.Errorf("over the limit: status = %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+}
+
+// records the events handlers notify
+type stubNotifier struct {
+	mu     sync.Mutex
+	events []entities.Event
+}
+
+func (n *stubNotifier) Notify(e entities.Event) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.events = append(n.events, e)
+}
+
+func TestLifecycleEventsAreNotified(t *testing.T) {
+	notifier := &stubNotifier{}
+	Notifier = notifier
+	t.Cleanup(func() { Notifier = nil })
+
+	r := newTestRouter(t, newStubRepo(testAdmin, testUser))
+
+	body := `{"first_name":"New","last_name":"User","email":"new@example.com","password":"` + testPassword + `"}`
+	if w := doRequest(t, r, http.MethodPost, "/register", body, entities.UserResponse{}); w.Code != http.StatusOK {
+		t.Fatalf("register: status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	if w := doRequest(t, r, http.MethodDelete, "/api/users/2", "", testAdmin); w.Code != http.StatusOK {
+		t.Fatalf("delete: status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+
+	var types []string
+	for _, e := range notifier.events {
+		types = append(types, e.Type)
+		if e.OccurredAt.IsZero() {
+			t.Errorf("%s has no occurred_at", e.Type)
+		}
+	}
+	if len(types) != 2 || types[0] != entities.EventUserCreated || types[1] != entities.EventUserDeleted {
+		t.Errorf("events = %v, want created then deleted", types)
+	}
+}
+
+func (r *stubUserRepo) BumpTokenVersion(ctx context.Context, id int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	u, ok := r.users[id]
+	if !ok {
+		return entities.ErrNotFound
+	}
+	u.TokenVersion++
+	r.users[id] = u
+
+	return nil
+}
+
+func TestForceLogoutRevokesTokens(t *testing.T) {
+	r, audit := newAuditedRouter(t, newStubRepo(testAdmin, testUser))
+
+	userToken, _, err := token.CreateToken(testUser.ID, testUser.Email, testUser.Role, testUser.TokenVersion, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := doRequestToken(t, r, http.MethodGet, "/api/me/permissions", "", userToken); w.Code != http.StatusOK {
+		t.Fatalf("before: status = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	if w := doRequestToken(t, r, http.MethodPost, "/api/users/1/logout", "", userToken); w.Code != http.StatusForbidden {
+		t.Errorf("as a user: status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+	if w := doRequest(t, r, http.MethodPost, "/api/users/2/logout", "", testAdmin); w.Code != http.StatusOK {
+		t.Fatalf("logout: status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+
+	w := doRequestToken(t, r, http.MethodGet, "/api/me/permissions", "", userToken)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("after: status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+	if msg := decodeBody(t, w)["message"]; msg != entities.TokenRevoked {
+		t.Errorf("message = %v, want %q", msg, entities.TokenRevoked)
+	}
+
+	// the admin's own session is untouched
+	if w := doRequest(t, r, http.MethodGet, "/api/me/permissions", "", testAdmin); w.Code != http.StatusOK {
+		t.Errorf("admin after: status = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	logs := audit.logs
+	if len(logs) == 0 || logs[len(logs)-1].Action != entities.AuditForceLogout || logs[len(logs)-1].Target != testUser.Email {
+		t.Errorf("audit = %+v, want a force logout of %s", logs, testUser.Email)
+	}
+}
+
+// keyed by email like the repository's upsert
+func (r *stubUserRepo) Upsert(ctx context.Context, u *entities.User) (entities.UserResponse, bool, error) {
+	existing, err := r.FetchByEmail(ctx, u.Email)
+	created := errors.Is(err, entities.ErrNotFound)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user := existing
+	if created {
+		user = entities.UserResponse{ID: int64(len(r.users) + 1), Email: u.Email, Role: "user", Active: true}
+	}
+	user.FirstName, user.LastName = u.FirstName, u.LastName
+	r.users[user.ID] = user
+
+	return user, created, nil
+}
+
+func TestUpsertCreatesOrUpdates(t *testing.T) {
+	repo := newStubRepo(testAdmin, testUser)
+	r := newTestRouter(t, repo)
+
+	body := `{"first_name":"New","last_name":"User","email":"new@example.com","password":"` + testPassword + `"}`
+	w := doRequest(t, r, http.MethodPut, "/api/users", body, testAdmin)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("insert: status = %d, want %d: %s", w.Code, http.StatusCreated, w.Body)
+	}
+	if data := decodeBody(t, w)["data"].(map[string]interface{}); data["id"] != float64(3) {
+		t.Errorf("insert: data = %v, want user 3", data)
+	}
+
+	body = `{"first_name":"Renamed","last_name":"User","email":"` + testUser.Email + `","password":"` + testPassword + `"}`
+	w = doRequest(t, r, http.MethodPut, "/api/users", body, testAdmin)
+	if w.Code != http.StatusOK {
+		t.Fatalf("update: status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	if user := decodeBody(t, w)["user"].(map[string]interface{}); user["id"] != float64(testUser.ID) || user["first_name"] != "Renamed" {
+		t.Errorf("update: user = %v, want user 2 renamed", user)
+	}
+	if len(repo.users) != 3 {
+		t.Errorf("%d users, want 3", len(repo.users))
+	}
+
+	if w := doRequest(t, r, http.MethodPut, "/api/users", body, testUser); w.Code != http.StatusForbidden {
+		t.Errorf("as a user: status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+}
+
+// a database failing every lookup by id
+type brokenFetchRepo struct {
+	*stubUserRepo
+}
+
+func (r brokenFetchRepo) FetchById(ctx context.Context, id int64) (entities.UserResponse, error) {
+	return entities.UserResponse{}, errors.New("connection refused")
+}
+
+func TestFetchByIdMissingOrFailing(t *testing.T) {
+	w := doRequest(t, newTestRouter(t, newStubRepo(testAdmin)), http.MethodGet, "/api/users/99", "", testAdmin)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("missing: status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+	if msg := decodeBody(t, w)["message"]; msg != entities.ItemNotFound {
+		t.Errorf("missing: message = %v, want %q", msg, entities.ItemNotFound)
+	}
+
+	w = doRequest(t, newTestRouter(t, brokenFetchRepo{newStubRepo(testAdmin)}), http.MethodGet, "/api/users/1", "", testAdmin)
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("db error: status = %d, want %d", w.Code, http.StatusInternalServerError)
+	}
+}
+
+func (r *stubUserRepo) Create(ctx context.Context, u *entities.User) (entities.UserResponse, error) {
+	return r.Register(ctx, u)
+}
+
+func TestPreferReturn(t *testing.T) {
+	for _, tc := range []struct {
+		path   string
+		user   entities.UserResponse
+		prefer string
+	}{
+		{"/register", entities.UserResponse{}, ""},
+		{"/register", entities.UserResponse{}, "return=representation"},
+		{"/register", entities.UserResponse{}, "respond-async, return=minimal"},
+		{"/api/users", testAdmin, ""},
+		{"/api/users", testAdmin, "return=minimal"},
+	} {
+		r := newTestRouter(t, newStubRepo(testAdmin))
+		body := `{"first_name":"New","last_name":"User","email":"new@example.com","password":"` + testPassword + `"}`
+		req := newRequest(t, http.MethodPost, tc.path, body, tc.user)
+		if tc.prefer != "" {
+			req.Header.Set("Prefer", tc.prefer)
+		}
+		w := serve(r, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s %q: status = %d, want %d: %s", tc.path, tc.prefer, w.Code, http.StatusOK, w.Body)
+		}
+
+		res := decodeBody(t, w)
+		minimal := strings.Contains(tc.prefer, "return=minimal")
+		if _, ok := res["data"]; ok == minimal {
+			t.Errorf("%s %q: data sent = %v, want %v", tc.path, tc.prefer, ok, !minimal)
+		}
+		if id, ok := res["id"]; ok != minimal || (minimal && id != float64(2)) {
+			t.Errorf("%s %q: id = %v, want it only when minimal", tc.path, tc.prefer, id)
+		}
+		if applied := w.Header().Get("Preference-Applied"); (applied == "return=minimal") != minimal {
+			t.Errorf("%s %q: Preference-Applied = %q", tc.path, tc.prefer, applied)
+		}
+	}
+}
+
+func TestUpdateAndDeleteAreSelfOrAdmin(t *testing.T) {
+	stranger := entities.UserResponse{ID: 3, Email: "stranger@example.com", Role: "user", Active: true}
+	r := newTestRouter(t, newStubRepo(testAdmin, testUser, stranger))
+	body := `{"first_name":"Uma","last_name":"User"}`
+
+	if w := doRequest(t, r, http.MethodPut, "/api/users/2", body, stranger); w.Code != http.StatusForbidden {
+		t.Errorf("update by another user: status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+	if w := doRequest(t, r, http.MethodDelete, "/api/users/2", "", stranger); w.Code != http.StatusForbidden {
+		t.Errorf("delete by another user: status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+	if w := doRequest(t, r, http.MethodPut, "/api/users/2", body, testAdmin); w.Code != http.StatusOK {
+		t.Errorf("update by an admin: status = %d, want %d", w.Code, http.StatusOK)
+	}
+	if w := doRequest(t, r, http.MethodDelete, "/api/users/3", "", stranger); w.Code != http.StatusOK {
+		t.Errorf("delete of self: status = %d, want %d", w.Code, http.StatusOK)
+	}
+}
+
+func TestPublicRoutesUseTheirOwnCORS(t *testing.T) {
+	PublicCORSOrigins = []string{"https://login.example.com"}
+	t.Cleanup(func() { PublicCORSOrigins = nil })
+
+	r := gin.New()
+	r.Use(middleware.InitMiddleware().CORS([]string{"https://app.example.com"}))
+	if err := NewUserHandler(r, newStubRepo(testUser), &stubAuditRepo{}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		method, path, origin string
+		want                 string
+	}{
+		{http.MethodOptions, "/login", "https://login.example.com", "https://login.example.com"},
+		{http.MethodOptions, "/login", "https://app.example.com", ""},
+		{http.MethodPost, "/logout", "https://login.example.com", "https://login.example.com"},
+		{http.MethodPost, "/logout", "https://app.example.com", ""},
+		{http.MethodOptions, "/api/users", "https://app.example.com", "https://app.example.com"},
+		{http.MethodOptions, "/api/users", "https://login.example.com", ""},
+	} {
+		req := newRequest(t, tc.method, tc.path, "", entities.UserResponse{})
+		req.Header.Set("Origin", tc.origin)
+		w := serve(r, req)
+
+		if tc.method == http.MethodOptions && w.Code != http.StatusNoContent {
+			t.Errorf("%s %s from %s: status = %d, want %d", tc.method, tc.path, tc.origin, w.Code, http.StatusNoContent)
+		}
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != tc.want {
+			t.Errorf("%s %s from %s: allowed origin = %q, want %q", tc.method, tc.path, tc.origin, got, tc.want)
+		}
+	}
+}
+
+func TestUserRateLimitOnAPIRoutes(t *testing.T) {
+	UserRateLimit = 1
+	t.Cleanup(func() { UserRateLimit = 0 })
+
+	r := newTestRouter(t, newStubRepo(testAdmin, testUser))
+
+	if w := doRequest(t, r, http.MethodGet, "/api/users/2", "", testUser); w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	if w := doRequest(t, r, http.MethodGet, "/api/users/2", "", testUser); w.Code != http.StatusTooManyRequests {
+		t.Errorf("over the limit: status = %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+	if w := doRequest(t, r, http.MethodGet, "/api/users/2", "", testAdmin); w.Code != http.StatusOK {
+		t.Errorf("another user: status = %d, want %d", w.Code, http.StatusOK)
+	}
+}
+
+// reports the listed passwords as breached, or err for every password
+type stubPwned struct {
+	breached map[string]bool
+	err      error
+}
+
+func (p stubPwned) Pwned(ctx context.Context, password string) (bool, error) {
+	return p.breached[password], p.err
+}
+
+func TestBreachedPasswordsAreRejected(t *testing.T) {
+	PwnedChecker = stubPwned{breached: map[string]bool{"Passw0rd!": true}}
+	t.Cleanup(func() { PwnedChecker = nil })
+
+	r := newTestRouter(t, newStubRepo(testAdmin, testUser))
+
+	for _, tc := range []struct {
+		method, path, body string
+		as                 entities.UserResponse
+		want               int
+	}{
+		{http.MethodPost, "/register", `{"first_name":"Nia","last_name":"New","email":"new@example.com","password":"Passw0rd!"}`, entities.UserResponse{}, http.StatusUnprocessableEntity},
+		{http.MethodPut, "/api/users/2", `{"first_name":"Uma","last_name":"User","email":"user@example.com","password":"Passw0rd!"}`, testUser, http.StatusUnprocessableEntity},
+		{http.MethodPost, "/register", `{"first_name":"Nia","last_name":"New","email":"new@example.com","password":"Str0ng-pass!"}`, entities.UserResponse{}, http.StatusOK},
+		{http.MethodPut, "/api/users/2", `{"first_name":"Uma","last_name":"User","email":"user@example.com","password":"Str0ng-pass!"}`, testUser, http.StatusOK},
+	} {
+		w := doRequest(t, r, tc.method, tc.path, tc.body, tc.as)
+		if w.Code != tc.want {
+			t.Errorf("%s %s %s: status = %d, want %d: %s", tc.method, tc.path, tc.body, w.Code, tc.want, w.Body)
+			continue
+		}
+		if tc.want == http.StatusUnprocessableEntity && !strings.Contains(w.Body.String(), `"condition":"breached"`) {
+			t.Errorf("%s %s: body = %s, want a breached password error", tc.method, tc.path, w.Body)
+		}
+	}
+}
+
+func TestBreachCheckFailsOpen(t *testing.T) {
+	PwnedChecker = stubPwned{err: errors.New("range api unreachable")}
+	t.Cleanup(func() { PwnedChecker = nil })
+
+	body := `{"first_name":"Nia","last_name":"New","email":"new@example.com","password":"Str0ng-pass!"}`
+	if w := doRequest(t, newTestRouter(t, newStubRepo()), http.MethodPost, "/register", body, entities.UserResponse{}); w.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+}
+
+func TestTokensBoundToTheFingerprint(t *testing.T) {
+	token.BindFingerprint = true
+	t.Cleanup(func() { token.BindFingerprint = false })
+
+	r := newTestRouter(t, newStubRepo(testUser))
+
+	// send req from ip with user agent
+	from := func(req *http.Request, ip, userAgent string) *httptest.ResponseRecorder {
+		req.RemoteAddr = ip + ":1234"
+		req.Header.Set("User-Agent", userAgent)
+		return serve(r, req)
+	}
+
+	body := `{"email":"` + testUser.Email + `","password":"` + testPassword + `"}`
+	w := from(newRequest(t, http.MethodPost, "/login", body, entities.UserResponse{}), "192.0.2.10", "Firefox")
+	if w.Code != http.StatusOK {
+		t.Fatalf("login: status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	tokenStr := decodeBody(t, w)["token"].(string)
+
+	for _, tc := range []struct {
+		ip, userAgent string
+		want          int
+	}{
+		{"192.0.2.10", "Firefox", http.StatusOK},
+		// the same subnet
+		{"192.0.2.77", "Firefox", http.StatusOK},
+		{"198.51.100.1", "Firefox", http.StatusUnauthorized},
+		{"192.0.2.10", "curl", http.StatusUnauthorized},
+	} {
+		req := newRequest(t, http.MethodGet, "/api/me/permissions", "", entities.UserResponse{})
+		req.Header.Set("Authorization", "Bearer "+tokenStr)
+		if w := from(req, tc.ip, tc.userAgent); w.Code != tc.want {
+			t.Errorf("%s from %s: status = %d, want %d", tc.userAgent, tc.ip, w.Code, tc.want)
+		}
+	}
+}
+
+func TestUpdateStatusErrors(t *testing.T) {
+	for _, tc := range []struct {
+		path    string
+		err     error
+		code    int
+		message string
+	}{
+		{"/api/users/9/status", nil, http.StatusNotFound, entities.ItemNotFound},
+		{"/api/users/2/status", errors.New("connection refused"), http.StatusInternalServerError, entities.InternalServer},
+	} {
+		repo := newStubRepo(testAdmin, testUser)
+		repo.statusErr = tc.err
+
+		w := doRequest(t, newTestRouter(t, repo), http.MethodPut, tc.path, `{"active":false}`, testAdmin)
+		if w.Code != tc.code {
+			t.Errorf("%s: status = %d, want %d: %s", tc.path, w.Code, tc.code, w.Body)
+		}
+		if res := decodeBody(t, w); res["message"] != tc.message {
+			t.Errorf("%s: message = %v, want %q", tc.path, res["message"], tc.message)
+		}
+	}
+}
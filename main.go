@@ -1,36 +1,213 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"net/http"
 
 	_ "github.com/go-sql-driver/mysql"
 
+	"github.com/ariopri/Let-It-Be/tree/main/backend/config"
 	"github.com/ariopri/Let-It-Be/tree/main/backend/database/migration"
 	"github.com/ariopri/Let-It-Be/tree/main/backend/handler"
 	"github.com/ariopri/Let-It-Be/tree/main/backend/handler/middleware"
 	"github.com/ariopri/Let-It-Be/tree/main/backend/repository"
+	"github.com/ariopri/Let-It-Be/tree/main/backend/utils/breaker"
+	"github.com/ariopri/Let-It-Be/tree/main/backend/utils/hash"
+	"github.com/ariopri/Let-It-Be/tree/main/backend/utils/mailer"
+	"github.com/ariopri/Let-It-Be/tree/main/backend/utils/pwned"
+	"github.com/ariopri/Let-It-Be/tree/main/backend/utils/token"
+	"github.com/ariopri/Let-It-Be/tree/main/backend/utils/webhook"
 	"github.com/gin-gonic/gin"
 )
 
+// set at build time:
+// go build -ldflags "-X main.version=v1.0.0 -X main.commit=$(git rev-parse --short HEAD)"
+var (
+	version = "dev"
+	commit  = "none"
+)
+
 func main() {
+	//config
+	cfg, err := config.Load()
+	if err != nil {
+		panic(err)
+	}
+
+	token.JwtToken = []byte(cfg.JWTSecret)
+	token.TokenTTL = cfg.JWTTTL
+	token.RememberTTL = cfg.RememberTTL
+	token.MaxSessions = cfg.MaxSessions
+	token.BindFingerprint = cfg.BindTokenFingerprint
+	// old keys must outlive the longest token they signed
+	token.RotationGrace = cfg.RememberTTL
+	token.KeySyncInterval = cfg.KeySyncInterval
+	hash.Cost = cfg.BcryptCost
+	repository.PasswordHistory = cfg.PasswordHistory
+	repository.LogQueries = cfg.LogQueries
+	repository.SlowQueryThreshold = cfg.SlowQueryThreshold
+	repository.StatementTimeout = cfg.StatementTimeout
+	repository.PasswordChangeCooldown = cfg.PasswordChangeCooldown
+	if err := repository.SetDefaultSort(cfg.DefaultSort); err != nil {
+		panic(err)
+	}
+	handler.CheckUserStatus = cfg.CheckUserStatus
+	handler.TokenCookie = cfg.TokenCookie
+	handler.RequireVerifiedEmail = cfg.RequireVerifiedEmail
+	handler.MaxPageSize = cfg.MaxPageSize
+	handler.StrictPagination = cfg.StrictPagination
+	handler.DeletionGrace = cfg.DeletionGrace
+	handler.MaxPreferencesSize = cfg.MaxPreferencesSize
+	handler.EmailChangeTTL = cfg.EmailChangeTTL
+	handler.EventsHeartbeat = cfg.EventsHeartbeat
+	handler.LoginMaxBytes = cfg.LoginMaxBytes
+	handler.JSONMaxBytes = cfg.JSONMaxBytes
+	handler.JSONMaxDepth = cfg.JSONMaxDepth
+	handler.JSONMaxElements = cfg.JSONMaxElements
+	handler.MaxImportRows = cfg.MaxImportRows
+	handler.ExportTimeout = cfg.ExportTimeout
+	handler.PublicCORSOrigins = cfg.PublicCORSOrigins
+	if cfg.PwnedURL != "" {
+		handler.PwnedChecker = pwned.New(cfg.PwnedURL)
+	}
+	if cfg.ExportURLSecret != "" {
+		handler.ExportURLSecret = []byte(cfg.ExportURLSecret)
+	}
+	handler.UserRateLimit = cfg.UserRateLimit
+	handler.UserRateWindow = cfg.RateWindow
+	middleware.RateLimitExempt = cfg.RateLimitExempt
+	if len(cfg.WebhookURLs) > 0 {
+		handler.Notifier = webhook.New(cfg.WebhookURLs, cfg.WebhookSecret)
+	}
+	if cfg.SMTPAddr != "" {
+		handler.Mailer = mailer.New(cfg.SMTPAddr, cfg.SMTPFrom, cfg.SMTPUsername, cfg.SMTPPassword)
+		handler.PublicURL = cfg.PublicURL
+	}
+
 	//database
-	db, err := sql.Open("mysql", "root:tanahdamai@tcp(localhost:3306)/pusing")
+	// DB_DSN needs parseTime=true, e.g. root:pass@tcp(localhost:3306)/pusing?parseTime=true
+	db, err := sql.Open("mysql", cfg.DatabaseDSN)
 	if err != nil {
 		panic(err)
 	}
 	defer db.Close()
 
-	migration.Migrate(db)
+	if cfg.AutoMigrate {
+		migration.Migrate(db)
+	}
+	// rotated signing keys are shared through the database, JWT_SECRET
+	// stays the key until the first rotation
+	token.Keys = repository.NewKeyRepo(db)
+	if err := token.LoadKeys(); err != nil {
+		panic(err)
+	}
+
+	handler.SchemaVersion = func(ctx context.Context) (int, int, error) {
+		v, err := migration.Version(ctx, db)
+		return v, migration.Latest(), err
+	}
+
+	var replicas []*sql.DB
+	for _, dsn := range cfg.ReplicaDSNs {
+		replica, err := sql.Open("mysql", dsn)
+		if err != nil {
+			panic(err)
+		}
+		defer replica.Close()
+
+		replicas = append(replicas, replica)
+	}
 
 	r := gin.Default()
+	// trailing slashes are trimmed before routing, see TrimTrailingSlash,
+	// so no other path should be redirected either
+	r.RedirectTrailingSlash = false
+	r.RedirectFixedPath = false
+	// gin trusts every proxy unless told otherwise
+	if cfg.TrustedProxies != nil {
+		if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+			panic(err)
+		}
+	}
 
 	//middleware
 	m := middleware.InitMiddleware()
-	r.Use(m.CORS())
+	// gin.Default already put recovery and the logger in front of these
+	r.Use(globalMiddleware(cfg)...)
+
+	// health
+	handler.NewHealthHandler(r, version, commit)
+
+	// added after /health so plain http probes from inside still get through
+	if cfg.HTTPSMode != "off" {
+		r.Use(m.RequireHTTPS(cfg.HTTPSMode == "redirect", cfg.TrustedProxyNets))
+	}
 
 	// users
-	u := repository.NewUserRepo(db)
-	handler.NewUserHandler(r, u)
+	u := repository.NewUserRepo(db, replicas...)
+	a := repository.NewAuditRepo(db)
+	if cfg.UserCacheSize > 0 {
+		u = repository.NewCachedUserRepo(u, cfg.UserCacheSize, cfg.UserCacheTTL)
+	}
+	if cfg.BreakerThreshold > 0 {
+		b := breaker.New(cfg.BreakerThreshold, cfg.BreakerCooldown)
+		u = repository.NewBreakerUserRepo(u, b)
+		a = repository.NewBreakerAuditRepo(a, b)
+		// added after /health so it keeps answering while the db is down
+		r.Use(m.CircuitBreaker(b))
+	}
+	if err := handler.NewUserHandler(r, u, a); err != nil {
+		panic(err)
+	}
+
+	// forgets revoked tokens and sessions once they expired
+	go token.CollectGarbage(context.Background(), cfg.TokenGCInterval)
+
+	// picks up keys rotated by other instances
+	go token.SyncKeys(context.Background())
+
+	// removes users whose deletion grace period ran out
+	go handler.PurgeDeletedUsers(context.Background(), u, cfg.PurgeInterval)
+
+	srv := &http.Server{
+		Addr:      cfg.Addr,
+		Handler:   middleware.TrimTrailingSlash(r),
+		TLSConfig: cfg.TLSConfig(),
+		// net/http answers 431 itself past this
+		MaxHeaderBytes: cfg.MaxHeaderBytes,
+	}
+
+	if cfg.TLSCertFile != "" {
+		err = srv.ListenAndServeTLS(cfg.TLSCertFile, cfg.TLSKeyFile)
+	} else {
+		err = srv.ListenAndServe()
+	}
+	if err != nil {
+		panic(err)
+	}
+}
+
+// middleware in front of every route. CORS_DISABLED=true drops the CORS
+// headers entirely, only use it when the frontend is served from the same
+// origin as the api. browsers will then block every cross-origin call,
+// which is the safer default for such setups
+func globalMiddleware(cfg *config.Config) gin.HandlersChain {
+	m := middleware.InitMiddleware()
+	var cors, rateLimit gin.HandlerFunc
+	if !cfg.CORSDisabled {
+		cors = m.CORS(cfg.CORSOrigins)
+	}
+	if cfg.RateLimit > 0 {
+		rateLimit = m.RateLimit(cfg.RateLimit, cfg.RateWindow)
+	}
 
-	r.Run()
+	return middleware.Chain(
+		cors,
+		rateLimit,
+		m.ContentLanguage(),
+		m.Timeout(cfg.RequestTimeout),
+		m.ValidUTF8(),
+		m.LimitHeaders(cfg.MaxHeaderCount, cfg.MaxHeaderBytes),
+	)
 }
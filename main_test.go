@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/config"
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// the default config, with changes from set
+func testConfig(t *testing.T, set func(*config.Config)) *config.Config {
+	t.Helper()
+
+	t.Setenv("DB_DSN", "user:pass@/db")
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if set != nil {
+		set(cfg)
+	}
+
+	return cfg
+}
+
+// a cross origin GET through the global middleware
+func crossOriginGet(cfg *config.Config) *httptest.ResponseRecorder {
+	r := gin.New()
+	r.Use(globalMiddleware(cfg)...)
+	r.GET("/ping", func(c *gin.Context) { c.String(http.StatusOK, "pong") })
+
+	req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+	req.Header.Set("Origin", "https://elsewhere.example.com")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	return w
+}
+
+func TestCORSDisabledSendsNoHeaders(t *testing.T) {
+	w := crossOriginGet(testConfig(t, func(cfg *config.Config) { cfg.CORSDisabled = true }))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	for name := range w.Header() {
+		if strings.HasPrefix(name, "Access-Control-") {
+			t.Errorf("%s sent with CORS disabled", name)
+		}
+	}
+}
+
+func TestCORSEnabledByDefault(t *testing.T) {
+	w := crossOriginGet(testConfig(t, nil))
+
+	if w.Header().Get("Access-Control-Allow-Origin") == "" {
+		t.Errorf("no Access-Control-Allow-Origin, headers: %v", w.Header())
+	}
+}
@@ -0,0 +1,38 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+)
+
+// store the user's new key hash, replacing and so invalidating the old one
+func (u *userConn) RotateAPIKey(ctx context.Context, id int64, keyHash string) error {
+	// check the user if exists
+	if _, err := u.fetchPrimary(ctx, id); err != nil {
+		return err
+	}
+
+	query := `INSERT INTO api_keys (user_id, key_hash, created_at) VALUES(?, ?, CURRENT_TIMESTAMP)
+		ON DUPLICATE KEY UPDATE key_hash = VALUES(key_hash), created_at = VALUES(created_at)`
+	_, err := u.conn.ExecContext(ctx, query, id, keyHash)
+
+	return err
+}
+
+// owner of the key with keyHash, ErrNotFound for unknown keys
+func (u *userConn) FetchByAPIKey(ctx context.Context, keyHash string) (entities.UserResponse, error) {
+	var id int64
+	err := u.conn.QueryRowContext(ctx, `SELECT user_id FROM api_keys WHERE key_hash = ?`, keyHash).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return entities.UserResponse{}, entities.ErrNotFound
+	}
+	if err != nil {
+		return entities.UserResponse{}, err
+	}
+
+	// the primary, a rotation has to take effect right away
+	return u.fetchPrimary(ctx, id)
+}
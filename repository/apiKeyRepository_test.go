@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+	"github.com/ariopri/Let-It-Be/tree/main/backend/utils/sqltest"
+)
+
+var keyOwner = entities.UserResponse{ID: 2, FirstName: "Uma", LastName: "User", Email: "user@example.com", Role: "user", Active: true}
+
+func TestRotateAPIKeyReplacesTheHash(t *testing.T) {
+	db, fake := sqltest.Open(t, func(query string, args []driver.Value) sqltest.Result {
+		if strings.Contains(query, "FROM users") {
+			return userRow(keyOwner, "hash")
+		}
+		return sqltest.Result{Affected: 1}
+	})
+
+	if err := NewUserRepo(db).RotateAPIKey(context.Background(), keyOwner.ID, "keyhash"); err != nil {
+		t.Fatal(err)
+	}
+
+	ran := fake.Ran("INSERT INTO api_keys")
+	if len(ran) != 1 {
+		t.Fatalf("ran %v", ran)
+	}
+	if !strings.Contains(ran[0].Query, "ON DUPLICATE KEY UPDATE key_hash = VALUES(key_hash)") {
+		t.Errorf("query = %q, want it to replace an existing key", ran[0].Query)
+	}
+	if args := ran[0].Args; len(args) != 2 || args[0] != keyOwner.ID || args[1] != "keyhash" {
+		t.Errorf("args = %v, want the id and hash", args)
+	}
+}
+
+func TestRotateAPIKeyOfUnknownUser(t *testing.T) {
+	db, fake := sqltest.Open(t, nil)
+
+	if err := NewUserRepo(db).RotateAPIKey(context.Background(), 9, "keyhash"); !errors.Is(err, entities.ErrNotFound) {
+		t.Errorf("err = %v, want %v", err, entities.ErrNotFound)
+	}
+	if len(fake.Ran("INSERT INTO api_keys")) != 0 {
+		t.Error("a key was stored for an unknown user")
+	}
+}
+
+func TestFetchByAPIKey(t *testing.T) {
+	db, fake := sqltest.Open(t, func(query string, args []driver.Value) sqltest.Result {
+		switch {
+		case strings.Contains(query, "FROM api_keys") && args[0] == "keyhash":
+			return column("user_id", keyOwner.ID)
+		case strings.Contains(query, "FROM users"):
+			return userRow(keyOwner, "hash")
+		}
+		return sqltest.Result{}
+	})
+	repo := NewUserRepo(db)
+
+	user, err := repo.FetchByAPIKey(context.Background(), "keyhash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.ID != keyOwner.ID || user.Email != keyOwner.Email {
+		t.Errorf("user = %+v, want %+v", user, keyOwner)
+	}
+
+	if _, err := repo.FetchByAPIKey(context.Background(), "oldhash"); !errors.Is(err, entities.ErrNotFound) {
+		t.Errorf("unknown key: err = %v, want %v", err, entities.ErrNotFound)
+	}
+	if ran := fake.Ran("FROM api_keys"); len(ran) != 2 {
+		t.Errorf("ran %v", ran)
+	}
+}
@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+)
+
+type auditConn struct {
+	conn dbConn
+}
+
+func NewAuditRepo(conn *sql.DB) entities.AuditRepository {
+	return &auditConn{loggedDB{conn}}
+}
+
+// create audit log
+func (a *auditConn) Create(ctx context.Context, l *entities.AuditLog) error {
+	query := `INSERT INTO audit_logs (actor, action, target) VALUES(?, ?, ?)`
+
+	_, err := a.conn.ExecContext(ctx, query, l.Actor, l.Action, l.Target)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// audit logs with the user as actor or target, newest first
+func (a *auditConn) ByUser(ctx context.Context, email string, limit, offset int) ([]entities.AuditLog, error) {
+	query := `SELECT id, actor, action, target, created_at FROM audit_logs
+		WHERE actor = ? OR target = ? ORDER BY created_at DESC, id DESC`
+	args := []interface{}{email, email}
+	if limit > 0 {
+		query += ` LIMIT ? OFFSET ?`
+		args = append(args, limit, offset)
+	}
+
+	return a.query(ctx, query, args...)
+}
+
+// audit logs matching the filter, newest first. served by the
+// (action, created_at), (actor, created_at) and created_at indexes
+func (a *auditConn) Search(ctx context.Context, f *entities.AuditFilter) ([]entities.AuditLog, error) {
+	var where []string
+	var args []interface{}
+	if f.Action != "" {
+		where = append(where, "action = ?")
+		args = append(args, f.Action)
+	}
+	if f.Actor != "" {
+		where = append(where, "actor = ?")
+		args = append(args, f.Actor)
+	}
+	if !f.From.IsZero() {
+		where = append(where, "created_at >= ?")
+		args = append(args, f.From)
+	}
+	if !f.To.IsZero() {
+		where = append(where, "created_at <= ?")
+		args = append(args, f.To)
+	}
+
+	query := `SELECT id, actor, action, target, created_at FROM audit_logs`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += ` ORDER BY created_at DESC, id DESC LIMIT ? OFFSET ?`
+	args = append(args, f.Limit, f.Offset)
+
+	return a.query(ctx, query, args...)
+}
+
+func (a *auditConn) query(ctx context.Context, query string, args ...interface{}) ([]entities.AuditLog, error) {
+	rows, err := a.conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	logs := []entities.AuditLog{}
+	for rows.Next() {
+		var l entities.AuditLog
+		if err := rows.Scan(&l.ID, &l.Actor, &l.Action, &l.Target, &l.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		logs = append(logs, l)
+	}
+
+	return logs, rows.Err()
+}
@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+	"github.com/ariopri/Let-It-Be/tree/main/backend/utils/sqltest"
+)
+
+func TestAuditByUserNewestFirst(t *testing.T) {
+	now := time.Now()
+	db, fake := sqltest.Open(t, func(query string, args []driver.Value) sqltest.Result {
+		// the database orders as told
+		res := sqltest.Row([]string{"id", "actor", "action", "target", "created_at"},
+			int64(2), "user@example.com", "profile.update", "user@example.com", now)
+		res.Rows = append(res.Rows, []driver.Value{int64(1), "user@example.com", "user.login", "user@example.com", now.Add(-time.Minute)})
+		return res
+	})
+
+	logs, err := NewAuditRepo(db).ByUser(context.Background(), "user@example.com", 10, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 2 || logs[0].ID != 2 || logs[1].ID != 1 {
+		t.Errorf("logs = %+v, want ids 2 and 1", logs)
+	}
+
+	ran := fake.Ran("FROM audit_logs")
+	if len(ran) != 1 {
+		t.Fatalf("ran %v", ran)
+	}
+	if want := "ORDER BY created_at DESC, id DESC LIMIT ? OFFSET ?"; !strings.HasSuffix(ran[0].Query, want) {
+		t.Errorf("query = %q, want it to end with %q", ran[0].Query, want)
+	}
+	if args := ran[0].Args; len(args) != 4 || args[0] != "user@example.com" || args[1] != "user@example.com" || args[2] != int64(10) || args[3] != int64(20) {
+		t.Errorf("args = %v, want the email as actor and target, limit and offset", args)
+	}
+}
+
+func TestAuditSearch(t *testing.T) {
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(24 * time.Hour)
+
+	for _, tc := range []struct {
+		filter entities.AuditFilter
+		where  string
+		args   []driver.Value
+	}{
+		{
+			entities.AuditFilter{Limit: 10},
+			"",
+			[]driver.Value{int64(10), int64(0)},
+		},
+		{
+			entities.AuditFilter{Action: "user.login", Limit: 10, Offset: 5},
+			" WHERE action = ?",
+			[]driver.Value{"user.login", int64(10), int64(5)},
+		},
+		{
+			entities.AuditFilter{Action: "user.login", Actor: "admin@example.com", From: from, To: to, Limit: 10},
+			" WHERE action = ? AND actor = ? AND created_at >= ? AND created_at <= ?",
+			[]driver.Value{"user.login", "admin@example.com", from, to, int64(10), int64(0)},
+		},
+		{
+			entities.AuditFilter{From: from, Limit: 10},
+			" WHERE created_at >= ?",
+			[]driver.Value{from, int64(10), int64(0)},
+		},
+	} {
+		db, fake := sqltest.Open(t, func(query string, args []driver.Value) sqltest.Result {
+			return sqltest.Row([]string{"id", "actor", "action", "target", "created_at"},
+				int64(1), "admin@example.com", "user.login", "admin@example.com", from)
+		})
+
+		logs, err := NewAuditRepo(db).Search(context.Background(), &tc.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(logs) != 1 || logs[0].ID != 1 {
+			t.Errorf("%+v: logs = %+v, want id 1", tc.filter, logs)
+		}
+
+		ran := fake.Ran("FROM audit_logs")
+		if len(ran) != 1 {
+			t.Fatalf("ran %v", ran)
+		}
+		if want := "FROM audit_logs" + tc.where + " ORDER BY created_at DESC, id DESC LIMIT ? OFFSET ?"; !strings.HasSuffix(ran[0].Query, want) {
+			t.Errorf("%+v: query = %q, want it to end with %q", tc.filter, ran[0].Query, want)
+		}
+		if args := ran[0].Args; len(args) != len(tc.args) {
+			t.Errorf("%+v: args = %v, want %v", tc.filter, args, tc.args)
+		} else {
+			for i := range args {
+				if args[i] != tc.args[i] {
+					t.Errorf("%+v: args = %v, want %v", tc.filter, args, tc.args)
+					break
+				}
+			}
+		}
+	}
+}
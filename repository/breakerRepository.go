@@ -0,0 +1,287 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+	"github.com/ariopri/Let-It-Be/tree/main/backend/utils/breaker"
+	"github.com/go-sql-driver/mysql"
+)
+
+// only errors that mean the database can't be reached trip the breaker,
+// not found or constraint errors prove it's alive
+func connectionError(err error) bool {
+	var netErr net.Error
+
+	return errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, mysql.ErrInvalidConn) ||
+		errors.As(err, &netErr)
+}
+
+func record(b *breaker.Breaker, err error) {
+	if connectionError(err) {
+		b.Failure()
+		return
+	}
+
+	b.Success()
+}
+
+type breakerUserRepo struct {
+	repo entities.UserRepository
+	b    *breaker.Breaker
+}
+
+// report the outcome of every user repository call to b
+func NewBreakerUserRepo(repo entities.UserRepository, b *breaker.Breaker) entities.UserRepository {
+	return &breakerUserRepo{repo, b}
+}
+
+func (r *breakerUserRepo) Fetch(ctx context.Context, f *entities.UserFilter) ([]entities.UserResponse, error) {
+	res, err := r.repo.Fetch(ctx, f)
+	record(r.b, err)
+	return res, err
+}
+
+func (r *breakerUserRepo) Count(ctx context.Context, f *entities.UserFilter, mode string) (int64, error) {
+	res, err := r.repo.Count(ctx, f, mode)
+	record(r.b, err)
+	return res, err
+}
+
+func (r *breakerUserRepo) FetchById(ctx context.Context, id int64) (entities.UserResponse, error) {
+	res, err := r.repo.FetchById(ctx, id)
+	record(r.b, err)
+	return res, err
+}
+
+func (r *breakerUserRepo) FetchByEmail(ctx context.Context, email string) (entities.UserResponse, error) {
+	res, err := r.repo.FetchByEmail(ctx, email)
+	record(r.b, err)
+	return res, err
+}
+
+func (r *breakerUserRepo) FetchByIDs(ctx context.Context, ids []int64) ([]entities.UserResponse, []int64, error) {
+	res, missing, err := r.repo.FetchByIDs(ctx, ids)
+	record(r.b, err)
+	return res, missing, err
+}
+
+func (r *breakerUserRepo) EmailExists(ctx context.Context, email string) (bool, error) {
+	res, err := r.repo.EmailExists(ctx, email)
+	record(r.b, err)
+	return res, err
+}
+
+func (r *breakerUserRepo) Create(ctx context.Context, u *entities.User) (entities.UserResponse, error) {
+	res, err := r.repo.Create(ctx, u)
+	record(r.b, err)
+	return res, err
+}
+
+func (r *breakerUserRepo) Update(ctx context.Context, id int64, u *entities.User) (entities.UserResponse, error) {
+	res, err := r.repo.Update(ctx, id, u)
+	record(r.b, err)
+	return res, err
+}
+
+func (r *breakerUserRepo) Upsert(ctx context.Context, u *entities.User) (entities.UserResponse, bool, error) {
+	res, created, err := r.repo.Upsert(ctx, u)
+	record(r.b, err)
+	return res, created, err
+}
+
+func (r *breakerUserRepo) Delete(ctx context.Context, id int64) error {
+	err := r.repo.Delete(ctx, id)
+	record(r.b, err)
+	return err
+}
+
+func (r *breakerUserRepo) UpdateStatus(ctx context.Context, id int64, active bool) (entities.UserResponse, error) {
+	res, err := r.repo.UpdateStatus(ctx, id, active)
+	record(r.b, err)
+	return res, err
+}
+
+func (r *breakerUserRepo) UpdateRoles(ctx context.Context, roles []entities.RoleAssignment, changedBy string) ([]entities.UserResponse, error) {
+	res, err := r.repo.UpdateRoles(ctx, roles, changedBy)
+	record(r.b, err)
+	return res, err
+}
+
+func (r *breakerUserRepo) RoleHistory(ctx context.Context, id int64) ([]entities.RoleChange, error) {
+	res, err := r.repo.RoleHistory(ctx, id)
+	record(r.b, err)
+	return res, err
+}
+
+func (r *breakerUserRepo) BumpTokenVersion(ctx context.Context, id int64) error {
+	err := r.repo.BumpTokenVersion(ctx, id)
+	record(r.b, err)
+	return err
+}
+
+func (r *breakerUserRepo) Export(ctx context.Context, fn func(entities.UserResponse) error) error {
+	err := r.repo.Export(ctx, fn)
+	record(r.b, err)
+	return err
+}
+
+func (r *breakerUserRepo) FetchProfile(ctx context.Context, id int64) (entities.Profile, error) {
+	res, err := r.repo.FetchProfile(ctx, id)
+	record(r.b, err)
+	return res, err
+}
+
+func (r *breakerUserRepo) UpdateProfile(ctx context.Context, id int64, p *entities.Profile) (entities.Profile, error) {
+	res, err := r.repo.UpdateProfile(ctx, id, p)
+	record(r.b, err)
+	return res, err
+}
+
+func (r *breakerUserRepo) FetchPreferences(ctx context.Context, id int64) (json.RawMessage, error) {
+	res, err := r.repo.FetchPreferences(ctx, id)
+	record(r.b, err)
+	return res, err
+}
+
+func (r *breakerUserRepo) UpdatePreferences(ctx context.Context, id int64, prefs json.RawMessage) error {
+	err := r.repo.UpdatePreferences(ctx, id, prefs)
+	record(r.b, err)
+	return err
+}
+
+func (r *breakerUserRepo) RequestEmailChange(ctx context.Context, id int64, password, newEmail, tokenHash string, expiresAt time.Time) error {
+	err := r.repo.RequestEmailChange(ctx, id, password, newEmail, tokenHash, expiresAt)
+	record(r.b, err)
+	return err
+}
+
+func (r *breakerUserRepo) RequestEmailVerification(ctx context.Context, id int64, tokenHash string, expiresAt time.Time) error {
+	err := r.repo.RequestEmailVerification(ctx, id, tokenHash, expiresAt)
+	record(r.b, err)
+	return err
+}
+
+func (r *breakerUserRepo) ConfirmEmailChange(ctx context.Context, tokenHash string, now time.Time) (entities.UserResponse, error) {
+	res, err := r.repo.ConfirmEmailChange(ctx, tokenHash, now)
+	record(r.b, err)
+	return res, err
+}
+
+func (r *breakerUserRepo) RotateAPIKey(ctx context.Context, id int64, keyHash string) error {
+	err := r.repo.RotateAPIKey(ctx, id, keyHash)
+	record(r.b, err)
+	return err
+}
+
+func (r *breakerUserRepo) FetchByAPIKey(ctx context.Context, keyHash string) (entities.UserResponse, error) {
+	res, err := r.repo.FetchByAPIKey(ctx, keyHash)
+	record(r.b, err)
+	return res, err
+}
+
+func (r *breakerUserRepo) RecordPasswordHistory(ctx context.Context, id int64, passwordHash string) error {
+	err := r.repo.RecordPasswordHistory(ctx, id, passwordHash)
+	record(r.b, err)
+	return err
+}
+
+func (r *breakerUserRepo) PasswordReused(ctx context.Context, id int64, password string) (bool, error) {
+	res, err := r.repo.PasswordReused(ctx, id, password)
+	record(r.b, err)
+	return res, err
+}
+
+func (r *breakerUserRepo) FetchPasswordPolicy(ctx context.Context) (entities.PasswordPolicy, error) {
+	res, err := r.repo.FetchPasswordPolicy(ctx)
+	record(r.b, err)
+	return res, err
+}
+
+func (r *breakerUserRepo) UpdatePasswordPolicy(ctx context.Context, p *entities.PasswordPolicy) (entities.PasswordPolicy, error) {
+	res, err := r.repo.UpdatePasswordPolicy(ctx, p)
+	record(r.b, err)
+	return res, err
+}
+
+func (r *breakerUserRepo) AddTags(ctx context.Context, id int64, tags []string) ([]string, error) {
+	res, err := r.repo.AddTags(ctx, id, tags)
+	record(r.b, err)
+	return res, err
+}
+
+func (r *breakerUserRepo) RemoveTags(ctx context.Context, id int64, tags []string) ([]string, error) {
+	res, err := r.repo.RemoveTags(ctx, id, tags)
+	record(r.b, err)
+	return res, err
+}
+
+func (r *breakerUserRepo) UserTags(ctx context.Context, id int64) ([]string, error) {
+	res, err := r.repo.UserTags(ctx, id)
+	record(r.b, err)
+	return res, err
+}
+
+func (r *breakerUserRepo) RequestDeletion(ctx context.Context, id int64, purgeAt time.Time) error {
+	err := r.repo.RequestDeletion(ctx, id, purgeAt)
+	record(r.b, err)
+	return err
+}
+
+func (r *breakerUserRepo) CancelDeletion(ctx context.Context, id int64) (bool, error) {
+	res, err := r.repo.CancelDeletion(ctx, id)
+	record(r.b, err)
+	return res, err
+}
+
+func (r *breakerUserRepo) DueDeletions(ctx context.Context, now time.Time) ([]int64, error) {
+	res, err := r.repo.DueDeletions(ctx, now)
+	record(r.b, err)
+	return res, err
+}
+
+func (r *breakerUserRepo) Login(ctx context.Context, l *entities.Login) (entities.UserResponse, error) {
+	res, err := r.repo.Login(ctx, l)
+	record(r.b, err)
+	return res, err
+}
+
+func (r *breakerUserRepo) Register(ctx context.Context, u *entities.User) (entities.UserResponse, error) {
+	res, err := r.repo.Register(ctx, u)
+	record(r.b, err)
+	return res, err
+}
+
+type breakerAuditRepo struct {
+	repo entities.AuditRepository
+	b    *breaker.Breaker
+}
+
+// report the outcome of every audit repository call to b
+func NewBreakerAuditRepo(repo entities.AuditRepository, b *breaker.Breaker) entities.AuditRepository {
+	return &breakerAuditRepo{repo, b}
+}
+
+func (r *breakerAuditRepo) Create(ctx context.Context, l *entities.AuditLog) error {
+	err := r.repo.Create(ctx, l)
+	record(r.b, err)
+	return err
+}
+
+func (r *breakerAuditRepo) Search(ctx context.Context, f *entities.AuditFilter) ([]entities.AuditLog, error) {
+	res, err := r.repo.Search(ctx, f)
+	record(r.b, err)
+	return res, err
+}
+
+func (r *breakerAuditRepo) ByUser(ctx context.Context, email string, limit, offset int) ([]entities.AuditLog, error) {
+	res, err := r.repo.ByUser(ctx, email, limit, offset)
+	record(r.b, err)
+	return res, err
+}
@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+	"github.com/ariopri/Let-It-Be/tree/main/backend/utils/breaker"
+	"github.com/ariopri/Let-It-Be/tree/main/backend/utils/sqltest"
+)
+
+func TestOnlyConnectionErrorsTripTheBreaker(t *testing.T) {
+	var answer sqltest.Result
+	db, _ := sqltest.Open(t, func(query string, args []driver.Value) sqltest.Result {
+		return answer
+	})
+	b := breaker.New(2, time.Minute)
+	repo := NewBreakerUserRepo(NewUserRepo(db), b)
+
+	// no rows, the user isn't there but the database is
+	for i := 0; i < 3; i++ {
+		if _, err := repo.FetchById(context.Background(), 1); err != entities.ErrNotFound {
+			t.Fatalf("err = %v, want ErrNotFound", err)
+		}
+	}
+	if !b.Allow() {
+		t.Fatal("not found errors opened the breaker")
+	}
+
+	answer = sqltest.Result{Err: driver.ErrBadConn}
+	for i := 0; i < 2; i++ {
+		repo.FetchById(context.Background(), 1)
+	}
+	if b.Allow() {
+		t.Error("connection errors left the breaker closed")
+	}
+}
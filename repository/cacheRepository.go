@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+)
+
+// lru of users by id whose entries also expire after ttl
+type userCache struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	order   *list.List
+	entries map[int64]*list.Element
+}
+
+type userCacheEntry struct {
+	id      int64
+	user    entities.UserResponse
+	expires time.Time
+}
+
+func newUserCache(size int, ttl time.Duration) *userCache {
+	return &userCache{
+		size:    size,
+		ttl:     ttl,
+		order:   list.New(),
+		entries: map[int64]*list.Element{},
+	}
+}
+
+func (c *userCache) get(id int64) (entities.UserResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[id]
+	if !ok {
+		return entities.UserResponse{}, false
+	}
+
+	e := el.Value.(*userCacheEntry)
+	if time.Now().After(e.expires) {
+		c.order.Remove(el)
+		delete(c.entries, id)
+		return entities.UserResponse{}, false
+	}
+
+	c.order.MoveToFront(el)
+
+	return e.user, true
+}
+
+func (c *userCache) set(user entities.UserResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e := &userCacheEntry{id: user.ID, user: user, expires: time.Now().Add(c.ttl)}
+	if el, ok := c.entries[user.ID]; ok {
+		el.Value = e
+		c.order.MoveToFront(el)
+		return
+	}
+
+	c.entries[user.ID] = c.order.PushFront(e)
+
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*userCacheEntry).id)
+	}
+}
+
+func (c *userCache) remove(ids ...int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, id := range ids {
+		if el, ok := c.entries[id]; ok {
+			c.order.Remove(el)
+			delete(c.entries, id)
+		}
+	}
+}
+
+// serves FetchById from memory, every write drops the users it touched.
+// other methods go straight to the wrapped repository
+type cachedUserRepo struct {
+	entities.UserRepository
+	cache *userCache
+}
+
+// cache up to size users for ttl
+func NewCachedUserRepo(repo entities.UserRepository, size int, ttl time.Duration) entities.UserRepository {
+	return &cachedUserRepo{repo, newUserCache(size, ttl)}
+}
+
+func (r *cachedUserRepo) FetchById(ctx context.Context, id int64) (entities.UserResponse, error) {
+	if user, ok := r.cache.get(id); ok {
+		return user, nil
+	}
+
+	user, err := r.UserRepository.FetchById(ctx, id)
+	if err != nil {
+		return user, err
+	}
+	r.cache.set(user)
+
+	return user, nil
+}
+
+func (r *cachedUserRepo) Update(ctx context.Context, id int64, u *entities.User) (entities.UserResponse, error) {
+	defer r.cache.remove(id)
+	return r.UserRepository.Update(ctx, id, u)
+}
+
+func (r *cachedUserRepo) Upsert(ctx context.Context, u *entities.User) (entities.UserResponse, bool, error) {
+	res, created, err := r.UserRepository.Upsert(ctx, u)
+	r.cache.remove(res.ID)
+	return res, created, err
+}
+
+func (r *cachedUserRepo) Delete(ctx context.Context, id int64) error {
+	defer r.cache.remove(id)
+	return r.UserRepository.Delete(ctx, id)
+}
+
+func (r *cachedUserRepo) UpdateStatus(ctx context.Context, id int64, active bool) (entities.UserResponse, error) {
+	defer r.cache.remove(id)
+	return r.UserRepository.UpdateStatus(ctx, id, active)
+}
+
+func (r *cachedUserRepo) UpdateRoles(ctx context.Context, roles []entities.RoleAssignment, changedBy string) ([]entities.UserResponse, error) {
+	for _, role := range roles {
+		defer r.cache.remove(role.ID)
+	}
+	return r.UserRepository.UpdateRoles(ctx, roles, changedBy)
+}
+
+func (r *cachedUserRepo) BumpTokenVersion(ctx context.Context, id int64) error {
+	defer r.cache.remove(id)
+	return r.UserRepository.BumpTokenVersion(ctx, id)
+}
+
+func (r *cachedUserRepo) ConfirmEmailChange(ctx context.Context, tokenHash string, now time.Time) (entities.UserResponse, error) {
+	res, err := r.UserRepository.ConfirmEmailChange(ctx, tokenHash, now)
+	r.cache.remove(res.ID)
+	return res, err
+}
@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+)
+
+// counts the lookups that reach the database
+type countingRepo struct {
+	entities.UserRepository
+	fetches map[int64]int
+	names   map[int64]string
+}
+
+func newCountingRepo() *countingRepo {
+	return &countingRepo{fetches: map[int64]int{}, names: map[int64]string{}}
+}
+
+func (r *countingRepo) FetchById(ctx context.Context, id int64) (entities.UserResponse, error) {
+	r.fetches[id]++
+	if id == 404 {
+		return entities.UserResponse{}, entities.ErrNotFound
+	}
+
+	return entities.UserResponse{ID: id, FirstName: r.names[id]}, nil
+}
+
+func (r *countingRepo) Update(ctx context.Context, id int64, u *entities.User) (entities.UserResponse, error) {
+	r.names[id] = u.FirstName
+	return entities.UserResponse{ID: id, FirstName: u.FirstName}, nil
+}
+
+func (r *countingRepo) Delete(ctx context.Context, id int64) error {
+	return nil
+}
+
+func TestCachedFetchByIdHitsAndMisses(t *testing.T) {
+	ctx := context.Background()
+	db := newCountingRepo()
+	repo := NewCachedUserRepo(db, 10, time.Minute)
+
+	for i := 0; i < 3; i++ {
+		if _, err := repo.FetchById(ctx, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if db.fetches[1] != 1 {
+		t.Errorf("%d lookups reached the database, want 1", db.fetches[1])
+	}
+
+	// misses aren't cached, the user may be created any moment
+	repo.FetchById(ctx, 404)
+	repo.FetchById(ctx, 404)
+	if db.fetches[404] != 2 {
+		t.Errorf("%d lookups of a missing user reached the database, want 2", db.fetches[404])
+	}
+}
+
+func TestCachedUserIsDroppedOnWrites(t *testing.T) {
+	ctx := context.Background()
+	db := newCountingRepo()
+	repo := NewCachedUserRepo(db, 10, time.Minute)
+
+	repo.FetchById(ctx, 1)
+	repo.Update(ctx, 1, &entities.User{FirstName: "Renamed"})
+
+	user, _ := repo.FetchById(ctx, 1)
+	if user.FirstName != "Renamed" || db.fetches[1] != 2 {
+		t.Errorf("after update: %q with %d lookups, want the new name from a second lookup", user.FirstName, db.fetches[1])
+	}
+
+	repo.Delete(ctx, 1)
+	repo.FetchById(ctx, 1)
+	if db.fetches[1] != 3 {
+		t.Errorf("after delete: %d lookups, want 3", db.fetches[1])
+	}
+}
+
+func TestUserCacheEvictsAndExpires(t *testing.T) {
+	c := newUserCache(2, time.Minute)
+	c.set(entities.UserResponse{ID: 1})
+	c.set(entities.UserResponse{ID: 2})
+	c.get(1)
+	c.set(entities.UserResponse{ID: 3})
+
+	// 2 was used least recently
+	for id, want := range map[int64]bool{1: true, 2: false, 3: true} {
+		if _, ok := c.get(id); ok != want {
+			t.Errorf("cached %d = %v, want %v", id, ok, want)
+		}
+	}
+
+	c = newUserCache(2, time.Millisecond)
+	c.set(entities.UserResponse{ID: 1})
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := c.get(1); ok {
+		t.Error("an expired entry was served")
+	}
+}
@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"sync"
+	"time"
+)
+
+// how long counts are reused for estimates of filtered listings
+var CountCacheTTL = time.Second * 30
+
+type countEntry struct {
+	n       int64
+	expires time.Time
+}
+
+// short lived cache of COUNT(*) results keyed by query and args
+type countCache struct {
+	mu      sync.Mutex
+	entries map[string]countEntry
+}
+
+func newCountCache() *countCache {
+	return &countCache{entries: map[string]countEntry{}}
+}
+
+func (c *countCache) get(key string) (int64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok || time.Now().After(e.expires) {
+		delete(c.entries, key)
+		return 0, false
+	}
+
+	return e.n, true
+}
+
+func (c *countCache) set(key string, n int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// every filter gets its own key, drop expired ones as we go
+	now := time.Now()
+	for k, e := range c.entries {
+		if now.After(e.expires) {
+			delete(c.entries, k)
+		}
+	}
+
+	c.entries[key] = countEntry{n: n, expires: now.Add(CountCacheTTL)}
+}
@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+	"github.com/ariopri/Let-It-Be/tree/main/backend/utils/sqltest"
+)
+
+// a database whose COUNT(*) answers one more on every run, statistics
+// always say 1000
+func newCountDB(t *testing.T) (entities.UserRepository, *sqltest.DB) {
+	t.Helper()
+
+	var counted int64
+	db, fake := sqltest.Open(t, func(query string, args []driver.Value) sqltest.Result {
+		switch {
+		case strings.Contains(query, "information_schema.TABLES"):
+			return column("TABLE_ROWS", int64(1000))
+		case strings.Contains(query, "COUNT(*)"):
+			return column("n", atomic.AddInt64(&counted, 1))
+		}
+		return sqltest.Result{}
+	})
+
+	return NewUserRepo(db), fake
+}
+
+func countUsers(t *testing.T, repo entities.UserRepository, f *entities.UserFilter, mode string) int64 {
+	t.Helper()
+
+	n, err := repo.Count(context.Background(), f, mode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return n
+}
+
+func TestExactCountIsNeverCached(t *testing.T) {
+	repo, _ := newCountDB(t)
+	f := &entities.UserFilter{}
+
+	if n := countUsers(t, repo, f, entities.CountExact); n != 1 {
+		t.Fatalf("first count = %d, want 1", n)
+	}
+	if n := countUsers(t, repo, f, entities.CountExact); n != 2 {
+		t.Errorf("second count = %d, want a fresh 2", n)
+	}
+}
+
+func TestUnfilteredEstimateUsesStatistics(t *testing.T) {
+	repo, fake := newCountDB(t)
+
+	if n := countUsers(t, repo, &entities.UserFilter{Limit: 10, Sort: "-id"}, entities.CountEstimate); n != 1000 {
+		t.Errorf("estimate = %d, want 1000", n)
+	}
+	if ran := fake.Ran("COUNT(*)"); len(ran) != 0 {
+		t.Errorf("estimate ran %q", ran[0].Query)
+	}
+}
+
+func TestFilteredEstimateCountsTheFilter(t *testing.T) {
+	repo, fake := newCountDB(t)
+	f := &entities.UserFilter{Role: "admin"}
+
+	if n := countUsers(t, repo, f, entities.CountEstimate); n != 1 {
+		t.Fatalf("estimate = %d, want the filtered count 1", n)
+	}
+	if n := countUsers(t, repo, f, entities.CountEstimate); n != 1 {
+		t.Errorf("second estimate = %d, want the cached 1", n)
+	}
+
+	ran := fake.Ran("COUNT(*)")
+	if len(ran) != 1 {
+		t.Fatalf("counted %d times, want once", len(ran))
+	}
+	if !strings.Contains(ran[0].Query, "role") {
+		t.Errorf("count %q ignores the role filter", ran[0].Query)
+	}
+}
@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+// schedule the user's removal, a repeated request moves the date
+func (u *userConn) RequestDeletion(ctx context.Context, id int64, purgeAt time.Time) error {
+	if _, err := u.fetchPrimary(ctx, id); err != nil {
+		return err
+	}
+
+	query := `INSERT INTO deletion_requests (user_id, purge_at) VALUES(?, ?)
+		ON DUPLICATE KEY UPDATE purge_at = VALUES(purge_at)`
+	_, err := u.conn.ExecContext(ctx, query, id, purgeAt)
+
+	return err
+}
+
+// returns false when no deletion was pending
+func (u *userConn) CancelDeletion(ctx context.Context, id int64) (bool, error) {
+	res, err := u.conn.ExecContext(ctx, `DELETE FROM deletion_requests WHERE user_id = ?`, id)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+// ids of users whose grace period is over. they're removed through Delete
+// so the same hooks run as for any other deletion, the request rows go
+// with the users through the cascade
+func (u *userConn) DueDeletions(ctx context.Context, now time.Time) ([]int64, error) {
+	rows, err := u.conn.QueryContext(ctx, `SELECT user_id FROM deletion_requests WHERE purge_at <= ?`, now)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
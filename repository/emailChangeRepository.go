@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+	"github.com/ariopri/Let-It-Be/tree/main/backend/utils/hash"
+)
+
+// store newEmail as pending until the token is confirmed, the current
+// email stays in use. a repeated request replaces the pending one
+func (u *userConn) RequestEmailChange(ctx context.Context, id int64, password, newEmail, tokenHash string, expiresAt time.Time) error {
+	user, err := u.fetchById(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if hash.CheckPassword(user.Password, password) != nil {
+		return entities.ErrWrongPassword
+	}
+
+	// checked again when confirming, someone may register it meanwhile
+	if _, err := u.fetchUserByEmail(ctx, newEmail); err == nil {
+		return &entities.DuplicateError{Field: "email"}
+	} else if !errors.Is(err, entities.ErrNotFound) {
+		return err
+	}
+
+	query := `INSERT INTO email_changes (user_id, new_email, token_hash, expires_at) VALUES(?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE new_email = VALUES(new_email), token_hash = VALUES(token_hash),
+		expires_at = VALUES(expires_at)`
+	_, err = u.conn.ExecContext(ctx, query, id, newEmail, tokenHash, expiresAt)
+
+	return err
+}
+
+// have the user confirm their current email, through the same table and
+// link as an email change whose new email is the current one. replaces a
+// pending change
+func (u *userConn) RequestEmailVerification(ctx context.Context, id int64, tokenHash string, expiresAt time.Time) error {
+	user, err := u.fetchById(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO email_changes (user_id, new_email, token_hash, expires_at) VALUES(?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE new_email = VALUES(new_email), token_hash = VALUES(token_hash),
+		expires_at = VALUES(expires_at)`
+	_, err = u.conn.ExecContext(ctx, query, id, user.Email, tokenHash, expiresAt)
+
+	return err
+}
+
+// switch to the pending email of tokenHash and mark it verified. a new
+// email revokes the user's tokens and moves their audit to it
+func (u *userConn) ConfirmEmailChange(ctx context.Context, tokenHash string, now time.Time) (entities.UserResponse, error) {
+	tx, err := u.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return entities.UserResponse{}, err
+	}
+	defer tx.Rollback()
+
+	var id int64
+	var newEmail, oldEmail string
+	err = tx.QueryRowContext(ctx, `SELECT e.user_id, e.new_email, u.email FROM email_changes e
+		JOIN users u ON u.id = e.user_id
+		WHERE e.token_hash = ? AND e.expires_at > ? FOR UPDATE`, tokenHash, now).Scan(&id, &newEmail, &oldEmail)
+	if errors.Is(err, sql.ErrNoRows) {
+		return entities.UserResponse{}, entities.ErrNotFound
+	}
+	if err != nil {
+		return entities.UserResponse{}, err
+	}
+
+	query := `UPDATE users SET email = ?, email_verified = TRUE WHERE id = ?`
+	if newEmail != oldEmail {
+		// revoke the tokens issued for the old email, like BumpTokenVersion
+		query = `UPDATE users SET email = ?, email_verified = TRUE, token_version = token_version + 1 WHERE id = ?`
+	}
+	_, err = tx.ExecContext(ctx, query, newEmail, id)
+	if err != nil {
+		return entities.UserResponse{}, duplicateError(err)
+	}
+
+	// the audit refers to users by email, keep the user's history with
+	// them instead of leaving it to whoever registers the old address
+	if newEmail != oldEmail {
+		for _, query := range []string{
+			`UPDATE audit_logs SET actor = ? WHERE actor = ?`,
+			`UPDATE audit_logs SET target = ? WHERE target = ?`,
+			`UPDATE role_history SET changed_by = ? WHERE changed_by = ?`,
+		} {
+			if _, err := tx.ExecContext(ctx, query, newEmail, oldEmail); err != nil {
+				return entities.UserResponse{}, err
+			}
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM email_changes WHERE user_id = ?`, id); err != nil {
+		return entities.UserResponse{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return entities.UserResponse{}, err
+	}
+
+	return u.fetchPrimary(ctx, id)
+}
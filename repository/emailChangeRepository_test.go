@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+	"github.com/ariopri/Let-It-Be/tree/main/backend/utils/sqltest"
+)
+
+func TestConfirmEmailChange(t *testing.T) {
+	user := entities.UserResponse{ID: 2, FirstName: "Uma", LastName: "User", Email: "new@example.com", Role: "user", Active: true}
+
+	for _, tc := range []struct {
+		oldEmail string
+		revoked  bool
+	}{
+		{"user@example.com", true},
+		// a verification of the current email
+		{"new@example.com", false},
+	} {
+		db, fake := sqltest.Open(t, func(query string, args []driver.Value) sqltest.Result {
+			switch {
+			case strings.Contains(query, "FROM email_changes"):
+				return sqltest.Row([]string{"user_id", "new_email", "email"}, user.ID, user.Email, tc.oldEmail)
+			case strings.Contains(query, "FROM users"):
+				return userRow(user, "hash")
+			}
+			return sqltest.Result{Affected: 1}
+		})
+
+		if _, err := NewUserRepo(db).ConfirmEmailChange(context.Background(), "tokenhash", time.Now()); err != nil {
+			t.Fatal(err)
+		}
+
+		ran := fake.Ran("UPDATE users SET email")
+		if len(ran) != 1 {
+			t.Fatalf("ran %v", ran)
+		}
+		if got := strings.Contains(ran[0].Query, "token_version = token_version + 1"); got != tc.revoked {
+			t.Errorf("from %s: token version bumped %v, want %v", tc.oldEmail, got, tc.revoked)
+		}
+		for _, query := range []string{"UPDATE audit_logs SET actor", "UPDATE audit_logs SET target", "UPDATE role_history SET changed_by"} {
+			ran := fake.Ran(query)
+			if !tc.revoked {
+				if len(ran) != 0 {
+					t.Errorf("from %s: ran %v", tc.oldEmail, ran)
+				}
+				continue
+			}
+			if len(ran) != 1 || len(ran[0].Args) != 2 || ran[0].Args[0] != user.Email || ran[0].Args[1] != tc.oldEmail {
+				t.Errorf("from %s: %q ran %v, want it to move the rows to the new email", tc.oldEmail, query, ran)
+			}
+		}
+		if len(fake.Ran("COMMIT")) != 1 {
+			t.Errorf("from %s: the change wasn't committed", tc.oldEmail)
+		}
+	}
+}
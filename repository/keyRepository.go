@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/utils/token"
+)
+
+// signing keys shared by every instance on the database
+type keyConn struct {
+	conn dbConn
+}
+
+func NewKeyRepo(conn *sql.DB) token.KeyStore {
+	return &keyConn{loggedDB{conn}}
+}
+
+func (k *keyConn) AddKey(key token.Key) error {
+	query := `INSERT INTO signing_keys (secret, created_at) VALUES(?, ?)`
+	_, err := k.conn.ExecContext(context.Background(), query, key.Secret, key.CreatedAt)
+
+	return err
+}
+
+// in the order they were added
+func (k *keyConn) Keys() ([]token.Key, error) {
+	rows, err := k.conn.QueryContext(context.Background(), `SELECT secret, created_at FROM signing_keys ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var keys []token.Key
+	for rows.Next() {
+		var key token.Key
+		if err := rows.Scan(&key.Secret, &key.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+	"github.com/ariopri/Let-It-Be/tree/main/backend/utils/hash"
+	"github.com/ariopri/Let-It-Be/tree/main/backend/utils/sqltest"
+)
+
+// a database holding historyUser with password current, last changed at
+// changedAt
+func newCooldownDB(t *testing.T, current string, changedAt time.Time) (entities.UserRepository, *sqltest.DB) {
+	t.Helper()
+
+	old := PasswordChangeCooldown
+	PasswordChangeCooldown = time.Hour
+	t.Cleanup(func() { PasswordChangeCooldown = old })
+
+	currentHash, _ := hash.HashPassword(current)
+
+	db, fake := sqltest.Open(t, func(query string, args []driver.Value) sqltest.Result {
+		switch {
+		case strings.HasPrefix(query, "SELECT * FROM users"):
+			return userRow(historyUser, currentHash)
+		case strings.HasPrefix(query, "SELECT changed_at FROM password_changes"):
+			return column("changed_at", changedAt)
+		}
+		return sqltest.Result{Affected: 1}
+	})
+
+	return NewUserRepo(db), fake
+}
+
+func TestPasswordChangesBackToBack(t *testing.T) {
+	repo, fake := newCooldownDB(t, "current-pass", time.Now().Add(-time.Minute))
+
+	user := &entities.User{FirstName: "Uma", LastName: "User", Email: historyUser.Email, Password: "brand-new-pass"}
+	_, err := repo.Update(context.Background(), historyUser.ID, user)
+
+	var cooldown *entities.PasswordCooldownError
+	if !errors.As(err, &cooldown) {
+		t.Fatalf("err = %v, want a PasswordCooldownError", err)
+	}
+	if cooldown.RetryAfter <= 58*time.Minute || cooldown.RetryAfter > time.Hour {
+		t.Errorf("retry after %s, want about 59m", cooldown.RetryAfter)
+	}
+	if len(fake.Ran("UPDATE users")) != 0 {
+		t.Error("password was changed during the cooldown")
+	}
+}
+
+func TestPasswordChangeAfterCooldown(t *testing.T) {
+	repo, fake := newCooldownDB(t, "current-pass", time.Now().Add(-2*time.Hour))
+
+	user := &entities.User{FirstName: "Uma", LastName: "User", Email: historyUser.Email, Password: "brand-new-pass"}
+	if _, err := repo.Update(context.Background(), historyUser.ID, user); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(fake.Ran("INSERT INTO password_changes")) != 1 {
+		t.Error("the change wasn't recorded for the next cooldown")
+	}
+}
+
+func TestProfileEditsIgnoreCooldown(t *testing.T) {
+	repo, fake := newCooldownDB(t, "current-pass", time.Now().Add(-time.Minute))
+
+	for _, password := range []string{"", "current-pass"} {
+		user := &entities.User{FirstName: "Uma", LastName: "Renamed", Email: historyUser.Email, Password: password}
+		if _, err := repo.Update(context.Background(), historyUser.ID, user); err != nil {
+			t.Fatalf("password %q: %v", password, err)
+		}
+	}
+
+	if len(fake.Ran("password_changes")) != 0 {
+		t.Error("a profile edit counted as a password change")
+	}
+}
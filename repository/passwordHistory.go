@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+	"github.com/ariopri/Let-It-Be/tree/main/backend/utils/hash"
+)
+
+// previous passwords a user can't reuse, 0 disables the check
+var PasswordHistory = 5
+
+// remember a replaced password hash, keeping only the newest entries
+func (u *userConn) RecordPasswordHistory(ctx context.Context, id int64, passwordHash string) error {
+	if PasswordHistory <= 0 {
+		return nil
+	}
+
+	query := `INSERT INTO password_history (user_id, password) VALUES(?, ?)`
+	_, err := u.conn.ExecContext(ctx, query, id, passwordHash)
+	if err != nil {
+		return err
+	}
+
+	// mysql can't LIMIT inside IN subqueries, hence the derived table
+	query = `DELETE FROM password_history WHERE user_id = ? AND id NOT IN (
+		SELECT id FROM (
+			SELECT id FROM password_history WHERE user_id = ? ORDER BY id DESC LIMIT ?
+		) AS keep
+	)`
+	_, err = u.conn.ExecContext(ctx, query, id, id, PasswordHistory)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// check a plain password against the remembered ones. the current
+// password isn't among them, resending it is no change
+func (u *userConn) PasswordReused(ctx context.Context, id int64, password string) (bool, error) {
+	if PasswordHistory <= 0 {
+		return false, nil
+	}
+
+	query := `SELECT password FROM password_history WHERE user_id = ? ORDER BY id DESC LIMIT ?`
+	rows, err := u.conn.QueryContext(ctx, query, id, PasswordHistory)
+	if err != nil {
+		return false, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var old string
+		if err := rows.Scan(&old); err != nil {
+			return false, err
+		}
+
+		if hash.CheckPassword(old, password) == nil {
+			return true, nil
+		}
+	}
+
+	return false, rows.Err()
+}
+
+// least time between two password changes of a user, 0 disables it
+var PasswordChangeCooldown time.Duration
+
+// fail with a PasswordCooldownError while the user's last change is more
+// recent than PasswordChangeCooldown
+func (u *userConn) checkPasswordCooldown(ctx context.Context, id int64) error {
+	if PasswordChangeCooldown <= 0 {
+		return nil
+	}
+
+	var changedAt time.Time
+	err := u.conn.QueryRowContext(ctx, `SELECT changed_at FROM password_changes WHERE user_id = ?`, id).Scan(&changedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if left := PasswordChangeCooldown - time.Since(changedAt); left > 0 {
+		return &entities.PasswordCooldownError{RetryAfter: left}
+	}
+
+	return nil
+}
+
+// note when the user's password was changed for the cooldown
+func (u *userConn) recordPasswordChange(ctx context.Context, id int64) error {
+	query := `INSERT INTO password_changes (user_id, changed_at) VALUES(?, ?)
+		ON DUPLICATE KEY UPDATE changed_at = VALUES(changed_at)`
+	_, err := u.conn.ExecContext(ctx, query, id, time.Now())
+
+	return err
+}
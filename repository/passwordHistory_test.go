@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+	"github.com/ariopri/Let-It-Be/tree/main/backend/utils/hash"
+	"github.com/ariopri/Let-It-Be/tree/main/backend/utils/sqltest"
+)
+
+var historyUser = entities.UserResponse{ID: 7, FirstName: "Uma", LastName: "User", Email: "user@example.com", Role: "user", Active: true}
+
+// a database holding historyUser with password current and old in its
+// password history
+func newHistoryDB(t *testing.T, current, old string) (entities.UserRepository, *sqltest.DB) {
+	t.Helper()
+
+	currentHash, _ := hash.HashPassword(current)
+	oldHash, _ := hash.HashPassword(old)
+
+	db, fake := sqltest.Open(t, func(query string, args []driver.Value) sqltest.Result {
+		switch {
+		case strings.HasPrefix(query, "SELECT * FROM users"):
+			return userRow(historyUser, currentHash)
+		case strings.HasPrefix(query, "SELECT password FROM password_history"):
+			return column("password", oldHash)
+		}
+		return sqltest.Result{Affected: 1}
+	})
+
+	return NewUserRepo(db), fake
+}
+
+func TestUpdateRejectsOldPassword(t *testing.T) {
+	repo, fake := newHistoryDB(t, "current-pass", "old-pass")
+
+	user := &entities.User{FirstName: "Uma", LastName: "User", Email: historyUser.Email, Password: "old-pass"}
+	_, err := repo.Update(context.Background(), historyUser.ID, user)
+	if !errors.Is(err, entities.ErrPasswordReused) {
+		t.Fatalf("err = %v, want %v", err, entities.ErrPasswordReused)
+	}
+
+	if len(fake.Ran("UPDATE users")) != 0 {
+		t.Error("user was updated with a reused password")
+	}
+}
+
+func TestUpdateWithCurrentPasswordIsNoChange(t *testing.T) {
+	repo, fake := newHistoryDB(t, "current-pass", "old-pass")
+
+	user := &entities.User{FirstName: "Uma", LastName: "Renamed", Email: historyUser.Email, Password: "current-pass"}
+	if _, err := repo.Update(context.Background(), historyUser.ID, user); err != nil {
+		t.Fatalf("resending the current password: %v", err)
+	}
+
+	if len(fake.Ran("password_history")) != 0 {
+		t.Error("password history was read or written for an unchanged password")
+	}
+}
+
+func TestUpdateRecordsReplacedPassword(t *testing.T) {
+	repo, fake := newHistoryDB(t, "current-pass", "old-pass")
+
+	user := &entities.User{FirstName: "Uma", LastName: "User", Email: historyUser.Email, Password: "brand-new-pass"}
+	if _, err := repo.Update(context.Background(), historyUser.ID, user); err != nil {
+		t.Fatal(err)
+	}
+
+	inserts := fake.Ran("INSERT INTO password_history")
+	if len(inserts) != 1 {
+		t.Fatalf("%d history inserts, want 1", len(inserts))
+	}
+	if hash.CheckPassword(inserts[0].Args[1].(string), "current-pass") != nil {
+		t.Error("the replaced password wasn't the one remembered")
+	}
+}
+
+func TestUpsertWithCurrentPasswordIsNoChange(t *testing.T) {
+	repo, fake := newHistoryDB(t, "current-pass", "old-pass")
+
+	user := &entities.User{FirstName: "Uma", LastName: "Renamed", Email: historyUser.Email, Password: "current-pass"}
+	if _, _, err := repo.Upsert(context.Background(), user); err != nil {
+		t.Fatalf("resending the current password: %v", err)
+	}
+
+	if len(fake.Ran("password_history")) != 0 {
+		t.Error("password history was read or written for an unchanged password")
+	}
+}
+
+func TestUpsertRejectsOldPassword(t *testing.T) {
+	repo, _ := newHistoryDB(t, "current-pass", "old-pass")
+
+	user := &entities.User{FirstName: "Uma", LastName: "User", Email: historyUser.Email, Password: "old-pass"}
+	_, _, err := repo.Upsert(context.Background(), user)
+	if !errors.Is(err, entities.ErrPasswordReused) {
+		t.Fatalf("err = %v, want %v", err, entities.ErrPasswordReused)
+	}
+}
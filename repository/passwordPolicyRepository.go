@@ -0,0 +1,38 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+)
+
+// the stored policy, the default when none was stored yet
+func (u *userConn) FetchPasswordPolicy(ctx context.Context) (entities.PasswordPolicy, error) {
+	var p entities.PasswordPolicy
+	sqlStmt := `SELECT min_length, require_upper, require_lower, require_digit, require_symbol, updated_at FROM password_policy WHERE id = 1`
+	err := u.conn.QueryRowContext(ctx, sqlStmt).Scan(&p.MinLength, &p.RequireUpper, &p.RequireLower, &p.RequireDigit, &p.RequireSymbol, &p.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return entities.DefaultPasswordPolicy, nil
+	}
+	if err != nil {
+		return entities.PasswordPolicy{}, err
+	}
+
+	return p, nil
+}
+
+// replace the policy, there is only ever one row
+func (u *userConn) UpdatePasswordPolicy(ctx context.Context, p *entities.PasswordPolicy) (entities.PasswordPolicy, error) {
+	query := `INSERT INTO password_policy (id, min_length, require_upper, require_lower, require_digit, require_symbol)
+		VALUES(1, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE min_length = VALUES(min_length), require_upper = VALUES(require_upper),
+			require_lower = VALUES(require_lower), require_digit = VALUES(require_digit), require_symbol = VALUES(require_symbol)`
+	_, err := u.conn.ExecContext(ctx, query, p.MinLength, p.RequireUpper, p.RequireLower, p.RequireDigit, p.RequireSymbol)
+	if err != nil {
+		return entities.PasswordPolicy{}, err
+	}
+
+	return u.FetchPasswordPolicy(ctx)
+}
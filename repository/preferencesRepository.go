@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+)
+
+// fetch preferences, users without any get an empty object
+func (u *userConn) FetchPreferences(ctx context.Context, id int64) (json.RawMessage, error) {
+	// check the user if exists
+	_, err := u.fetchPrimary(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var data []byte
+	sqlStmt := `SELECT data FROM user_preferences WHERE user_id = ?`
+	err = u.conn.QueryRowContext(ctx, sqlStmt, id).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return json.RawMessage(`{}`), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return json.RawMessage(data), nil
+}
+
+// replace preferences, prefs must be a json object
+func (u *userConn) UpdatePreferences(ctx context.Context, id int64, prefs json.RawMessage) error {
+	// check the user if exists
+	_, err := u.fetchPrimary(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO user_preferences (user_id, data) VALUES(?, ?)
+		ON DUPLICATE KEY UPDATE data = VALUES(data)`
+
+	_, err = u.conn.ExecContext(ctx, query, id, []byte(prefs))
+
+	return err
+}
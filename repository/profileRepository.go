@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+)
+
+// fetch profile, users without one get an empty profile
+func (u *userConn) FetchProfile(ctx context.Context, id int64) (entities.Profile, error) {
+	// check the user if exists
+	_, err := u.fetchPrimary(ctx, id)
+	if err != nil {
+		return entities.Profile{}, err
+	}
+
+	var p entities.Profile
+	sqlStmt := `SELECT user_id, display_name, bio, avatar_url, locale, updated_at FROM profiles WHERE user_id = ?`
+	row := u.conn.QueryRowContext(ctx, sqlStmt, id)
+	err = row.Scan(&p.UserID, &p.DisplayName, &p.Bio, &p.AvatarURL, &p.Locale, &p.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return entities.Profile{UserID: id}, nil
+	}
+	if err != nil {
+		return entities.Profile{}, err
+	}
+
+	return p, nil
+}
+
+// create or replace profile
+func (u *userConn) UpdateProfile(ctx context.Context, id int64, p *entities.Profile) (entities.Profile, error) {
+	// check the user if exists
+	_, err := u.fetchPrimary(ctx, id)
+	if err != nil {
+		return entities.Profile{}, err
+	}
+
+	query := `INSERT INTO profiles (user_id, display_name, bio, avatar_url, locale) VALUES(?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE display_name = VALUES(display_name), bio = VALUES(bio),
+		avatar_url = VALUES(avatar_url), locale = VALUES(locale)`
+
+	_, err = u.conn.ExecContext(ctx, query, id, p.DisplayName, p.Bio, p.AvatarURL, p.Locale)
+	if err != nil {
+		return entities.Profile{}, err
+	}
+
+	return u.FetchProfile(ctx, id)
+}
@@ -0,0 +1,160 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+)
+
+// comparison operators allowed in where clauses
+var operators = map[string]bool{
+	"=":    true,
+	"<>":   true,
+	"<":    true,
+	"<=":   true,
+	">":    true,
+	">=":   true,
+	"LIKE": true,
+}
+
+// selectQuery assembles listing queries. fields are mapped to columns
+// through a whitelist and values are always passed as args.
+type selectQuery struct {
+	table   string
+	columns map[string]string
+	where   []string
+	args    []interface{}
+	order   string
+	limit   int
+	offset  int
+}
+
+func newSelectQuery(table string, columns map[string]string) *selectQuery {
+	return &selectQuery{
+		table:   table,
+		columns: columns,
+	}
+}
+
+// add a "field op ?" condition, conditions are joined with AND
+func (q *selectQuery) Where(field, op string, value interface{}) error {
+	col, ok := q.columns[field]
+	if !ok {
+		return fmt.Errorf("unknown field %q", field)
+	}
+	if !operators[op] {
+		return fmt.Errorf("unknown operator %q", op)
+	}
+
+	q.where = append(q.where, fmt.Sprintf("%s %s ?", col, op))
+	q.args = append(q.args, value)
+
+	return nil
+}
+
+// add a condition written out by hand, e.g. a subquery. cond must never
+// hold user input, values go in args
+func (q *selectQuery) WhereRaw(cond string, args ...interface{}) {
+	q.where = append(q.where, cond)
+	q.args = append(q.args, args...)
+}
+
+// add a "field IN (...)" condition, an empty list matches nothing
+func (q *selectQuery) WhereIn(field string, values []interface{}) error {
+	col, ok := q.columns[field]
+	if !ok {
+		return fmt.Errorf("unknown field %q", field)
+	}
+
+	if len(values) == 0 {
+		q.where = append(q.where, "1 = 0")
+		return nil
+	}
+
+	q.where = append(q.where, fmt.Sprintf("%s IN (?%s)", col, strings.Repeat(", ?", len(values)-1)))
+	q.args = append(q.args, values...)
+
+	return nil
+}
+
+// sort by field, prefix with "-" for descending. id is always the last
+// key so the order is stable across pages
+func (q *selectQuery) OrderBy(sort string) error {
+	dir := "ASC"
+	if strings.HasPrefix(sort, "-") {
+		dir = "DESC"
+		sort = strings.TrimPrefix(sort, "-")
+	}
+
+	if sort == "" {
+		sort = "id"
+	}
+
+	col, ok := q.columns[sort]
+	if !ok {
+		return entities.ErrInvalidSort
+	}
+
+	if col == "id" {
+		q.order = fmt.Sprintf("ORDER BY id %s", dir)
+		return nil
+	}
+
+	q.order = fmt.Sprintf("ORDER BY %s %s, id %s", col, dir, dir)
+
+	return nil
+}
+
+// a zero limit means no limit
+func (q *selectQuery) Paginate(limit, offset int) {
+	q.limit = limit
+	q.offset = offset
+}
+
+// count the rows matching the where clauses, ignoring order and paging
+func (q *selectQuery) BuildCount() (string, []interface{}) {
+	var sb strings.Builder
+
+	sb.WriteString("SELECT COUNT(*) FROM ")
+	sb.WriteString(q.table)
+
+	if len(q.where) > 0 {
+		sb.WriteString(" WHERE ")
+		sb.WriteString(strings.Join(q.where, " AND "))
+	}
+
+	return sb.String(), append([]interface{}{}, q.args...)
+}
+
+func (q *selectQuery) Build() (string, []interface{}) {
+	var sb strings.Builder
+	args := append([]interface{}{}, q.args...)
+
+	sb.WriteString("SELECT * FROM ")
+	sb.WriteString(q.table)
+
+	if len(q.where) > 0 {
+		sb.WriteString(" WHERE ")
+		sb.WriteString(strings.Join(q.where, " AND "))
+	}
+
+	if q.order != "" {
+		sb.WriteString(" ")
+		sb.WriteString(q.order)
+	}
+
+	if q.limit > 0 {
+		sb.WriteString(" LIMIT ? OFFSET ?")
+		args = append(args, q.limit, q.offset)
+	}
+
+	return sb.String(), args
+}
+
+// escape LIKE wildcards in s so they match literally, use with ESCAPE '!'
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+)
+
+func TestSelectQueryBuild(t *testing.T) {
+	q := newSelectQuery("users", userColumns)
+	if err := q.Where("created_at", ">=", "2024-01-01"); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.WhereIn("role", []interface{}{"admin", "user"}); err != nil {
+		t.Fatal(err)
+	}
+	q.WhereRaw("id IN (SELECT user_id FROM user_tags WHERE tag_id = ?)", 7)
+	if err := q.OrderBy("-first_name"); err != nil {
+		t.Fatal(err)
+	}
+	q.Paginate(10, 20)
+
+	query, args := q.Build()
+
+	wantQuery := "SELECT * FROM users WHERE created_at >= ? AND role IN (?, ?) AND id IN (SELECT user_id FROM user_tags WHERE tag_id = ?) ORDER BY firstname DESC, id DESC LIMIT ? OFFSET ?"
+	if query != wantQuery {
+		t.Errorf("query = %q\nwant    %q", query, wantQuery)
+	}
+	wantArgs := []interface{}{"2024-01-01", "admin", "user", 7, 10, 20}
+	if !reflect.DeepEqual(args, wantArgs) {
+		t.Errorf("args = %v, want %v", args, wantArgs)
+	}
+
+	query, args = q.BuildCount()
+	if want := "SELECT COUNT(*) FROM users WHERE created_at >= ? AND role IN (?, ?) AND id IN (SELECT user_id FROM user_tags WHERE tag_id = ?)"; query != want {
+		t.Errorf("count query = %q\nwant          %q", query, want)
+	}
+	if !reflect.DeepEqual(args, wantArgs[:4]) {
+		t.Errorf("count args = %v, want %v", args, wantArgs[:4])
+	}
+}
+
+func TestSelectQueryWhitelists(t *testing.T) {
+	q := newSelectQuery("users", userColumns)
+
+	if err := q.Where("password", "=", "x"); err == nil {
+		t.Error("unlisted field accepted")
+	}
+	if err := q.Where("email", "; DROP TABLE users; --", "x"); err == nil {
+		t.Error("unknown operator accepted")
+	}
+	if err := q.WhereIn("password", []interface{}{"x"}); err == nil {
+		t.Error("unlisted field accepted in WhereIn")
+	}
+	if err := q.OrderBy("email; DROP TABLE users"); !errors.Is(err, entities.ErrInvalidSort) {
+		t.Errorf("OrderBy err = %v, want ErrInvalidSort", err)
+	}
+
+	if query, args := q.Build(); query != "SELECT * FROM users" || len(args) != 0 {
+		t.Errorf("rejected input reached the query: %q %v", query, args)
+	}
+}
+
+func TestSelectQueryEmptyIn(t *testing.T) {
+	q := newSelectQuery("users", userColumns)
+	if err := q.WhereIn("id", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if query, _ := q.Build(); query != "SELECT * FROM users WHERE 1 = 0" {
+		t.Errorf("query = %q, want one matching nothing", query)
+	}
+}
+
+func TestEscapeLike(t *testing.T) {
+	if got := escapeLike("50%_off!"); got != "50!%!_off!!" {
+		t.Errorf("escapeLike = %q", got)
+	}
+}
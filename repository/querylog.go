@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+)
+
+// log every query with its duration
+var LogQueries = false
+
+// queries slower than this are logged as warnings, 0 disables it
+var SlowQueryThreshold = time.Millisecond * 200
+
+// longest a single statement may run, on top of the request deadline.
+// 0 disables it
+var StatementTimeout = time.Second * 10
+
+type noStatementTimeoutKey struct{}
+
+// lift StatementTimeout for statements run with ctx, for long streaming reads
+func withoutStatementTimeout(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noStatementTimeoutKey{}, true)
+}
+
+// ctx with the statement deadline applied
+func statementContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if StatementTimeout <= 0 || ctx.Value(noStatementTimeoutKey{}) != nil {
+		return ctx, func() {}
+	}
+
+	return context.WithTimeout(ctx, StatementTimeout)
+}
+
+// what repositories need from a database handle
+type dbConn interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*stmtRows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *stmtRow
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*loggedTx, error)
+}
+
+// what *sql.DB and *sql.Tx have in common
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// times the queries of db, only the parameterized sql is logged, never
+// the args
+type loggedDB struct {
+	*sql.DB
+}
+
+func (db loggedDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*stmtRows, error) {
+	return queryContext(ctx, db.DB, query, args...)
+}
+
+func (db loggedDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *stmtRow {
+	return queryRowContext(ctx, db.DB, query, args...)
+}
+
+func (db loggedDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return execContext(ctx, db.DB, query, args...)
+}
+
+// the transaction lives until Commit or Rollback, only its statements get
+// the statement deadline
+func (db loggedDB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*loggedTx, error) {
+	tx, err := db.DB.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &loggedTx{tx}, nil
+}
+
+// a transaction whose statements are timed and time limited like loggedDB's
+type loggedTx struct {
+	*sql.Tx
+}
+
+func (tx *loggedTx) QueryContext(ctx context.Context, query string, args ...interface{}) (*stmtRows, error) {
+	return queryContext(ctx, tx.Tx, query, args...)
+}
+
+func (tx *loggedTx) QueryRowContext(ctx context.Context, query string, args ...interface{}) *stmtRow {
+	return queryRowContext(ctx, tx.Tx, query, args...)
+}
+
+func (tx *loggedTx) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return execContext(ctx, tx.Tx, query, args...)
+}
+
+// rows are read after the query returns, closing them ends the statement
+// context
+type stmtRows struct {
+	*sql.Rows
+	cancel context.CancelFunc
+}
+
+func (r *stmtRows) Close() error {
+	defer r.cancel()
+	return r.Rows.Close()
+}
+
+// a row ends its statement context once scanned
+type stmtRow struct {
+	*sql.Row
+	cancel context.CancelFunc
+}
+
+func (r *stmtRow) Scan(dest ...interface{}) error {
+	defer r.cancel()
+	return r.Row.Scan(dest...)
+}
+
+func queryContext(ctx context.Context, q querier, query string, args ...interface{}) (*stmtRows, error) {
+	defer logQuery(query, time.Now())
+	ctx, cancel := statementContext(ctx)
+
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	return &stmtRows{rows, cancel}, nil
+}
+
+func queryRowContext(ctx context.Context, q querier, query string, args ...interface{}) *stmtRow {
+	defer logQuery(query, time.Now())
+	ctx, cancel := statementContext(ctx)
+
+	return &stmtRow{q.QueryRowContext(ctx, query, args...), cancel}
+}
+
+func execContext(ctx context.Context, q querier, query string, args ...interface{}) (sql.Result, error) {
+	defer logQuery(query, time.Now())
+	ctx, cancel := statementContext(ctx)
+	defer cancel()
+
+	return q.ExecContext(ctx, query, args...)
+}
+
+func logQuery(query string, start time.Time) {
+	took := time.Since(start)
+
+	if SlowQueryThreshold > 0 && took > SlowQueryThreshold {
+		log.Printf("WARN slow query (%s): %s", took, query)
+		return
+	}
+	if LogQueries {
+		log.Printf("query (%s): %s", took, query)
+	}
+}
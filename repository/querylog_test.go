@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/utils/sqltest"
+)
+
+func TestClosingRowsEndsStatementContext(t *testing.T) {
+	db, fake := sqltest.Open(t, func(query string, args []driver.Value) sqltest.Result {
+		return sqltest.Row([]string{"id"}, int64(1))
+	})
+	conn := loggedDB{db}
+
+	rows, err := conn.QueryContext(context.Background(), `SELECT id FROM users`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := fake.Ran("SELECT id")[0].Ctx
+	if _, ok := ctx.Deadline(); !ok {
+		t.Fatal("query ran without the statement deadline")
+	}
+	if ctx.Err() != nil {
+		t.Fatalf("context ended before the rows were read: %v", ctx.Err())
+	}
+
+	rows.Close()
+	if ctx.Err() != context.Canceled {
+		t.Errorf("context after Close = %v, want canceled", ctx.Err())
+	}
+}
+
+func TestScanningRowEndsStatementContext(t *testing.T) {
+	db, fake := sqltest.Open(t, func(query string, args []driver.Value) sqltest.Result {
+		return sqltest.Row([]string{"role"}, "admin")
+	})
+	conn := loggedDB{db}
+
+	var role string
+	if err := conn.QueryRowContext(context.Background(), `SELECT role FROM users`).Scan(&role); err != nil {
+		t.Fatal(err)
+	}
+
+	if ctx := fake.Ran("SELECT role")[0].Ctx; ctx.Err() != context.Canceled {
+		t.Errorf("context after Scan = %v, want canceled", ctx.Err())
+	}
+}
+
+func TestTransactionStatementsAreLoggedAndLimited(t *testing.T) {
+	db, fake := sqltest.Open(t, func(query string, args []driver.Value) sqltest.Result {
+		if strings.HasPrefix(query, "SELECT") {
+			return sqltest.Row([]string{"role"}, "user")
+		}
+		return sqltest.Result{Affected: 1}
+	})
+	conn := loggedDB{db}
+
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	LogQueries = true
+	t.Cleanup(func() {
+		log.SetOutput(os.Stderr)
+		LogQueries = false
+	})
+
+	tx, err := conn.BeginTx(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+
+	var role string
+	if err := tx.QueryRowContext(context.Background(), `SELECT role FROM users WHERE id = ?`, 1).Scan(&role); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.ExecContext(context.Background(), `UPDATE users SET role = ? WHERE id = ?`, "admin", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, query := range []string{"SELECT role FROM users", "UPDATE users SET role"} {
+		if !strings.Contains(buf.String(), query) {
+			t.Errorf("%q wasn't logged, log: %s", query, buf.String())
+		}
+
+		ctx := fake.Ran(query)[0].Ctx
+		if _, ok := ctx.Deadline(); !ok {
+			t.Errorf("%q ran without the statement deadline", query)
+		}
+		if ctx.Err() != context.Canceled {
+			t.Errorf("%q context = %v after it finished, want canceled", query, ctx.Err())
+		}
+	}
+}
+
+func TestSlowQueriesAreWarned(t *testing.T) {
+	db, _ := sqltest.Open(t, func(query string, args []driver.Value) sqltest.Result {
+		if strings.Contains(query, "slow") {
+			time.Sleep(20 * time.Millisecond)
+		}
+		return sqltest.Result{Affected: 1}
+	})
+	conn := loggedDB{db}
+
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	SlowQueryThreshold = 10 * time.Millisecond
+	t.Cleanup(func() {
+		log.SetOutput(os.Stderr)
+		SlowQueryThreshold = 200 * time.Millisecond
+	})
+
+	if _, err := conn.ExecContext(context.Background(), `UPDATE fast SET a = ? WHERE id = ?`, "x", 1); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("a fast query was logged: %s", buf.String())
+	}
+
+	if _, err := conn.ExecContext(context.Background(), `UPDATE slow SET a = ? WHERE id = ?`, "secret value", 1); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "WARN slow query") || !strings.Contains(buf.String(), "UPDATE slow SET a = ? WHERE id = ?") {
+		t.Errorf("log = %q, want a warning with the query", buf.String())
+	}
+	if strings.Contains(buf.String(), "secret value") {
+		t.Errorf("log = %q, the arguments were interpolated", buf.String())
+	}
+}
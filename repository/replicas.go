@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+)
+
+// read replicas picked round robin
+type replicaSet struct {
+	dbs  []dbConn
+	next uint32
+}
+
+// the next replica, nil without any
+func (r *replicaSet) pick() dbConn {
+	if r == nil || len(r.dbs) == 0 {
+		return nil
+	}
+
+	n := atomic.AddUint32(&r.next, 1)
+
+	return r.dbs[(n-1)%uint32(len(r.dbs))]
+}
+
+// handle for reads that tolerate replication lag, the primary when no
+// replica is configured
+func (u *userConn) reader() dbConn {
+	if db := u.replicas.pick(); db != nil {
+		return db
+	}
+
+	return u.conn
+}
+
+// fetch user by id from the primary, for reads right after a write
+func (u *userConn) fetchPrimary(ctx context.Context, id int64) (entities.UserResponse, error) {
+	user, err := u.fetchById(ctx, id)
+	if err != nil {
+		return entities.UserResponse{}, err
+	}
+
+	return toUserResponse(user), nil
+}
@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+	"github.com/ariopri/Let-It-Be/tree/main/backend/utils/sqltest"
+)
+
+// answers lookups with user 1, and execs with one affected row
+func oneUser(query string, args []driver.Value) sqltest.Result {
+	if strings.HasPrefix(query, "SELECT") {
+		return userRow(entities.UserResponse{ID: 1, Email: "a@example.com"}, "")
+	}
+	return sqltest.Result{Affected: 1}
+}
+
+func TestReadsGoToReplicasRoundRobin(t *testing.T) {
+	primaryDB, primary := sqltest.Open(t, oneUser)
+	firstDB, first := sqltest.Open(t, oneUser)
+	secondDB, second := sqltest.Open(t, oneUser)
+	repo := NewUserRepo(primaryDB, firstDB, secondDB)
+
+	for i := 0; i < 4; i++ {
+		if _, err := repo.FetchById(context.Background(), 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := repo.Fetch(context.Background(), &entities.UserFilter{Limit: 10}); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := len(primary.Ran("SELECT")); n != 0 {
+		t.Errorf("primary ran %d reads, want none", n)
+	}
+	if a, b := len(first.Ran("SELECT")), len(second.Ran("SELECT")); a != 3 || b != 2 {
+		t.Errorf("replicas ran %d and %d reads, want 3 and 2", a, b)
+	}
+}
+
+func TestWritesGoToThePrimary(t *testing.T) {
+	primaryDB, primary := sqltest.Open(t, oneUser)
+	replicaDB, replica := sqltest.Open(t, oneUser)
+	repo := NewUserRepo(primaryDB, replicaDB)
+
+	if err := repo.Delete(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+
+	// the existence check reads the primary too, a replica may lag
+	if ran := primary.Ran("DELETE FROM users"); len(ran) != 1 {
+		t.Errorf("primary ran %v, want the delete", ran)
+	}
+	if ran := primary.Ran("SELECT"); len(ran) != 1 {
+		t.Errorf("primary ran %v, want the existence check", ran)
+	}
+	if n := len(replica.Ran("")); n != 0 {
+		t.Errorf("replica ran %d statements during a write, want none", n)
+	}
+}
+
+func TestReadsUseThePrimaryWithoutReplicas(t *testing.T) {
+	db, fake := sqltest.Open(t, oneUser)
+
+	if _, err := NewUserRepo(db).FetchById(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(fake.Ran("SELECT")); n != 1 {
+		t.Errorf("ran %d reads, want 1", n)
+	}
+}
@@ -0,0 +1,38 @@
+package repository
+
+import (
+	"database/sql/driver"
+	"time"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+	"github.com/ariopri/Let-It-Be/tree/main/backend/utils/hash"
+	"github.com/ariopri/Let-It-Be/tree/main/backend/utils/sqltest"
+)
+
+func init() {
+	// bcrypt.MinCost, tests hash a lot
+	hash.Cost = 4
+}
+
+// columns scanUser reads, in table order
+var userRowColumns = []string{"id", "firstname", "lastname", "email", "password", "role", "active", "email_verified", "token_version", "created_at", "updated_at"}
+
+// a users row for u with passwordHash as its password
+func userRow(u entities.UserResponse, passwordHash string) sqltest.Result {
+	now := time.Now()
+
+	return sqltest.Row(userRowColumns,
+		u.ID, u.FirstName, u.LastName, u.Email, passwordHash, u.Role,
+		u.Active, u.EmailVerified, int64(u.TokenVersion), now, now,
+	)
+}
+
+// values of a single column
+func column(name string, values ...driver.Value) sqltest.Result {
+	res := sqltest.Result{Columns: []string{name}}
+	for _, v := range values {
+		res.Rows = append(res.Rows, []driver.Value{v})
+	}
+
+	return res
+}
@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+	"github.com/ariopri/Let-It-Be/tree/main/backend/utils/sqltest"
+)
+
+// a database with users 1 (admin) and 2 (user), admins counted as given
+func newRolesDB(t *testing.T, admins int64) (entities.UserRepository, *sqltest.DB) {
+	t.Helper()
+
+	roles := map[int64]string{1: "admin", 2: "user"}
+	db, fake := sqltest.Open(t, func(query string, args []driver.Value) sqltest.Result {
+		switch {
+		case strings.HasPrefix(query, "SELECT role FROM users"):
+			role, ok := roles[args[0].(int64)]
+			if !ok {
+				return sqltest.Result{}
+			}
+			return column("role", role)
+		case strings.HasPrefix(query, "SELECT COUNT(*) FROM users WHERE role = 'admin'"):
+			return column("n", admins)
+		case strings.HasPrefix(query, "SELECT * FROM users"):
+			return userRow(entities.UserResponse{ID: args[0].(int64)}, "")
+		}
+		return sqltest.Result{Affected: 1}
+	})
+
+	return NewUserRepo(db), fake
+}
+
+func TestUpdateRolesAllOrNothing(t *testing.T) {
+	repo, fake := newRolesDB(t, 1)
+
+	roles := []entities.RoleAssignment{{ID: 2, Role: "admin"}, {ID: 9, Role: "admin"}}
+	_, err := repo.UpdateRoles(context.Background(), roles, "admin@example.com")
+
+	var itemErr *entities.BatchItemError
+	if !errors.As(err, &itemErr) || itemErr.Index != 1 || !errors.Is(err, entities.ErrNotFound) {
+		t.Fatalf("err = %v, want item 1 not found", err)
+	}
+	if len(fake.Ran("COMMIT")) != 0 || len(fake.Ran("ROLLBACK")) != 1 {
+		t.Error("the batch wasn't rolled back")
+	}
+}
+
+func TestUpdateRolesLastAdminAcrossBatch(t *testing.T) {
+	repo, fake := newRolesDB(t, 0)
+
+	roles := []entities.RoleAssignment{{ID: 1, Role: "user"}, {ID: 2, Role: "user"}}
+	_, err := repo.UpdateRoles(context.Background(), roles, "admin@example.com")
+	if !errors.Is(err, entities.ErrLastAdmin) {
+		t.Fatalf("err = %v, want %v", err, entities.ErrLastAdmin)
+	}
+	if len(fake.Ran("COMMIT")) != 0 {
+		t.Error("a batch without admins was committed")
+	}
+}
+
+func TestUpdateRolesRecordsChanges(t *testing.T) {
+	repo, fake := newRolesDB(t, 1)
+
+	roles := []entities.RoleAssignment{{ID: 1, Role: "admin"}, {ID: 2, Role: "admin"}}
+	if _, err := repo.UpdateRoles(context.Background(), roles, "admin@example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	// user 1 kept its role
+	inserts := fake.Ran("INSERT INTO role_history")
+	if len(inserts) != 1 {
+		t.Fatalf("%d history entries, want 1", len(inserts))
+	}
+	if args := inserts[0].Args; args[0] != int64(2) || args[1] != "user" || args[2] != "admin" || args[3] != "admin@example.com" {
+		t.Errorf("history entry = %v, want user 2 from user to admin by admin@example.com", args)
+	}
+	if len(fake.Ran("COMMIT")) != 1 {
+		t.Error("the batch wasn't committed")
+	}
+}
+
+func TestRoleHistoryOfUnknownUser(t *testing.T) {
+	db, fake := sqltest.Open(t, nil)
+
+	if _, err := NewUserRepo(db).RoleHistory(context.Background(), 9); !errors.Is(err, entities.ErrNotFound) {
+		t.Errorf("err = %v, want %v", err, entities.ErrNotFound)
+	}
+	if len(fake.Ran("FROM role_history")) != 0 {
+		t.Error("history of an unknown user was queried")
+	}
+}
@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"context"
+)
+
+// label the user, tags are created on first use
+func (u *userConn) AddTags(ctx context.Context, id int64, tags []string) ([]string, error) {
+	if _, err := u.fetchPrimary(ctx, id); err != nil {
+		return nil, err
+	}
+
+	tx, err := u.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	for _, tag := range tags {
+		if _, err := tx.ExecContext(ctx, `INSERT IGNORE INTO tags (name) VALUES(?)`, tag); err != nil {
+			return nil, err
+		}
+
+		query := `INSERT IGNORE INTO user_tags (user_id, tag_id) SELECT ?, id FROM tags WHERE name = ?`
+		if _, err := tx.ExecContext(ctx, query, id, tag); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return u.UserTags(ctx, id)
+}
+
+// remove labels from the user, unknown tags are ignored
+func (u *userConn) RemoveTags(ctx context.Context, id int64, tags []string) ([]string, error) {
+	if _, err := u.fetchPrimary(ctx, id); err != nil {
+		return nil, err
+	}
+
+	for _, tag := range tags {
+		query := `DELETE ut FROM user_tags ut JOIN tags t ON t.id = ut.tag_id WHERE ut.user_id = ? AND t.name = ?`
+		if _, err := u.conn.ExecContext(ctx, query, id, tag); err != nil {
+			return nil, err
+		}
+	}
+
+	return u.UserTags(ctx, id)
+}
+
+// the user's tags sorted by name
+func (u *userConn) UserTags(ctx context.Context, id int64) ([]string, error) {
+	query := `SELECT t.name FROM tags t JOIN user_tags ut ON ut.tag_id = t.id WHERE ut.user_id = ? ORDER BY t.name`
+	rows, err := u.conn.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	tags := []string{}
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, err
+		}
+
+		tags = append(tags, tag)
+	}
+
+	return tags, rows.Err()
+}
@@ -3,25 +3,134 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
 
 	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
 	"github.com/ariopri/Let-It-Be/tree/main/backend/utils/hash"
+	"github.com/go-sql-driver/mysql"
 )
 
+// queryable fields mapped to their columns
+var userColumns = map[string]string{
+	"id":         "id",
+	"first_name": "firstname",
+	"last_name":  "lastname",
+	"email":      "email",
+	"role":       "role",
+	"created_at": "created_at",
+}
+
+// listing order when the filter has none, see SetDefaultSort
+var defaultSort = "id"
+
+// set the listing order used without ?sort=, in the same "-field" form
+func SetDefaultSort(sort string) error {
+	if err := newSelectQuery("users", userColumns).OrderBy(sort); err != nil {
+		return fmt.Errorf("default sort %q: %w", sort, err)
+	}
+
+	defaultSort = sort
+
+	return nil
+}
+
+// unique constraints mapped to the field they guard
+var uniqueConstraints = map[string]string{
+	"users_email_unique": "email",
+}
+
 type userConn struct {
-	conn *sql.DB
+	conn     dbConn
+	replicas *replicaSet
+	counts   *countCache
 }
 
-func NewUserRepo(conn *sql.DB) entities.UserRepository {
-	return &userConn{conn}
+// writes go to conn, listings and lookups by id are spread over replicas
+func NewUserRepo(conn *sql.DB, replicas ...*sql.DB) entities.UserRepository {
+	set := &replicaSet{}
+	for _, r := range replicas {
+		set.dbs = append(set.dbs, loggedDB{r})
+	}
+
+	return &userConn{conn: loggedDB{conn}, replicas: set, counts: newCountCache()}
+}
+
+// turn a duplicate entry error into a DuplicateError naming the field. a
+// duplicate is always reported as one, also when the key is unknown, so a
+// lost insert race ends in 409 and never in 500. keys missing from
+// uniqueConstraints are reported as "entry", clients never see the
+// schema's names
+func duplicateError(err error) error {
+	var myErr *mysql.MySQLError
+	if !errors.As(err, &myErr) || myErr.Number != 1062 {
+		return err
+	}
+
+	// message looks like: Duplicate entry 'x' for key 'users.users_email_unique'
+	msg := myErr.Message
+	i := strings.LastIndex(msg, "for key '")
+	if i < 0 {
+		return &entities.DuplicateError{Field: "entry"}
+	}
+	key := strings.TrimSuffix(msg[i+len("for key '"):], "'")
+	key = key[strings.LastIndex(key, ".")+1:]
+
+	field, ok := uniqueConstraints[key]
+	if !ok {
+		field = "entry"
+	}
+
+	return &entities.DuplicateError{Field: field}
+}
+
+// a delete blocked by a foreign key (1451) becomes ErrReferenced. the
+// tables here cascade, it happens with tables added next to them
+func referencedError(err error) error {
+	var myErr *mysql.MySQLError
+	if errors.As(err, &myErr) && myErr.Number == 1451 {
+		return entities.ErrReferenced
+	}
+
+	return err
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scan a full users row, columns in table order
+func scanUser(row scanner) (entities.User, error) {
+	var u entities.User
+	err := row.Scan(&u.ID, &u.FirstName, &u.LastName, &u.Email, &u.Password, &u.Role, &u.Active, &u.EmailVerified, &u.TokenVersion, &u.CreatedAt, &u.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = entities.ErrNotFound
+	}
+
+	return u, err
+}
+
+func toUserResponse(u entities.User) entities.UserResponse {
+	return entities.UserResponse{
+		ID:            u.ID,
+		FirstName:     u.FirstName,
+		LastName:      u.LastName,
+		Email:         u.Email,
+		Role:          u.Role,
+		Active:        u.Active,
+		EmailVerified: u.EmailVerified,
+		TokenVersion:  u.TokenVersion,
+		CreatedAt:     u.CreatedAt,
+		UpdatedAt:     u.UpdatedAt,
+	}
 }
 
 // fetch user by email
 func (repo *userConn) fetchUserByEmail(ctx context.Context, email string) (entities.User, error) {
-	var u entities.User
 	sqlStmt := `SELECT * FROM users WHERE email = ?`
-	row := repo.conn.QueryRow(sqlStmt, email)
-	err := row.Scan(&u.ID, &u.FirstName, &u.LastName, &u.Email, &u.Password, &u.Role, &u.CreatedAt)
+	row := repo.conn.QueryRowContext(ctx, sqlStmt, email)
+	u, err := scanUser(row)
 	if err != nil {
 		return u, err
 	}
@@ -31,10 +140,9 @@ func (repo *userConn) fetchUserByEmail(ctx context.Context, email string) (entit
 
 // fetch user by id for comparing password
 func (u *userConn) fetchById(ctx context.Context, id int64) (entities.User, error) {
-	var user entities.User
 	sqlStmt := `SELECT * FROM users WHERE id = ?`
 	row := u.conn.QueryRowContext(ctx, sqlStmt, id)
-	err := row.Scan(&user.ID, &user.FirstName, &user.LastName, &user.Email, &user.Password, &user.Role, &user.CreatedAt)
+	user, err := scanUser(row)
 	if err != nil {
 		return entities.User{}, err
 	}
@@ -46,6 +154,8 @@ func (u *userConn) fetchById(ctx context.Context, id int64) (entities.User, erro
 func (u *userConn) Login(ctx context.Context, login *entities.Login) (entities.UserResponse, error) {
 	user, err := u.fetchUserByEmail(ctx, login.Email)
 	if err != nil {
+		// compare anyway so unknown emails take as long as known ones
+		hash.CheckDummy(login.Password)
 		return entities.UserResponse{}, err
 	}
 
@@ -54,19 +164,16 @@ func (u *userConn) Login(ctx context.Context, login *entities.Login) (entities.U
 		return entities.UserResponse{}, err
 	}
 
-	userResponse := &entities.UserResponse{
-		ID:        user.ID,
-		FirstName: user.FirstName,
-		LastName:  user.LastName,
-		Email:     user.Email,
-		Role:      user.Role,
-		CreatedAt: user.CreatedAt,
+	// disabled accounts can't log in
+	if !user.Active {
+		return entities.UserResponse{}, entities.ErrUserDisabled
 	}
 
-	return *userResponse, nil
+	return toUserResponse(user), nil
 }
 
-// register
+// register. there is no separate email check, concurrent registrations
+// race on the unique constraint and the losers get a DuplicateError
 func (u *userConn) Register(ctx context.Context, user *entities.User) (entities.UserResponse, error) {
 	res, err := u.Create(ctx, user)
 	if err != nil {
@@ -76,59 +183,190 @@ func (u *userConn) Register(ctx context.Context, user *entities.User) (entities.
 	return res, nil
 }
 
+// users query with the filter's conditions applied
+func userFilterQuery(f *entities.UserFilter) (*selectQuery, error) {
+	q := newSelectQuery("users", userColumns)
+	if !f.CreatedAfter.IsZero() {
+		if err := q.Where("created_at", ">=", f.CreatedAfter); err != nil {
+			return nil, err
+		}
+	}
+	if !f.CreatedBefore.IsZero() {
+		if err := q.Where("created_at", "<=", f.CreatedBefore); err != nil {
+			return nil, err
+		}
+	}
+	// the columns use mysql's default case insensitive collation, so LIKE
+	// ignores case without LOWER() and the prefix match can use indexes
+	if f.Search != "" {
+		pattern := escapeLike(f.Search) + "%"
+		q.WhereRaw(`(email LIKE ? ESCAPE '!' OR firstname LIKE ? ESCAPE '!' OR lastname LIKE ? ESCAPE '!'
+			OR CONCAT(firstname, ' ', lastname) LIKE ? ESCAPE '!')`, pattern, pattern, pattern, pattern)
+	}
+	if f.Tag != "" {
+		q.WhereRaw(`id IN (SELECT ut.user_id FROM user_tags ut JOIN tags t ON t.id = ut.tag_id WHERE t.name = ?)`, f.Tag)
+	}
+	if roles := f.Roles(); roles != nil {
+		values := make([]interface{}, len(roles))
+		for i, r := range roles {
+			values[i] = r
+		}
+		if err := q.WhereIn("role", values); err != nil {
+			return nil, err
+		}
+	}
+
+	return q, nil
+}
+
 // fetch users
-func (u *userConn) Fetch(ctx context.Context) ([]entities.UserResponse, error) {
-	query := `SELECT * FROM users`
-	rows, err := u.conn.QueryContext(ctx, query)
+func (u *userConn) Fetch(ctx context.Context, f *entities.UserFilter) ([]entities.UserResponse, error) {
+	q, err := userFilterQuery(f)
+	if err != nil {
+		return []entities.UserResponse{}, err
+	}
+	sort := f.Sort
+	if sort == "" {
+		sort = defaultSort
+	}
+	if err := q.OrderBy(sort); err != nil {
+		return []entities.UserResponse{}, err
+	}
+	q.Paginate(f.Limit, f.Offset)
+
+	query, args := q.Build()
+	rows, err := u.reader().QueryContext(ctx, query, args...)
 	if err != nil {
 		return []entities.UserResponse{}, err
 	}
 
 	defer rows.Close()
 
-	var users []entities.UserResponse
+	users := []entities.UserResponse{}
 	for rows.Next() {
-		var user entities.User
-		err = rows.Scan(&user.ID, &user.FirstName, &user.LastName, &user.Email, &user.Password, &user.Role, &user.CreatedAt)
+		user, err := scanUser(rows)
 		if err != nil {
 			return []entities.UserResponse{}, err
 		}
 
-		userResponse := &entities.UserResponse{
-			ID:        user.ID,
-			FirstName: user.FirstName,
-			LastName:  user.LastName,
-			Email:     user.Email,
-			Role:      user.Role,
-			CreatedAt: user.CreatedAt,
+		users = append(users, toUserResponse(user))
+	}
+
+	return users, nil
+}
+
+// number of users matching the filter. exact counts always run COUNT(*),
+// estimates come from table statistics, or for a filtered listing from a
+// COUNT(*) cached for CountCacheTTL
+func (u *userConn) Count(ctx context.Context, f *entities.UserFilter, mode string) (int64, error) {
+	if mode == entities.CountEstimate && !f.Filtered() {
+		var n sql.NullInt64
+		sqlStmt := `SELECT TABLE_ROWS FROM information_schema.TABLES WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'users'`
+		err := u.reader().QueryRowContext(ctx, sqlStmt).Scan(&n)
+
+		return n.Int64, err
+	}
+
+	q, err := userFilterQuery(f)
+	if err != nil {
+		return 0, err
+	}
+
+	query, args := q.BuildCount()
+	key := fmt.Sprint(query, args)
+	if mode == entities.CountEstimate {
+		if n, ok := u.counts.get(key); ok {
+			return n, nil
 		}
+	}
 
-		users = append(users, *userResponse)
+	var n int64
+	if err := u.reader().QueryRowContext(ctx, query, args...).Scan(&n); err != nil {
+		return 0, err
 	}
+	u.counts.set(key, n)
 
-	return users, nil
+	return n, nil
 }
 
 // fetch user by id
 func (u *userConn) FetchById(ctx context.Context, id int64) (entities.UserResponse, error) {
-	var user entities.User
 	sqlStmt := `SELECT * FROM users WHERE id = ?`
-	row := u.conn.QueryRowContext(ctx, sqlStmt, id)
-	err := row.Scan(&user.ID, &user.FirstName, &user.LastName, &user.Email, &user.Password, &user.Role, &user.CreatedAt)
+	row := u.reader().QueryRowContext(ctx, sqlStmt, id)
+	user, err := scanUser(row)
 	if err != nil {
 		return entities.UserResponse{}, err
 	}
 
-	userResponse := &entities.UserResponse{
-		ID:        user.ID,
-		FirstName: user.FirstName,
-		LastName:  user.LastName,
-		Email:     user.Email,
-		Role:      user.Role,
-		CreatedAt: user.CreatedAt,
+	return toUserResponse(user), nil
+}
+
+// fetch users by ids, in the order given. ids without a user are returned
+// as missing
+func (u *userConn) FetchByIDs(ctx context.Context, ids []int64) ([]entities.UserResponse, []int64, error) {
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+
+	q := newSelectQuery("users", userColumns)
+	if err := q.WhereIn("id", args); err != nil {
+		return []entities.UserResponse{}, []int64{}, err
+	}
+
+	query, qArgs := q.Build()
+	rows, err := u.reader().QueryContext(ctx, query, qArgs...)
+	if err != nil {
+		return []entities.UserResponse{}, []int64{}, err
+	}
+
+	defer rows.Close()
+
+	found := map[int64]entities.UserResponse{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return []entities.UserResponse{}, []int64{}, err
+		}
+
+		found[user.ID] = toUserResponse(user)
+	}
+
+	users := []entities.UserResponse{}
+	missing := []int64{}
+	for _, id := range ids {
+		user, ok := found[id]
+		if !ok {
+			missing = append(missing, id)
+			continue
+		}
+
+		users = append(users, user)
+	}
+
+	return users, missing, nil
+}
+
+// check if an email is taken
+func (u *userConn) EmailExists(ctx context.Context, email string) (bool, error) {
+	var exists bool
+	sqlStmt := `SELECT EXISTS(SELECT 1 FROM users WHERE email = ?)`
+	err := u.conn.QueryRowContext(ctx, sqlStmt, email).Scan(&exists)
+	if err != nil {
+		return false, err
+	}
+
+	return exists, nil
+}
+
+// fetch user by email
+func (u *userConn) FetchByEmail(ctx context.Context, email string) (entities.UserResponse, error) {
+	user, err := u.fetchUserByEmail(ctx, email)
+	if err != nil {
+		return entities.UserResponse{}, err
 	}
 
-	return *userResponse, nil
+	return toUserResponse(user), nil
 }
 
 // create user
@@ -140,26 +378,79 @@ func (u *userConn) Create(ctx context.Context, user *entities.User) (entities.Us
 
 	row, err := u.conn.ExecContext(ctx, query, &user.FirstName, &user.LastName, &user.Email, &user.Password)
 	if err != nil {
-		return entities.UserResponse{}, err
+		return entities.UserResponse{}, duplicateError(err)
 	}
 
 	lastId, _ := row.LastInsertId()
 
-	res, err := u.FetchById(ctx, lastId)
+	res, err := u.fetchPrimary(ctx, lastId)
 	if err != nil {
 		return entities.UserResponse{}, err
 	}
 
-	userResponse := &entities.UserResponse{
-		ID:        res.ID,
-		FirstName: res.FirstName,
-		LastName:  res.LastName,
-		Email:     res.Email,
-		Role:      res.Role,
-		CreatedAt: res.CreatedAt,
+	return res, nil
+}
+
+// create or update the user with the same email, reports if it was created
+func (u *userConn) Upsert(ctx context.Context, user *entities.User) (entities.UserResponse, bool, error) {
+	// the password history applies when the user exists already, resending
+	// the current password keeps it
+	existing, err := u.fetchUserByEmail(ctx, user.Email)
+	exists := err == nil
+	if err != nil && !errors.Is(err, entities.ErrNotFound) {
+		return entities.UserResponse{}, false, err
+	}
+	changed := !exists || hash.CheckPassword(existing.Password, user.Password) != nil
+	if exists && changed {
+		if err := u.checkPasswordCooldown(ctx, existing.ID); err != nil {
+			return entities.UserResponse{}, false, err
+		}
+
+		reused, err := u.PasswordReused(ctx, existing.ID, user.Password)
+		if err != nil {
+			return entities.UserResponse{}, false, err
+		}
+		if reused {
+			return entities.UserResponse{}, false, entities.ErrPasswordReused
+		}
+	}
+
+	// hash password
+	if changed {
+		user.Password, _ = hash.HashPassword(user.Password)
+	} else {
+		user.Password = existing.Password
+	}
+
+	query := `INSERT INTO users (firstname, lastname, email, password) VALUES(?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE id = LAST_INSERT_ID(id), firstname = VALUES(firstname),
+		lastname = VALUES(lastname), password = VALUES(password)`
+
+	row, err := u.conn.ExecContext(ctx, query, &user.FirstName, &user.LastName, &user.Email, &user.Password)
+	if err != nil {
+		return entities.UserResponse{}, false, duplicateError(err)
 	}
 
-	return *userResponse, nil
+	// 1 for an insert, 2 for an update
+	affected, _ := row.RowsAffected()
+	created := affected == 1
+	lastId, _ := row.LastInsertId()
+
+	if !created && exists && changed {
+		if err := u.RecordPasswordHistory(ctx, lastId, existing.Password); err != nil {
+			return entities.UserResponse{}, false, err
+		}
+		if err := u.recordPasswordChange(ctx, lastId); err != nil {
+			return entities.UserResponse{}, false, err
+		}
+	}
+
+	res, err := u.fetchPrimary(ctx, lastId)
+	if err != nil {
+		return entities.UserResponse{}, false, err
+	}
+
+	return res, created, nil
 }
 
 // update user
@@ -169,20 +460,46 @@ func (u *userConn) Update(ctx context.Context, id int64, user *entities.User) (e
 		return entities.UserResponse{}, err
 	}
 
-	// compare with the old password
-	if user.Password != usr.Password {
+	// an empty or the current password keeps the stored hash, only a new
+	// one is a change
+	changed := user.Password != "" && hash.CheckPassword(usr.Password, user.Password) != nil
+	if changed {
+		if err := u.checkPasswordCooldown(ctx, id); err != nil {
+			return entities.UserResponse{}, err
+		}
+
+		reused, err := u.PasswordReused(ctx, id, user.Password)
+		if err != nil {
+			return entities.UserResponse{}, err
+		}
+		if reused {
+			return entities.UserResponse{}, entities.ErrPasswordReused
+		}
+
 		// hash password
 		user.Password, _ = hash.HashPassword(user.Password)
+	} else {
+		user.Password = usr.Password
 	}
 
-	query := `UPDATE users SET firstname = ?, lastname = ?,  email = ?, password = ? WHERE id = ?`
+	// the email is changed by ConfirmEmailChange only, once it's verified
+	query := `UPDATE users SET firstname = ?, lastname = ?, password = ? WHERE id = ?`
 
-	_, err = u.conn.ExecContext(ctx, query, &user.FirstName, &user.LastName, &user.Email, &user.Password, id)
+	_, err = u.conn.ExecContext(ctx, query, &user.FirstName, &user.LastName, &user.Password, id)
 	if err != nil {
-		return entities.UserResponse{}, err
+		return entities.UserResponse{}, duplicateError(err)
 	}
 
-	res, err := u.FetchById(ctx, id)
+	if changed {
+		if err := u.RecordPasswordHistory(ctx, id, usr.Password); err != nil {
+			return entities.UserResponse{}, err
+		}
+		if err := u.recordPasswordChange(ctx, id); err != nil {
+			return entities.UserResponse{}, err
+		}
+	}
+
+	res, err := u.fetchPrimary(ctx, id)
 	if err != nil {
 		return entities.UserResponse{}, err
 	}
@@ -193,13 +510,158 @@ func (u *userConn) Update(ctx context.Context, id int64, user *entities.User) (e
 // delete user
 func (u *userConn) Delete(ctx context.Context, id int64) error {
 	// check the user if exists
-	_, err := u.FetchById(ctx, id)
+	_, err := u.fetchPrimary(ctx, id)
 	if err != nil {
 		return err
 	}
 
 	query := `DELETE FROM users WHERE id = ?`
 	_, err = u.conn.ExecContext(ctx, query, id)
+	if err != nil {
+		return referencedError(err)
+	}
+
+	return nil
+}
+
+// enable or disable user
+func (u *userConn) UpdateStatus(ctx context.Context, id int64, active bool) (entities.UserResponse, error) {
+	// check the user if exists
+	_, err := u.fetchPrimary(ctx, id)
+	if err != nil {
+		return entities.UserResponse{}, err
+	}
+
+	query := `UPDATE users SET active = ? WHERE id = ?`
+	_, err = u.conn.ExecContext(ctx, query, active, id)
+	if err != nil {
+		return entities.UserResponse{}, err
+	}
+
+	res, err := u.fetchPrimary(ctx, id)
+	if err != nil {
+		return entities.UserResponse{}, err
+	}
+
+	return res, nil
+}
+
+// set roles of many users at once, all or nothing. a failing assignment is
+// reported as a BatchItemError, the last admin guard for the whole batch
+func (u *userConn) UpdateRoles(ctx context.Context, roles []entities.RoleAssignment, changedBy string) ([]entities.UserResponse, error) {
+	tx, err := u.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return []entities.UserResponse{}, err
+	}
+	defer tx.Rollback()
+
+	for i, r := range roles {
+		var old string
+		err := tx.QueryRowContext(ctx, `SELECT role FROM users WHERE id = ? FOR UPDATE`, r.ID).Scan(&old)
+		if errors.Is(err, sql.ErrNoRows) {
+			return []entities.UserResponse{}, &entities.BatchItemError{Index: i, Err: entities.ErrNotFound}
+		}
+		if err != nil {
+			return []entities.UserResponse{}, err
+		}
+
+		// only actual changes go into the history
+		if old == r.Role {
+			continue
+		}
+
+		if _, err := tx.ExecContext(ctx, `UPDATE users SET role = ? WHERE id = ?`, r.Role, r.ID); err != nil {
+			return []entities.UserResponse{}, err
+		}
+
+		query := `INSERT INTO role_history (user_id, old_role, new_role, changed_by) VALUES(?, ?, ?, ?)`
+		if _, err := tx.ExecContext(ctx, query, r.ID, old, r.Role, changedBy); err != nil {
+			return []entities.UserResponse{}, err
+		}
+	}
+
+	// last admin guard, checked against the whole batch
+	var admins int
+	err = tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM users WHERE role = 'admin'`).Scan(&admins)
+	if err != nil {
+		return []entities.UserResponse{}, err
+	}
+	if admins == 0 {
+		return []entities.UserResponse{}, entities.ErrLastAdmin
+	}
+
+	if err := tx.Commit(); err != nil {
+		return []entities.UserResponse{}, err
+	}
+
+	users := []entities.UserResponse{}
+	for _, r := range roles {
+		res, err := u.fetchPrimary(ctx, r.ID)
+		if err != nil {
+			return []entities.UserResponse{}, err
+		}
+
+		users = append(users, res)
+	}
+
+	return users, nil
+}
+
+// role changes of the user, oldest first
+func (u *userConn) RoleHistory(ctx context.Context, id int64) ([]entities.RoleChange, error) {
+	if _, err := u.fetchPrimary(ctx, id); err != nil {
+		return nil, err
+	}
+
+	query := `SELECT id, user_id, old_role, new_role, changed_by, created_at FROM role_history WHERE user_id = ? ORDER BY created_at, id`
+	rows, err := u.conn.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	changes := []entities.RoleChange{}
+	for rows.Next() {
+		var rc entities.RoleChange
+		if err := rows.Scan(&rc.ID, &rc.UserID, &rc.OldRole, &rc.NewRole, &rc.ChangedBy, &rc.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		changes = append(changes, rc)
+	}
+
+	return changes, rows.Err()
+}
+
+// stream all users to fn one row at a time
+func (u *userConn) Export(ctx context.Context, fn func(entities.UserResponse) error) error {
+	// streams every user, only the export deadline applies
+	rows, err := u.reader().QueryContext(withoutStatementTimeout(ctx), `SELECT * FROM users ORDER BY id`)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return err
+		}
+
+		if err := fn(toUserResponse(user)); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// invalidate every token issued to the user so far
+func (u *userConn) BumpTokenVersion(ctx context.Context, id int64) error {
+	query := `UPDATE users SET token_version = token_version + 1 WHERE id = ?`
+	_, err := u.conn.ExecContext(ctx, query, id)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,428 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+	"github.com/ariopri/Let-It-Be/tree/main/backend/utils/hash"
+	"github.com/ariopri/Let-It-Be/tree/main/backend/utils/sqltest"
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestFetchSortsByIDLast(t *testing.T) {
+	for sort, want := range map[string]string{
+		"role":   "ORDER BY role ASC, id ASC",
+		"-role":  "ORDER BY role DESC, id DESC",
+		"-id":    "ORDER BY id DESC",
+		"":       "ORDER BY id ASC",
+		"-email": "ORDER BY email DESC, id DESC",
+	} {
+		db, fake := sqltest.Open(t, nil)
+
+		_, err := NewUserRepo(db).Fetch(context.Background(), &entities.UserFilter{Sort: sort, Limit: 10, Offset: 20})
+		if err != nil {
+			t.Fatalf("sort %q: %v", sort, err)
+		}
+
+		ran := fake.Ran("SELECT * FROM users")
+		if len(ran) != 1 || !strings.Contains(ran[0].Query, want+" LIMIT") {
+			t.Errorf("sort %q ran %v, want %q before the limit", sort, ran, want)
+		}
+	}
+}
+
+func TestFetchWithoutUsersIsEmpty(t *testing.T) {
+	db, _ := sqltest.Open(t, nil)
+
+	users, err := NewUserRepo(db).Fetch(context.Background(), &entities.UserFilter{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if users == nil || len(users) != 0 {
+		t.Errorf("users = %#v, want an empty slice", users)
+	}
+}
+
+func TestFetchRejectsUnknownSort(t *testing.T) {
+	db, fake := sqltest.Open(t, nil)
+
+	_, err := NewUserRepo(db).Fetch(context.Background(), &entities.UserFilter{Sort: "password"})
+	if !errors.Is(err, entities.ErrInvalidSort) {
+		t.Errorf("err = %v, want ErrInvalidSort", err)
+	}
+	if ran := fake.Ran("SELECT"); len(ran) != 0 {
+		t.Errorf("ran %q", ran[0].Query)
+	}
+}
+
+func TestDuplicateErrorNamesTheField(t *testing.T) {
+	for _, tc := range []struct {
+		msg   string
+		field string
+	}{
+		{"Duplicate entry 'a@example.com' for key 'users.users_email_unique'", "email"},
+		// mysql before 8 leaves out the table
+		{"Duplicate entry 'a@example.com' for key 'users_email_unique'", "email"},
+		// unknown keys don't leak into responses
+		{"Duplicate entry 'x' for key 'users.users_nickname_unique'", "entry"},
+		{"Duplicate entry 'x'", "entry"},
+	} {
+		err := duplicateError(&mysql.MySQLError{Number: 1062, Message: tc.msg})
+
+		var dup *entities.DuplicateError
+		if !errors.As(err, &dup) || dup.Field != tc.field {
+			t.Errorf("%q: err = %v, want a DuplicateError for %s", tc.msg, err, tc.field)
+		}
+	}
+
+	other := &mysql.MySQLError{Number: 1451, Message: "Cannot delete or update a parent row"}
+	if err := duplicateError(other); err != other {
+		t.Errorf("err = %v, want other errors unchanged", err)
+	}
+}
+
+func TestRegisterRaceIsAConflict(t *testing.T) {
+	db, _ := sqltest.Open(t, func(query string, args []driver.Value) sqltest.Result {
+		if strings.HasPrefix(query, "INSERT INTO users") {
+			return sqltest.Result{Err: &mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'a@example.com' for key 'users.users_email_unique'"}}
+		}
+		return sqltest.Result{}
+	})
+
+	_, err := NewUserRepo(db).Register(context.Background(), &entities.User{Email: "a@example.com", Password: "secret-pass"})
+
+	var dup *entities.DuplicateError
+	if !errors.As(err, &dup) || dup.Field != "email" {
+		t.Errorf("err = %v, want a DuplicateError for email", err)
+	}
+}
+
+// a conflict on a key the api doesn't know is still a conflict, without
+// the constraint's name
+func TestUnknownUniqueKeyConflict(t *testing.T) {
+	db, _ := sqltest.Open(t, func(query string, args []driver.Value) sqltest.Result {
+		if strings.HasPrefix(query, "INSERT INTO users") {
+			return sqltest.Result{Err: &mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'x' for key 'users.users_nickname_unique'"}}
+		}
+		return sqltest.Result{}
+	})
+	repo := NewUserRepo(db)
+
+	for name, write := range map[string]func() error{
+		"register": func() error {
+			_, err := repo.Register(context.Background(), &entities.User{Email: "a@example.com", Password: "secret-pass"})
+			return err
+		},
+		// the import and batch paths
+		"create": func() error {
+			_, err := repo.Create(context.Background(), &entities.User{Email: "a@example.com", Password: "secret-pass"})
+			return err
+		},
+		"upsert": func() error {
+			_, _, err := repo.Upsert(context.Background(), &entities.User{Email: "a@example.com", Password: "secret-pass"})
+			return err
+		},
+	} {
+		err := write()
+
+		var dup *entities.DuplicateError
+		if !errors.As(err, &dup) || dup.Field != "entry" {
+			t.Errorf("%s: err = %v, want a DuplicateError for entry", name, err)
+		}
+		if strings.Contains(err.Error(), "nickname") {
+			t.Errorf("%s: err = %q names the constraint", name, err)
+		}
+	}
+}
+
+func TestFetchByIDsKeepsRequestOrder(t *testing.T) {
+	db, fake := sqltest.Open(t, func(query string, args []driver.Value) sqltest.Result {
+		// the database answers in its own order
+		res := userRow(entities.UserResponse{ID: 3, Email: "c@example.com"}, "")
+		res.Rows = append(res.Rows, userRow(entities.UserResponse{ID: 1, Email: "a@example.com"}, "").Rows...)
+		return res
+	})
+
+	users, missing, err := NewUserRepo(db).FetchByIDs(context.Background(), []int64{1, 2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(users) != 2 || users[0].ID != 1 || users[1].ID != 3 {
+		t.Errorf("users = %v, want 1 and 3 in that order", users)
+	}
+	if len(missing) != 1 || missing[0] != 2 {
+		t.Errorf("missing = %v, want [2]", missing)
+	}
+
+	ran := fake.Ran("SELECT")
+	if len(ran) != 1 || !strings.Contains(ran[0].Query, "id IN (?, ?, ?)") {
+		t.Errorf("ran %v, want a single IN query", ran)
+	}
+}
+
+func TestFetchCreatedBoundsAreInclusive(t *testing.T) {
+	db, fake := sqltest.Open(t, nil)
+	after := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	before := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	_, err := NewUserRepo(db).Fetch(context.Background(), &entities.UserFilter{CreatedAfter: after, CreatedBefore: before, Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ran := fake.Ran("SELECT * FROM users")
+	if len(ran) != 1 || !strings.Contains(ran[0].Query, "created_at >= ? AND created_at <= ?") {
+		t.Fatalf("ran %v, want inclusive created_at bounds", ran)
+	}
+	if args := ran[0].Args; len(args) < 2 || args[0] != after || args[1] != before {
+		t.Errorf("args = %v, want %v and %v first", args, after, before)
+	}
+}
+
+// a database for Upsert holding existing, a zero user for none, that
+// reports affected rows for the upsert itself
+func upsertDB(t *testing.T, existing entities.UserResponse, passwordHash string, affected int64) (entities.UserRepository, *sqltest.DB) {
+	t.Helper()
+
+	db, fake := sqltest.Open(t, func(query string, args []driver.Value) sqltest.Result {
+		switch {
+		case strings.HasPrefix(query, "INSERT INTO users"):
+			return sqltest.Result{Affected: affected, LastID: 5}
+		case strings.HasPrefix(query, "SELECT") && strings.Contains(query, "FROM users"):
+			if _, byEmail := args[0].(string); byEmail && existing.ID == 0 {
+				return sqltest.Result{}
+			}
+			u := existing
+			u.ID = 5
+			return userRow(u, passwordHash)
+		}
+		return sqltest.Result{}
+	})
+
+	return NewUserRepo(db), fake
+}
+
+func TestUpsertInserts(t *testing.T) {
+	repo, fake := upsertDB(t, entities.UserResponse{}, "", 1)
+
+	user, created, err := repo.Upsert(context.Background(), &entities.User{FirstName: "New", Email: "new@example.com", Password: "secret-pass"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !created || user.ID != 5 {
+		t.Errorf("created %v id %d, want an insert of 5", created, user.ID)
+	}
+
+	ran := fake.Ran("INSERT INTO users")
+	if len(ran) != 1 || !strings.Contains(ran[0].Query, "ON DUPLICATE KEY UPDATE") {
+		t.Fatalf("ran %v, want a single upsert", ran)
+	}
+	if hashed := ran[0].Args[3].(string); hash.CheckPassword(hashed, "secret-pass") != nil {
+		t.Errorf("stored password %q isn't the hash", hashed)
+	}
+}
+
+func TestLoginOfUnknownEmailComparesAHash(t *testing.T) {
+	known := entities.UserResponse{ID: 1, Email: "known@example.com", Active: true}
+	current, _ := hash.HashPassword("secret-pass")
+	db, _ := sqltest.Open(t, func(query string, args []driver.Value) sqltest.Result {
+		if args[0] == known.Email {
+			return userRow(known, current)
+		}
+		return sqltest.Result{}
+	})
+	repo := NewUserRepo(db)
+
+	// total time of n logins as email with a wrong password
+	timeLogins := func(email string, want error) time.Duration {
+		start := time.Now()
+		for i := 0; i < 10; i++ {
+			_, err := repo.Login(context.Background(), &entities.Login{Email: email, Password: "wrong-pass"})
+			if err == nil || (want != nil && !errors.Is(err, want)) {
+				t.Fatalf("%s: err = %v", email, err)
+			}
+		}
+		return time.Since(start)
+	}
+
+	// warm the dummy hash up
+	timeLogins("unknown@example.com", entities.ErrNotFound)
+
+	unknown := timeLogins("unknown@example.com", entities.ErrNotFound)
+	wrong := timeLogins(known.Email, nil)
+
+	// without a compare an unknown email returns in a fraction of a bcrypt
+	// run, the margin is wide so a busy machine doesn't fail it
+	if unknown < wrong/3 {
+		t.Errorf("unknown emails took %v, wrong passwords %v, want them comparable", unknown, wrong)
+	}
+}
+
+func TestDeleteBlockedByForeignKey(t *testing.T) {
+	db, _ := sqltest.Open(t, func(query string, args []driver.Value) sqltest.Result {
+		if strings.HasPrefix(query, "DELETE FROM users") {
+			return sqltest.Result{Err: &mysql.MySQLError{Number: 1451, Message: "Cannot delete or update a parent row: a foreign key constraint fails"}}
+		}
+		return userRow(entities.UserResponse{ID: 2}, "")
+	})
+
+	if err := NewUserRepo(db).Delete(context.Background(), 2); !errors.Is(err, entities.ErrReferenced) {
+		t.Errorf("err = %v, want %v", err, entities.ErrReferenced)
+	}
+}
+
+func TestUpsertUpdatesKeepingThePassword(t *testing.T) {
+	existing := entities.UserResponse{ID: 5, FirstName: "Old", Email: "old@example.com"}
+	current, _ := hash.HashPassword("secret-pass")
+	repo, fake := upsertDB(t, existing, current, 2)
+
+	user, created, err := repo.Upsert(context.Background(), &entities.User{FirstName: "Renamed", Email: existing.Email, Password: "secret-pass"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created || user.ID != 5 {
+		t.Errorf("created %v id %d, want an update of 5", created, user.ID)
+	}
+
+	// the same password is neither rehashed nor recorded as a change
+	if ran := fake.Ran("INSERT INTO users"); len(ran) != 1 || ran[0].Args[3] != current {
+		t.Errorf("ran %v, want the current hash kept", ran)
+	}
+	if ran := fake.Ran("password_history"); len(ran) != 0 {
+		t.Errorf("ran %v, want no password history", ran)
+	}
+}
+
+func TestFetchByIdNotFound(t *testing.T) {
+	db, _ := sqltest.Open(t, nil)
+	if _, err := NewUserRepo(db).FetchById(context.Background(), 1); !errors.Is(err, entities.ErrNotFound) {
+		t.Errorf("no rows: err = %v, want ErrNotFound", err)
+	}
+
+	db, _ = sqltest.Open(t, func(query string, args []driver.Value) sqltest.Result {
+		return sqltest.Result{Err: errors.New("connection refused")}
+	})
+	if _, err := NewUserRepo(db).FetchById(context.Background(), 1); err == nil || errors.Is(err, entities.ErrNotFound) {
+		t.Errorf("db error: err = %v, want it passed on", err)
+	}
+}
+
+func TestFetchSearch(t *testing.T) {
+	for search, want := range map[string]string{
+		// the case is left to the column collation
+		"ANN":   "ANN%",
+		"100%":  "100!%%",
+		"a_b":   "a!_b%",
+		"Ann S": "Ann S%",
+	} {
+		db, fake := sqltest.Open(t, nil)
+
+		if _, err := NewUserRepo(db).Fetch(context.Background(), &entities.UserFilter{Search: search, Limit: 10}); err != nil {
+			t.Fatal(err)
+		}
+
+		ran := fake.Ran("SELECT * FROM users")
+		if len(ran) != 1 {
+			t.Fatalf("search %q ran %v", search, ran)
+		}
+		for _, column := range []string{"email LIKE", "firstname LIKE", "lastname LIKE", "CONCAT(firstname, ' ', lastname) LIKE"} {
+			if !strings.Contains(ran[0].Query, column+" ? ESCAPE '!'") {
+				t.Errorf("search %q: query %q doesn't match %s", search, ran[0].Query, column)
+			}
+		}
+		// four patterns, then limit and offset
+		if args := ran[0].Args; len(args) != 6 || args[0] != want || args[3] != want {
+			t.Errorf("search %q: args = %v, want the pattern %q", search, args, want)
+		}
+	}
+}
+
+func TestFetchByRoles(t *testing.T) {
+	for role, want := range map[string]string{
+		"admin":      "role IN (?)",
+		"admin,user": "role IN (?, ?)",
+	} {
+		db, fake := sqltest.Open(t, nil)
+
+		if _, err := NewUserRepo(db).Fetch(context.Background(), &entities.UserFilter{Role: role, Limit: 10}); err != nil {
+			t.Fatal(err)
+		}
+
+		ran := fake.Ran("SELECT * FROM users")
+		if len(ran) != 1 || !strings.Contains(ran[0].Query, want) {
+			t.Fatalf("role %q ran %v, want %q", role, ran, want)
+		}
+		roles := strings.Split(role, ",")
+		for i, r := range roles {
+			if ran[0].Args[i] != r {
+				t.Errorf("role %q: args = %v, want the roles first", role, ran[0].Args)
+			}
+		}
+	}
+}
+
+func TestFetchByTag(t *testing.T) {
+	db, fake := sqltest.Open(t, nil)
+
+	if _, err := NewUserRepo(db).Fetch(context.Background(), &entities.UserFilter{Tag: "vip", Limit: 10}); err != nil {
+		t.Fatal(err)
+	}
+
+	ran := fake.Ran("SELECT * FROM users")
+	if len(ran) != 1 || !strings.Contains(ran[0].Query, "JOIN tags t ON t.id = ut.tag_id WHERE t.name = ?") || ran[0].Args[0] != "vip" {
+		t.Errorf("ran %v, want the tag bound in a subquery", ran)
+	}
+}
+
+func TestAddTagsInOneTransaction(t *testing.T) {
+	db, fake := sqltest.Open(t, func(query string, args []driver.Value) sqltest.Result {
+		if strings.HasPrefix(query, "SELECT t.name") {
+			return column("name", "beta", "vip")
+		}
+		return oneUser(query, args)
+	})
+
+	if _, err := NewUserRepo(db).AddTags(context.Background(), 1, []string{"vip", "beta"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := len(fake.Ran("INSERT IGNORE INTO user_tags")); n != 2 {
+		t.Errorf("%d tags linked, want 2", n)
+	}
+	if len(fake.Ran("BEGIN")) != 1 || len(fake.Ran("COMMIT")) != 1 {
+		t.Error("tags weren't added in a transaction")
+	}
+}
+
+func TestFetchDefaultSort(t *testing.T) {
+	t.Cleanup(func() { defaultSort = "id" })
+
+	if err := SetDefaultSort("-created_at"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetDefaultSort("password"); err == nil {
+		t.Error("an unsortable default was accepted")
+	}
+
+	db, fake := sqltest.Open(t, nil)
+	if _, err := NewUserRepo(db).Fetch(context.Background(), &entities.UserFilter{Limit: 10}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewUserRepo(db).Fetch(context.Background(), &entities.UserFilter{Sort: "email", Limit: 10}); err != nil {
+		t.Fatal(err)
+	}
+
+	ran := fake.Ran("SELECT * FROM users")
+	if len(ran) != 2 || !strings.Contains(ran[0].Query, "ORDER BY created_at DESC, id DESC") {
+		t.Fatalf("ran %v, want the default order", ran)
+	}
+	if !strings.Contains(ran[1].Query, "ORDER BY email ASC, id ASC") {
+		t.Errorf("ran %q, want ?sort= to win over the default", ran[1].Query)
+	}
+}
@@ -0,0 +1,101 @@
+package breaker
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	closed = iota
+	open
+	halfOpen
+)
+
+// Breaker opens after threshold consecutive failures, rejects calls for
+// cooldown and then lets a single probe through to test recovery
+type Breaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	state     int
+	failures  int
+	openedAt  time.Time
+	probing   bool
+}
+
+func New(threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+	}
+}
+
+// report whether a call may go through
+func (b *Breaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case open:
+		if time.Since(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.state = halfOpen
+		b.probing = true
+		return true
+	case halfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	}
+
+	return true
+}
+
+// release a probe that ended without reporting success or failure
+func (b *Breaker) Release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+}
+
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.state = closed
+	b.failures = 0
+	b.probing = false
+}
+
+func (b *Breaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.probing = false
+
+	if b.state == halfOpen || b.failures >= b.threshold {
+		b.state = open
+		b.openedAt = time.Now()
+	}
+}
+
+// time left until the breaker lets a probe through, 0 when it's closed
+func (b *Breaker) RetryAfter() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state != open {
+		return 0
+	}
+
+	if left := b.cooldown - time.Since(b.openedAt); left > 0 {
+		return left
+	}
+
+	return 0
+}
@@ -0,0 +1,85 @@
+package breaker
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBreakerOpensAfterThreshold(t *testing.T) {
+	b := New(3, time.Hour)
+
+	for i := 0; i < 2; i++ {
+		b.Failure()
+		if !b.Allow() {
+			t.Fatalf("open after %d failures, threshold 3", i+1)
+		}
+	}
+
+	// a success in between resets the count
+	b.Success()
+	b.Failure()
+	b.Failure()
+	if !b.Allow() {
+		t.Fatal("failures before a success counted")
+	}
+
+	b.Failure()
+	if b.Allow() {
+		t.Fatal("still closed after 3 consecutive failures")
+	}
+	if d := b.RetryAfter(); d <= 0 || d > time.Hour {
+		t.Errorf("RetryAfter = %v, want up to the cooldown", d)
+	}
+}
+
+func TestBreakerProbesAfterCooldown(t *testing.T) {
+	b := New(1, 10*time.Millisecond)
+	b.Failure()
+	if b.Allow() {
+		t.Fatal("allowed during the cooldown")
+	}
+
+	time.Sleep(20 * time.Millisecond)
+
+	if !b.Allow() {
+		t.Fatal("no probe after the cooldown")
+	}
+	if b.Allow() {
+		t.Fatal("a second call went through while probing")
+	}
+
+	// a failed probe opens it again for a whole cooldown
+	b.Failure()
+	if b.Allow() {
+		t.Fatal("allowed right after a failed probe")
+	}
+
+	time.Sleep(20 * time.Millisecond)
+
+	if !b.Allow() {
+		t.Fatal("no probe after the second cooldown")
+	}
+	b.Success()
+	for i := 0; i < 3; i++ {
+		if !b.Allow() {
+			t.Fatal("not closed after a successful probe")
+		}
+	}
+	if d := b.RetryAfter(); d != 0 {
+		t.Errorf("closed RetryAfter = %v, want 0", d)
+	}
+}
+
+func TestBreakerReleaseFreesTheProbe(t *testing.T) {
+	b := New(1, time.Millisecond)
+	b.Failure()
+	time.Sleep(5 * time.Millisecond)
+
+	if !b.Allow() {
+		t.Fatal("no probe after the cooldown")
+	}
+	b.Release()
+	if !b.Allow() {
+		t.Error("a released probe kept blocking")
+	}
+}
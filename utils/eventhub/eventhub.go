@@ -0,0 +1,55 @@
+package eventhub
+
+import (
+	"sync"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+)
+
+// fans events out to in-process subscribers. slow subscribers miss events
+// instead of blocking the publisher
+type Hub struct {
+	mu     sync.Mutex
+	buffer int
+	subs   map[chan entities.Event]struct{}
+}
+
+// buffer is the number of events a subscriber may lag behind
+func New(buffer int) *Hub {
+	return &Hub{
+		buffer: buffer,
+		subs:   map[chan entities.Event]struct{}{},
+	}
+}
+
+// channel of future events, cancel must be called once done with it
+func (h *Hub) Subscribe() (<-chan entities.Event, func()) {
+	ch := make(chan entities.Event, h.buffer)
+
+	h.mu.Lock()
+	h.subs[ch] = struct{}{}
+	h.mu.Unlock()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			h.mu.Lock()
+			delete(h.subs, ch)
+			h.mu.Unlock()
+		})
+	}
+
+	return ch, cancel
+}
+
+func (h *Hub) Notify(e entities.Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for ch := range h.subs {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package eventhub
+
+import (
+	"testing"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+)
+
+func TestHubFansOut(t *testing.T) {
+	h := New(1)
+	a, cancelA := h.Subscribe()
+	b, cancelB := h.Subscribe()
+	defer cancelB()
+
+	h.Notify(entities.Event{Type: "one"})
+	for name, ch := range map[string]<-chan entities.Event{"a": a, "b": b} {
+		if e := <-ch; e.Type != "one" {
+			t.Errorf("%s got %q, want one", name, e.Type)
+		}
+	}
+
+	// a cancelled subscriber gets nothing more, cancelling twice is fine
+	cancelA()
+	cancelA()
+	h.Notify(entities.Event{Type: "two"})
+	select {
+	case e := <-a:
+		t.Errorf("cancelled subscriber got %q", e.Type)
+	default:
+	}
+	if e := <-b; e.Type != "two" {
+		t.Errorf("b got %q, want two", e.Type)
+	}
+}
+
+func TestSlowSubscriberMissesEvents(t *testing.T) {
+	h := New(1)
+	ch, cancel := h.Subscribe()
+	defer cancel()
+
+	// the second event doesn't fit the buffer and mustn't block
+	h.Notify(entities.Event{Type: "one"})
+	h.Notify(entities.Event{Type: "two"})
+
+	if e := <-ch; e.Type != "one" {
+		t.Errorf("got %q, want one", e.Type)
+	}
+	select {
+	case e := <-ch:
+		t.Errorf("got %q past the buffer", e.Type)
+	default:
+	}
+}
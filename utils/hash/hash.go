@@ -2,15 +2,19 @@ package hash
 
 import (
 	"fmt"
+	"sync"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
+// bcrypt cost used for new hashes
+var Cost = bcrypt.DefaultCost
+
 func HashPassword(p string) (string, error) {
 	if p == "" {
 		return "", fmt.Errorf("password is empty")
 	}
-	hash, err := bcrypt.GenerateFromPassword([]byte(p), bcrypt.DefaultCost)
+	hash, err := bcrypt.GenerateFromPassword([]byte(p), Cost)
 	if err != nil {
 		return "", err
 	}
@@ -18,6 +22,21 @@ func HashPassword(p string) (string, error) {
 
 }
 
+var (
+	dummyOnce sync.Once
+	dummyHash []byte
+)
+
+// spend as long as CheckPassword against a real hash, so a missing user
+// can't be told apart from a wrong password by timing
+func CheckDummy(p string) {
+	dummyOnce.Do(func() {
+		dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), Cost)
+	})
+
+	bcrypt.CompareHashAndPassword(dummyHash, []byte(p))
+}
+
 func CheckPassword(hash, p string) error {
 	if p == "" {
 		return fmt.Errorf("password is empty")
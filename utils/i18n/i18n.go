@@ -0,0 +1,94 @@
+package i18n
+
+import (
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+	"github.com/ariopri/Let-It-Be/tree/main/backend/utils/jsonschema"
+	"golang.org/x/text/language"
+)
+
+// locale used when the client asks for none we support
+var DefaultLocale = "en"
+
+// translations keyed by the english message, english needs no entries
+var catalog = map[string]map[string]string{
+	"en": {},
+	"id": {
+		entities.BadRequest:            "permintaan tidak valid",
+		entities.BodyRequired:          "body permintaan wajib diisi",
+		entities.InternalServer:        "terjadi kesalahan pada server",
+		entities.Unauthorized:          "tidak terautentikasi",
+		entities.Forbidden:             "akses ditolak",
+		entities.ItemNotFound:          "data tidak ditemukan",
+		entities.UserDisabled:          "akun pengguna dinonaktifkan",
+		entities.EmailNotVerified:      "email belum diverifikasi",
+		entities.LastAdmin:             "minimal harus ada satu admin",
+		entities.PreconditionFailed:    "pengguna telah diubah sejak waktu yang diberikan",
+		entities.LimitTooLarge:         "limit melebihi ukuran halaman maksimum",
+		entities.HeadersTooLarge:       "header permintaan terlalu besar",
+		entities.JSONTooComplex:        "isi permintaan terlalu dalam atau terlalu besar",
+		entities.PreferencesTooLarge:   "preferensi melebihi ukuran maksimum",
+		entities.WrongPassword:         "password saat ini salah",
+		entities.BodyTooLarge:          "isi permintaan terlalu besar",
+		entities.InvalidRole:           "peran tidak dikenal",
+		entities.HTTPSRequired:         "https wajib digunakan",
+		entities.UserReferenced:        "pengguna masih direferensikan, nonaktifkan akun sebagai gantinya",
+		entities.PasswordChangeTooSoon: "password baru saja diubah",
+		entities.PasswordWeak:          "password dapat diterima tetapi lemah",
+		entities.TooManyRequests:       "terlalu banyak permintaan",
+		entities.InvalidSort:           "kolom pengurutan tidak valid",
+		entities.ValidationFailed:      "validasi gagal",
+		entities.ServiceUnavailable:    "layanan sedang tidak tersedia",
+		entities.RequestTimeout:        "waktu permintaan habis",
+		entities.PasswordReused:        "password sudah pernah digunakan",
+		entities.PasswordBreached:      "password ditemukan dalam kebocoran data",
+		entities.TokenRevoked:          "token telah dicabut",
+		entities.InvalidSignedURL:      "tautan tidak valid atau kedaluwarsa",
+		entities.InvalidAuthScheme:     "header authorization harus Bearer <token>",
+		entities.DeleteVetoed:          "pengguna tidak dapat dihapus",
+		entities.NoDeletionRequest:     "tidak ada permintaan penghapusan",
+		entities.UnsupportedMediaType:  "content type harus application/json",
+		entities.MailUnavailable:       "pengiriman email belum dikonfigurasi",
+		entities.BatchNotApplied:       "tidak diterapkan, batch gagal",
+		entities.AlreadyExists:         "%s sudah digunakan",
+		entities.FieldError:            "kesalahan pada field %s, kondisi: %s",
+
+		jsonschema.MsgType:       "harus bertipe %s",
+		jsonschema.MsgEnum:       "harus salah satu nilai yang diizinkan",
+		jsonschema.MsgMinLength:  "minimal %d karakter",
+		jsonschema.MsgMaxLength:  "maksimal %d karakter",
+		jsonschema.MsgPattern:    "harus cocok dengan %s",
+		jsonschema.MsgMinimum:    "minimal %v",
+		jsonschema.MsgMaximum:    "maksimal %v",
+		jsonschema.MsgRequired:   "wajib diisi",
+		jsonschema.MsgDependent:  "wajib diisi jika %s diisi",
+		jsonschema.MsgNotAllowed: "tidak diizinkan",
+	},
+}
+
+// pick the supported language the client weighs highest in an
+// Accept-Language header. q=0 rules a language out, a malformed header
+// gets the default
+func Locale(acceptLanguage string) string {
+	tags, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil {
+		return DefaultLocale
+	}
+
+	for _, tag := range tags {
+		base, _ := tag.Base()
+		if _, ok := catalog[base.String()]; ok {
+			return base.String()
+		}
+	}
+
+	return DefaultLocale
+}
+
+// translate msg for the Accept-Language header, falls back to msg itself
+func Translate(acceptLanguage, msg string) string {
+	if t, ok := catalog[Locale(acceptLanguage)][msg]; ok {
+		return t
+	}
+
+	return msg
+}
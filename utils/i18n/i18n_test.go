@@ -0,0 +1,60 @@
+package i18n
+
+import (
+	"testing"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+)
+
+func TestLocale(t *testing.T) {
+	for header, want := range map[string]string{
+		"":                        DefaultLocale,
+		"id":                      "id",
+		"id-ID,en;q=0.8":          "id",
+		"fr-FR, id;q=0.5":         "id",
+		"en-US,id;q=0.9":          "en",
+		"de, fr":                  DefaultLocale,
+		" ID-id ; q=1.0 , en;q=0": "id",
+		// q=0 isn't acceptable
+		"id;q=0, en": "en",
+		"id;q=0":     DefaultLocale,
+		// the highest weight wins, not the first
+		"en;q=0.3, id;q=0.8":     "id",
+		"fr, en;q=0.5, id;q=0.7": "id",
+		"*":                      DefaultLocale,
+		"id;q=x":                 DefaultLocale,
+	} {
+		if got := Locale(header); got != want {
+			t.Errorf("Locale(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestTranslate(t *testing.T) {
+	if got := Translate("id", entities.Unauthorized); got != "tidak terautentikasi" {
+		t.Errorf("id: %q", got)
+	}
+	if got := Translate("en", entities.Unauthorized); got != entities.Unauthorized {
+		t.Errorf("en: %q, want the message itself", got)
+	}
+	if got := Translate("id", "no translation for this"); got != "no translation for this" {
+		t.Errorf("missing entry: %q, want the message itself", got)
+	}
+}
+
+// every locale translates the same messages, so none falls back to
+// english for a message another covers
+func TestCatalogsAreComplete(t *testing.T) {
+	for locale, msgs := range catalog {
+		if locale == "en" {
+			continue
+		}
+		for other, otherMsgs := range catalog {
+			for msg := range otherMsgs {
+				if _, ok := msgs[msg]; !ok {
+					t.Errorf("%s lacks %q translated in %s", locale, msg, other)
+				}
+			}
+		}
+	}
+}
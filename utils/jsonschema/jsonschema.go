@@ -0,0 +1,283 @@
+package jsonschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// the subset of JSON Schema request bodies need: type, required,
+// properties, additionalProperties, dependentRequired, items, enum,
+// pattern, minLength, maxLength, minimum and maximum
+type Schema struct {
+	Type                 string              `json:"type"`
+	Required             []string            `json:"required"`
+	Properties           map[string]*Schema  `json:"properties"`
+	AdditionalProperties *bool               `json:"additionalProperties"`
+	DependentRequired    map[string][]string `json:"dependentRequired"`
+	Items                *Schema             `json:"items"`
+	Enum                 []interface{}       `json:"enum"`
+	Pattern              string              `json:"pattern"`
+	MinLength            *int                `json:"minLength"`
+	MaxLength            *int                `json:"maxLength"`
+	Minimum              *float64            `json:"minimum"`
+	Maximum              *float64            `json:"maximum"`
+
+	pattern *regexp.Regexp
+}
+
+// violation messages, format strings so they can be translated, see
+// Error.Translate
+const (
+	MsgType       = "must be of type %s"
+	MsgEnum       = "must be one of the allowed values"
+	MsgMinLength  = "must be at least %d characters"
+	MsgMaxLength  = "must be at most %d characters"
+	MsgPattern    = "must match %s"
+	MsgMinimum    = "must be at least %v"
+	MsgMaximum    = "must be at most %v"
+	MsgRequired   = "is required"
+	MsgDependent  = "is required when %s is set"
+	MsgNotAllowed = "is not allowed"
+)
+
+// a violation, Pointer is the RFC 6901 path of the offending value
+type Error struct {
+	Pointer string `json:"pointer"`
+	Message string `json:"message"`
+
+	format string
+	args   []interface{}
+}
+
+func newError(ptr, format string, args ...interface{}) Error {
+	return Error{
+		Pointer: ptr,
+		Message: fmt.Sprintf(format, args...),
+		format:  format,
+		args:    args,
+	}
+}
+
+// the error with its message rebuilt from translate(format)
+func (e Error) Translate(translate func(string) string) Error {
+	if e.format != "" {
+		e.Message = fmt.Sprintf(translate(e.format), e.args...)
+	}
+
+	return e
+}
+
+func Compile(src []byte) (*Schema, error) {
+	var s Schema
+	if err := json.Unmarshal(src, &s); err != nil {
+		return nil, err
+	}
+	if err := s.compile(); err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+// like Compile, panics on invalid schemas. for package level vars
+func MustCompile(src string) *Schema {
+	s, err := Compile([]byte(src))
+	if err != nil {
+		panic(fmt.Sprintf("jsonschema: %v", err))
+	}
+
+	return s
+}
+
+func (s *Schema) compile() error {
+	if s.Pattern != "" {
+		re, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return err
+		}
+		s.pattern = re
+	}
+
+	for _, p := range s.Properties {
+		if err := p.compile(); err != nil {
+			return err
+		}
+	}
+	if s.Items != nil {
+		return s.Items.compile()
+	}
+
+	return nil
+}
+
+// validate a json document, the error is only set for malformed json
+func (s *Schema) ValidateJSON(data []byte) ([]Error, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	return s.Validate(doc), nil
+}
+
+// violations of doc, decoded with json.Number for numbers
+func (s *Schema) Validate(doc interface{}) []Error {
+	var errs []Error
+	s.validate(doc, "", &errs)
+
+	return errs
+}
+
+func (s *Schema) validate(v interface{}, ptr string, errs *[]Error) {
+	add := func(format string, args ...interface{}) {
+		*errs = append(*errs, newError(ptr, format, args...))
+	}
+
+	if s.Type != "" && !hasType(v, s.Type) {
+		add(MsgType, s.Type)
+		return
+	}
+
+	if len(s.Enum) > 0 && !inEnum(v, s.Enum) {
+		add(MsgEnum)
+	}
+
+	switch v := v.(type) {
+	case string:
+		n := utf8.RuneCountInString(v)
+		if s.MinLength != nil && n < *s.MinLength {
+			add(MsgMinLength, *s.MinLength)
+		}
+		if s.MaxLength != nil && n > *s.MaxLength {
+			add(MsgMaxLength, *s.MaxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(v) {
+			add(MsgPattern, s.Pattern)
+		}
+	case json.Number:
+		f, _ := v.Float64()
+		if s.Minimum != nil && f < *s.Minimum {
+			add(MsgMinimum, *s.Minimum)
+		}
+		if s.Maximum != nil && f > *s.Maximum {
+			add(MsgMaximum, *s.Maximum)
+		}
+	case []interface{}:
+		if s.Items != nil {
+			for i, item := range v {
+				s.Items.validate(item, fmt.Sprintf("%s/%d", ptr, i), errs)
+			}
+		}
+	case map[string]interface{}:
+		s.validateObject(v, ptr, errs)
+	}
+}
+
+func (s *Schema) validateObject(obj map[string]interface{}, ptr string, errs *[]Error) {
+	for _, name := range s.Required {
+		if _, ok := obj[name]; !ok {
+			*errs = append(*errs, newError(ptr+"/"+escape(name), MsgRequired))
+		}
+	}
+
+	// if the key is present, the listed ones must be too
+	keys := make([]string, 0, len(s.DependentRequired))
+	for k := range s.DependentRequired {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if _, ok := obj[key]; !ok {
+			continue
+		}
+		for _, name := range s.DependentRequired[key] {
+			if _, ok := obj[name]; !ok {
+				*errs = append(*errs, newError(ptr+"/"+escape(name), MsgDependent, key))
+			}
+		}
+	}
+
+	for _, name := range sortedKeys(obj) {
+		p := ptr + "/" + escape(name)
+		if prop, ok := s.Properties[name]; ok {
+			prop.validate(obj[name], p, errs)
+		} else if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+			*errs = append(*errs, newError(p, MsgNotAllowed))
+		}
+	}
+}
+
+func hasType(v interface{}, typ string) bool {
+	switch typ {
+	case "object":
+		_, ok := v.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := v.([]interface{})
+		return ok
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "null":
+		return v == nil
+	case "number":
+		_, ok := v.(json.Number)
+		return ok
+	case "integer":
+		n, ok := v.(json.Number)
+		if !ok {
+			return false
+		}
+		_, err := n.Int64()
+		return err == nil
+	}
+
+	return false
+}
+
+func inEnum(v interface{}, enum []interface{}) bool {
+	for _, e := range enum {
+		// enum values come without json.Number, compare numbers as text
+		if n, ok := v.(json.Number); ok {
+			if f, ok := e.(float64); ok {
+				if vf, err := n.Float64(); err == nil && vf == f {
+					return true
+				}
+			}
+			continue
+		}
+		if reflect.DeepEqual(v, e) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RFC 6901 escaping of a reference token
+func escape(s string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(s)
+}
+
+// errors come out in a stable order
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
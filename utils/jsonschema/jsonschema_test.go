@@ -0,0 +1,34 @@
+package jsonschema
+
+import "testing"
+
+func TestErrorTranslate(t *testing.T) {
+	s := MustCompile(`{
+		"type": "object",
+		"properties": {"name": {"type": "string", "minLength": 3}}
+	}`)
+
+	errs := s.Validate(map[string]interface{}{"name": "ab"})
+	if len(errs) != 1 || errs[0].Message != "must be at least 3 characters" {
+		t.Fatalf("errs = %+v, want one minLength violation", errs)
+	}
+
+	translated := errs[0].Translate(func(msg string) string {
+		if msg == MsgMinLength {
+			return "minimal %d karakter"
+		}
+		return msg
+	})
+	if translated.Message != "minimal 3 karakter" || translated.Pointer != "/name" {
+		t.Errorf("translated = %+v, want the message in the translated format", translated)
+	}
+	if errs[0].Message != "must be at least 3 characters" {
+		t.Errorf("translating changed the original: %+v", errs[0])
+	}
+
+	// errors built elsewhere keep their message
+	e := Error{Pointer: "/x", Message: "custom"}
+	if got := e.Translate(func(string) string { return "other" }); got.Message != "custom" {
+		t.Errorf("message = %q, want it kept", got.Message)
+	}
+}
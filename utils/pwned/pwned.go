@@ -0,0 +1,66 @@
+package pwned
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// the public Pwned Passwords range api
+const DefaultURL = "https://api.pwnedpasswords.com/range/"
+
+// checks passwords against a range api using k-anonymity: only the first
+// five hex chars of the sha1 leave the process
+type Checker struct {
+	url    string
+	client *http.Client
+}
+
+// rangeURL gets the 5 char prefix appended, e.g. DefaultURL or a mock
+func New(rangeURL string) *Checker {
+	return &Checker{
+		url:    rangeURL,
+		client: &http.Client{Timeout: time.Second * 5},
+	}
+}
+
+// report whether password appears in the breach corpus
+func (c *Checker) Pwned(ctx context.Context, password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:5], hash[5:]
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url+prefix, nil)
+	if err != nil {
+		return false, err
+	}
+	// padded responses don't leak the result through their size
+	req.Header.Set("Add-Padding", "true")
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("pwned: range api answered %d", res.StatusCode)
+	}
+
+	// lines look like SUFFIX:COUNT, padding entries have a count of 0
+	sc := bufio.NewScanner(res.Body)
+	for sc.Scan() {
+		s, count, ok := strings.Cut(strings.TrimSpace(sc.Text()), ":")
+		if ok && s == suffix && count != "0" {
+			return true, nil
+		}
+	}
+
+	return false, sc.Err()
+}
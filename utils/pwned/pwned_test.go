@@ -0,0 +1,83 @@
+package pwned
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// a range api that knows the given passwords, answering with padding too
+func mockRange(t *testing.T, breached ...string) *httptest.Server {
+	t.Helper()
+
+	counts := map[string]string{}
+	for _, p := range breached {
+		sum := sha1.Sum([]byte(p))
+		counts[strings.ToUpper(hex.EncodeToString(sum[:]))] = "42"
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		prefix := strings.TrimPrefix(r.URL.Path, "/range/")
+		if len(prefix) != 5 {
+			t.Errorf("range api got %q, want a 5 char prefix", r.URL.Path)
+		}
+		if r.Header.Get("Add-Padding") != "true" {
+			t.Error("the request didn't ask for padding")
+		}
+
+		for hash, count := range counts {
+			if strings.HasPrefix(hash, prefix) {
+				fmt.Fprintf(w, "%s:%s\r\n", hash[5:], count)
+			}
+		}
+		fmt.Fprint(w, "0000000000000000000000000000000000A:0\r\n")
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestPwned(t *testing.T) {
+	c := New(mockRange(t, "password123").URL + "/range/")
+
+	for password, want := range map[string]bool{
+		"password123":          true,
+		"correct horse staple": false,
+	} {
+		got, err := c.Pwned(context.Background(), password)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("Pwned(%q) = %v, want %v", password, got, want)
+		}
+	}
+}
+
+func TestPwnedIgnoresPadding(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sum := sha1.Sum([]byte("padded"))
+		fmt.Fprintf(w, "%s:0\r\n", strings.ToUpper(hex.EncodeToString(sum[:]))[5:])
+	}))
+	t.Cleanup(srv.Close)
+
+	if got, err := New(srv.URL+"/").Pwned(context.Background(), "padded"); err != nil || got {
+		t.Errorf("Pwned = %v, %v, want a padding entry to not count", got, err)
+	}
+}
+
+func TestPwnedAPIError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(srv.Close)
+
+	if _, err := New(srv.URL+"/").Pwned(context.Background(), "anything"); err == nil {
+		t.Error("a failing range api wasn't reported")
+	}
+}
@@ -0,0 +1,62 @@
+package signedurl
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrExpired   = errors.New("signed url expired")
+	ErrSignature = errors.New("signed url signature mismatch")
+)
+
+// return path with q, an expiry and an hmac over all of them
+func Sign(secret []byte, path string, q url.Values, expires time.Time) string {
+	signed := url.Values{}
+	for k, v := range q {
+		signed[k] = v
+	}
+	signed.Set("expires", strconv.FormatInt(expires.Unix(), 10))
+	signed.Set("signature", signature(secret, path, signed))
+
+	return path + "?" + signed.Encode()
+}
+
+// check the signature and expiry Sign added to the query
+func Verify(secret []byte, path string, q url.Values, now time.Time) error {
+	expires, err := strconv.ParseInt(q.Get("expires"), 10, 64)
+	if err != nil {
+		return ErrSignature
+	}
+
+	want := signature(secret, path, q)
+	if !hmac.Equal([]byte(q.Get("signature")), []byte(want)) {
+		return ErrSignature
+	}
+
+	if now.Unix() > expires {
+		return ErrExpired
+	}
+
+	return nil
+}
+
+// hex hmac of path and the query without its signature, Encode sorts keys
+func signature(secret []byte, path string, q url.Values) string {
+	unsigned := url.Values{}
+	for k, v := range q {
+		if k != "signature" {
+			unsigned[k] = v
+		}
+	}
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(path + "?" + unsigned.Encode()))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
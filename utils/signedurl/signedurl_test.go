@@ -0,0 +1,71 @@
+package signedurl
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+var secret = []byte("test-secret")
+
+// the query of a url Sign returned
+func query(t *testing.T, link string) url.Values {
+	t.Helper()
+
+	u, err := url.Parse(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return u.Query()
+}
+
+func TestSignAndVerify(t *testing.T) {
+	now := time.Now()
+	link := Sign(secret, "/exports/users", url.Values{"format": {"csv"}}, now.Add(time.Minute))
+	if !strings.HasPrefix(link, "/exports/users?") {
+		t.Fatalf("link = %q, want it on the path", link)
+	}
+	q := query(t, link)
+
+	if err := Verify(secret, "/exports/users", q, now); err != nil {
+		t.Errorf("valid url: err = %v", err)
+	}
+	if err := Verify(secret, "/exports/users", q, now.Add(2*time.Minute)); err != ErrExpired {
+		t.Errorf("expired url: err = %v, want %v", err, ErrExpired)
+	}
+}
+
+func TestVerifyRejectsTampering(t *testing.T) {
+	now := time.Now()
+	q := query(t, Sign(secret, "/exports/users", url.Values{"format": {"csv"}}, now.Add(time.Minute)))
+
+	for name, check := range map[string]func() error{
+		"other secret": func() error { return Verify([]byte("other"), "/exports/users", q, now) },
+		"other path":   func() error { return Verify(secret, "/exports/audit", q, now) },
+		"changed param": func() error {
+			changed := url.Values{}
+			for k, v := range q {
+				changed[k] = v
+			}
+			changed.Set("format", "jsonl")
+			return Verify(secret, "/exports/users", changed, now)
+		},
+		"extended expiry": func() error {
+			changed := url.Values{}
+			for k, v := range q {
+				changed[k] = v
+			}
+			changed.Set("expires", "99999999999")
+			return Verify(secret, "/exports/users", changed, now)
+		},
+		"no expiry": func() error {
+			return Verify(secret, "/exports/users", url.Values{"signature": q["signature"]}, now)
+		},
+	} {
+		if err := check(); err != ErrSignature {
+			t.Errorf("%s: err = %v, want %v", name, err, ErrSignature)
+		}
+	}
+}
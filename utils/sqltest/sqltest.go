@@ -0,0 +1,210 @@
+// scripted database/sql driver for tests. every statement is recorded
+// and answered by the test's answer func
+package sqltest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type DB struct {
+	mu     sync.Mutex
+	answer func(query string, args []driver.Value) Result
+	stmts  []Stmt
+}
+
+// a statement the database was sent, BEGIN, COMMIT and ROLLBACK included
+type Stmt struct {
+	Query string
+	Args  []driver.Value
+	// the context the statement ran with, nil for BEGIN, COMMIT and ROLLBACK
+	Ctx context.Context
+}
+
+// Columns and Rows answer queries, LastID and Affected execs
+type Result struct {
+	Columns  []string
+	Rows     [][]driver.Value
+	LastID   int64
+	Affected int64
+	Err      error
+}
+
+// a single row of columns
+func Row(columns []string, values ...driver.Value) Result {
+	return Result{Columns: columns, Rows: [][]driver.Value{values}}
+}
+
+// a handle answered by answer, a nil answer answers every statement with
+// an empty Result. closed when the test ends
+func Open(t *testing.T, answer func(query string, args []driver.Value) Result) (*sql.DB, *DB) {
+	t.Helper()
+
+	f := &DB{answer: answer}
+	db := sql.OpenDB(f)
+	t.Cleanup(func() { db.Close() })
+
+	return db, f
+}
+
+// recorded statements containing substr, in the order they ran
+func (f *DB) Ran(substr string) []Stmt {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var found []Stmt
+	for _, s := range f.stmts {
+		if strings.Contains(s.Query, substr) {
+			found = append(found, s)
+		}
+	}
+
+	return found
+}
+
+// driver.Connector
+func (f *DB) Connect(ctx context.Context) (driver.Conn, error) {
+	return &conn{db: f}, nil
+}
+
+func (f *DB) Driver() driver.Driver {
+	return nil
+}
+
+func (f *DB) run(ctx context.Context, query string, args []driver.Value) Result {
+	f.mu.Lock()
+	f.stmts = append(f.stmts, Stmt{Query: query, Args: args, Ctx: ctx})
+	f.mu.Unlock()
+
+	if f.answer == nil {
+		return Result{}
+	}
+
+	return f.answer(query, args)
+}
+
+type conn struct {
+	db *DB
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return &stmt{db: c.db, query: query}, nil
+}
+
+// driver.QueryerContext, so statements keep their context
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return answerQuery(c.db.run(ctx, query, values(args)))
+}
+
+// driver.ExecerContext
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return answerExec(c.db.run(ctx, query, values(args)))
+}
+
+func values(args []driver.NamedValue) []driver.Value {
+	vals := make([]driver.Value, len(args))
+	for i, a := range args {
+		vals[i] = a.Value
+	}
+
+	return vals
+}
+
+func (c *conn) Close() error {
+	return nil
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	c.db.run(nil, "BEGIN", nil)
+	return tx{db: c.db}, nil
+}
+
+type tx struct {
+	db *DB
+}
+
+func (t tx) Commit() error {
+	t.db.run(nil, "COMMIT", nil)
+	return nil
+}
+
+func (t tx) Rollback() error {
+	t.db.run(nil, "ROLLBACK", nil)
+	return nil
+}
+
+type stmt struct {
+	db    *DB
+	query string
+}
+
+func (s *stmt) Close() error {
+	return nil
+}
+
+func (s *stmt) NumInput() int {
+	return -1
+}
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return answerExec(s.db.run(context.Background(), s.query, args))
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return answerQuery(s.db.run(context.Background(), s.query, args))
+}
+
+func answerExec(res Result) (driver.Result, error) {
+	if res.Err != nil {
+		return nil, res.Err
+	}
+
+	return execResult(res), nil
+}
+
+func answerQuery(res Result) (driver.Rows, error) {
+	if res.Err != nil {
+		return nil, res.Err
+	}
+
+	return &rows{columns: res.Columns, rows: res.Rows}, nil
+}
+
+type execResult Result
+
+func (r execResult) LastInsertId() (int64, error) {
+	return r.LastID, nil
+}
+
+func (r execResult) RowsAffected() (int64, error) {
+	return r.Affected, nil
+}
+
+type rows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *rows) Columns() []string {
+	return r.columns
+}
+
+func (r *rows) Close() error {
+	return nil
+}
+
+func (r *rows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+
+	return nil
+}
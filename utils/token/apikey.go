@@ -0,0 +1,31 @@
+package token
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// header api keys are sent in
+const APIKeyHeader = "X-API-Key"
+
+// prefix of api keys, makes leaked ones easy to spot
+const apiKeyPrefix = "uk_"
+
+// a random api key and the hash to store, the key itself is only shown once
+func NewAPIKey() (key, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+
+	key = apiKeyPrefix + hex.EncodeToString(b)
+
+	return key, HashAPIKey(key), nil
+}
+
+// keys are random enough that a plain sha256 can't be brute forced
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,27 @@
+package token
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewAPIKey(t *testing.T) {
+	key, hash, err := NewAPIKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(key, apiKeyPrefix) || len(key) != len(apiKeyPrefix)+64 {
+		t.Errorf("key = %q, want %s and 64 hex digits", key, apiKeyPrefix)
+	}
+	if hash != HashAPIKey(key) || hash == key || strings.Contains(hash, key) {
+		t.Errorf("hash = %q, want the hash of %q", hash, key)
+	}
+
+	other, otherHash, err := NewAPIKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == key || otherHash == hash {
+		t.Error("two keys came out the same")
+	}
+}
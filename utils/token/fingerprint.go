@@ -0,0 +1,28 @@
+package token
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+)
+
+// issue tokens bound to the client's fingerprint and reject them from other
+// clients. off by default, a user switching networks has to log in again
+var BindFingerprint = false
+
+// hash of the user agent and the client's /24 (ipv4) or /48 (ipv6), so
+// moving within a network keeps the token working
+func Fingerprint(userAgent, ip string) string {
+	subnet := ip
+	if parsed := net.ParseIP(ip); parsed != nil {
+		if v4 := parsed.To4(); v4 != nil {
+			subnet = v4.Mask(net.CIDRMask(24, 32)).String()
+		} else {
+			subnet = parsed.Mask(net.CIDRMask(48, 128)).String()
+		}
+	}
+
+	sum := sha256.Sum256([]byte(userAgent + "|" + subnet))
+
+	return hex.EncodeToString(sum[:16])
+}
@@ -0,0 +1,28 @@
+package token
+
+import "testing"
+
+func TestFingerprint(t *testing.T) {
+	base := Fingerprint("Firefox", "192.0.2.10")
+
+	for _, tc := range []struct {
+		userAgent, ip string
+		same          bool
+	}{
+		{"Firefox", "192.0.2.10", true},
+		{"Firefox", "192.0.2.200", true},
+		{"Firefox", "192.0.3.10", false},
+		{"Chrome", "192.0.2.10", false},
+	} {
+		if got := Fingerprint(tc.userAgent, tc.ip) == base; got != tc.same {
+			t.Errorf("%s from %s: same fingerprint %v, want %v", tc.userAgent, tc.ip, got, tc.same)
+		}
+	}
+
+	if Fingerprint("Firefox", "2001:db8:1:2::1") != Fingerprint("Firefox", "2001:db8:1:ffff::9") {
+		t.Error("ipv6 addresses in one /48 got different fingerprints")
+	}
+	if Fingerprint("Firefox", "2001:db8:1::1") == Fingerprint("Firefox", "2001:db8:2::1") {
+		t.Error("ipv6 addresses in different /48s got the same fingerprint")
+	}
+}
@@ -0,0 +1,196 @@
+package token
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// how long a rotated out key still verifies tokens, keep it at least as
+// long as TokenTTL so no token signed before a rotation is cut short
+var RotationGrace = time.Hour * 12
+
+type graceKey struct {
+	secret    []byte
+	expiresAt time.Time
+}
+
+var (
+	keysMu    sync.RWMutex
+	graceKeys = map[string]graceKey{}
+	// JwtToken as configured, the key before the first stored one
+	baseKey []byte
+	// when Keys was last read, reloads on unknown key ids wait KeySyncInterval
+	loadedAt time.Time
+)
+
+// a signing key and when it became the current one
+type Key struct {
+	Secret    []byte
+	CreatedAt time.Time
+}
+
+// persists rotated signing keys. share one store between instances so a
+// rotation reaches all of them
+type KeyStore interface {
+	AddKey(k Key) error
+	// every key, oldest first
+	Keys() ([]Key, error)
+}
+
+// where RotateKey puts new keys, nil keeps them in this instance's memory
+var Keys KeyStore
+
+// how often the keys are read from Keys again
+var KeySyncInterval = time.Minute
+
+// key id put in the token header, derived from the secret
+func keyID(secret []byte) string {
+	sum := sha256.Sum256(secret)
+
+	return hex.EncodeToString(sum[:4])
+}
+
+// current signing key and its id
+func currentKey() ([]byte, string) {
+	keysMu.RLock()
+	defer keysMu.RUnlock()
+
+	return JwtToken, keyID(JwtToken)
+}
+
+// key for verifying a token signed with kid, empty kid means current key.
+// an unknown kid may come from a rotation on another instance, the keys
+// are read again unless that happened recently
+func verificationKey(kid string) ([]byte, error) {
+	secret, ok := knownKey(kid)
+	if ok {
+		return secret, nil
+	}
+
+	keysMu.RLock()
+	stale := Keys != nil && time.Since(loadedAt) > KeySyncInterval
+	keysMu.RUnlock()
+
+	if stale {
+		if err := LoadKeys(); err != nil {
+			return nil, err
+		}
+		if secret, ok := knownKey(kid); ok {
+			return secret, nil
+		}
+	}
+
+	return nil, errors.New("unknown signing key")
+}
+
+func knownKey(kid string) ([]byte, bool) {
+	keysMu.RLock()
+	defer keysMu.RUnlock()
+
+	if kid == "" || kid == keyID(JwtToken) {
+		return JwtToken, true
+	}
+
+	k, ok := graceKeys[kid]
+	if !ok || time.Now().After(k.expiresAt) {
+		return nil, false
+	}
+
+	return k.secret, true
+}
+
+// take the keys from Keys, the newest becomes current. each older one
+// verifies tokens until RotationGrace after the key that replaced it.
+// JwtToken as configured counts as the key before the first stored one
+func LoadKeys() error {
+	if Keys == nil {
+		return nil
+	}
+
+	keys, err := Keys.Keys()
+	if err != nil {
+		return err
+	}
+
+	keysMu.Lock()
+	defer keysMu.Unlock()
+
+	if baseKey == nil {
+		baseKey = JwtToken
+	}
+	keys = append([]Key{{Secret: baseKey}}, keys...)
+
+	now := time.Now()
+	grace := map[string]graceKey{}
+	for i, k := range keys[:len(keys)-1] {
+		expiresAt := keys[i+1].CreatedAt.Add(RotationGrace)
+		if now.Before(expiresAt) {
+			grace[keyID(k.Secret)] = graceKey{secret: k.Secret, expiresAt: expiresAt}
+		}
+	}
+
+	graceKeys = grace
+	JwtToken = keys[len(keys)-1].Secret
+	loadedAt = now
+
+	return nil
+}
+
+// LoadKeys every KeySyncInterval so rotations on other instances are
+// picked up for signing too, blocks until ctx is done
+func SyncKeys(ctx context.Context) {
+	ticker := time.NewTicker(KeySyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := LoadKeys(); err != nil {
+				log.Printf("signing keys: %v", err)
+			}
+		}
+	}
+}
+
+// generate a new signing key and make it current, the previous key keeps
+// verifying tokens for RotationGrace. returns the new key id
+func RotateKey() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+
+	if Keys != nil {
+		if err := Keys.AddKey(Key{Secret: secret, CreatedAt: time.Now()}); err != nil {
+			return "", err
+		}
+
+		return keyID(secret), LoadKeys()
+	}
+
+	keysMu.Lock()
+	defer keysMu.Unlock()
+
+	now := time.Now()
+	for kid, k := range graceKeys {
+		if now.After(k.expiresAt) {
+			delete(graceKeys, kid)
+		}
+	}
+
+	graceKeys[keyID(JwtToken)] = graceKey{
+		secret:    JwtToken,
+		expiresAt: now.Add(RotationGrace),
+	}
+	JwtToken = secret
+
+	return keyID(secret), nil
+}
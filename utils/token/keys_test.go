@@ -0,0 +1,128 @@
+package token
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// KeyStore shared by the "instances" of a test
+type memoryKeys struct {
+	mu   sync.Mutex
+	keys []Key
+}
+
+func (m *memoryKeys) AddKey(k Key) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.keys = append(m.keys, k)
+
+	return nil
+}
+
+func (m *memoryKeys) Keys() ([]Key, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]Key{}, m.keys...), nil
+}
+
+// start from the configured key with store as Keys, as main does
+func useKeyStore(t *testing.T, store KeyStore) {
+	t.Helper()
+
+	oldToken, oldStore := JwtToken, Keys
+	t.Cleanup(func() {
+		JwtToken, Keys = oldToken, oldStore
+		baseKey, graceKeys, loadedAt = nil, map[string]graceKey{}, time.Time{}
+	})
+
+	JwtToken, Keys = []byte("configured"), store
+	baseKey, graceKeys, loadedAt = nil, map[string]graceKey{}, time.Time{}
+
+	if err := LoadKeys(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// a token signed the way another instance with secret as current key would
+func signedWith(t *testing.T, secret []byte) string {
+	t.Helper()
+
+	claims := &Claims{Email: "user@example.com", Role: "user"}
+	claims.ExpiresAt = time.Now().Add(time.Hour).Unix()
+
+	tok := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	tok.Header["kid"] = keyID(secret)
+	s, err := tok.SignedString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s
+}
+
+func TestRotatedKeyIsStored(t *testing.T) {
+	store := &memoryKeys{}
+	useKeyStore(t, store)
+
+	old := signedWith(t, []byte("configured"))
+
+	kid, err := RotateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(store.keys) != 1 || keyID(store.keys[0].Secret) != kid {
+		t.Fatalf("stored keys %+v, want the rotated %s", store.keys, kid)
+	}
+	if _, current := currentKey(); current != kid {
+		t.Errorf("current key = %s, want %s", current, kid)
+	}
+	if _, err := ValidateToken(old); err != nil {
+		t.Errorf("token of the configured key during the grace period: %v", err)
+	}
+}
+
+func TestKeyRotatedElsewhereVerifies(t *testing.T) {
+	store := &memoryKeys{}
+	useKeyStore(t, store)
+
+	// another instance rotated since the keys were loaded
+	secret := []byte("rotated elsewhere")
+	store.AddKey(Key{Secret: secret, CreatedAt: time.Now()})
+	loadedAt = time.Now().Add(-KeySyncInterval - time.Second)
+
+	if _, err := ValidateToken(signedWith(t, secret)); err != nil {
+		t.Fatalf("token of the other instance's key: %v", err)
+	}
+	if _, current := currentKey(); current != keyID(secret) {
+		t.Errorf("still signing with %s after the reload", current)
+	}
+}
+
+func TestKeysPastGraceStopVerifying(t *testing.T) {
+	store := &memoryKeys{}
+	store.AddKey(Key{Secret: []byte("first"), CreatedAt: time.Now().Add(-RotationGrace * 3)})
+	store.AddKey(Key{Secret: []byte("second"), CreatedAt: time.Now().Add(-RotationGrace * 2)})
+	store.AddKey(Key{Secret: []byte("third"), CreatedAt: time.Now().Add(-time.Minute)})
+	useKeyStore(t, store)
+
+	for secret, valid := range map[string]bool{
+		"configured": false,
+		"first":      false,
+		"second":     true,
+		"third":      true,
+	} {
+		_, err := ValidateToken(signedWith(t, []byte(secret)))
+		if valid && err != nil {
+			t.Errorf("%s: %v", secret, err)
+		}
+		if !valid && err == nil {
+			t.Errorf("%s verifies past its grace period", secret)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package token
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// the subset of a redis client RedisStore needs, e.g. a thin wrapper
+// around go-redis' Set, Exists, ZAdd, ZRange, ZRem and Expire
+type RedisClient interface {
+	Set(ctx context.Context, key, value string, ttl time.Duration) error
+	Exists(ctx context.Context, key string) (bool, error)
+	ZAdd(ctx context.Context, key string, score float64, member string) error
+	// members from start to stop by ascending score, -1 is the last
+	ZRange(ctx context.Context, key string, start, stop int64) ([]string, error)
+	ZRem(ctx context.Context, key string, member string) error
+	Expire(ctx context.Context, key string, ttl time.Duration) error
+}
+
+// store shared between instances, entries expire with their tokens. it
+// keeps revocations and, in a sorted set per user, sessions
+type RedisStore struct {
+	client RedisClient
+	prefix string
+}
+
+func NewRedisStore(client RedisClient, prefix string) *RedisStore {
+	return &RedisStore{client: client, prefix: prefix}
+}
+
+func (s *RedisStore) Revoke(id string, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+
+	return s.client.Set(context.Background(), s.prefix+id, "1", ttl)
+}
+
+func (s *RedisStore) IsRevoked(id string) (bool, error) {
+	return s.client.Exists(context.Background(), s.prefix+id)
+}
+
+// redis expires the keys by itself, expired sessions are dropped by Active
+func (s *RedisStore) GC() error {
+	return nil
+}
+
+func (s *RedisStore) sessionsKey(user string) string {
+	return s.prefix + "sessions:" + user
+}
+
+// scored by when they're added, so ZRange lists them oldest first. the set
+// lives as long as its longest session
+func (s *RedisStore) Add(user string, session Session) error {
+	ctx := context.Background()
+
+	member, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	key := s.sessionsKey(user)
+	if err := s.client.ZAdd(ctx, key, float64(time.Now().UnixMicro()), string(member)); err != nil {
+		return err
+	}
+
+	sessions, _, err := s.sessions(ctx, user)
+	if err != nil {
+		return err
+	}
+	last := session.ExpiresAt
+	for _, other := range sessions {
+		if other.ExpiresAt.After(last) {
+			last = other.ExpiresAt
+		}
+	}
+
+	return s.client.Expire(ctx, key, time.Until(last))
+}
+
+func (s *RedisStore) Active(user string) ([]Session, error) {
+	ctx := context.Background()
+
+	sessions, members, err := s.sessions(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	live := []Session{}
+	for i, session := range sessions {
+		if now.Before(session.ExpiresAt) {
+			live = append(live, session)
+			continue
+		}
+		if err := s.client.ZRem(ctx, s.sessionsKey(user), members[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	return live, nil
+}
+
+func (s *RedisStore) Remove(user, id string) error {
+	ctx := context.Background()
+
+	sessions, members, err := s.sessions(ctx, user)
+	if err != nil {
+		return err
+	}
+
+	for i, session := range sessions {
+		if session.ID == id {
+			return s.client.ZRem(ctx, s.sessionsKey(user), members[i])
+		}
+	}
+
+	return nil
+}
+
+// all sessions of user, oldest first, and the members they're stored as
+func (s *RedisStore) sessions(ctx context.Context, user string) ([]Session, []string, error) {
+	members, err := s.client.ZRange(ctx, s.sessionsKey(user), 0, -1)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sessions := make([]Session, 0, len(members))
+	for _, member := range members {
+		var session Session
+		if err := json.Unmarshal([]byte(member), &session); err != nil {
+			return nil, nil, err
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, members, nil
+}
@@ -0,0 +1,235 @@
+package token
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+var ErrRevoked = errors.New("token has been revoked")
+
+// keeps revoked token ids until the tokens would have expired anyway.
+// share one store between instances so a logout counts everywhere
+type TokenStore interface {
+	Revoke(id string, expiresAt time.Time) error
+	IsRevoked(id string) (bool, error)
+	// drop entries of tokens that expired
+	GC() error
+}
+
+// tracks the live tokens of each user so their number can be capped
+type SessionStore interface {
+	Add(user string, s Session) error
+	// live sessions, oldest first
+	Active(user string) ([]Session, error)
+	Remove(user, id string) error
+}
+
+type Session struct {
+	ID        string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
+
+var memory = NewMemoryStore()
+
+// consulted by ValidateToken, nil disables revocation
+var Store TokenStore = memory
+
+// fed by every issued token, nil disables session tracking
+var Sessions SessionStore = memory
+
+// live tokens per user, the oldest are revoked past it. 0 is unlimited
+var MaxSessions = 0
+
+// revoke the token the claims came from
+func Revoke(claims *Claims) error {
+	if claims.Id == "" {
+		return nil
+	}
+
+	if Sessions != nil {
+		if err := Sessions.Remove(claims.Email, claims.Id); err != nil {
+			return err
+		}
+	}
+	if Store == nil {
+		return nil
+	}
+
+	return Store.Revoke(claims.Id, time.Unix(claims.ExpiresAt, 0))
+}
+
+// record a new token and revoke the user's oldest ones past MaxSessions
+func trackSession(claims *Claims) error {
+	if Sessions == nil {
+		return nil
+	}
+
+	err := Sessions.Add(claims.Email, Session{
+		ID:        claims.Id,
+		IssuedAt:  time.Unix(claims.IssuedAt, 0),
+		ExpiresAt: time.Unix(claims.ExpiresAt, 0),
+	})
+	if err != nil || MaxSessions <= 0 {
+		return err
+	}
+
+	active, err := Sessions.Active(claims.Email)
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < len(active)-MaxSessions; i++ {
+		old := active[i]
+		if err := Sessions.Remove(claims.Email, old.ID); err != nil {
+			return err
+		}
+		if Store != nil {
+			if err := Store.Revoke(old.ID, old.ExpiresAt); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// run Store.GC every interval, blocks until ctx is done
+func CollectGarbage(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if Store != nil {
+				if err := Store.GC(); err != nil {
+					log.Printf("token store: %v", err)
+				}
+			}
+
+			// a session store of its own is swept too
+			gc, ok := Sessions.(interface{ GC() error })
+			if ok && interface{}(Sessions) != interface{}(Store) {
+				if err := gc.GC(); err != nil {
+					log.Printf("session store: %v", err)
+				}
+			}
+		}
+	}
+}
+
+func newTokenID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// single instance store, keeps revocations and sessions
+type MemoryStore struct {
+	mu       sync.Mutex
+	revoked  map[string]time.Time
+	sessions map[string][]Session
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		revoked:  map[string]time.Time{},
+		sessions: map[string][]Session{},
+	}
+}
+
+func (s *MemoryStore) Revoke(id string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.revoked[id] = expiresAt
+
+	return nil
+}
+
+func (s *MemoryStore) IsRevoked(id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.revoked[id]
+
+	return ok, nil
+}
+
+func (s *MemoryStore) GC() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for id, exp := range s.revoked {
+		if now.After(exp) {
+			delete(s.revoked, id)
+		}
+	}
+
+	for user := range s.sessions {
+		s.sessions[user] = liveSessions(s.sessions[user], now)
+		if len(s.sessions[user]) == 0 {
+			delete(s.sessions, user)
+		}
+	}
+
+	return nil
+}
+
+// sessions are appended as issued, so the slice stays oldest first
+func (s *MemoryStore) Add(user string, session Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sessions[user] = append(s.sessions[user], session)
+
+	return nil
+}
+
+func (s *MemoryStore) Active(user string) ([]Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	live := liveSessions(s.sessions[user], time.Now())
+	s.sessions[user] = live
+
+	return append([]Session{}, live...), nil
+}
+
+func (s *MemoryStore) Remove(user, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kept := s.sessions[user][:0]
+	for _, session := range s.sessions[user] {
+		if session.ID != id {
+			kept = append(kept, session)
+		}
+	}
+	s.sessions[user] = kept
+
+	return nil
+}
+
+func liveSessions(sessions []Session, now time.Time) []Session {
+	live := sessions[:0]
+	for _, session := range sessions {
+		if now.Before(session.ExpiresAt) {
+			live = append(live, session)
+		}
+	}
+
+	return live
+}
@@ -0,0 +1,375 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreRevokes(t *testing.T) {
+	s := NewMemoryStore()
+
+	s.Revoke("live", time.Now().Add(time.Hour))
+	s.Revoke("expired", time.Now().Add(-time.Second))
+
+	for id, want := range map[string]bool{"live": true, "expired": true, "other": false} {
+		if got, _ := s.IsRevoked(id); got != want {
+			t.Errorf("IsRevoked(%q) = %v, want %v", id, got, want)
+		}
+	}
+
+	// the expired token can't validate anymore, its entry may go
+	if err := s.GC(); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := s.IsRevoked("expired"); got {
+		t.Error("GC kept an expired entry")
+	}
+	if got, _ := s.IsRevoked("live"); !got {
+		t.Error("GC dropped a live entry")
+	}
+}
+
+// records calls, the way a shared store would be seen by every instance
+type mockStore struct {
+	revoked map[string]time.Time
+	err     error
+}
+
+func (m *mockStore) Revoke(id string, expiresAt time.Time) error {
+	m.revoked[id] = expiresAt
+	return m.err
+}
+
+func (m *mockStore) IsRevoked(id string) (bool, error) {
+	_, ok := m.revoked[id]
+	return ok, m.err
+}
+
+func (m *mockStore) GC() error { return m.err }
+
+func useStore(t *testing.T, s TokenStore) {
+	t.Helper()
+
+	old := Store
+	Store = s
+	t.Cleanup(func() { Store = old })
+}
+
+func TestValidateTokenConsultsTheStore(t *testing.T) {
+	store := &mockStore{revoked: map[string]time.Time{}}
+	useStore(t, store)
+
+	tokenStr, expires, err := CreateToken(2, "a@example.com", "user", 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := ValidateToken(tokenStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Revoke(claims); err != nil {
+		t.Fatal(err)
+	}
+	if exp := store.revoked[claims.Id]; !exp.Equal(expires.Truncate(time.Second)) {
+		t.Errorf("revoked until %v, want the token's expiry %v", exp, expires)
+	}
+	if _, err := ValidateToken(tokenStr); !errors.Is(err, ErrRevoked) {
+		t.Errorf("err = %v, want ErrRevoked", err)
+	}
+
+	// a store that can't answer doesn't let tokens through
+	store.revoked = map[string]time.Time{}
+	store.err = errors.New("store down")
+	if _, err := ValidateToken(tokenStr); err == nil {
+		t.Error("token validated while the store failed")
+	}
+}
+
+type fakeRedis struct {
+	mu sync.Mutex
+	// ttl by key, sorted sets included once they have one
+	keys  map[string]time.Duration
+	zsets map[string]map[string]float64
+}
+
+func newFakeRedis() *fakeRedis {
+	return &fakeRedis{keys: map[string]time.Duration{}, zsets: map[string]map[string]float64{}}
+}
+
+func (f *fakeRedis) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.keys[key] = ttl
+	return nil
+}
+
+func (f *fakeRedis) Exists(ctx context.Context, key string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	_, ok := f.keys[key]
+	return ok, nil
+}
+
+func (f *fakeRedis) ZAdd(ctx context.Context, key string, score float64, member string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.zsets[key] == nil {
+		f.zsets[key] = map[string]float64{}
+	}
+	f.zsets[key][member] = score
+	return nil
+}
+
+func (f *fakeRedis) ZRange(ctx context.Context, key string, start, stop int64) ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if start != 0 || stop != -1 {
+		return nil, errors.New("fakeRedis only ranges over whole sets")
+	}
+
+	var members []string
+	for m := range f.zsets[key] {
+		members = append(members, m)
+	}
+	sort.Slice(members, func(i, j int) bool {
+		a, b := f.zsets[key][members[i]], f.zsets[key][members[j]]
+		return a < b || a == b && members[i] < members[j]
+	})
+
+	return members, nil
+}
+
+func (f *fakeRedis) ZRem(ctx context.Context, key string, member string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	delete(f.zsets[key], member)
+	return nil
+}
+
+func (f *fakeRedis) Expire(ctx context.Context, key string, ttl time.Duration) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.keys[key] = ttl
+	return nil
+}
+
+func TestRedisStoreExpiresWithTheToken(t *testing.T) {
+	client := newFakeRedis()
+	s := NewRedisStore(client, "revoked:")
+
+	s.Revoke("abc", time.Now().Add(time.Hour))
+	s.Revoke("old", time.Now().Add(-time.Minute))
+
+	if ttl, ok := client.keys["revoked:abc"]; !ok || ttl <= 59*time.Minute || ttl > time.Hour {
+		t.Errorf("keys = %v, want revoked:abc for about an hour", client.keys)
+	}
+	if _, ok := client.keys["revoked:old"]; ok {
+		t.Error("an expired token was stored")
+	}
+	if got, _ := s.IsRevoked("abc"); !got {
+		t.Error("abc isn't revoked")
+	}
+}
+
+// a fresh memory store for revocations and sessions, capped at max
+func useSessions(t *testing.T, max int) *MemoryStore {
+	t.Helper()
+
+	s := NewMemoryStore()
+	useStore(t, s)
+
+	oldSessions, oldMax := Sessions, MaxSessions
+	Sessions, MaxSessions = s, max
+	t.Cleanup(func() { Sessions, MaxSessions = oldSessions, oldMax })
+
+	return s
+}
+
+func TestOldestSessionRevokedPastTheLimit(t *testing.T) {
+	useSessions(t, 3)
+
+	var tokens []string
+	for i := 0; i < 4; i++ {
+		tokenStr, _, err := CreateToken(2, "user@example.com", "user", 0, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		tokens = append(tokens, tokenStr)
+	}
+	other, _, err := CreateToken(1, "admin@example.com", "admin", 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ValidateToken(tokens[0]); err == nil {
+		t.Error("the oldest session still validates")
+	}
+	for i, tokenStr := range append(tokens[1:], other) {
+		if _, err := ValidateToken(tokenStr); err != nil {
+			t.Errorf("token %d: %v", i+1, err)
+		}
+	}
+}
+
+func TestRevokeFreesASession(t *testing.T) {
+	s := useSessions(t, 2)
+
+	first, _, _ := CreateToken(2, "user@example.com", "user", 0, "")
+	second, _, _ := CreateToken(2, "user@example.com", "user", 0, "")
+
+	claims, err := ValidateToken(second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Revoke(claims); err != nil {
+		t.Fatal(err)
+	}
+	if active, _ := s.Active("user@example.com"); len(active) != 1 {
+		t.Fatalf("%d active sessions after a logout, want 1", len(active))
+	}
+
+	// the logged out slot is reused, the first session stays
+	CreateToken(2, "user@example.com", "user", 0, "")
+	if _, err := ValidateToken(first); err != nil {
+		t.Errorf("first session: %v", err)
+	}
+}
+
+func TestMemoryStoreActiveSessions(t *testing.T) {
+	s := NewMemoryStore()
+	now := time.Now()
+
+	s.Add("u", Session{ID: "expired", IssuedAt: now.Add(-2 * time.Hour), ExpiresAt: now.Add(-time.Hour)})
+	s.Add("u", Session{ID: "old", IssuedAt: now.Add(-time.Hour), ExpiresAt: now.Add(time.Hour)})
+	s.Add("u", Session{ID: "new", IssuedAt: now, ExpiresAt: now.Add(time.Hour)})
+
+	active, err := s.Active("u")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(active) != 2 || active[0].ID != "old" || active[1].ID != "new" {
+		t.Errorf("active = %+v, want old and new, oldest first", active)
+	}
+
+	s.Remove("u", "old")
+	if active, _ := s.Active("u"); len(active) != 1 || active[0].ID != "new" {
+		t.Errorf("active after Remove = %+v, want new", active)
+	}
+}
+
+func TestCollectGarbageSweepsOnTick(t *testing.T) {
+	s := useSessions(t, 0)
+	now := time.Now()
+	s.Revoke("expired", now.Add(-time.Second))
+	s.Revoke("live", now.Add(time.Hour))
+	s.Add("user@example.com", Session{ID: "expired", ExpiresAt: now.Add(-time.Second)})
+
+	// a separate session store gets swept as well
+	sessions := NewMemoryStore()
+	sessions.Add("user@example.com", Session{ID: "expired", ExpiresAt: now.Add(-time.Second)})
+	Sessions = sessions
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		CollectGarbage(ctx, time.Millisecond)
+		close(done)
+	}()
+
+	swept := func(m *MemoryStore) bool {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		_, revoked := m.revoked["expired"]
+		return !revoked && len(m.sessions) == 0
+	}
+	deadline := time.Now().Add(time.Second)
+	for !swept(s) || !swept(sessions) {
+		if time.Now().After(deadline) {
+			t.Fatal("expired entries are still there after a second")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if got, _ := s.IsRevoked("live"); !got {
+		t.Error("a live revocation was swept")
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("CollectGarbage didn't stop with its context")
+	}
+}
+
+func TestRedisStoreSessions(t *testing.T) {
+	client := newFakeRedis()
+	s := NewRedisStore(client, "tokens:")
+	now := time.Now()
+
+	s.Add("u", Session{ID: "expired", IssuedAt: now.Add(-2 * time.Hour), ExpiresAt: now.Add(-time.Hour)})
+	s.Add("u", Session{ID: "long", IssuedAt: now.Add(-time.Hour), ExpiresAt: now.Add(48 * time.Hour)})
+	s.Add("u", Session{ID: "new", IssuedAt: now, ExpiresAt: now.Add(time.Hour)})
+
+	// the set outlives its longest session only
+	if ttl := client.keys["tokens:sessions:u"]; ttl <= 47*time.Hour || ttl > 48*time.Hour {
+		t.Errorf("sessions expire in %v, want about 48h", ttl)
+	}
+
+	active, err := s.Active("u")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(active) != 2 || active[0].ID != "long" || active[1].ID != "new" {
+		t.Errorf("active = %+v, want long and new, oldest first", active)
+	}
+	if len(client.zsets["tokens:sessions:u"]) != 2 {
+		t.Errorf("the expired session is still stored: %v", client.zsets)
+	}
+
+	s.Remove("u", "long")
+	if active, _ := s.Active("u"); len(active) != 1 || active[0].ID != "new" {
+		t.Errorf("active after Remove = %+v, want new", active)
+	}
+}
+
+// the session limit holds across instances sharing redis
+func TestRedisStoreLimitsSessions(t *testing.T) {
+	s := NewRedisStore(newFakeRedis(), "tokens:")
+	useStore(t, s)
+
+	oldSessions, oldMax := Sessions, MaxSessions
+	Sessions, MaxSessions = s, 2
+	t.Cleanup(func() { Sessions, MaxSessions = oldSessions, oldMax })
+
+	var tokens []string
+	for i := 0; i < 3; i++ {
+		tokenStr, _, err := CreateToken(2, "user@example.com", "user", 0, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		tokens = append(tokens, tokenStr)
+	}
+
+	if _, err := ValidateToken(tokens[0]); err == nil {
+		t.Error("the oldest session still validates")
+	}
+	for i, tokenStr := range tokens[1:] {
+		if _, err := ValidateToken(tokenStr); err != nil {
+			t.Errorf("token %d: %v", i+1, err)
+		}
+	}
+	if active, _ := s.Active("user@example.com"); len(active) != 2 {
+		t.Errorf("%d active sessions, want 2", len(active))
+	}
+}
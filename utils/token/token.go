@@ -1,44 +1,117 @@
 package token
 
 import (
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
 )
 
+// current signing key, use RotateKey to replace it at runtime
 var JwtToken = []byte("jwtToken")
 
+// lifetime of regular tokens
+var TokenTTL = time.Hour * 12
+
+// lifetime of tokens issued for "remember me" logins
+var RememberTTL = time.Hour * 24 * 30
+
+const (
+	CookieName       = "access_token"
+	TokenType        = "Bearer"
+	ImpersonationTTL = time.Minute * 15
+)
+
 type Claims struct {
-	Email string `json:"email"`
-	Role  string `json:"role"`
+	// id of the user, unlike the email it's never reassigned
+	UserID         int64  `json:"uid"`
+	Email          string `json:"email"`
+	Role           string `json:"role"`
+	ImpersonatedBy string `json:"impersonated_by,omitempty"`
+	TokenVersion   int    `json:"token_version"`
+	// hash of the client the token was issued to, see Fingerprint
+	Fingerprint string `json:"fp,omitempty"`
 	jwt.StandardClaims
 }
 
-func CreateToken(email, role string) (string, error) {
-	expTime := time.Now().Add(time.Hour * 12)
+// returns the signed token and its expiry time. fingerprint binds the
+// token to a client, see Fingerprint, empty leaves it unbound
+func CreateToken(id int64, email, role string, version int, fingerprint string) (string, time.Time, error) {
+	return CreateTokenTTL(id, email, role, version, fingerprint, TokenTTL)
+}
 
+// like CreateToken with a custom lifetime
+func CreateTokenTTL(id int64, email, role string, version int, fingerprint string, ttl time.Duration) (string, time.Time, error) {
 	claims := &Claims{
-		Email: email,
-		Role:  role,
-		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: expTime.Unix(),
-		},
+		UserID:       id,
+		Email:        email,
+		Role:         role,
+		TokenVersion: version,
+		Fingerprint:  fingerprint,
+	}
+
+	return signToken(claims, ttl)
+}
+
+// short lived token for the target user, carrying the admin's email
+func CreateImpersonationToken(id int64, email, role string, version int, impersonatedBy, fingerprint string) (string, time.Time, error) {
+	claims := &Claims{
+		UserID:         id,
+		Email:          email,
+		Role:           role,
+		ImpersonatedBy: impersonatedBy,
+		TokenVersion:   version,
+		Fingerprint:    fingerprint,
+	}
+
+	return signToken(claims, ImpersonationTTL)
+}
+
+func signToken(claims *Claims, ttl time.Duration) (string, time.Time, error) {
+	now := time.Now()
+	expTime := now.Add(ttl)
+	claims.ExpiresAt = expTime.Unix()
+	claims.IssuedAt = now.Unix()
+	// unusable before it was issued
+	claims.NotBefore = now.Unix()
+
+	// lets single tokens be revoked
+	id, err := newTokenID()
+	if err != nil {
+		return "", time.Time{}, err
 	}
+	claims.Id = id
+
+	secret, kid := currentKey()
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenStr, err := token.SignedString(JwtToken)
+	token.Header["kid"] = kid
+	tokenStr, err := token.SignedString(secret)
 	if err != nil {
-		return "", err
+		return "", time.Time{}, err
 	}
 
-	return tokenStr, nil
+	if err := trackSession(claims); err != nil {
+		return "", time.Time{}, err
+	}
+
+	return tokenStr, expTime, nil
 }
 
 func ValidateToken(tokenStr string) (*Claims, error) {
 	jToken := func(token *jwt.Token) (interface{}, error) {
-		return JwtToken, nil
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+		}
+
+		kid, _ := token.Header["kid"].(string)
+
+		return verificationKey(kid)
 	}
 
+	// checks exp and nbf too, tokens issued before nbf was set have none
 	token, err := jwt.ParseWithClaims(tokenStr, &Claims{}, jToken)
 	if err != nil {
 		return nil, err
@@ -50,5 +123,29 @@ func ValidateToken(tokenStr string) (*Claims, error) {
 
 	claims := token.Claims.(*Claims)
 
+	// fail closed when the store can't be asked
+	if Store != nil && claims.Id != "" {
+		revoked, err := Store.IsRevoked(claims.Id)
+		if err != nil {
+			return nil, err
+		}
+		if revoked {
+			return nil, ErrRevoked
+		}
+	}
+
 	return claims, nil
 }
+
+var ErrAuthScheme = errors.New("authorization header must be Bearer <token>")
+
+// the token of an "Authorization: Bearer <token>" header value. the scheme
+// is matched case insensitively as RFC 7235 asks, anything else is rejected
+func FromHeader(header string) (string, error) {
+	scheme, tokenStr, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, TokenType) || tokenStr == "" || strings.ContainsAny(tokenStr, " \t") {
+		return "", ErrAuthScheme
+	}
+
+	return tokenStr, nil
+}
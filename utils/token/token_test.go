@@ -0,0 +1,61 @@
+package token
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// sign claims with the current key the way signToken does, without
+// touching their times
+func signRaw(t *testing.T, claims *Claims) string {
+	t.Helper()
+
+	secret, kid := currentKey()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token.Header["kid"] = kid
+	tokenStr, err := token.SignedString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tokenStr
+}
+
+func TestIssuedTokensAreValidFromNow(t *testing.T) {
+	before := time.Now().Unix()
+	tokenStr, _, err := CreateToken(2, "user@example.com", "user", 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims, err := ValidateToken(tokenStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.NotBefore < before || claims.NotBefore > time.Now().Unix() || claims.IssuedAt != claims.NotBefore {
+		t.Errorf("nbf %d iat %d, want both the issue time", claims.NotBefore, claims.IssuedAt)
+	}
+}
+
+func TestTokenRejectedBeforeNotBefore(t *testing.T) {
+	now := time.Now()
+
+	for _, tc := range []struct {
+		nbf   time.Time
+		valid bool
+	}{
+		{now.Add(time.Hour), false},
+		{now.Add(-time.Minute), true},
+	} {
+		claims := &Claims{Email: "user@example.com", Role: "user"}
+		claims.ExpiresAt = now.Add(2 * time.Hour).Unix()
+		claims.NotBefore = tc.nbf.Unix()
+
+		_, err := ValidateToken(signRaw(t, claims))
+		if valid := err == nil; valid != tc.valid {
+			t.Errorf("nbf %v: err = %v, want valid %v", tc.nbf, err, tc.valid)
+		}
+	}
+}
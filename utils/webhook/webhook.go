@@ -0,0 +1,95 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+)
+
+const (
+	SignatureHeader = "X-Webhook-Signature"
+	EventHeader     = "X-Webhook-Event"
+)
+
+// posts events to every url, signed with an HMAC-SHA256 of the body
+type Notifier struct {
+	urls    []string
+	secret  []byte
+	client  *http.Client
+	retries int
+	backoff time.Duration
+}
+
+func New(urls []string, secret string) *Notifier {
+	return &Notifier{
+		urls:    urls,
+		secret:  []byte(secret),
+		client:  &http.Client{Timeout: time.Second * 10},
+		retries: 3,
+		backoff: time.Second,
+	}
+}
+
+// signature header value for body, receivers recompute and compare it
+func Sign(secret []byte, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// deliver in the background, one goroutine per url
+func (n *Notifier) Notify(e entities.Event) {
+	if e.OccurredAt.IsZero() {
+		e.OccurredAt = entities.Timestamp{Time: time.Now()}
+	}
+
+	body, err := json.Marshal(e)
+	if err != nil {
+		log.Printf("webhook: encoding %s: %v", e.Type, err)
+		return
+	}
+
+	for _, url := range n.urls {
+		go n.deliver(url, e.Type, body)
+	}
+}
+
+// retry with exponential backoff until a 2xx or retries run out
+func (n *Notifier) deliver(url, event string, body []byte) {
+	wait := n.backoff
+	for attempt := 0; attempt <= n.retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(wait)
+			wait *= 2
+		}
+
+		req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			log.Printf("webhook: %s: %v", url, err)
+			return
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(EventHeader, event)
+		req.Header.Set(SignatureHeader, Sign(n.secret, body))
+
+		res, err := n.client.Do(req)
+		if err != nil {
+			continue
+		}
+		res.Body.Close()
+
+		if res.StatusCode >= 200 && res.StatusCode < 300 {
+			return
+		}
+	}
+
+	log.Printf("webhook: giving up on %s for %s", url, event)
+}
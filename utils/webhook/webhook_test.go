@@ -0,0 +1,96 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ariopri/Let-It-Be/tree/main/backend/entities"
+)
+
+type delivery struct {
+	event, signature string
+	body             []byte
+}
+
+// a receiver failing the first fail deliveries with 500
+func receiver(t *testing.T, fail int) (*httptest.Server, <-chan delivery) {
+	t.Helper()
+
+	var mu sync.Mutex
+	got := make(chan delivery, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		mu.Lock()
+		defer mu.Unlock()
+		if fail > 0 {
+			fail--
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		got <- delivery{r.Header.Get(EventHeader), r.Header.Get(SignatureHeader), body}
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv, got
+}
+
+func received(t *testing.T, got <-chan delivery) delivery {
+	t.Helper()
+
+	select {
+	case d := <-got:
+		return d
+	case <-time.After(5 * time.Second):
+		t.Fatal("nothing delivered")
+		return delivery{}
+	}
+}
+
+func TestNotifyPostsSignedEvents(t *testing.T) {
+	srv, got := receiver(t, 0)
+	n := New([]string{srv.URL}, "s3cret")
+
+	n.Notify(entities.Event{Type: entities.EventUserCreated, Data: map[string]int{"id": 7}})
+	d := received(t, got)
+
+	if d.event != entities.EventUserCreated {
+		t.Errorf("event header = %q, want %q", d.event, entities.EventUserCreated)
+	}
+	if want := Sign([]byte("s3cret"), d.body); d.signature != want {
+		t.Errorf("signature = %q, want %q", d.signature, want)
+	}
+	if d.signature == Sign([]byte("other"), d.body) {
+		t.Error("signature doesn't depend on the secret")
+	}
+
+	var e struct {
+		Type       string         `json:"type"`
+		Data       map[string]int `json:"data"`
+		OccurredAt string         `json:"occurred_at"`
+	}
+	if err := json.Unmarshal(d.body, &e); err != nil {
+		t.Fatal(err)
+	}
+	if e.Type != entities.EventUserCreated || e.Data["id"] != 7 || e.OccurredAt == "" {
+		t.Errorf("payload = %s", d.body)
+	}
+}
+
+func TestNotifyRetriesFailedDeliveries(t *testing.T) {
+	srv, got := receiver(t, 2)
+	n := New([]string{srv.URL}, "s3cret")
+	n.backoff = time.Millisecond
+
+	n.Notify(entities.Event{Type: entities.EventUserDeleted})
+
+	if d := received(t, got); d.event != entities.EventUserDeleted {
+		t.Errorf("event header = %q after retrying", d.event)
+	}
+}